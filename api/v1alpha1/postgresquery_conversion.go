@@ -0,0 +1,173 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"encoding/json"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/conversion"
+
+	"github.com/rsavage/KubeQuery/api/v1beta1"
+)
+
+// ConnectionRefAnnotation holds spec.connectionRef of a v1beta1 object while
+// it is read and written as v1alpha1, which has no equivalent field. The
+// reference is restored when converting back unless connection.host was set
+// in the meantime.
+const ConnectionRefAnnotation = "kubequery.cloudnexus.io/connection-ref"
+
+// ConvertTo converts this PostgresQuery to the v1beta1 hub version. Of the
+// SQL fields only the one that takes precedence is kept, as v1beta1 accepts
+// exactly one SQL source.
+func (src *PostgresQuery) ConvertTo(dstRaw conversion.Hub) error {
+	dst := dstRaw.(*v1beta1.PostgresQuery)
+	dst.ObjectMeta = *src.ObjectMeta.DeepCopy()
+
+	if ref := dst.Annotations[ConnectionRefAnnotation]; ref != "" && src.Spec.Connection.Host == "" {
+		dst.Spec.ConnectionRef = &v1beta1.ConnectionReference{Name: ref}
+	} else {
+		dst.Spec.Connection = &v1beta1.PostgresConnectionSpec{}
+		if err := convertIdentical(src.Spec.Connection, dst.Spec.Connection); err != nil {
+			return err
+		}
+	}
+	delete(dst.Annotations, ConnectionRefAnnotation)
+
+	switch {
+	case src.Spec.SQLSecretRef != nil:
+		dst.Spec.SQLSource.SecretKeyRef = &v1beta1.SecretKeySelector{
+			Name: src.Spec.SQLSecretRef.Name, Key: src.Spec.SQLSecretRef.Key,
+		}
+	case src.Spec.SQLConfigMapRef != nil:
+		dst.Spec.SQLSource.ConfigMapKeyRef = &v1beta1.ConfigMapKeySelector{
+			Name: src.Spec.SQLConfigMapRef.Name, Key: src.Spec.SQLConfigMapRef.Key,
+		}
+	default:
+		dst.Spec.SQLSource.Inline = src.Spec.SQL
+	}
+	if err := convertIdentical(src.Spec.Options, &dst.Spec.Options); err != nil {
+		return err
+	}
+	if err := convertIdentical(src.Spec.Job, &dst.Spec.Job); err != nil {
+		return err
+	}
+	dst.Spec.Cancel = src.Spec.Cancel
+	dst.Spec.ExecutionMode = v1beta1.ExecutionMode(src.Spec.ExecutionMode)
+	dst.Spec.RequireApproval = src.Spec.RequireApproval
+
+	dst.Status.Result = src.Status.Result
+	dst.Status.IdempotencyHash = src.Status.IdempotencyHash
+	dst.Status.ApprovalHash = src.Status.ApprovalHash
+	if err := convertIdentical(src.Status.Connection, &dst.Status.Connection); err != nil {
+		return err
+	}
+	dst.Status.BackendPID = src.Status.BackendPID
+	dst.Status.StartTime = src.Status.StartTime.DeepCopy()
+	dst.Status.CompletionTime = src.Status.CompletionTime.DeepCopy()
+	dst.Status.JobName = src.Status.JobName
+	dst.Status.Plan = src.Status.Plan
+
+	// Objects written before status.phase existed only record executed and
+	// error.
+	phase := v1beta1.QueryPhase(src.Status.Phase)
+	if phase == "" && src.Status.Executed {
+		phase = v1beta1.PhaseSucceeded
+	} else if phase == "" && src.Status.Error != "" {
+		phase = v1beta1.PhaseFailed
+	}
+	if phase != "" {
+		dst.SetPhase(phase, src.Status.Error, src.transitionTime())
+	}
+	return nil
+}
+
+// ConvertFrom converts from the v1beta1 hub version to this version.
+func (dst *PostgresQuery) ConvertFrom(srcRaw conversion.Hub) error {
+	src := srcRaw.(*v1beta1.PostgresQuery)
+	dst.ObjectMeta = *src.ObjectMeta.DeepCopy()
+
+	if src.Spec.ConnectionRef != nil {
+		if dst.Annotations == nil {
+			dst.Annotations = map[string]string{}
+		}
+		dst.Annotations[ConnectionRefAnnotation] = src.Spec.ConnectionRef.Name
+	} else if src.Spec.Connection != nil {
+		if err := convertIdentical(src.Spec.Connection, &dst.Spec.Connection); err != nil {
+			return err
+		}
+	}
+
+	dst.Spec.SQL = src.Spec.SQLSource.Inline
+	if ref := src.Spec.SQLSource.ConfigMapKeyRef; ref != nil {
+		dst.Spec.SQLConfigMapRef = &ConfigMapKeySelector{Name: ref.Name, Key: ref.Key}
+	}
+	if ref := src.Spec.SQLSource.SecretKeyRef; ref != nil {
+		dst.Spec.SQLSecretRef = &SecretKeySelector{Name: ref.Name, Key: ref.Key}
+	}
+	if err := convertIdentical(src.Spec.Options, &dst.Spec.Options); err != nil {
+		return err
+	}
+	if err := convertIdentical(src.Spec.Job, &dst.Spec.Job); err != nil {
+		return err
+	}
+	dst.Spec.Cancel = src.Spec.Cancel
+	dst.Spec.ExecutionMode = ExecutionMode(src.Spec.ExecutionMode)
+	dst.Spec.RequireApproval = src.Spec.RequireApproval
+
+	dst.Status.Phase = QueryPhase(src.Status.Phase)
+	dst.Status.Executed = src.Status.Phase == v1beta1.PhaseSucceeded
+	switch src.Status.Phase {
+	case v1beta1.PhaseFailed, v1beta1.PhaseCancelled, v1beta1.PhaseOrphaned:
+		dst.Status.Error = src.Message()
+	}
+	dst.Status.Result = src.Status.Result
+	dst.Status.IdempotencyHash = src.Status.IdempotencyHash
+	dst.Status.ApprovalHash = src.Status.ApprovalHash
+	if err := convertIdentical(src.Status.Connection, &dst.Status.Connection); err != nil {
+		return err
+	}
+	dst.Status.BackendPID = src.Status.BackendPID
+	dst.Status.StartTime = src.Status.StartTime.DeepCopy()
+	dst.Status.CompletionTime = src.Status.CompletionTime.DeepCopy()
+	dst.Status.JobName = src.Status.JobName
+	dst.Status.Plan = src.Status.Plan
+	return nil
+}
+
+// transitionTime is the time recorded on conditions derived from a v1alpha1
+// status: when the query last changed state, as far as status tells.
+func (pq *PostgresQuery) transitionTime() metav1.Time {
+	switch {
+	case pq.Status.CompletionTime != nil:
+		return *pq.Status.CompletionTime
+	case pq.Status.StartTime != nil:
+		return *pq.Status.StartTime
+	default:
+		return pq.CreationTimestamp
+	}
+}
+
+// convertIdentical copies in to out, the v1alpha1 and v1beta1 forms of a
+// type whose schema is the same in both versions.
+func convertIdentical(in, out any) error {
+	b, err := json.Marshal(in)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, out)
+}
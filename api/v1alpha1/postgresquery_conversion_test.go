@@ -0,0 +1,147 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+
+	"github.com/rsavage/KubeQuery/api/v1beta1"
+)
+
+func TestConvertRoundTrip(t *testing.T) {
+	started := metav1.NewTime(time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC))
+	src := &PostgresQuery{
+		ObjectMeta: metav1.ObjectMeta{Name: "q", Namespace: "ns", Annotations: map[string]string{"a": "b"}},
+		Spec: PostgresQuerySpec{
+			Connection: PostgresConnection{
+				Host:     "db",
+				Port:     5432,
+				Database: "app",
+				User:     "app",
+				Auth:     &PostgresAuth{Password: &PasswordAuth{SecretRef: SecretKeySelector{Name: "pw", Key: "password"}}},
+				SSL:      &PostgresSSL{Mode: "verify-full", CaSecretRef: &SecretKeySelector{Name: "ca", Key: "ca.crt"}},
+			},
+			SQLConfigMapRef: &ConfigMapKeySelector{Name: "sql", Key: "script.sql"},
+			Options: &QueryOptions{
+				TimeoutSeconds: ptr.To(60),
+				LockTimeout:    &metav1.Duration{Duration: time.Second},
+				Explain:        &ExplainOptions{Analyze: true},
+			},
+			ExecutionMode:   ExecutionModeJob,
+			RequireApproval: true,
+		},
+		Status: PostgresQueryStatus{
+			Phase:           PhaseFailed,
+			Error:           "sql exec error: boom",
+			IdempotencyHash: "abc",
+			ApprovalHash:    "def",
+			BackendPID:      42,
+			StartTime:       &started,
+			CompletionTime:  &started,
+			Plan:            `[{"Plan":{"Node Type":"Result"}}]`,
+		},
+	}
+
+	var hub v1beta1.PostgresQuery
+	if err := src.ConvertTo(&hub); err != nil {
+		t.Fatal(err)
+	}
+	if hub.Spec.SQLSource.ConfigMapKeyRef == nil || hub.Spec.SQLSource.Inline != "" {
+		t.Errorf("sqlSource = %+v", hub.Spec.SQLSource)
+	}
+	if hub.Spec.Connection == nil || hub.Spec.Connection.SSL.CaSecretRef.Name != "ca" {
+		t.Errorf("connection = %+v", hub.Spec.Connection)
+	}
+	if hub.Spec.Options.Explain == nil || !hub.Spec.Options.Explain.Analyze || hub.Status.Plan == "" {
+		t.Errorf("explain = %+v, plan = %q", hub.Spec.Options.Explain, hub.Status.Plan)
+	}
+	if hub.Status.Phase != v1beta1.PhaseFailed || hub.Message() != "sql exec error: boom" {
+		t.Errorf("status = %+v", hub.Status)
+	}
+
+	var dst PostgresQuery
+	if err := dst.ConvertFrom(&hub); err != nil {
+		t.Fatal(err)
+	}
+	if !equality.Semantic.DeepEqual(src, &dst) {
+		t.Errorf("round trip changed the object:\n got %+v\nwant %+v", dst, *src)
+	}
+}
+
+func TestConvertConnectionRef(t *testing.T) {
+	hub := &v1beta1.PostgresQuery{
+		ObjectMeta: metav1.ObjectMeta{Name: "q", Namespace: "ns"},
+		Spec: v1beta1.PostgresQuerySpec{
+			ConnectionRef: &v1beta1.ConnectionReference{Name: "orders"},
+			SQLSource:     v1beta1.SQLSource{Inline: "SELECT 1"},
+		},
+	}
+	var spoke PostgresQuery
+	if err := spoke.ConvertFrom(hub); err != nil {
+		t.Fatal(err)
+	}
+	if spoke.Annotations[ConnectionRefAnnotation] != "orders" || spoke.Spec.SQL != "SELECT 1" {
+		t.Fatalf("spoke = %+v", spoke)
+	}
+	// The schema accepts the empty connection, so the object can be
+	// written back as v1alpha1.
+	if c := spoke.Spec.Connection; c.Host != "" || c.Port != 0 || c.Database != "" || c.User != "" {
+		t.Errorf("spoke connection = %+v, want it empty", c)
+	}
+
+	var back v1beta1.PostgresQuery
+	if err := spoke.ConvertTo(&back); err != nil {
+		t.Fatal(err)
+	}
+	if back.Spec.ConnectionRef == nil || back.Spec.ConnectionRef.Name != "orders" || back.Spec.Connection != nil {
+		t.Errorf("connection was not restored: %+v", back.Spec)
+	}
+	if _, ok := back.Annotations[ConnectionRefAnnotation]; ok {
+		t.Errorf("annotation %s was not removed", ConnectionRefAnnotation)
+	}
+}
+
+func TestConvertLegacyStatus(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		status PostgresQueryStatus
+		want   v1beta1.QueryPhase
+	}{
+		{"executed", PostgresQueryStatus{Executed: true, IdempotencyHash: "abc"}, v1beta1.PhaseSucceeded},
+		{"failed", PostgresQueryStatus{Error: "db connect error"}, v1beta1.PhaseFailed},
+		{"new", PostgresQueryStatus{}, ""},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			src := &PostgresQuery{Spec: PostgresQuerySpec{SQL: "SELECT 1"}, Status: tc.status}
+			var hub v1beta1.PostgresQuery
+			if err := src.ConvertTo(&hub); err != nil {
+				t.Fatal(err)
+			}
+			if hub.Status.Phase != tc.want {
+				t.Errorf("phase = %q, want %q", hub.Status.Phase, tc.want)
+			}
+			if tc.want == "" && len(hub.Status.Conditions) != 0 {
+				t.Errorf("conditions = %+v", hub.Status.Conditions)
+			}
+		})
+	}
+}
@@ -17,78 +17,328 @@ limitations under the License.
 package v1alpha1
 
 import (
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
-// EDIT THIS FILE!  THIS IS SCAFFOLDING FOR YOU TO OWN!
-// NOTE: json tags are required.  Any new fields you add must have json tags for the fields to be serialized.
-
 // PostgresQuerySpec defines the desired state of PostgresQuery.
+// +kubebuilder:validation:XValidation:rule="[has(self.sql), has(self.sqlConfigMapRef), has(self.sqlSecretRef)].filter(x, x).size() == 1",message="exactly one of sql, sqlConfigMapRef or sqlSecretRef must be set"
 type PostgresQuerySpec struct {
 	// Connection contains the PostgreSQL connection configuration.
 	Connection PostgresConnection `json:"connection"`
 	// SQL is the SQL statement to execute against the target database.
 	// This should be a single statement or a transaction block.
-	// If sqlSecretRef or sqlConfigMapRef is set, this field is ignored.
+	// Exactly one of sql, sqlConfigMapRef or sqlSecretRef must be set.
 	SQL string `json:"sql,omitempty"`
 	// sqlConfigMapRef references a ConfigMap containing the SQL script (optional).
-	// If both sqlSecretRef and sqlConfigMapRef are set, sqlSecretRef takes precedence.
 	SQLConfigMapRef *ConfigMapKeySelector `json:"sqlConfigMapRef,omitempty"`
 	// sqlSecretRef references a Secret containing the SQL script (optional).
-	// If set, this takes precedence over sqlConfigMapRef and sql.
+	// Objects created before only one SQL source was allowed may set several;
+	// sqlSecretRef then takes precedence over sqlConfigMapRef and sql.
 	SQLSecretRef *SecretKeySelector `json:"sqlSecretRef,omitempty"`
 	// Options for query execution (e.g., timeout).
 	Options *QueryOptions `json:"options,omitempty"`
+	// Cancel stops the query. A running query is cancelled with
+	// pg_cancel_backend, escalating to pg_terminate_backend if it does not stop
+	// within the grace period; a query that has not started yet is never run.
+	// Setting the kubequery.cloudnexus.io/cancel annotation to "true" has the
+	// same effect.
+	Cancel bool `json:"cancel,omitempty"`
+	// ExecutionMode selects where the SQL runs: Controller (the default) runs
+	// it inside the manager pod; Job runs it in a batch/v1 Job in the
+	// PostgresQuery's namespace, so that namespace's network policies and
+	// resource limits apply.
+	ExecutionMode ExecutionMode `json:"executionMode,omitempty"`
+	// Job customises the runner Job when executionMode is Job (optional).
+	Job *JobOptions `json:"job,omitempty"`
+	// RequireApproval holds the query in the PendingApproval phase until the
+	// approved-by and approved-hash annotations are set for its current SQL,
+	// connection and execution settings, e.g. with `kubectl kubequery
+	// approve`. The approver needs the approve verb and cannot be the
+	// submitter. It cannot be unset once set.
+	RequireApproval bool `json:"requireApproval,omitempty"`
+}
+
+// ExecutionMode selects where a PostgresQuery is executed.
+// +kubebuilder:validation:Enum=Controller;Job
+type ExecutionMode string
+
+// Execution modes accepted in spec.executionMode.
+const (
+	ExecutionModeController ExecutionMode = "Controller"
+	ExecutionModeJob        ExecutionMode = "Job"
+)
+
+// JobOptions customises the runner Job created for executionMode Job.
+type JobOptions struct {
+	// Resources sets the runner container's resource requests and limits.
+	Resources *corev1.ResourceRequirements `json:"resources,omitempty"`
+	// ServiceAccountName runs the runner pod as this ServiceAccount. Its
+	// token is not mounted. Setting it requires the use verb on the
+	// ServiceAccount.
+	ServiceAccountName string `json:"serviceAccountName,omitempty"`
+	// PodLabels are added to the runner pod, e.g. to select it in a
+	// NetworkPolicy.
+	PodLabels map[string]string `json:"podLabels,omitempty"`
 }
 
-// PostgresConnection defines how to connect to the PostgreSQL database.
+// PostgresConnection defines how to connect to the database.
+// +kubebuilder:validation:XValidation:rule="!has(self.engine) || self.engine == 'postgres' || !has(self.targetSessionAttrs) || self.targetSessionAttrs == 'any'",message="targetSessionAttrs is only supported by the postgres engine"
 type PostgresConnection struct {
-	// Host is the hostname or IP address of the PostgreSQL server.
+	// Engine is the database engine: postgres (the default) or mysql, which
+	// also covers MariaDB.
+	// +kubebuilder:validation:Enum=postgres;mysql
+	// +optional
+	Engine string `json:"engine,omitempty"`
+	// Host is the hostname or IP address of the PostgreSQL server. Host,
+	// port, database and user are empty for queries that use a
+	// PostgresConnection through v1beta1's spec.connectionRef.
+	// +kubebuilder:validation:MaxLength=253
 	Host string `json:"host"`
 	// Port is the port number of the PostgreSQL server.
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:validation:Maximum=65535
 	Port int `json:"port"`
+	// Hosts lists additional servers tried in order after host, for example
+	// the members of a highly-available cluster (optional).
+	// +kubebuilder:validation:MaxItems=16
+	Hosts []PostgresHost `json:"hosts,omitempty"`
+	// TargetSessionAttrs selects which server is accepted when several hosts
+	// are configured: any, read-write, read-only, primary, standby or
+	// prefer-standby. Use read-write or primary to follow the primary after a
+	// failover. Defaults to any.
+	// +kubebuilder:validation:Enum=any;read-write;read-only;primary;standby;prefer-standby
+	TargetSessionAttrs string `json:"targetSessionAttrs,omitempty"`
+	// RuntimeParams are session parameters sent when connecting, such as
+	// application_name, search_path or statement_timeout (optional).
+	// application_name defaults to "kubequery".
+	// +kubebuilder:validation:MaxProperties=64
+	RuntimeParams map[string]string `json:"runtimeParams,omitempty"`
 	// Database is the name of the target database.
+	// +kubebuilder:validation:MaxLength=63
 	Database string `json:"database"`
 	// User is the username for authentication.
+	// +kubebuilder:validation:MaxLength=63
 	User string `json:"user"`
 	// PasswordSecretRef references a Kubernetes Secret for the database password.
-	PasswordSecretRef SecretKeySelector `json:"passwordSecretRef"`
+	// It is ignored if auth is set; new manifests should prefer auth.password.
+	PasswordSecretRef *SecretKeySelector `json:"passwordSecretRef,omitempty"`
+	// Auth selects how credentials are obtained for each new connection (optional).
+	// If unset, passwordSecretRef is used.
+	Auth *PostgresAuth `json:"auth,omitempty"`
 	// SSL contains SSL/TLS configuration for the connection.
 	SSL *PostgresSSL `json:"ssl,omitempty"`
 }
 
+// PostgresHost is the address of an additional PostgreSQL server.
+type PostgresHost struct {
+	// Host is the hostname or IP address of the server.
+	// +kubebuilder:validation:MinLength=1
+	// +kubebuilder:validation:MaxLength=253
+	Host string `json:"host"`
+	// Port is the port number of the server.
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=65535
+	Port int `json:"port"`
+}
+
+// PostgresAuth defines how the controller authenticates to the database.
+// Exactly one method should be set.
+// +kubebuilder:validation:XValidation:rule="[has(self.password), has(self.token), has(self.exec)].filter(x, x).size() == 1",message="exactly one of password, token or exec must be set"
+type PostgresAuth struct {
+	// Password authenticates with a static password stored in a Secret.
+	Password *PasswordAuth `json:"password,omitempty"`
+	// Token authenticates with a short-lived token, such as a cloud IAM token.
+	Token *TokenAuth `json:"token,omitempty"`
+	// Exec authenticates with a token printed by an external command.
+	Exec *ExecAuth `json:"exec,omitempty"`
+}
+
+// PasswordAuth reads a static password from a Secret.
+type PasswordAuth struct {
+	// SecretRef references the Secret key holding the password.
+	SecretRef SecretKeySelector `json:"secretRef"`
+}
+
+// TokenAuth reads a token that is sent as the password. The token is re-read
+// for every new connection, so rotated tokens are picked up automatically.
+// Exactly one of secretRef or path should be set.
+// +kubebuilder:validation:XValidation:rule="has(self.secretRef) != has(self.path)",message="exactly one of secretRef or path must be set"
+type TokenAuth struct {
+	// SecretRef references a Secret key holding the token.
+	SecretRef *SecretKeySelector `json:"secretRef,omitempty"`
+	// Path is a file in the controller pod holding the token, such as a
+	// projected ServiceAccount token. Requires the manager to run with
+	// --enable-credential-plugins.
+	Path string `json:"path,omitempty"`
+}
+
+// ExecAuth runs a command in the controller pod whose output is used as the
+// password. The command may print the token as plain text, or a JSON object
+// of the form {"token": "...", "expirationTimestamp": "<RFC 3339>"} to allow
+// the token to be cached until it expires. Requires the manager to run with
+// --enable-credential-plugins.
+type ExecAuth struct {
+	// Command is the executable to run.
+	// +kubebuilder:validation:MinLength=1
+	Command string `json:"command"`
+	// Args are passed to the command.
+	// +kubebuilder:validation:MaxItems=64
+	Args []string `json:"args,omitempty"`
+	// Env sets additional environment variables for the command.
+	// +kubebuilder:validation:MaxItems=64
+	Env []ExecEnvVar `json:"env,omitempty"`
+}
+
+// ExecEnvVar is an environment variable passed to an exec credential plugin.
+type ExecEnvVar struct {
+	// Name of the environment variable.
+	// +kubebuilder:validation:MinLength=1
+	Name string `json:"name"`
+	// Value of the environment variable.
+	Value string `json:"value"`
+}
+
 // PostgresSSL defines SSL/TLS settings for PostgreSQL connections.
 type PostgresSSL struct {
-	// Mode is the SSL mode (disable, require, verify-ca, verify-full).
+	// Mode is the SSL mode (disable, allow, prefer, require, verify-ca, verify-full).
+	// The modes follow libpq semantics: allow and prefer fall back between
+	// plaintext and TLS, require only encrypts (unless a CA is given, in which
+	// case it behaves like verify-ca), verify-ca checks the certificate chain
+	// and verify-full also checks the host name.
+	// +kubebuilder:validation:Enum=disable;allow;prefer;require;verify-ca;verify-full
 	Mode string `json:"mode"`
 	// CaSecretRef references a Kubernetes Secret for the CA certificate (optional).
 	CaSecretRef *SecretKeySelector `json:"caSecretRef,omitempty"`
+	// ClientCertSecretRef references a kubernetes.io/tls Secret whose tls.crt
+	// and tls.key are presented to the server as a client certificate (optional).
+	ClientCertSecretRef *SecretReference `json:"clientCertSecretRef,omitempty"`
+	// ServerName overrides the host name used for SNI and for certificate
+	// verification in verify-full mode (optional).
+	ServerName string `json:"serverName,omitempty"`
+	// CRLSecretRef references a Kubernetes Secret for a certificate revocation
+	// list checked in verify-ca and verify-full modes (optional).
+	CRLSecretRef *SecretKeySelector `json:"crlSecretRef,omitempty"`
+}
+
+// SecretReference refers to a Secret in the same namespace.
+type SecretReference struct {
+	// Name of the secret.
+	// +kubebuilder:validation:MinLength=1
+	Name string `json:"name"`
 }
 
 // SecretKeySelector selects a key of a Secret.
 type SecretKeySelector struct {
 	// Name of the secret.
+	// +kubebuilder:validation:MinLength=1
 	Name string `json:"name"`
 	// Key within the secret.
+	// +kubebuilder:validation:MinLength=1
 	Key string `json:"key"`
 }
 
 // ConfigMapKeySelector selects a key of a ConfigMap.
 type ConfigMapKeySelector struct {
 	// Name of the ConfigMap.
+	// +kubebuilder:validation:MinLength=1
 	Name string `json:"name"`
 	// Key within the ConfigMap.
+	// +kubebuilder:validation:MinLength=1
 	Key string `json:"key"`
 }
 
 // QueryOptions defines optional execution parameters.
 type QueryOptions struct {
 	// TimeoutSeconds is the query execution timeout in seconds.
+	// +kubebuilder:validation:Minimum=1
 	TimeoutSeconds *int `json:"timeoutSeconds,omitempty"`
+	// LockTimeout aborts any statement that waits longer than this for a lock,
+	// so that a blocked DDL statement cannot queue other traffic behind it.
+	// Sent to the server as the lock_timeout session parameter; for MySQL,
+	// rounded up to seconds and sent as innodb_lock_wait_timeout and
+	// lock_wait_timeout (optional).
+	LockTimeout *metav1.Duration `json:"lockTimeout,omitempty"`
+	// StatementTimeout aborts any statement that runs longer than this on the
+	// server. Sent as the statement_timeout session parameter; for MySQL, as
+	// max_execution_time, which only applies to SELECT (optional).
+	StatementTimeout *metav1.Duration `json:"statementTimeout,omitempty"`
+	// IdleInTransactionSessionTimeout terminates the session if it stays idle
+	// inside an open transaction longer than this. Sent as the
+	// idle_in_transaction_session_timeout session parameter. Not supported
+	// for MySQL (optional).
+	IdleInTransactionSessionTimeout *metav1.Duration `json:"idleInTransactionSessionTimeout,omitempty"`
+	// RetryOnLockTimeout re-runs the SQL when it fails because lockTimeout
+	// expired (optional). The failed attempt is rolled back by the server, so
+	// this is safe for a single statement or a script without explicit
+	// COMMITs; retries stop when timeoutSeconds is reached.
+	RetryOnLockTimeout *LockTimeoutRetry `json:"retryOnLockTimeout,omitempty"`
+	// RedactSQLLiterals replaces single-quoted SQL literals in errors, logs
+	// and status messages, in addition to credentials which are always redacted.
+	RedactSQLLiterals bool `json:"redactSQLLiterals,omitempty"`
+	// Explain runs EXPLAIN on the SQL instead of executing it and records the
+	// plan in status.plan (optional). Not supported for MySQL or with
+	// executionMode Job.
+	Explain *ExplainOptions `json:"explain,omitempty"`
+}
+
+// ExplainOptions configures the EXPLAIN run in place of a query.
+type ExplainOptions struct {
+	// Analyze runs the statement to report actual times and row counts, in a
+	// transaction that is always rolled back so that DML changes nothing.
+	Analyze bool `json:"analyze,omitempty"`
+	// Buffers reports buffer usage, which without analyze covers planning
+	// only.
+	Buffers bool `json:"buffers,omitempty"`
 }
 
+// LockTimeoutRetry configures retries after a lock_timeout error.
+type LockTimeoutRetry struct {
+	// MaxAttempts is the total number of attempts, including the first. Defaults to 3.
+	// +kubebuilder:validation:Minimum=1
+	MaxAttempts *int `json:"maxAttempts,omitempty"`
+	// Backoff is the wait before the first retry; it doubles after each
+	// further attempt. Defaults to 5s.
+	Backoff *metav1.Duration `json:"backoff,omitempty"`
+}
+
+// CancelAnnotation requests cancellation of a PostgresQuery when set to "true".
+const CancelAnnotation = "kubequery.cloudnexus.io/cancel"
+
+// Annotations recording approval of a query that sets spec.requireApproval.
+// ApprovedHashAnnotation must match status.approvalHash, so an approval does
+// not carry over to changed SQL, connection or execution settings.
+const (
+	ApprovedByAnnotation   = "kubequery.cloudnexus.io/approved-by"
+	ApprovedHashAnnotation = "kubequery.cloudnexus.io/approved-hash"
+)
+
+// SubmittedByAnnotation records the user who created a query. It is set by
+// the admission webhook and may not be changed; a query cannot be approved
+// by the user who submitted it.
+const SubmittedByAnnotation = "kubequery.cloudnexus.io/submitted-by"
+
+// QueryPhase summarises the execution state of a PostgresQuery.
+type QueryPhase string
+
+// Execution phases reported in status.phase.
+const (
+	// PhasePendingApproval means the query waits for approval before it runs.
+	PhasePendingApproval QueryPhase = "PendingApproval"
+	PhaseRunning         QueryPhase = "Running"
+	PhaseSucceeded       QueryPhase = "Succeeded"
+	PhaseFailed          QueryPhase = "Failed"
+	PhaseCancelled       QueryPhase = "Cancelled"
+	// PhaseOrphaned means the query was running when the manager that started
+	// it stopped, so its outcome was not observed. It is never re-run.
+	PhaseOrphaned QueryPhase = "Orphaned"
+)
+
 // PostgresQueryStatus defines the observed state of PostgresQuery.
 type PostgresQueryStatus struct {
+	// Phase is a summary of the execution state: PendingApproval, Running,
+	// Succeeded, Failed, Cancelled or Orphaned.
+	Phase QueryPhase `json:"phase,omitempty"`
 	// Executed indicates if the query was executed successfully.
 	Executed bool `json:"executed"`
 	// Error contains any error message from execution.
@@ -97,12 +347,43 @@ type PostgresQueryStatus struct {
 	Result string `json:"result,omitempty"`
 	// IdempotencyHash is a hash of the SQL and connection info to prevent re-execution.
 	IdempotencyHash string `json:"idempotencyHash,omitempty"`
+	// ApprovalHash is the value the approved-hash annotation must have for a
+	// query that requires approval to run. It extends idempotencyHash with
+	// the connection's settings, options, executionMode and job.
+	ApprovalHash string `json:"approvalHash,omitempty"`
+	// Connection reports observed properties of the database connection.
+	Connection *ConnectionStatus `json:"connection,omitempty"`
+	// BackendPID is the PostgreSQL backend process ID that ran the query.
+	BackendPID int32 `json:"backendPID,omitempty"`
+	// StartTime is when execution started.
+	StartTime *metav1.Time `json:"startTime,omitempty"`
+	// CompletionTime is when execution finished, failed or was cancelled.
+	CompletionTime *metav1.Time `json:"completionTime,omitempty"`
+	// JobName is the runner Job executing the query in executionMode Job.
+	JobName string `json:"jobName,omitempty"`
+	// Plan is the plan recorded by EXPLAIN in spec.options.explain mode, as
+	// compact JSON.
+	Plan string `json:"plan,omitempty"`
+}
+
+// ConnectionStatus reports observed properties of the database connection.
+type ConnectionStatus struct {
+	// CANotAfter is the earliest expiry time of the certificates in the
+	// configured CA bundle.
+	CANotAfter *metav1.Time `json:"caNotAfter,omitempty"`
 }
 
+// +genclient
 // +kubebuilder:object:root=true
 // +kubebuilder:subresource:status
+// +kubebuilder:validation:XValidation:rule="!has(oldSelf.status) || !has(oldSelf.status.executed) || !oldSelf.status.executed || self.spec.connection == oldSelf.spec.connection",message="connection is immutable once the query has been executed"
+// +kubebuilder:deprecatedversion:warning="kubequery.cloudnexus.io/v1alpha1 PostgresQuery is deprecated; use v1beta1"
+// +kubebuilder:printcolumn:name="Phase",type=string,JSONPath=`.status.phase`
+// +kubebuilder:printcolumn:name="Result",type=string,JSONPath=`.status.result`
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
 
-// PostgresQuery is the Schema for the postgresqueries API.
+// PostgresQuery is the Schema for the postgresqueries API. It is served by
+// converting to and from v1beta1, the storage version.
 type PostgresQuery struct {
 	metav1.TypeMeta   `json:",inline"`
 	metav1.ObjectMeta `json:"metadata,omitempty"`
@@ -120,6 +401,24 @@ type PostgresQueryList struct {
 	Items           []PostgresQuery `json:"items"`
 }
 
+// CancelRequested reports whether the user asked for the query to be cancelled,
+// either with spec.cancel or the cancel annotation.
+func (pq *PostgresQuery) CancelRequested() bool {
+	return pq.Spec.Cancel || pq.Annotations[CancelAnnotation] == "true"
+}
+
+// Approved reports whether the query may run. Queries that do not require
+// approval are always approved; others need approval for hash, the current
+// approval hash, by someone other than the submitter.
+func (pq *PostgresQuery) Approved(hash string) bool {
+	if !pq.Spec.RequireApproval {
+		return true
+	}
+	approvedBy := pq.Annotations[ApprovedByAnnotation]
+	return approvedBy != "" && approvedBy != pq.Annotations[SubmittedByAnnotation] &&
+		pq.Annotations[ApprovedHashAnnotation] == hash
+}
+
 func init() {
 	SchemeBuilder.Register(&PostgresQuery{}, &PostgresQueryList{})
 }
@@ -0,0 +1,191 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// PostgresConnectionSpec defines how to connect to a PostgreSQL or MySQL database.
+// +kubebuilder:validation:XValidation:rule="!has(self.engine) || self.engine == 'postgres' || !has(self.targetSessionAttrs) || self.targetSessionAttrs == 'any'",message="targetSessionAttrs is only supported by the postgres engine"
+type PostgresConnectionSpec struct {
+	// Engine is the database engine: postgres (the default) or mysql, which
+	// also covers MariaDB.
+	// +kubebuilder:validation:Enum=postgres;mysql
+	// +optional
+	Engine string `json:"engine,omitempty"`
+	// Host is the hostname or IP address of the PostgreSQL server.
+	// +kubebuilder:validation:MinLength=1
+	// +kubebuilder:validation:MaxLength=253
+	Host string `json:"host"`
+	// Port is the port number of the PostgreSQL server.
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=65535
+	Port int `json:"port"`
+	// Hosts lists additional servers tried in order after host, for example
+	// the members of a highly-available cluster (optional).
+	// +kubebuilder:validation:MaxItems=16
+	Hosts []PostgresHost `json:"hosts,omitempty"`
+	// TargetSessionAttrs selects which server is accepted when several hosts
+	// are configured: any, read-write, read-only, primary, standby or
+	// prefer-standby. Use read-write or primary to follow the primary after a
+	// failover. Defaults to any.
+	// +kubebuilder:validation:Enum=any;read-write;read-only;primary;standby;prefer-standby
+	TargetSessionAttrs string `json:"targetSessionAttrs,omitempty"`
+	// RuntimeParams are session parameters sent when connecting, such as
+	// application_name, search_path or statement_timeout (optional).
+	// application_name defaults to "kubequery".
+	// +kubebuilder:validation:MaxProperties=64
+	RuntimeParams map[string]string `json:"runtimeParams,omitempty"`
+	// Database is the name of the target database.
+	// +kubebuilder:validation:MinLength=1
+	// +kubebuilder:validation:MaxLength=63
+	Database string `json:"database"`
+	// User is the username for authentication.
+	// +kubebuilder:validation:MinLength=1
+	// +kubebuilder:validation:MaxLength=63
+	User string `json:"user"`
+	// PasswordSecretRef references a Kubernetes Secret for the database password.
+	// It is ignored if auth is set; new manifests should prefer auth.password.
+	PasswordSecretRef *SecretKeySelector `json:"passwordSecretRef,omitempty"`
+	// Auth selects how credentials are obtained for each new connection (optional).
+	// If unset, passwordSecretRef is used.
+	Auth *PostgresAuth `json:"auth,omitempty"`
+	// SSL contains SSL/TLS configuration for the connection.
+	SSL *PostgresSSL `json:"ssl,omitempty"`
+}
+
+// PostgresHost is the address of an additional PostgreSQL server.
+type PostgresHost struct {
+	// Host is the hostname or IP address of the server.
+	// +kubebuilder:validation:MinLength=1
+	// +kubebuilder:validation:MaxLength=253
+	Host string `json:"host"`
+	// Port is the port number of the server.
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=65535
+	Port int `json:"port"`
+}
+
+// PostgresAuth defines how the controller authenticates to the database.
+// Exactly one method should be set.
+// +kubebuilder:validation:XValidation:rule="[has(self.password), has(self.token), has(self.exec)].filter(x, x).size() == 1",message="exactly one of password, token or exec must be set"
+type PostgresAuth struct {
+	// Password authenticates with a static password stored in a Secret.
+	Password *PasswordAuth `json:"password,omitempty"`
+	// Token authenticates with a short-lived token, such as a cloud IAM token.
+	Token *TokenAuth `json:"token,omitempty"`
+	// Exec authenticates with a token printed by an external command.
+	Exec *ExecAuth `json:"exec,omitempty"`
+}
+
+// PasswordAuth reads a static password from a Secret.
+type PasswordAuth struct {
+	// SecretRef references the Secret key holding the password.
+	SecretRef SecretKeySelector `json:"secretRef"`
+}
+
+// TokenAuth reads a token that is sent as the password. The token is re-read
+// for every new connection, so rotated tokens are picked up automatically.
+// Exactly one of secretRef or path should be set.
+// +kubebuilder:validation:XValidation:rule="has(self.secretRef) != has(self.path)",message="exactly one of secretRef or path must be set"
+type TokenAuth struct {
+	// SecretRef references a Secret key holding the token.
+	SecretRef *SecretKeySelector `json:"secretRef,omitempty"`
+	// Path is a file in the controller pod holding the token, such as a
+	// projected ServiceAccount token. Requires the manager to run with
+	// --enable-credential-plugins.
+	Path string `json:"path,omitempty"`
+}
+
+// ExecAuth runs a command in the controller pod whose output is used as the
+// password. The command may print the token as plain text, or a JSON object
+// of the form {"token": "...", "expirationTimestamp": "<RFC 3339>"} to allow
+// the token to be cached until it expires. Requires the manager to run with
+// --enable-credential-plugins.
+type ExecAuth struct {
+	// Command is the executable to run.
+	// +kubebuilder:validation:MinLength=1
+	Command string `json:"command"`
+	// Args are passed to the command.
+	// +kubebuilder:validation:MaxItems=64
+	Args []string `json:"args,omitempty"`
+	// Env sets additional environment variables for the command.
+	// +kubebuilder:validation:MaxItems=64
+	Env []ExecEnvVar `json:"env,omitempty"`
+}
+
+// ExecEnvVar is an environment variable passed to an exec credential plugin.
+type ExecEnvVar struct {
+	// Name of the environment variable.
+	// +kubebuilder:validation:MinLength=1
+	Name string `json:"name"`
+	// Value of the environment variable.
+	Value string `json:"value"`
+}
+
+// PostgresSSL defines SSL/TLS settings for PostgreSQL connections.
+type PostgresSSL struct {
+	// Mode is the SSL mode (disable, allow, prefer, require, verify-ca, verify-full).
+	// The modes follow libpq semantics: allow and prefer fall back between
+	// plaintext and TLS, require only encrypts (unless a CA is given, in which
+	// case it behaves like verify-ca), verify-ca checks the certificate chain
+	// and verify-full also checks the host name.
+	// +kubebuilder:validation:Enum=disable;allow;prefer;require;verify-ca;verify-full
+	Mode string `json:"mode"`
+	// CaSecretRef references a Kubernetes Secret for the CA certificate (optional).
+	CaSecretRef *SecretKeySelector `json:"caSecretRef,omitempty"`
+	// ClientCertSecretRef references a kubernetes.io/tls Secret whose tls.crt
+	// and tls.key are presented to the server as a client certificate (optional).
+	ClientCertSecretRef *SecretReference `json:"clientCertSecretRef,omitempty"`
+	// ServerName overrides the host name used for SNI and for certificate
+	// verification in verify-full mode (optional).
+	ServerName string `json:"serverName,omitempty"`
+	// CRLSecretRef references a Kubernetes Secret for a certificate revocation
+	// list checked in verify-ca and verify-full modes (optional).
+	CRLSecretRef *SecretKeySelector `json:"crlSecretRef,omitempty"`
+}
+
+// +genclient
+// +kubebuilder:object:root=true
+// +kubebuilder:storageversion
+// +kubebuilder:printcolumn:name="Host",type=string,JSONPath=`.spec.host`
+// +kubebuilder:printcolumn:name="Database",type=string,JSONPath=`.spec.database`
+// +kubebuilder:printcolumn:name="User",type=string,JSONPath=`.spec.user`
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+
+// PostgresConnection is a reusable database connection that PostgresQuery
+// objects in the same namespace refer to with spec.connectionRef.
+type PostgresConnection struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec PostgresConnectionSpec `json:"spec,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// PostgresConnectionList contains a list of PostgresConnection.
+type PostgresConnectionList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []PostgresConnection `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&PostgresConnection{}, &PostgresConnectionList{})
+}
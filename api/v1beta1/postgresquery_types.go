@@ -0,0 +1,370 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// PostgresQuerySpec defines the desired state of PostgresQuery.
+// +kubebuilder:validation:XValidation:rule="has(self.connection) != has(self.connectionRef)",message="exactly one of connection or connectionRef must be set"
+type PostgresQuerySpec struct {
+	// Connection is the PostgreSQL connection configuration. Exactly one of
+	// connection or connectionRef must be set.
+	Connection *PostgresConnectionSpec `json:"connection,omitempty"`
+	// ConnectionRef names a PostgresConnection in the same namespace whose
+	// spec is used as the connection configuration.
+	ConnectionRef *ConnectionReference `json:"connectionRef,omitempty"`
+	// SQLSource is where the SQL to execute comes from. Exactly one source
+	// must be set.
+	SQLSource SQLSource `json:"sqlSource"`
+	// Options for query execution (e.g., timeout).
+	Options *QueryOptions `json:"options,omitempty"`
+	// Cancel stops the query. A running query is cancelled with
+	// pg_cancel_backend, escalating to pg_terminate_backend if it does not stop
+	// within the grace period; a query that has not started yet is never run.
+	// Setting the kubequery.cloudnexus.io/cancel annotation to "true" has the
+	// same effect.
+	Cancel bool `json:"cancel,omitempty"`
+	// ExecutionMode selects where the SQL runs: Controller (the default) runs
+	// it inside the manager pod; Job runs it in a batch/v1 Job in the
+	// PostgresQuery's namespace, so that namespace's network policies and
+	// resource limits apply.
+	ExecutionMode ExecutionMode `json:"executionMode,omitempty"`
+	// Job customises the runner Job when executionMode is Job (optional).
+	Job *JobOptions `json:"job,omitempty"`
+	// RequireApproval holds the query in the PendingApproval phase until the
+	// approved-by and approved-hash annotations are set for its current SQL,
+	// connection and execution settings, e.g. with `kubectl kubequery
+	// approve`. The approver needs the approve verb and cannot be the
+	// submitter. It cannot be unset once set.
+	RequireApproval bool `json:"requireApproval,omitempty"`
+}
+
+// ConnectionReference names a PostgresConnection in the same namespace.
+type ConnectionReference struct {
+	// Name of the PostgresConnection.
+	// +kubebuilder:validation:MinLength=1
+	Name string `json:"name"`
+}
+
+// SQLSource selects where the SQL of a PostgresQuery is read from. It is a
+// union: exactly one field must be set.
+// +kubebuilder:validation:XValidation:rule="[has(self.inline), has(self.configMapKeyRef), has(self.secretKeyRef)].filter(x, x).size() == 1",message="exactly one of inline, configMapKeyRef or secretKeyRef must be set"
+type SQLSource struct {
+	// Inline is the SQL itself. This should be a single statement or a
+	// transaction block.
+	// +kubebuilder:validation:MinLength=1
+	Inline string `json:"inline,omitempty"`
+	// ConfigMapKeyRef reads the SQL from a ConfigMap key.
+	ConfigMapKeyRef *ConfigMapKeySelector `json:"configMapKeyRef,omitempty"`
+	// SecretKeyRef reads the SQL from a Secret key.
+	SecretKeyRef *SecretKeySelector `json:"secretKeyRef,omitempty"`
+}
+
+// ExecutionMode selects where a PostgresQuery is executed.
+// +kubebuilder:validation:Enum=Controller;Job
+type ExecutionMode string
+
+// Execution modes accepted in spec.executionMode.
+const (
+	ExecutionModeController ExecutionMode = "Controller"
+	ExecutionModeJob        ExecutionMode = "Job"
+)
+
+// JobOptions customises the runner Job created for executionMode Job.
+type JobOptions struct {
+	// Resources sets the runner container's resource requests and limits.
+	Resources *corev1.ResourceRequirements `json:"resources,omitempty"`
+	// ServiceAccountName runs the runner pod as this ServiceAccount. Its
+	// token is not mounted. Setting it requires the use verb on the
+	// ServiceAccount.
+	ServiceAccountName string `json:"serviceAccountName,omitempty"`
+	// PodLabels are added to the runner pod, e.g. to select it in a
+	// NetworkPolicy.
+	PodLabels map[string]string `json:"podLabels,omitempty"`
+}
+
+// SecretReference refers to a Secret in the same namespace.
+type SecretReference struct {
+	// Name of the secret.
+	// +kubebuilder:validation:MinLength=1
+	Name string `json:"name"`
+}
+
+// SecretKeySelector selects a key of a Secret.
+type SecretKeySelector struct {
+	// Name of the secret.
+	// +kubebuilder:validation:MinLength=1
+	Name string `json:"name"`
+	// Key within the secret.
+	// +kubebuilder:validation:MinLength=1
+	Key string `json:"key"`
+}
+
+// ConfigMapKeySelector selects a key of a ConfigMap.
+type ConfigMapKeySelector struct {
+	// Name of the ConfigMap.
+	// +kubebuilder:validation:MinLength=1
+	Name string `json:"name"`
+	// Key within the ConfigMap.
+	// +kubebuilder:validation:MinLength=1
+	Key string `json:"key"`
+}
+
+// QueryOptions defines optional execution parameters.
+type QueryOptions struct {
+	// TimeoutSeconds is the query execution timeout in seconds.
+	// +kubebuilder:validation:Minimum=1
+	TimeoutSeconds *int `json:"timeoutSeconds,omitempty"`
+	// LockTimeout aborts any statement that waits longer than this for a lock,
+	// so that a blocked DDL statement cannot queue other traffic behind it.
+	// Sent to the server as the lock_timeout session parameter; for MySQL,
+	// rounded up to seconds and sent as innodb_lock_wait_timeout and
+	// lock_wait_timeout (optional).
+	LockTimeout *metav1.Duration `json:"lockTimeout,omitempty"`
+	// StatementTimeout aborts any statement that runs longer than this on the
+	// server. Sent as the statement_timeout session parameter; for MySQL, as
+	// max_execution_time, which only applies to SELECT (optional).
+	StatementTimeout *metav1.Duration `json:"statementTimeout,omitempty"`
+	// IdleInTransactionSessionTimeout terminates the session if it stays idle
+	// inside an open transaction longer than this. Sent as the
+	// idle_in_transaction_session_timeout session parameter. Not supported
+	// for MySQL (optional).
+	IdleInTransactionSessionTimeout *metav1.Duration `json:"idleInTransactionSessionTimeout,omitempty"`
+	// RetryOnLockTimeout re-runs the SQL when it fails because lockTimeout
+	// expired (optional). The failed attempt is rolled back by the server, so
+	// this is safe for a single statement or a script without explicit
+	// COMMITs; retries stop when timeoutSeconds is reached.
+	RetryOnLockTimeout *LockTimeoutRetry `json:"retryOnLockTimeout,omitempty"`
+	// RedactSQLLiterals replaces single-quoted SQL literals in errors, logs
+	// and status messages, in addition to credentials which are always redacted.
+	RedactSQLLiterals bool `json:"redactSQLLiterals,omitempty"`
+	// Explain runs EXPLAIN on the SQL instead of executing it and records the
+	// plan in status.plan (optional). Not supported for MySQL or with
+	// executionMode Job.
+	Explain *ExplainOptions `json:"explain,omitempty"`
+}
+
+// ExplainOptions configures the EXPLAIN run in place of a query.
+type ExplainOptions struct {
+	// Analyze runs the statement to report actual times and row counts, in a
+	// transaction that is always rolled back so that DML changes nothing.
+	Analyze bool `json:"analyze,omitempty"`
+	// Buffers reports buffer usage, which without analyze covers planning
+	// only.
+	Buffers bool `json:"buffers,omitempty"`
+}
+
+// LockTimeoutRetry configures retries after a lock_timeout error.
+type LockTimeoutRetry struct {
+	// MaxAttempts is the total number of attempts, including the first. Defaults to 3.
+	// +kubebuilder:validation:Minimum=1
+	MaxAttempts *int `json:"maxAttempts,omitempty"`
+	// Backoff is the wait before the first retry; it doubles after each
+	// further attempt. Defaults to 5s.
+	Backoff *metav1.Duration `json:"backoff,omitempty"`
+}
+
+// CancelAnnotation requests cancellation of a PostgresQuery when set to "true".
+const CancelAnnotation = "kubequery.cloudnexus.io/cancel"
+
+// Annotations recording approval of a query that sets spec.requireApproval.
+// ApprovedHashAnnotation must match status.approvalHash, so an approval does
+// not carry over to changed SQL, connection or execution settings.
+const (
+	ApprovedByAnnotation   = "kubequery.cloudnexus.io/approved-by"
+	ApprovedHashAnnotation = "kubequery.cloudnexus.io/approved-hash"
+)
+
+// SubmittedByAnnotation records the user who created a query. It is set by
+// the admission webhook and may not be changed; a query cannot be approved
+// by the user who submitted it.
+const SubmittedByAnnotation = "kubequery.cloudnexus.io/submitted-by"
+
+// QueryPhase summarises the execution state of a PostgresQuery.
+type QueryPhase string
+
+// Execution phases reported in status.phase.
+const (
+	// PhasePendingApproval means the query waits for approval before it runs.
+	PhasePendingApproval QueryPhase = "PendingApproval"
+	PhaseRunning         QueryPhase = "Running"
+	PhaseSucceeded       QueryPhase = "Succeeded"
+	PhaseFailed          QueryPhase = "Failed"
+	PhaseCancelled       QueryPhase = "Cancelled"
+	// PhaseOrphaned means the query was running when the manager that started
+	// it stopped, so its outcome was not observed. It is never re-run.
+	PhaseOrphaned QueryPhase = "Orphaned"
+)
+
+// Condition types reported in status.conditions. The reason of each
+// condition is the phase that set it.
+const (
+	// ConditionSucceeded is True once the SQL has run successfully and False
+	// if it failed or was cancelled. Its message holds the error, if any.
+	ConditionSucceeded = "Succeeded"
+	// ConditionRunning is True while the SQL is executing, and Unknown while
+	// the query is orphaned.
+	ConditionRunning = "Running"
+	// ConditionApproved is set on queries with spec.requireApproval.
+	ConditionApproved = "Approved"
+)
+
+// PostgresQueryStatus defines the observed state of PostgresQuery.
+type PostgresQueryStatus struct {
+	// Conditions describe the execution state: Succeeded, Running and, for
+	// queries that require approval, Approved.
+	// +listType=map
+	// +listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+	// Phase is a summary of the conditions: PendingApproval, Running,
+	// Succeeded, Failed, Cancelled or Orphaned.
+	Phase QueryPhase `json:"phase,omitempty"`
+	// Result contains a summary or result of the execution (if applicable).
+	Result string `json:"result,omitempty"`
+	// IdempotencyHash is a hash of the SQL and connection info to prevent re-execution.
+	IdempotencyHash string `json:"idempotencyHash,omitempty"`
+	// ApprovalHash is the value the approved-hash annotation must have for a
+	// query that requires approval to run. It extends idempotencyHash with
+	// the connection's settings, options, executionMode and job.
+	ApprovalHash string `json:"approvalHash,omitempty"`
+	// Connection reports observed properties of the database connection.
+	Connection *ConnectionStatus `json:"connection,omitempty"`
+	// BackendPID is the PostgreSQL backend process ID that ran the query.
+	BackendPID int32 `json:"backendPID,omitempty"`
+	// StartTime is when execution started.
+	StartTime *metav1.Time `json:"startTime,omitempty"`
+	// CompletionTime is when execution finished, failed or was cancelled.
+	CompletionTime *metav1.Time `json:"completionTime,omitempty"`
+	// JobName is the runner Job executing the query in executionMode Job.
+	JobName string `json:"jobName,omitempty"`
+	// Plan is the plan recorded by EXPLAIN in spec.options.explain mode, as
+	// compact JSON.
+	Plan string `json:"plan,omitempty"`
+}
+
+// ConnectionStatus reports observed properties of the database connection.
+type ConnectionStatus struct {
+	// CANotAfter is the earliest expiry time of the certificates in the
+	// configured CA bundle.
+	CANotAfter *metav1.Time `json:"caNotAfter,omitempty"`
+}
+
+// +genclient
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:storageversion
+// +kubebuilder:validation:XValidation:rule="!has(oldSelf.status) || !has(oldSelf.status.phase) || oldSelf.status.phase != 'Succeeded' || (has(self.spec.connection) == has(oldSelf.spec.connection) && (!has(self.spec.connection) || self.spec.connection == oldSelf.spec.connection) && has(self.spec.connectionRef) == has(oldSelf.spec.connectionRef) && (!has(self.spec.connectionRef) || self.spec.connectionRef == oldSelf.spec.connectionRef))",message="connection and connectionRef are immutable once the query has succeeded"
+// +kubebuilder:printcolumn:name="Phase",type=string,JSONPath=`.status.phase`
+// +kubebuilder:printcolumn:name="Result",type=string,JSONPath=`.status.result`
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+
+// PostgresQuery is the Schema for the postgresqueries API.
+type PostgresQuery struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   PostgresQuerySpec   `json:"spec,omitempty"`
+	Status PostgresQueryStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// PostgresQueryList contains a list of PostgresQuery.
+type PostgresQueryList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []PostgresQuery `json:"items"`
+}
+
+// CancelRequested reports whether the user asked for the query to be cancelled,
+// either with spec.cancel or the cancel annotation.
+func (pq *PostgresQuery) CancelRequested() bool {
+	return pq.Spec.Cancel || pq.Annotations[CancelAnnotation] == "true"
+}
+
+// Approved reports whether the query may run. Queries that do not require
+// approval are always approved; others need approval for hash, the current
+// approval hash, by someone other than the submitter.
+func (pq *PostgresQuery) Approved(hash string) bool {
+	if !pq.Spec.RequireApproval {
+		return true
+	}
+	approvedBy := pq.Annotations[ApprovedByAnnotation]
+	return approvedBy != "" && approvedBy != pq.Annotations[SubmittedByAnnotation] &&
+		pq.Annotations[ApprovedHashAnnotation] == hash
+}
+
+// SetPhase sets status.phase and derives the Running and Succeeded
+// conditions, and the Approved condition while approval is pending, from it.
+// message is recorded on the Succeeded condition, and on the Running
+// condition while the query is running or orphaned; now is used as the
+// transition time of conditions whose status changes.
+func (pq *PostgresQuery) SetPhase(phase QueryPhase, message string, now metav1.Time) {
+	running, succeeded := metav1.ConditionFalse, metav1.ConditionUnknown
+	runningMessage := ""
+	switch phase {
+	case PhaseRunning:
+		running, runningMessage = metav1.ConditionTrue, message
+	case PhaseSucceeded:
+		succeeded = metav1.ConditionTrue
+	case PhaseFailed, PhaseCancelled:
+		succeeded = metav1.ConditionFalse
+	case PhaseOrphaned:
+		running, runningMessage = metav1.ConditionUnknown, message
+	}
+	reason := string(phase)
+	pq.Status.Phase = phase
+	pq.setCondition(ConditionRunning, running, reason, runningMessage, now)
+	pq.setCondition(ConditionSucceeded, succeeded, reason, message, now)
+	if phase == PhasePendingApproval {
+		pq.setCondition(ConditionApproved, metav1.ConditionFalse, reason, "Query requires approval before it runs", now)
+	}
+}
+
+// SetApproved records on the Approved condition who approved the query.
+func (pq *PostgresQuery) SetApproved(now metav1.Time) {
+	pq.setCondition(ConditionApproved, metav1.ConditionTrue, "Approved",
+		"Approved by "+pq.Annotations[ApprovedByAnnotation], now)
+}
+
+// Message returns the message of the Succeeded condition, which holds the
+// error of a failed, cancelled or orphaned query.
+func (pq *PostgresQuery) Message() string {
+	if c := meta.FindStatusCondition(pq.Status.Conditions, ConditionSucceeded); c != nil {
+		return c.Message
+	}
+	return ""
+}
+
+func (pq *PostgresQuery) setCondition(condType string, status metav1.ConditionStatus, reason, message string, now metav1.Time) {
+	meta.SetStatusCondition(&pq.Status.Conditions, metav1.Condition{
+		Type:               condType,
+		Status:             status,
+		Reason:             reason,
+		Message:            message,
+		ObservedGeneration: pq.Generation,
+		LastTransitionTime: now,
+	})
+}
+
+func init() {
+	SchemeBuilder.Register(&PostgresQuery{}, &PostgresQueryList{})
+}
@@ -0,0 +1,538 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/yaml"
+
+	kubequeryv1beta1 "github.com/rsavage/KubeQuery/api/v1beta1"
+)
+
+// queryFlags are the flags shared by run and plan.
+type queryFlags struct {
+	file            string
+	connection      string
+	timeoutSeconds  int
+	requireApproval bool
+	executionMode   string
+	explain         bool
+	analyze         bool
+}
+
+func (f *queryFlags) bind(cmd *cobra.Command) {
+	cmd.Flags().StringVarP(&f.file, "file", "f", "", "File containing the SQL to run, or - for stdin")
+	cmd.Flags().StringVar(&f.connection, "connection", "",
+		"Name of a PostgresConnection, or of an existing PostgresQuery whose connection settings are reused")
+	cmd.Flags().IntVar(&f.timeoutSeconds, "timeout-seconds", 0, "Query timeout in seconds (default: controller default)")
+	cmd.Flags().BoolVar(&f.requireApproval, "require-approval", false, "Hold the query until it is approved")
+	cmd.Flags().StringVar(&f.executionMode, "execution-mode", "", "Controller or Job (default: that of --connection)")
+	cmd.Flags().BoolVar(&f.explain, "explain", false, "Record the plan of the SQL in status.plan instead of running it")
+	cmd.Flags().BoolVar(&f.analyze, "analyze", false,
+		"With --explain, execute the SQL in a transaction that is rolled back to report actual rows and times")
+	_ = cmd.MarkFlagRequired("file")
+	_ = cmd.MarkFlagRequired("connection")
+}
+
+// build returns the PostgresQuery described by the flags.
+func (f *queryFlags) build(ctx context.Context, o *options, name string) (*kubequeryv1beta1.PostgresQuery, error) {
+	sql, err := readSQL(f.file)
+	if err != nil {
+		return nil, err
+	}
+	pq := &kubequeryv1beta1.PostgresQuery{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: o.namespace},
+		Spec: kubequeryv1beta1.PostgresQuerySpec{
+			SQLSource:       kubequeryv1beta1.SQLSource{Inline: sql},
+			RequireApproval: f.requireApproval,
+		},
+	}
+	key := types.NamespacedName{Namespace: o.namespace, Name: f.connection}
+	var conn kubequeryv1beta1.PostgresConnection
+	err = o.client.Get(ctx, key, &conn)
+	switch {
+	case err == nil:
+		pq.Spec.ConnectionRef = &kubequeryv1beta1.ConnectionReference{Name: conn.Name}
+	case apierrors.IsNotFound(err):
+		var tmpl kubequeryv1beta1.PostgresQuery
+		if err := o.client.Get(ctx, key, &tmpl); err != nil {
+			return nil, fmt.Errorf("failed to get connection %q: %w", f.connection, err)
+		}
+		pq.Spec.Connection = tmpl.Spec.Connection
+		pq.Spec.ConnectionRef = tmpl.Spec.ConnectionRef
+		pq.Spec.ExecutionMode = tmpl.Spec.ExecutionMode
+		pq.Spec.Job = tmpl.Spec.Job
+	default:
+		return nil, fmt.Errorf("failed to get connection %q: %w", f.connection, err)
+	}
+	if f.executionMode != "" {
+		pq.Spec.ExecutionMode = kubequeryv1beta1.ExecutionMode(f.executionMode)
+	}
+	if f.timeoutSeconds > 0 {
+		pq.Spec.Options = &kubequeryv1beta1.QueryOptions{TimeoutSeconds: &f.timeoutSeconds}
+	}
+	if f.analyze && !f.explain {
+		return nil, errors.New("--analyze requires --explain")
+	}
+	if f.explain {
+		if pq.Spec.Options == nil {
+			pq.Spec.Options = &kubequeryv1beta1.QueryOptions{}
+		}
+		pq.Spec.Options.Explain = &kubequeryv1beta1.ExplainOptions{Analyze: f.analyze, Buffers: f.analyze}
+	}
+	return pq, nil
+}
+
+func readSQL(path string) (string, error) {
+	var b []byte
+	var err error
+	if path == "-" {
+		b, err = io.ReadAll(os.Stdin)
+	} else {
+		b, err = os.ReadFile(path)
+	}
+	if err != nil {
+		return "", err
+	}
+	if strings.TrimSpace(string(b)) == "" {
+		return "", errors.New("SQL file is empty")
+	}
+	return string(b), nil
+}
+
+func newRunCommand(o *options) *cobra.Command {
+	var flags queryFlags
+	var w waitFlags
+	cmd := &cobra.Command{
+		Use:   "run NAME -f FILE --connection CONNECTION",
+		Short: "Create a PostgresQuery from a SQL file, wait for it and print the result",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := cmd.Context()
+			pq, err := flags.build(ctx, o, args[0])
+			if err != nil {
+				return err
+			}
+			if err := o.client.Create(ctx, pq); err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "postgresquery/%s created\n", pq.Name)
+			return w.waitAndPrint(ctx, o, cmd.OutOrStdout(), pq.Name)
+		},
+	}
+	flags.bind(cmd)
+	w.bind(cmd)
+	return cmd
+}
+
+func newPlanCommand(o *options) *cobra.Command {
+	var flags queryFlags
+	cmd := &cobra.Command{
+		Use:   "plan NAME -f FILE --connection CONNECTION",
+		Short: "Validate the PostgresQuery run would create with a server-side dry run, without executing it",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := cmd.Context()
+			pq, err := flags.build(ctx, o, args[0])
+			if err != nil {
+				return err
+			}
+			if err := o.client.Create(ctx, pq, client.DryRunAll); err != nil {
+				return err
+			}
+			pq.ManagedFields = nil
+			out, err := yaml.Marshal(pq)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "# postgresquery/%s is valid (server dry run); nothing was executed\n%s", pq.Name, out)
+			return nil
+		},
+	}
+	flags.bind(cmd)
+	return cmd
+}
+
+func newStatusCommand(o *options) *cobra.Command {
+	return &cobra.Command{
+		Use:   "status NAME",
+		Short: "Show the execution status of a PostgresQuery",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			pq, err := o.get(cmd.Context(), args[0])
+			if err != nil {
+				return err
+			}
+			printStatus(cmd.OutOrStdout(), pq)
+			return nil
+		},
+	}
+}
+
+func newLogsCommand(o *options) *cobra.Command {
+	return &cobra.Command{
+		Use:   "logs NAME",
+		Short: "Show the events, result and runner output of a PostgresQuery",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := cmd.Context()
+			out := cmd.OutOrStdout()
+			pq, err := o.get(ctx, args[0])
+			if err != nil {
+				return err
+			}
+
+			var events corev1.EventList
+			if err := o.client.List(ctx, &events, client.InNamespace(o.namespace)); err != nil {
+				return err
+			}
+			items := events.Items[:0]
+			for _, e := range events.Items {
+				if e.InvolvedObject.UID == pq.UID {
+					items = append(items, e)
+				}
+			}
+			sort.Slice(items, func(i, j int) bool { return eventTime(items[i]).Before(eventTime(items[j])) })
+			tw := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+			fmt.Fprintln(tw, "TIME\tTYPE\tREASON\tMESSAGE")
+			for _, e := range items {
+				fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", eventTime(e).Format(time.RFC3339), e.Type, e.Reason, e.Message)
+			}
+			if err := tw.Flush(); err != nil {
+				return err
+			}
+
+			fmt.Fprintln(out)
+			printStatus(out, pq)
+
+			if pq.Status.JobName != "" {
+				return o.printRunnerLogs(ctx, out, pq.Status.JobName)
+			}
+			return nil
+		},
+	}
+}
+
+func newRerunCommand(o *options) *cobra.Command {
+	var w waitFlags
+	cmd := &cobra.Command{
+		Use:   "rerun NAME",
+		Short: "Run the SQL of an existing PostgresQuery again as a new PostgresQuery",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := cmd.Context()
+			orig, err := o.get(ctx, args[0])
+			if err != nil {
+				return err
+			}
+			pq := &kubequeryv1beta1.PostgresQuery{
+				ObjectMeta: metav1.ObjectMeta{
+					GenerateName: orig.Name + "-",
+					Namespace:    o.namespace,
+					Labels:       map[string]string{rerunOfLabel: orig.Name},
+				},
+				Spec: *orig.Spec.DeepCopy(),
+			}
+			// Approvals and cancel requests do not carry over.
+			pq.Spec.Cancel = false
+			if err := o.client.Create(ctx, pq); err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "postgresquery/%s created\n", pq.Name)
+			return w.waitAndPrint(ctx, o, cmd.OutOrStdout(), pq.Name)
+		},
+	}
+	w.bind(cmd)
+	return cmd
+}
+
+// rerunOfLabel links a PostgresQuery created by rerun to the original.
+const rerunOfLabel = "kubequery.cloudnexus.io/rerun-of"
+
+func newCancelCommand(o *options) *cobra.Command {
+	return &cobra.Command{
+		Use:   "cancel NAME",
+		Short: "Cancel a pending or running PostgresQuery",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := o.annotate(cmd.Context(), args[0], map[string]string{kubequeryv1beta1.CancelAnnotation: "true"}); err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "postgresquery/%s cancel requested\n", args[0])
+			return nil
+		},
+	}
+}
+
+func newApproveCommand(o *options) *cobra.Command {
+	return &cobra.Command{
+		Use:   "approve NAME",
+		Short: "Approve a PostgresQuery that is pending approval",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := cmd.Context()
+			pq, err := o.get(ctx, args[0])
+			if err != nil {
+				return err
+			}
+			if pq.Status.Phase != kubequeryv1beta1.PhasePendingApproval || pq.Status.ApprovalHash == "" {
+				return fmt.Errorf("postgresquery/%s is not pending approval (phase %q)", pq.Name, pq.Status.Phase)
+			}
+			user, err := o.username(ctx)
+			if err != nil {
+				return err
+			}
+			if err := o.annotate(ctx, pq.Name, map[string]string{
+				kubequeryv1beta1.ApprovedByAnnotation:   user,
+				kubequeryv1beta1.ApprovedHashAnnotation: pq.Status.ApprovalHash,
+			}); err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "postgresquery/%s approved by %s\n", pq.Name, user)
+			return nil
+		},
+	}
+}
+
+func newHistoryCommand(o *options) *cobra.Command {
+	var allNamespaces bool
+	var limit int
+	cmd := &cobra.Command{
+		Use:   "history",
+		Short: "List PostgresQuery executions, most recent first",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			var list kubequeryv1beta1.PostgresQueryList
+			var opts []client.ListOption
+			if !allNamespaces {
+				opts = append(opts, client.InNamespace(o.namespace))
+			}
+			if err := o.client.List(cmd.Context(), &list, opts...); err != nil {
+				return err
+			}
+			items := list.Items
+			sort.Slice(items, func(i, j int) bool { return startTime(&items[j]).Before(startTime(&items[i])) })
+			if limit > 0 && len(items) > limit {
+				items = items[:limit]
+			}
+
+			tw := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 4, 2, ' ', 0)
+			fmt.Fprintln(tw, "NAMESPACE\tNAME\tPHASE\tSTARTED\tDURATION\tRESULT")
+			for i := range items {
+				pq := &items[i]
+				outcome := pq.Status.Result
+				if msg := queryError(pq); msg != "" {
+					outcome = msg
+				}
+				started := "-"
+				if t := startTime(pq); !t.IsZero() {
+					started = t.Format(time.RFC3339)
+				}
+				fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n", pq.Namespace, pq.Name, pq.Status.Phase,
+					started, duration(pq), truncate(outcome, 60))
+			}
+			return tw.Flush()
+		},
+	}
+	cmd.Flags().BoolVarP(&allNamespaces, "all-namespaces", "A", false, "List queries in all namespaces")
+	cmd.Flags().IntVar(&limit, "limit", 0, "Show at most this many queries")
+	return cmd
+}
+
+// waitFlags control waiting for a query to finish.
+type waitFlags struct {
+	wait    bool
+	timeout time.Duration
+}
+
+func (w *waitFlags) bind(cmd *cobra.Command) {
+	cmd.Flags().BoolVar(&w.wait, "wait", true, "Wait for the query to finish and print its result")
+	cmd.Flags().DurationVar(&w.timeout, "wait-timeout", 10*time.Minute, "How long to wait for the query to finish")
+}
+
+// waitAndPrint waits until the query reaches a final phase and prints its
+// status. It fails if the query did not succeed.
+func (w *waitFlags) waitAndPrint(ctx context.Context, o *options, out io.Writer, name string) error {
+	if !w.wait {
+		return nil
+	}
+	var pq *kubequeryv1beta1.PostgresQuery
+	err := wait.PollUntilContextTimeout(ctx, 2*time.Second, w.timeout, true, func(ctx context.Context) (bool, error) {
+		var err error
+		if pq, err = o.get(ctx, name); err != nil {
+			return false, err
+		}
+		return finished(pq.Status.Phase), nil
+	})
+	if err != nil {
+		return fmt.Errorf("waiting for postgresquery/%s: %w", name, err)
+	}
+	printStatus(out, pq)
+	if pq.Status.Phase != kubequeryv1beta1.PhaseSucceeded {
+		return fmt.Errorf("postgresquery/%s ended in phase %s", name, pq.Status.Phase)
+	}
+	return nil
+}
+
+// finished reports whether phase is final, or needs a person to act.
+func finished(phase kubequeryv1beta1.QueryPhase) bool {
+	switch phase {
+	case kubequeryv1beta1.PhaseSucceeded, kubequeryv1beta1.PhaseFailed, kubequeryv1beta1.PhaseCancelled,
+		kubequeryv1beta1.PhaseOrphaned, kubequeryv1beta1.PhasePendingApproval:
+		return true
+	}
+	return false
+}
+
+func (o *options) get(ctx context.Context, name string) (*kubequeryv1beta1.PostgresQuery, error) {
+	var pq kubequeryv1beta1.PostgresQuery
+	if err := o.client.Get(ctx, types.NamespacedName{Namespace: o.namespace, Name: name}, &pq); err != nil {
+		return nil, err
+	}
+	return &pq, nil
+}
+
+// annotate merges annotations into the named PostgresQuery.
+func (o *options) annotate(ctx context.Context, name string, annotations map[string]string) error {
+	data, err := json.Marshal(map[string]any{"metadata": map[string]any{"annotations": annotations}})
+	if err != nil {
+		return err
+	}
+	pq := &kubequeryv1beta1.PostgresQuery{ObjectMeta: metav1.ObjectMeta{Namespace: o.namespace, Name: name}}
+	return o.client.Patch(ctx, pq, client.RawPatch(types.MergePatchType, data))
+}
+
+// username returns the caller's user name as seen by the API server.
+func (o *options) username(ctx context.Context) (string, error) {
+	review, err := o.clientset.AuthenticationV1().SelfSubjectReviews().Create(ctx, &authenticationv1.SelfSubjectReview{}, metav1.CreateOptions{})
+	if err != nil {
+		return "", fmt.Errorf("failed to determine the current user: %w", err)
+	}
+	if review.Status.UserInfo.Username == "" {
+		return "", errors.New("failed to determine the current user")
+	}
+	return review.Status.UserInfo.Username, nil
+}
+
+// printRunnerLogs prints the output of the runner pods of a Job.
+func (o *options) printRunnerLogs(ctx context.Context, out io.Writer, jobName string) error {
+	pods, err := o.clientset.CoreV1().Pods(o.namespace).List(ctx, metav1.ListOptions{LabelSelector: batchv1.JobNameLabel + "=" + jobName})
+	if err != nil {
+		return err
+	}
+	for _, pod := range pods.Items {
+		fmt.Fprintf(out, "\n--- runner pod %s ---\n", pod.Name)
+		logs, err := o.clientset.CoreV1().Pods(o.namespace).GetLogs(pod.Name, &corev1.PodLogOptions{}).DoRaw(ctx)
+		if err != nil {
+			fmt.Fprintf(out, "(logs unavailable: %v)\n", err)
+			continue
+		}
+		_, _ = out.Write(logs)
+	}
+	return nil
+}
+
+func printStatus(out io.Writer, pq *kubequeryv1beta1.PostgresQuery) {
+	tw := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	st := pq.Status
+	fmt.Fprintf(tw, "Name:\t%s/%s\n", pq.Namespace, pq.Name)
+	fmt.Fprintf(tw, "Phase:\t%s\n", st.Phase)
+	if st.StartTime != nil {
+		fmt.Fprintf(tw, "Started:\t%s\n", st.StartTime.Format(time.RFC3339))
+	}
+	if st.CompletionTime != nil {
+		fmt.Fprintf(tw, "Completed:\t%s\n", st.CompletionTime.Format(time.RFC3339))
+	}
+	if st.BackendPID != 0 {
+		fmt.Fprintf(tw, "Backend PID:\t%d\n", st.BackendPID)
+	}
+	if st.JobName != "" {
+		fmt.Fprintf(tw, "Job:\t%s\n", st.JobName)
+	}
+	if approvedBy := pq.Annotations[kubequeryv1beta1.ApprovedByAnnotation]; approvedBy != "" {
+		fmt.Fprintf(tw, "Approved by:\t%s\n", approvedBy)
+	}
+	if st.Result != "" {
+		fmt.Fprintf(tw, "Result:\t%s\n", st.Result)
+	}
+	if msg := queryError(pq); msg != "" {
+		fmt.Fprintf(tw, "Error:\t%s\n", msg)
+	}
+	_ = tw.Flush()
+}
+
+// queryError returns why pq failed, was cancelled or was orphaned.
+func queryError(pq *kubequeryv1beta1.PostgresQuery) string {
+	switch pq.Status.Phase {
+	case kubequeryv1beta1.PhaseFailed, kubequeryv1beta1.PhaseCancelled, kubequeryv1beta1.PhaseOrphaned:
+		return pq.Message()
+	}
+	return ""
+}
+
+func eventTime(e corev1.Event) time.Time {
+	switch {
+	case !e.LastTimestamp.IsZero():
+		return e.LastTimestamp.Time
+	case !e.EventTime.IsZero():
+		return e.EventTime.Time
+	default:
+		return e.CreationTimestamp.Time
+	}
+}
+
+// startTime returns when pq started, falling back to its creation time.
+func startTime(pq *kubequeryv1beta1.PostgresQuery) time.Time {
+	if pq.Status.StartTime != nil {
+		return pq.Status.StartTime.Time
+	}
+	return pq.CreationTimestamp.Time
+}
+
+func duration(pq *kubequeryv1beta1.PostgresQuery) string {
+	if pq.Status.StartTime == nil || pq.Status.CompletionTime == nil {
+		return "-"
+	}
+	return pq.Status.CompletionTime.Sub(pq.Status.StartTime.Time).Round(time.Second).String()
+}
+
+func truncate(s string, n int) string {
+	s = strings.ReplaceAll(s, "\n", " ")
+	if len(s) <= n {
+		return s
+	}
+	return s[:n-3] + "..."
+}
@@ -0,0 +1,118 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// kubectl-kubequery is a kubectl plugin for authoring and operating
+// PostgresQuery objects. Install it on the PATH and run `kubectl kubequery`.
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	corev1 "k8s.io/api/core/v1"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	kubequeryv1beta1 "github.com/rsavage/KubeQuery/api/v1beta1"
+)
+
+// options holds the connection to the cluster shared by all subcommands.
+type options struct {
+	configFlags clientcmd.ClientConfig
+	namespace   string
+
+	client    client.Client
+	clientset kubernetes.Interface
+}
+
+// init creates the clients. It is run before every subcommand.
+func (o *options) init() error {
+	cfg, err := o.configFlags.ClientConfig()
+	if err != nil {
+		return err
+	}
+	if o.namespace, _, err = o.configFlags.Namespace(); err != nil {
+		return err
+	}
+	return o.initClients(cfg)
+}
+
+func (o *options) initClients(cfg *rest.Config) error {
+	scheme := runtime.NewScheme()
+	if err := corev1.AddToScheme(scheme); err != nil {
+		return err
+	}
+	if err := apiextensionsv1.AddToScheme(scheme); err != nil {
+		return err
+	}
+	if err := kubequeryv1beta1.AddToScheme(scheme); err != nil {
+		return err
+	}
+	c, err := client.New(cfg, client.Options{Scheme: scheme})
+	if err != nil {
+		return err
+	}
+	cs, err := kubernetes.NewForConfig(cfg)
+	if err != nil {
+		return err
+	}
+	o.client, o.clientset = c, cs
+	return nil
+}
+
+func newRootCommand() *cobra.Command {
+	o := &options{}
+	cmd := &cobra.Command{
+		Use:           "kubectl-kubequery",
+		Short:         "Author and operate KubeQuery PostgresQuery objects",
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		PersistentPreRunE: func(*cobra.Command, []string) error {
+			return o.init()
+		},
+	}
+
+	loadingRules := clientcmd.NewDefaultClientConfigLoadingRules()
+	overrides := &clientcmd.ConfigOverrides{}
+	cmd.PersistentFlags().StringVar(&loadingRules.ExplicitPath, "kubeconfig", "", "Path to the kubeconfig file")
+	clientcmd.BindOverrideFlags(overrides, cmd.PersistentFlags(), clientcmd.RecommendedConfigOverrideFlags(""))
+	o.configFlags = clientcmd.NewNonInteractiveDeferredLoadingClientConfig(loadingRules, overrides)
+
+	cmd.AddCommand(
+		newRunCommand(o),
+		newPlanCommand(o),
+		newStatusCommand(o),
+		newLogsCommand(o),
+		newRerunCommand(o),
+		newCancelCommand(o),
+		newApproveCommand(o),
+		newHistoryCommand(o),
+		newMigrateStorageCommand(o),
+	)
+	return cmd
+}
+
+func main() {
+	if err := newRootCommand().Execute(); err != nil {
+		fmt.Fprintln(os.Stderr, "error:", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,174 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/spf13/cobra"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+	clienttesting "k8s.io/client-go/testing"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	kubequeryv1beta1 "github.com/rsavage/KubeQuery/api/v1beta1"
+)
+
+// newOptions returns options backed by fake clients holding objs, for a
+// caller named user.
+func newOptions(t *testing.T, user string, objs ...client.Object) *options {
+	t.Helper()
+	scheme := runtime.NewScheme()
+	if err := apiextensionsv1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	if err := kubequeryv1beta1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	cs := k8sfake.NewClientset()
+	cs.PrependReactor("create", "selfsubjectreviews", func(clienttesting.Action) (bool, runtime.Object, error) {
+		return true, &authenticationv1.SelfSubjectReview{
+			Status: authenticationv1.SelfSubjectReviewStatus{UserInfo: authenticationv1.UserInfo{Username: user}},
+		}, nil
+	})
+	return &options{
+		namespace: "team-a",
+		client: fake.NewClientBuilder().WithScheme(scheme).WithObjects(objs...).
+			WithStatusSubresource(&apiextensionsv1.CustomResourceDefinition{}, &kubequeryv1beta1.PostgresQuery{}).Build(),
+		clientset: cs,
+	}
+}
+
+// execute runs cmd with args and returns its output.
+func execute(t *testing.T, cmd *cobra.Command, args ...string) (string, error) {
+	t.Helper()
+	var out bytes.Buffer
+	cmd.SetOut(&out)
+	cmd.SetErr(&out)
+	cmd.SetArgs(args)
+	cmd.SetContext(context.Background())
+	err := cmd.Execute()
+	return out.String(), err
+}
+
+func TestRootCommand(t *testing.T) {
+	root := newRootCommand()
+	for _, name := range []string{"run", "plan", "status", "logs", "rerun", "cancel", "approve", "history", "migrate-storage"} {
+		cmd, _, err := root.Find([]string{name})
+		if err != nil || cmd.Name() != name {
+			t.Errorf("subcommand %q not registered: %v", name, err)
+		}
+	}
+}
+
+func TestApprove(t *testing.T) {
+	pending := &kubequeryv1beta1.PostgresQuery{
+		ObjectMeta: metav1.ObjectMeta{Name: "drop", Namespace: "team-a"},
+		Spec:       kubequeryv1beta1.PostgresQuerySpec{RequireApproval: true},
+		Status: kubequeryv1beta1.PostgresQueryStatus{
+			Phase:           kubequeryv1beta1.PhasePendingApproval,
+			IdempotencyHash: "abc",
+			ApprovalHash:    "abc123",
+		},
+	}
+	o := newOptions(t, "bob", pending)
+
+	out, err := execute(t, newApproveCommand(o), "drop")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out, "approved by bob") {
+		t.Errorf("output = %q", out)
+	}
+	pq, err := o.get(context.Background(), "drop")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pq.Annotations[kubequeryv1beta1.ApprovedByAnnotation] != "bob" || pq.Annotations[kubequeryv1beta1.ApprovedHashAnnotation] != "abc123" {
+		t.Errorf("annotations = %v, want approval by bob for abc123", pq.Annotations)
+	}
+
+	pq.Status.Phase = kubequeryv1beta1.PhaseSucceeded
+	if err := o.client.Status().Update(context.Background(), pq); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := execute(t, newApproveCommand(o), "drop"); err == nil || !strings.Contains(err.Error(), "not pending approval") {
+		t.Errorf("approving a finished query: err = %v", err)
+	}
+}
+
+func TestMigrateStorage(t *testing.T) {
+	crd := &apiextensionsv1.CustomResourceDefinition{
+		ObjectMeta: metav1.ObjectMeta{Name: postgresQueryCRD},
+		Spec: apiextensionsv1.CustomResourceDefinitionSpec{Versions: []apiextensionsv1.CustomResourceDefinitionVersion{
+			{Name: "v1alpha1", Served: true},
+			{Name: "v1beta1", Served: true, Storage: true},
+		}},
+		Status: apiextensionsv1.CustomResourceDefinitionStatus{StoredVersions: []string{"v1alpha1", "v1beta1"}},
+	}
+	queries := []client.Object{
+		&kubequeryv1beta1.PostgresQuery{ObjectMeta: metav1.ObjectMeta{Name: "a", Namespace: "team-a"}},
+		&kubequeryv1beta1.PostgresQuery{ObjectMeta: metav1.ObjectMeta{Name: "b", Namespace: "team-b"}},
+	}
+	o := newOptions(t, "admin", append(queries, crd)...)
+	before := map[string]string{}
+	for _, q := range queries {
+		var pq kubequeryv1beta1.PostgresQuery
+		if err := o.client.Get(context.Background(), client.ObjectKeyFromObject(q), &pq); err != nil {
+			t.Fatal(err)
+		}
+		before[pq.Namespace+"/"+pq.Name] = pq.ResourceVersion
+	}
+
+	out, err := execute(t, newMigrateStorageCommand(o))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out, "migrated 2 postgresqueries to v1beta1") {
+		t.Errorf("output = %q", out)
+	}
+	var got apiextensionsv1.CustomResourceDefinition
+	if err := o.client.Get(context.Background(), client.ObjectKeyFromObject(crd), &got); err != nil {
+		t.Fatal(err)
+	}
+	if len(got.Status.StoredVersions) != 1 || got.Status.StoredVersions[0] != "v1beta1" {
+		t.Errorf("stored versions = %v, want [v1beta1]", got.Status.StoredVersions)
+	}
+	for _, q := range queries {
+		var pq kubequeryv1beta1.PostgresQuery
+		if err := o.client.Get(context.Background(), client.ObjectKeyFromObject(q), &pq); err != nil {
+			t.Fatal(err)
+		}
+		if pq.ResourceVersion == before[pq.Namespace+"/"+pq.Name] {
+			t.Errorf("%s/%s was not rewritten", pq.Namespace, pq.Name)
+		}
+	}
+
+	// A CRD still storing v1alpha1 means the upgrade has not happened yet.
+	crd.Spec.Versions[0].Storage, crd.Spec.Versions[1].Storage = true, false
+	o = newOptions(t, "admin", crd)
+	if err := o.migrateStorage(context.Background(), &bytes.Buffer{}); err == nil || !strings.Contains(err.Error(), "upgrade KubeQuery first") {
+		t.Errorf("err = %v, want a storage version error", err)
+	}
+}
@@ -0,0 +1,104 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/spf13/cobra"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	kubequeryv1beta1 "github.com/rsavage/KubeQuery/api/v1beta1"
+)
+
+// postgresQueryCRD is the name of the PostgresQuery CustomResourceDefinition.
+var postgresQueryCRD = kubequeryv1beta1.Resource("postgresqueries").String()
+
+func newMigrateStorageCommand(o *options) *cobra.Command {
+	return &cobra.Command{
+		Use:   "migrate-storage",
+		Short: "Rewrite all PostgresQuery objects in the current storage version",
+		Long: `Rewrite every PostgresQuery, in all namespaces, so that etcd holds it in the
+CRD's storage version, then record that version as the only stored version of
+the CRD. Run it after upgrading from a release that stored v1alpha1, before a
+later release stops serving v1alpha1.
+
+Requires permission to update postgresqueries in all namespaces and to update
+the customresourcedefinitions/status subresource.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			return o.migrateStorage(cmd.Context(), cmd.OutOrStdout())
+		},
+	}
+}
+
+func (o *options) migrateStorage(ctx context.Context, out io.Writer) error {
+	var crd apiextensionsv1.CustomResourceDefinition
+	if err := o.client.Get(ctx, types.NamespacedName{Name: postgresQueryCRD}, &crd); err != nil {
+		return err
+	}
+	storage := ""
+	for _, v := range crd.Spec.Versions {
+		if v.Storage {
+			storage = v.Name
+		}
+	}
+	if storage != kubequeryv1beta1.GroupVersion.Version {
+		return fmt.Errorf("the storage version of %s is %q, expected %s; upgrade KubeQuery first",
+			postgresQueryCRD, storage, kubequeryv1beta1.GroupVersion.Version)
+	}
+
+	var list kubequeryv1beta1.PostgresQueryList
+	if err := o.client.List(ctx, &list); err != nil {
+		return err
+	}
+	for i := range list.Items {
+		if err := o.rewrite(ctx, &list.Items[i]); err != nil {
+			return fmt.Errorf("failed to migrate postgresquery %s/%s: %w", list.Items[i].Namespace, list.Items[i].Name, err)
+		}
+	}
+	fmt.Fprintf(out, "migrated %d postgresqueries to %s\n", len(list.Items), storage)
+
+	patch := client.MergeFrom(crd.DeepCopy())
+	crd.Status.StoredVersions = []string{storage}
+	if err := o.client.Status().Patch(ctx, &crd, patch); err != nil {
+		return fmt.Errorf("failed to update stored versions: %w", err)
+	}
+	fmt.Fprintf(out, "customresourcedefinition/%s stored versions: %s\n", postgresQueryCRD, storage)
+	return nil
+}
+
+// rewrite writes pq back unchanged. The API server re-encodes it in the
+// storage version.
+func (o *options) rewrite(ctx context.Context, pq *kubequeryv1beta1.PostgresQuery) error {
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		err := o.client.Update(ctx, pq)
+		if apierrors.IsConflict(err) {
+			if getErr := o.client.Get(ctx, client.ObjectKeyFromObject(pq), pq); getErr != nil {
+				return getErr
+			}
+		}
+		return err
+	})
+	return client.IgnoreNotFound(err)
+}
@@ -0,0 +1,71 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// kubequery-runner executes one PostgresQuery inside a Job created by the
+// controller for executionMode Job. It reads the runner spec from a mounted
+// Secret and writes the result to the container's termination message.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/rsavage/KubeQuery/pkg/runner"
+)
+
+func main() {
+	var specPath, terminationLog string
+	flag.StringVar(&specPath, "spec", "/etc/kubequery/"+runner.SpecKey, "Path of the runner spec.")
+	flag.StringVar(&terminationLog, "termination-log", "/dev/termination-log",
+		"File the result is written to; it becomes the container's termination message.")
+	flag.Parse()
+
+	// The kubelet sends SIGTERM when the Job is deleted, e.g. on cancel.
+	// Cancelling the context cancels the query on the server.
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, os.Interrupt)
+	defer stop()
+
+	res := run(ctx, specPath)
+	msg, err := runner.EncodeResult(res)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to encode result: %v\n", err)
+		os.Exit(2)
+	}
+	fmt.Println(string(msg))
+	if err := os.WriteFile(terminationLog, msg, 0o644); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to write termination message: %v\n", err)
+	}
+	if !res.Succeeded {
+		os.Exit(1)
+	}
+}
+
+func run(ctx context.Context, specPath string) runner.Result {
+	data, err := os.ReadFile(specPath)
+	if err != nil {
+		return runner.Result{Error: fmt.Sprintf("failed to read runner spec: %v", err)}
+	}
+	var spec runner.Spec
+	if err := json.Unmarshal(data, &spec); err != nil {
+		return runner.Result{Error: fmt.Sprintf("invalid runner spec: %v", err)}
+	}
+	return runner.Run(ctx, spec)
+}
@@ -21,6 +21,7 @@ import (
 	"flag"
 	"os"
 	"path/filepath"
+	"strings"
 
 	// Import all Kubernetes client auth plugins (e.g. Azure, GCP, OIDC, etc.)
 	// to ensure that exec-entrypoint and run can make use of them.
@@ -38,7 +39,9 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/webhook"
 
 	kubequeryv1alpha1 "github.com/rsavage/KubeQuery/api/v1alpha1"
+	kubequeryv1beta1 "github.com/rsavage/KubeQuery/api/v1beta1"
 	"github.com/rsavage/KubeQuery/internal/controller"
+	webhookkubequeryv1beta1 "github.com/rsavage/KubeQuery/internal/webhook/v1beta1"
 	// +kubebuilder:scaffold:imports
 )
 
@@ -51,6 +54,7 @@ func init() {
 	utilruntime.Must(clientgoscheme.AddToScheme(scheme))
 
 	utilruntime.Must(kubequeryv1alpha1.AddToScheme(scheme))
+	utilruntime.Must(kubequeryv1beta1.AddToScheme(scheme))
 	// +kubebuilder:scaffold:scheme
 }
 
@@ -63,6 +67,10 @@ func main() {
 	var probeAddr string
 	var secureMetrics bool
 	var enableHTTP2 bool
+	var enableCredentialPlugins bool
+	var credentialTokenPaths, credentialExecCommands string
+	var approvalDelegates string
+	var runnerImage string
 	var tlsOpts []func(*tls.Config)
 	flag.StringVar(&metricsAddr, "metrics-bind-address", "0", "The address the metrics endpoint binds to. "+
 		"Use :8443 for HTTPS or :8080 for HTTP, or leave as 0 to disable the metrics service.")
@@ -81,6 +89,17 @@ func main() {
 	flag.StringVar(&metricsCertKey, "metrics-cert-key", "tls.key", "The name of the metrics server key file.")
 	flag.BoolVar(&enableHTTP2, "enable-http2", false,
 		"If set, HTTP/2 will be enabled for the metrics and webhook servers")
+	flag.BoolVar(&enableCredentialPlugins, "enable-credential-plugins", false,
+		"If set, PostgresQuery objects may authenticate with token files and exec plugins that run inside the manager pod")
+	flag.StringVar(&credentialTokenPaths, "credential-token-paths", "",
+		"Comma-separated token files, or directories containing them, that auth.token.path may read")
+	flag.StringVar(&credentialExecCommands, "credential-exec-commands", "",
+		"Comma-separated commands that auth.exec may run")
+	flag.StringVar(&approvalDelegates, "approval-delegates", "",
+		"Comma-separated users, such as ui-service's ServiceAccount, trusted to submit and approve PostgresQuery "+
+			"objects on behalf of other users")
+	flag.StringVar(&runnerImage, "runner-image", "",
+		"The kubequery-runner image used for PostgresQuery objects with executionMode Job")
 	opts := zap.Options{
 		Development: true,
 	}
@@ -203,12 +222,27 @@ func main() {
 	}
 
 	if err = (&controller.PostgresQueryReconciler{
-		Client: mgr.GetClient(),
-		Scheme: mgr.GetScheme(),
+		Client:                  mgr.GetClient(),
+		Scheme:                  mgr.GetScheme(),
+		Recorder:                mgr.GetEventRecorderFor("postgresquery-controller"),
+		EnableCredentialPlugins: enableCredentialPlugins,
+		AllowedTokenPaths:       splitList(credentialTokenPaths),
+		AllowedExecCommands:     splitList(credentialExecCommands),
+		RunnerImage:             runnerImage,
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "PostgresQuery")
 		os.Exit(1)
 	}
+	// The conversion webhook serves v1alpha1 PostgresQuery objects from the
+	// v1beta1 storage version; the admission webhooks enforce approvals. Set
+	// ENABLE_WEBHOOKS=false to run the manager locally without serving
+	// certificates.
+	if os.Getenv("ENABLE_WEBHOOKS") != "false" {
+		if err = webhookkubequeryv1beta1.SetupPostgresQueryWebhookWithManager(mgr, splitList(approvalDelegates)); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "PostgresQuery")
+			os.Exit(1)
+		}
+	}
 	// +kubebuilder:scaffold:builder
 
 	if metricsCertWatcher != nil {
@@ -242,3 +276,14 @@ func main() {
 		os.Exit(1)
 	}
 }
+
+// splitList splits a comma-separated flag value, dropping empty entries.
+func splitList(s string) []string {
+	var out []string
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			out = append(out, v)
+		}
+	}
+	return out
+}
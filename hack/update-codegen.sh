@@ -0,0 +1,48 @@
+#!/usr/bin/env bash
+
+# Generates the typed clientset, listers, informers and apply configurations
+# in pkg/client from the types in api/. Run via `make generate-client`.
+
+set -o errexit
+set -o nounset
+set -o pipefail
+
+ROOT=$(cd "$(dirname "${BASH_SOURCE[0]}")/.." && pwd)
+BIN=${LOCALBIN:-${ROOT}/bin}
+MODULE=github.com/rsavage/KubeQuery
+OUT_PKG=${MODULE}/pkg/client
+HEADER=${ROOT}/hack/boilerplate.go.txt
+APIS=(./api/v1alpha1 ./api/v1beta1)
+
+cd "${ROOT}"
+rm -rf pkg/client/clientset pkg/client/listers pkg/client/informers pkg/client/applyconfiguration
+
+"${BIN}/applyconfiguration-gen" \
+  --go-header-file "${HEADER}" \
+  --output-dir pkg/client/applyconfiguration \
+  --output-pkg "${OUT_PKG}/applyconfiguration" \
+  "${APIS[@]}"
+
+"${BIN}/client-gen" \
+  --go-header-file "${HEADER}" \
+  --clientset-name versioned \
+  --input-base "" \
+  --input "${MODULE}/api/v1alpha1" \
+  --input "${MODULE}/api/v1beta1" \
+  --apply-configuration-package "${OUT_PKG}/applyconfiguration" \
+  --output-dir pkg/client/clientset \
+  --output-pkg "${OUT_PKG}/clientset"
+
+"${BIN}/lister-gen" \
+  --go-header-file "${HEADER}" \
+  --output-dir pkg/client/listers \
+  --output-pkg "${OUT_PKG}/listers" \
+  "${APIS[@]}"
+
+"${BIN}/informer-gen" \
+  --go-header-file "${HEADER}" \
+  --versioned-clientset-package "${OUT_PKG}/clientset/versioned" \
+  --listers-package "${OUT_PKG}/listers" \
+  --output-dir pkg/client/informers \
+  --output-pkg "${OUT_PKG}/informers" \
+  "${APIS[@]}"
@@ -0,0 +1,116 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	kubequeryv1beta1 "github.com/rsavage/KubeQuery/api/v1beta1"
+	"github.com/rsavage/KubeQuery/pkg/connection"
+	"github.com/rsavage/KubeQuery/pkg/db"
+	"github.com/rsavage/KubeQuery/pkg/redact"
+)
+
+// defaultApplicationName identifies the controller's sessions in
+// pg_stat_activity, or by the program_name connection attribute in MySQL.
+const defaultApplicationName = "kubequery"
+
+// connection returns the connection settings of pq: spec.connection, or the
+// spec of the PostgresConnection named by spec.connectionRef.
+func (r *PostgresQueryReconciler) connection(ctx context.Context, pq *kubequeryv1beta1.PostgresQuery) (*kubequeryv1beta1.PostgresConnectionSpec, error) {
+	switch {
+	case (pq.Spec.Connection == nil) == (pq.Spec.ConnectionRef == nil):
+		return nil, errors.New("exactly one of connection or connectionRef must be set")
+	case pq.Spec.Connection != nil:
+		return pq.Spec.Connection, nil
+	}
+	var pc kubequeryv1beta1.PostgresConnection
+	if err := r.Get(ctx, client.ObjectKey{Namespace: pq.Namespace, Name: pq.Spec.ConnectionRef.Name}, &pc); err != nil {
+		return nil, fmt.Errorf("failed to get PostgresConnection: %w", err)
+	}
+	return &pc.Spec, nil
+}
+
+// connConfig resolves conn, the connection settings of pq, including
+// credentials and TLS material, into a db.ConnConfig. Every password it obtains is
+// registered with red. Observed connection properties such as CA expiry are
+// recorded in pq.Status.Connection.
+func (r *PostgresQueryReconciler) connConfig(ctx context.Context, pq *kubequeryv1beta1.PostgresQuery, conn *kubequeryv1beta1.PostgresConnectionSpec, red *redact.Redactor) (db.ConnConfig, error) {
+	resolver := &connection.Resolver{
+		Reader:                  r.Client,
+		EnableCredentialPlugins: r.EnableCredentialPlugins,
+		AllowedTokenPaths:       r.AllowedTokenPaths,
+		AllowedExecCommands:     r.AllowedExecCommands,
+		ApplicationName:         defaultApplicationName,
+	}
+	dbCfg, status, err := resolver.Resolve(ctx, pq.Namespace, conn, red)
+	if status != nil {
+		pq.Status.Connection = status
+	}
+	if errors.Is(err, connection.ErrPluginsDisabled) {
+		return db.ConnConfig{}, fmt.Errorf("%w; start the manager with --enable-credential-plugins", err)
+	}
+	if errors.Is(err, connection.ErrPluginNotAllowed) {
+		return db.ConnConfig{}, fmt.Errorf("%w; add it to --credential-token-paths or --credential-exec-commands", err)
+	}
+	if err != nil {
+		return db.ConnConfig{}, err
+	}
+	if opts := pq.Spec.Options; opts != nil {
+		if dbCfg.Engine == db.EngineMySQL {
+			if err := setMySQLTimeouts(dbCfg.RuntimeParams, opts); err != nil {
+				return db.ConnConfig{}, err
+			}
+		} else {
+			setDurationParam(dbCfg.RuntimeParams, "lock_timeout", opts.LockTimeout)
+			setDurationParam(dbCfg.RuntimeParams, "statement_timeout", opts.StatementTimeout)
+			setDurationParam(dbCfg.RuntimeParams, "idle_in_transaction_session_timeout", opts.IdleInTransactionSessionTimeout)
+		}
+	}
+	return dbCfg, nil
+}
+
+// setDurationParam sets a server-side timeout parameter in milliseconds.
+func setDurationParam(params map[string]string, name string, d *metav1.Duration) {
+	if d != nil {
+		params[name] = fmt.Sprintf("%dms", d.Milliseconds())
+	}
+}
+
+// setMySQLTimeouts maps the timeout options to MySQL session variables.
+// lockTimeout applies to both row and metadata locks and is rounded up to
+// whole seconds; statementTimeout only applies to SELECT statements.
+func setMySQLTimeouts(params map[string]string, opts *kubequeryv1beta1.QueryOptions) error {
+	if opts.IdleInTransactionSessionTimeout != nil {
+		return errors.New("idleInTransactionSessionTimeout is not supported for MySQL")
+	}
+	if d := opts.LockTimeout; d != nil {
+		seconds := fmt.Sprint(int64(math.Max(1, math.Ceil(d.Seconds()))))
+		params["innodb_lock_wait_timeout"] = seconds
+		params["lock_wait_timeout"] = seconds
+	}
+	if d := opts.StatementTimeout; d != nil {
+		params["max_execution_time"] = fmt.Sprint(d.Milliseconds())
+	}
+	return nil
+}
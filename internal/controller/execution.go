@@ -0,0 +1,235 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+
+	kubequeryv1beta1 "github.com/rsavage/KubeQuery/api/v1beta1"
+	"github.com/rsavage/KubeQuery/pkg/db"
+	"github.com/rsavage/KubeQuery/pkg/redact"
+)
+
+// Defaults for retrying after lock_timeout errors.
+const (
+	defaultLockRetryAttempts = 3
+	defaultLockRetryBackoff  = 5 * time.Second
+)
+
+const (
+	// executionPollInterval is how often a running execution is checked for
+	// completion and cancel requests.
+	executionPollInterval = 5 * time.Second
+	// defaultCancelGracePeriod is how long a cancel request is given before
+	// the session is terminated, unless the reconciler overrides it.
+	defaultCancelGracePeriod = 30 * time.Second
+)
+
+// execution is a query running in the background, detached from the
+// reconcile that started it.
+type execution struct {
+	// uid identifies the PostgresQuery the execution belongs to, so that a
+	// re-created object with the same name is not confused with it.
+	uid types.UID
+	// hash is the idempotency hash of the SQL being executed.
+	hash string
+	// redactor knows the credentials used by the execution.
+	redactor *redact.Redactor
+	// explain is set if the result is the plan of spec.options.explain.
+	explain bool
+	pid     uint32
+	pool    db.Pool
+	done    chan struct{}
+
+	// result and err are set before done is closed.
+	result string
+	err    error
+
+	mu          sync.Mutex
+	cancelledAt time.Time
+	terminated  bool
+}
+
+// finished reports whether the query has returned.
+func (e *execution) finished() bool {
+	select {
+	case <-e.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// cancelled reports whether the query failed after a cancel request. A query
+// that succeeded despite a late cancel request is not considered cancelled.
+func (e *execution) cancelled() bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return !e.cancelledAt.IsZero() && e.err != nil
+}
+
+// requestCancel cancels the backend on the first call and terminates it if it
+// is still busy once grace has passed. It is safe to call on every poll.
+func (e *execution) requestCancel(ctx context.Context, grace time.Duration) {
+	log := logf.FromContext(ctx)
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.finished() {
+		return
+	}
+	switch {
+	case e.cancelledAt.IsZero():
+		log.Info("Cancelling running query", "pid", e.pid)
+		e.cancelledAt = time.Now()
+		if _, err := e.pool.Cancel(ctx, e.pid); err != nil {
+			log.Error(err, "Failed to cancel query", "pid", e.pid)
+		}
+	case !e.terminated && time.Since(e.cancelledAt) > grace:
+		log.Info("Query did not stop after cancel, terminating backend", "pid", e.pid)
+		e.terminated = true
+		if _, err := e.pool.Terminate(ctx, e.pid); err != nil {
+			log.Error(err, "Failed to terminate backend", "pid", e.pid)
+		}
+	}
+}
+
+// executionTracker holds the executions started by this manager process,
+// keyed by object name. It is not persisted: after a restart, queries recorded as running in status
+// but unknown here are reported as orphaned.
+type executionTracker struct {
+	mu      sync.Mutex
+	running map[types.NamespacedName]*execution
+	// orphanCancels records when cancellation of an orphaned backend was
+	// first requested, for escalation to termination.
+	orphanCancels map[types.UID]time.Time
+}
+
+func newExecutionTracker() *executionTracker {
+	return &executionTracker{
+		running:       map[types.NamespacedName]*execution{},
+		orphanCancels: map[types.UID]time.Time{},
+	}
+}
+
+// get returns the execution for key, or nil.
+func (t *executionTracker) get(key types.NamespacedName) *execution {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.running[key]
+}
+
+// remove forgets the execution for key once its outcome has been recorded.
+func (t *executionTracker) remove(key types.NamespacedName) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.running, key)
+}
+
+// start runs fn on conn in the background. ctx bounds the execution; once fn
+// returns, cancel is called, conn released and pool closed.
+func (t *executionTracker) start(ctx context.Context, cancel context.CancelFunc, pq *kubequeryv1beta1.PostgresQuery, hash string,
+	red *redact.Redactor, pool db.Pool, conn db.Conn, fn func(context.Context, db.Conn) (string, error)) *execution {
+	e := &execution{
+		uid:      pq.UID,
+		hash:     hash,
+		redactor: red,
+		explain:  pq.Spec.Options != nil && pq.Spec.Options.Explain != nil,
+		pid:      conn.SessionID(),
+		pool:     pool,
+		done:     make(chan struct{}),
+	}
+	t.mu.Lock()
+	t.running[client.ObjectKeyFromObject(pq)] = e
+	t.mu.Unlock()
+
+	go func() {
+		defer close(e.done)
+		defer cancel()
+		defer pool.Close()
+		defer conn.Release()
+		result, err := fn(ctx, conn)
+		e.mu.Lock()
+		e.result, e.err = result, err
+		e.mu.Unlock()
+	}()
+	return e
+}
+
+// orphanCancelRequested records a cancel request for an orphaned backend and
+// returns when the first request was made.
+func (t *executionTracker) orphanCancelRequested(uid types.UID) time.Time {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	first, ok := t.orphanCancels[uid]
+	if !ok {
+		first = time.Now()
+		t.orphanCancels[uid] = first
+	}
+	return first
+}
+
+// orphanCancelled reports whether a cancel was requested for the orphaned
+// backend of uid.
+func (t *executionTracker) orphanCancelled(uid types.UID) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	_, ok := t.orphanCancels[uid]
+	return ok
+}
+
+// forgetOrphan drops the cancel bookkeeping for uid.
+func (t *executionTracker) forgetOrphan(uid types.UID) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.orphanCancels, uid)
+}
+
+// execWithRetry executes sql, retrying after lock_timeout errors when the
+// query options ask for it. Backoff doubles after each attempt. notify, if
+// set, is called with a progress message before each retry.
+func execWithRetry(ctx context.Context, q db.Execer, sql string, opts *kubequeryv1beta1.QueryOptions, notify func(string)) (string, error) {
+	policy := lockRetryPolicy(opts)
+	policy.OnRetry = func(attempt int, backoff time.Duration) {
+		logf.FromContext(ctx).Info("Lock timeout, retrying", "attempt", attempt, "maxAttempts", policy.MaxAttempts, "backoff", backoff)
+		if notify != nil {
+			notify(fmt.Sprintf("Lock timeout on attempt %d of %d, retrying in %s", attempt, policy.MaxAttempts, backoff))
+		}
+	}
+	return db.ExecWithRetry(ctx, q, sql, policy)
+}
+
+// lockRetryPolicy translates options.retryOnLockTimeout, applying defaults.
+func lockRetryPolicy(opts *kubequeryv1beta1.QueryOptions) db.LockRetry {
+	policy := db.LockRetry{MaxAttempts: 1, Backoff: defaultLockRetryBackoff}
+	if opts != nil && opts.RetryOnLockTimeout != nil {
+		policy.MaxAttempts = defaultLockRetryAttempts
+		if opts.RetryOnLockTimeout.MaxAttempts != nil {
+			policy.MaxAttempts = *opts.RetryOnLockTimeout.MaxAttempts
+		}
+		if opts.RetryOnLockTimeout.Backoff != nil {
+			policy.Backoff = opts.RetryOnLockTimeout.Backoff.Duration
+		}
+	}
+	return policy
+}
@@ -0,0 +1,322 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+
+	kubequeryv1beta1 "github.com/rsavage/KubeQuery/api/v1beta1"
+	"github.com/rsavage/KubeQuery/pkg/redact"
+	"github.com/rsavage/KubeQuery/pkg/runner"
+)
+
+const (
+	// runnerContainerName is the container in the runner pod.
+	runnerContainerName = "runner"
+	// runnerSpecDir is where the runner Secret is mounted.
+	runnerSpecDir = "/etc/kubequery"
+	// runnerDeadlineSlack is added to the query timeout for the Job's
+	// activeDeadlineSeconds, covering pod start-up.
+	runnerDeadlineSlack = 5 * time.Minute
+	// queryNameLabel labels runner Jobs and pods with their PostgresQuery.
+	queryNameLabel = "kubequery.cloudnexus.io/query"
+)
+
+// reconcileJob drives a PostgresQuery with executionMode Job. The query runs
+// in a Job owned by the PostgresQuery; its outcome is read from the runner's
+// termination message once the Job has finished.
+func (r *PostgresQueryReconciler) reconcileJob(ctx context.Context, pq *kubequeryv1beta1.PostgresQuery, conn *kubequeryv1beta1.PostgresConnectionSpec, sql, hash string) (ctrl.Result, error) {
+	name := runnerName(pq.Name, hash)
+	var job batchv1.Job
+	err := r.Get(ctx, client.ObjectKey{Namespace: pq.Namespace, Name: name}, &job)
+	if apierrors.IsNotFound(err) {
+		if pq.Status.Phase == kubequeryv1beta1.PhaseRunning && pq.Status.JobName == name {
+			if err := r.deleteRunnerSecret(ctx, pq.Namespace, name); err != nil {
+				return ctrl.Result{}, err
+			}
+			return r.updateStatus(ctx, pq, false, fmt.Sprintf("runner job %s was deleted before it finished", name), "", hash)
+		}
+		if pq.CancelRequested() {
+			return r.markCancelled(ctx, pq, hash, "query cancelled before execution")
+		}
+		return r.createRunnerJob(ctx, pq, conn, name, sql, hash)
+	}
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	if failed, finished := jobFinished(&job); finished {
+		if err := r.deleteRunnerSecret(ctx, pq.Namespace, name); err != nil {
+			return ctrl.Result{}, err
+		}
+		return r.collectJobResult(ctx, pq, &job, failed, hash)
+	}
+	if pq.CancelRequested() {
+		// Deleting the Job stops the pod; the runner cancels the query on SIGTERM.
+		if err := r.Delete(ctx, &job, client.PropagationPolicy(metav1.DeletePropagationBackground)); client.IgnoreNotFound(err) != nil {
+			return ctrl.Result{}, err
+		}
+		if err := r.deleteRunnerSecret(ctx, pq.Namespace, name); err != nil {
+			return ctrl.Result{}, err
+		}
+		return r.markCancelled(ctx, pq, hash, "query cancelled while running")
+	}
+	return ctrl.Result{RequeueAfter: executionPollInterval}, nil
+}
+
+// createRunnerJob stores the runner spec in a Secret and creates the Job
+// that executes it. Both are owned by pq; the Secret, which holds the
+// database credentials, is deleted as soon as the Job has finished or been
+// cancelled.
+func (r *PostgresQueryReconciler) createRunnerJob(ctx context.Context, pq *kubequeryv1beta1.PostgresQuery, conn *kubequeryv1beta1.PostgresConnectionSpec, name, sql, hash string) (ctrl.Result, error) {
+	if r.RunnerImage == "" {
+		return r.updateStatus(ctx, pq, false, "executionMode Job requires the manager to be started with --runner-image", "", hash)
+	}
+	if auth := conn.Auth; auth != nil && (auth.Exec != nil || (auth.Token != nil && auth.Token.Path != "")) {
+		return r.updateStatus(ctx, pq, false, "token path and exec authentication are not supported with executionMode Job", "", hash)
+	}
+
+	red := redact.FromContext(ctx)
+	dbCfg, err := r.connConfig(ctx, pq, conn, red)
+	if err != nil {
+		return r.updateStatus(ctx, pq, false, err.Error(), "", hash)
+	}
+	runnerConn, err := runner.NewConnection(ctx, dbCfg)
+	if err != nil {
+		return r.updateStatus(ctx, pq, false, err.Error(), "", hash)
+	}
+	timeout := 30 * time.Second
+	if pq.Spec.Options != nil && pq.Spec.Options.TimeoutSeconds != nil {
+		timeout = time.Duration(*pq.Spec.Options.TimeoutSeconds) * time.Second
+	}
+	policy := lockRetryPolicy(pq.Spec.Options)
+	spec, err := json.Marshal(runner.Spec{
+		Connection:        runnerConn,
+		SQL:               sql,
+		Timeout:           timeout,
+		LockRetryAttempts: policy.MaxAttempts,
+		LockRetryBackoff:  policy.Backoff,
+		RedactSQLLiterals: red.SQLLiterals,
+	})
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: pq.Namespace, Labels: runnerLabels(pq)},
+		Data:       map[string][]byte{runner.SpecKey: spec},
+	}
+	if err := controllerutil.SetControllerReference(pq, secret, r.Scheme); err != nil {
+		return ctrl.Result{}, err
+	}
+	if err := r.Create(ctx, secret); apierrors.IsAlreadyExists(err) {
+		// Left over from an attempt whose Job was never created.
+		var existing corev1.Secret
+		if err := r.Get(ctx, client.ObjectKeyFromObject(secret), &existing); err != nil {
+			return ctrl.Result{}, err
+		}
+		existing.Data = secret.Data
+		if err := r.Update(ctx, &existing); err != nil {
+			return ctrl.Result{}, err
+		}
+	} else if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	job := r.runnerJob(pq, name, timeout)
+	if err := controllerutil.SetControllerReference(pq, job, r.Scheme); err != nil {
+		return ctrl.Result{}, err
+	}
+	if err := r.Create(ctx, job); err != nil && !apierrors.IsAlreadyExists(err) {
+		return ctrl.Result{}, err
+	}
+
+	setRunning(pq, hash, fmt.Sprintf("Runner job %s created", name))
+	pq.Status.JobName = name
+	pq.Status.BackendPID = 0
+	if err := r.writeStatus(ctx, pq); err != nil {
+		return ctrl.Result{}, err
+	}
+	r.event(pq, corev1.EventTypeNormal, "Started", fmt.Sprintf("Created runner job %s", name))
+	return ctrl.Result{RequeueAfter: executionPollInterval}, nil
+}
+
+// deleteRunnerSecret deletes the runner Secret name, which holds the resolved
+// credentials, once its Job no longer needs it.
+func (r *PostgresQueryReconciler) deleteRunnerSecret(ctx context.Context, namespace, name string) error {
+	secret := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace}}
+	if err := r.Delete(ctx, secret); client.IgnoreNotFound(err) != nil {
+		return fmt.Errorf("failed to delete runner secret: %w", err)
+	}
+	return nil
+}
+
+// runnerJob builds the Job that runs kubequery-runner for pq. The Job is
+// never retried: the SQL may not be safe to run twice.
+func (r *PostgresQueryReconciler) runnerJob(pq *kubequeryv1beta1.PostgresQuery, name string, timeout time.Duration) *batchv1.Job {
+	podLabels := runnerLabels(pq)
+	container := corev1.Container{
+		Name:                     runnerContainerName,
+		Image:                    r.RunnerImage,
+		Command:                  []string{"/kubequery-runner"},
+		Args:                     []string{"--spec=" + runnerSpecDir + "/" + runner.SpecKey},
+		TerminationMessagePolicy: corev1.TerminationMessageFallbackToLogsOnError,
+		VolumeMounts:             []corev1.VolumeMount{{Name: "spec", MountPath: runnerSpecDir, ReadOnly: true}},
+		SecurityContext: &corev1.SecurityContext{
+			AllowPrivilegeEscalation: ptr.To(false),
+			ReadOnlyRootFilesystem:   ptr.To(true),
+			Capabilities:             &corev1.Capabilities{Drop: []corev1.Capability{"ALL"}},
+		},
+	}
+	var serviceAccount string
+	if opts := pq.Spec.Job; opts != nil {
+		if opts.Resources != nil {
+			container.Resources = *opts.Resources
+		}
+		serviceAccount = opts.ServiceAccountName
+		for k, v := range opts.PodLabels {
+			if _, reserved := podLabels[k]; !reserved {
+				podLabels[k] = v
+			}
+		}
+	}
+
+	return &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: pq.Namespace, Labels: runnerLabels(pq)},
+		Spec: batchv1.JobSpec{
+			BackoffLimit:          ptr.To[int32](0),
+			ActiveDeadlineSeconds: ptr.To(int64((timeout + runnerDeadlineSlack) / time.Second)),
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{Labels: podLabels},
+				Spec: corev1.PodSpec{
+					RestartPolicy:                corev1.RestartPolicyNever,
+					ServiceAccountName:           serviceAccount,
+					AutomountServiceAccountToken: ptr.To(false),
+					SecurityContext: &corev1.PodSecurityContext{
+						RunAsNonRoot:   ptr.To(true),
+						SeccompProfile: &corev1.SeccompProfile{Type: corev1.SeccompProfileTypeRuntimeDefault},
+					},
+					Containers: []corev1.Container{container},
+					Volumes: []corev1.Volume{{
+						Name: "spec",
+						VolumeSource: corev1.VolumeSource{Secret: &corev1.SecretVolumeSource{
+							SecretName:  name,
+							DefaultMode: ptr.To[int32](0o400),
+						}},
+					}},
+				},
+			},
+		},
+	}
+}
+
+// collectJobResult records the outcome reported by a finished runner Job.
+func (r *PostgresQueryReconciler) collectJobResult(ctx context.Context, pq *kubequeryv1beta1.PostgresQuery, job *batchv1.Job, failed string, hash string) (ctrl.Result, error) {
+	res, err := r.runnerResult(ctx, job)
+	if err != nil {
+		logf.FromContext(ctx).Info("Runner result unavailable", "job", job.Name, "error", err.Error())
+		if failed == "" {
+			failed = "runner job completed without reporting a result"
+		}
+		return r.updateStatus(ctx, pq, false, fmt.Sprintf("runner job %s failed: %s", job.Name, failed), "", hash)
+	}
+	pq.Status.BackendPID = int32(res.BackendPID)
+	if !res.Succeeded && res.Error == "" {
+		res.Error = fmt.Sprintf("runner job %s failed: %s", job.Name, failed)
+	}
+	return r.updateStatus(ctx, pq, res.Succeeded, res.Error, res.Result, hash)
+}
+
+// runnerResult reads the termination message of the most recent runner pod.
+func (r *PostgresQueryReconciler) runnerResult(ctx context.Context, job *batchv1.Job) (runner.Result, error) {
+	var pods corev1.PodList
+	if err := r.List(ctx, &pods, client.InNamespace(job.Namespace), client.MatchingLabels{batchv1.JobNameLabel: job.Name}); err != nil {
+		return runner.Result{}, err
+	}
+	sort.Slice(pods.Items, func(i, j int) bool {
+		return pods.Items[j].CreationTimestamp.Before(&pods.Items[i].CreationTimestamp)
+	})
+	for _, pod := range pods.Items {
+		for _, cs := range pod.Status.ContainerStatuses {
+			if cs.Name == runnerContainerName && cs.State.Terminated != nil {
+				return runner.DecodeResult(cs.State.Terminated.Message)
+			}
+		}
+	}
+	return runner.Result{}, errors.New("no terminated runner pod found")
+}
+
+// jobFinished reports whether job has completed or failed. For a failed Job
+// it also returns the failure message.
+func jobFinished(job *batchv1.Job) (failed string, finished bool) {
+	for _, c := range job.Status.Conditions {
+		if c.Status != corev1.ConditionTrue {
+			continue
+		}
+		switch c.Type {
+		case batchv1.JobComplete:
+			return "", true
+		case batchv1.JobFailed:
+			if c.Message != "" {
+				return c.Message, true
+			}
+			return c.Reason, true
+		}
+	}
+	return "", false
+}
+
+// runnerName names the runner Job and Secret of a query. The hash suffix
+// gives every distinct execution its own Job; the result stays a valid label
+// value.
+func runnerName(name, hash string) string {
+	return truncateLabelValue(name, 63-1-10) + "-" + hash[:10]
+}
+
+// truncateLabelValue shortens s to at most n bytes without leaving a
+// trailing separator, which names and label values may not end with.
+func truncateLabelValue(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return strings.TrimRight(s[:n], "-.")
+}
+
+// runnerLabels returns the labels set on runner Jobs, pods and Secrets.
+func runnerLabels(pq *kubequeryv1beta1.PostgresQuery) map[string]string {
+	return map[string]string{
+		"app.kubernetes.io/name":       "kubequery-runner",
+		"app.kubernetes.io/managed-by": "kubequery",
+		queryNameLabel:                 truncateLabelValue(pq.Name, 63),
+	}
+}
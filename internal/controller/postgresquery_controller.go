@@ -20,165 +20,583 @@ import (
 	"context"
 	"crypto/sha256"
 	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
-	"os"
-	"path/filepath"
+	"sync"
 	"time"
 
+	batchv1 "k8s.io/api/batch/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/retry"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
 
-	kubequeryv1alpha1 "github.com/rsavage/KubeQuery/api/v1alpha1"
+	kubequeryv1beta1 "github.com/rsavage/KubeQuery/api/v1beta1"
 	corev1 "k8s.io/api/core/v1"
 
 	"github.com/rsavage/KubeQuery/pkg/db"
+	"github.com/rsavage/KubeQuery/pkg/explain"
+	"github.com/rsavage/KubeQuery/pkg/redact"
 )
 
+// orphanPollInterval is how often the backend of an orphaned execution is
+// checked while it is still running.
+const orphanPollInterval = 30 * time.Second
+
+// maxPlanSize is the largest plan recorded in status.plan, which keeps a
+// plan of a complex query from pushing the object towards the etcd size
+// limit.
+const maxPlanSize = 256 << 10
+
 // PostgresQueryReconciler reconciles a PostgresQuery object
 type PostgresQueryReconciler struct {
 	client.Client
 	Scheme *runtime.Scheme
+	// Recorder emits events on PostgresQuery objects (optional).
+	Recorder record.EventRecorder
+	// EnableCredentialPlugins allows token files and exec plugins that run
+	// inside the manager pod to be used for database authentication.
+	EnableCredentialPlugins bool
+	// AllowedTokenPaths and AllowedExecCommands restrict the token files and
+	// exec commands that credential plugins may use.
+	AllowedTokenPaths   []string
+	AllowedExecCommands []string
+	// RunnerImage is the kubequery-runner image used for executionMode Job.
+	RunnerImage string
+	// Drivers overrides the database driver of an engine, e.g. in tests.
+	// Engines without an entry use the built-in driver from package db.
+	Drivers map[db.Engine]db.Driver
+	// CancelGracePeriod is how long a cancelled query is given to stop
+	// before its backend is terminated. Zero means 30 seconds.
+	CancelGracePeriod time.Duration
+
+	trackerOnce sync.Once
+	executions  *executionTracker
 }
 
 // +kubebuilder:rbac:groups=kubequery.cloudnexus.io,resources=postgresqueries,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=kubequery.cloudnexus.io,resources=postgresqueries/status,verbs=get;update;patch
 // +kubebuilder:rbac:groups=kubequery.cloudnexus.io,resources=postgresqueries/finalizers,verbs=update
+// +kubebuilder:rbac:groups=kubequery.cloudnexus.io,resources=postgresconnections,verbs=get;list;watch
+// +kubebuilder:rbac:groups=core,resources=events,verbs=create;patch
+// +kubebuilder:rbac:groups=core,resources=secrets,verbs=get;list;watch;create;update;delete
+// +kubebuilder:rbac:groups=core,resources=configmaps,verbs=get;list;watch
+// +kubebuilder:rbac:groups=core,resources=pods,verbs=get;list;watch
+// +kubebuilder:rbac:groups=batch,resources=jobs,verbs=get;list;watch;create;delete
 
 // Reconcile is part of the main kubernetes reconciliation loop which aims to
 // move the current state of the cluster closer to the desired state.
-// TODO(user): Modify the Reconcile function to compare the state specified by
-// the PostgresQuery object against the actual cluster state, and then
-// perform operations to make the cluster state reflect the state specified by
-// the user.
+//
+// Queries run in the background: Reconcile starts the query, records the
+// backend PID and start time in status and requeues until the query has
+// finished. A query recorded as running that this process did not start is
+// reported as orphaned and is never re-run.
 //
 // For more details, check Reconcile and its Result here:
 // - https://pkg.go.dev/sigs.k8s.io/controller-runtime@v0.20.4/pkg/reconcile
 func (r *PostgresQueryReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
 	log := logf.FromContext(ctx)
 
-	var pq kubequeryv1alpha1.PostgresQuery
+	running := r.tracker().get(req.NamespacedName)
+
+	var pq kubequeryv1beta1.PostgresQuery
 	if err := r.Get(ctx, req.NamespacedName, &pq); err != nil {
+		if apierrors.IsNotFound(err) && running != nil {
+			// The object was deleted while its query was running; keep
+			// polling so the execution is forgotten once it finishes.
+			return r.forgetStale(req.NamespacedName, running)
+		}
 		return ctrl.Result{}, client.IgnoreNotFound(err)
 	}
+	if running != nil && running.uid != pq.UID {
+		return r.forgetStale(req.NamespacedName, running)
+	}
+	if running != nil {
+		return r.reconcileRunning(ctx, &pq, running)
+	}
 
-	// --- Load SQL from Secret or ConfigMap if specified ---
-	sql := pq.Spec.SQL
-	if pq.Spec.SQLSecretRef != nil {
-		var sqlSecret corev1.Secret
-		if err := r.Get(ctx, client.ObjectKey{Namespace: pq.Namespace, Name: pq.Spec.SQLSecretRef.Name}, &sqlSecret); err != nil {
-			return r.updateStatus(ctx, &pq, false, fmt.Sprintf("failed to get sql secret: %v", err), "", "")
-		}
-		val, ok := sqlSecret.Data[pq.Spec.SQLSecretRef.Key]
-		if !ok {
-			return r.updateStatus(ctx, &pq, false, "sql key not found in secret", "", "")
-		}
-		sql = string(val)
-	} else if pq.Spec.SQLConfigMapRef != nil {
-		var sqlCM corev1.ConfigMap
-		if err := r.Get(ctx, client.ObjectKey{Namespace: pq.Namespace, Name: pq.Spec.SQLConfigMapRef.Name}, &sqlCM); err != nil {
-			return r.updateStatus(ctx, &pq, false, fmt.Sprintf("failed to get sql configmap: %v", err), "", "")
-		}
-		val, ok := sqlCM.Data[pq.Spec.SQLConfigMapRef.Key]
-		if !ok {
-			return r.updateStatus(ctx, &pq, false, "sql key not found in configmap", "", "")
-		}
-		sql = val
+	// Every message written to status or logs below passes through this redactor.
+	red := redact.New()
+	red.SQLLiterals = pq.Spec.Options != nil && pq.Spec.Options.RedactSQLLiterals
+	ctx = redact.NewContext(ctx, red)
+
+	sql, err := r.loadSQL(ctx, &pq)
+	if err != nil {
+		return r.updateStatus(ctx, &pq, false, err.Error(), "", "")
+	}
+	conn, err := r.connection(ctx, &pq)
+	if err != nil {
+		return r.updateStatus(ctx, &pq, false, err.Error(), "", "")
+	}
+	explainOpts := explainOptions(&pq)
+	if explainOpts != nil && pq.Spec.ExecutionMode == kubequeryv1beta1.ExecutionModeJob {
+		return r.updateStatus(ctx, &pq, false, "options.explain is not supported with executionMode Job", "", "")
 	}
 
 	// Compute idempotency hash (use loaded SQL)
 	hash := sha256.New()
-	hash.Write([]byte(fmt.Sprintf("%s|%d|%s|%s|%s", pq.Spec.Connection.Host, pq.Spec.Connection.Port, pq.Spec.Connection.Database, pq.Spec.Connection.User, sql)))
+	hash.Write([]byte(fmt.Sprintf("%s|%d|%s|%s|%s", conn.Host, conn.Port, conn.Database, conn.User, sql)))
+	// Explaining a query is not executing it, so the two hash differently.
+	if explainOpts != nil {
+		hash.Write([]byte(fmt.Sprintf("|explain analyze=%t buffers=%t", explainOpts.Analyze, explainOpts.Buffers)))
+	}
 	idempotencyHash := hex.EncodeToString(hash.Sum(nil))
 
 	// If already executed, skip
-	if pq.Status.Executed && pq.Status.IdempotencyHash == idempotencyHash {
+	if pq.Status.Phase == kubequeryv1beta1.PhaseSucceeded && pq.Status.IdempotencyHash == idempotencyHash {
 		log.Info("Query already executed, skipping", "name", pq.Name)
 		return ctrl.Result{}, nil
 	}
 
-	// Fetch password from secret
-	var pwSecret corev1.Secret
-	if err := r.Get(ctx, client.ObjectKey{Namespace: pq.Namespace, Name: pq.Spec.Connection.PasswordSecretRef.Name}, &pwSecret); err != nil {
-		return r.updateStatus(ctx, &pq, false, fmt.Sprintf("failed to get password secret: %v", err), "", idempotencyHash)
-	}
-	password, ok := pwSecret.Data[pq.Spec.Connection.PasswordSecretRef.Key]
-	if !ok {
-		return r.updateStatus(ctx, &pq, false, "password key not found in secret", "", idempotencyHash)
+	// A cancelled query is never re-run; create a new PostgresQuery instead.
+	if pq.Status.Phase == kubequeryv1beta1.PhaseCancelled && pq.Status.IdempotencyHash == idempotencyHash {
+		return ctrl.Result{}, nil
 	}
 
-	// Handle SSL config
-	var sslCfg *db.SSLConfig
-	if pq.Spec.Connection.SSL != nil && pq.Spec.Connection.SSL.Mode != "disable" {
-		sslCfg = &db.SSLConfig{Mode: pq.Spec.Connection.SSL.Mode}
-		if pq.Spec.Connection.SSL.CaSecretRef != nil {
-			var caSecret corev1.Secret
-			if err := r.Get(ctx, client.ObjectKey{Namespace: pq.Namespace, Name: pq.Spec.Connection.SSL.CaSecretRef.Name}, &caSecret); err != nil {
-				return r.updateStatus(ctx, &pq, false, fmt.Sprintf("failed to get CA secret: %v", err), "", idempotencyHash)
-			}
-			ca, ok := caSecret.Data[pq.Spec.Connection.SSL.CaSecretRef.Key]
-			if !ok {
-				return r.updateStatus(ctx, &pq, false, "CA key not found in secret", "", idempotencyHash)
-			}
-			// Write CA to a temp file
-			tmpDir := os.TempDir()
-			caPath := filepath.Join(tmpDir, fmt.Sprintf("ca-%s.crt", pq.Name))
-			if err := os.WriteFile(caPath, ca, 0600); err != nil {
-				return r.updateStatus(ctx, &pq, false, fmt.Sprintf("failed to write CA file: %v", err), "", idempotencyHash)
+	inFlight := (pq.Status.Phase == kubequeryv1beta1.PhaseRunning || pq.Status.Phase == kubequeryv1beta1.PhaseOrphaned) &&
+		pq.Status.IdempotencyHash == idempotencyHash
+	if !inFlight {
+		approval, err := approvalHash(idempotencyHash, conn, &pq.Spec)
+		if err != nil {
+			return r.updateStatus(ctx, &pq, false, err.Error(), "", "")
+		}
+		if !pq.Approved(approval) {
+			if pq.CancelRequested() {
+				return r.markCancelled(ctx, &pq, idempotencyHash, "query cancelled before execution")
 			}
-			sslCfg.CAPath = caPath
+			return r.markPendingApproval(ctx, &pq, idempotencyHash, approval)
+		}
+	}
+
+	if pq.Spec.ExecutionMode == kubequeryv1beta1.ExecutionModeJob {
+		return r.reconcileJob(ctx, &pq, conn, sql, idempotencyHash)
+	}
+
+	// Running in status but not in this process: the manager that started
+	// the query has gone away. Its outcome is unknown, so it is not re-run.
+	if inFlight {
+		return r.reconcileOrphan(ctx, &pq, conn)
+	}
+
+	if pq.CancelRequested() {
+		return r.markCancelled(ctx, &pq, idempotencyHash, "query cancelled before execution")
+	}
+
+	dbCfg, err := r.connConfig(ctx, &pq, conn, red)
+	if err != nil {
+		return r.updateStatus(ctx, &pq, false, err.Error(), "", idempotencyHash)
+	}
+	if explainOpts != nil && dbCfg.Engine == db.EngineMySQL {
+		return r.updateStatus(ctx, &pq, false, "options.explain is not supported for MySQL", "", idempotencyHash)
+	}
+	return r.startExecution(ctx, &pq, dbCfg, sql, idempotencyHash)
+}
+
+// approvalHash returns the hash an approval of pq binds to: idempotencyHash
+// extended with every other setting that affects how the query runs, so
+// that changing any of them after approval requires a new one.
+func approvalHash(idempotencyHash string, conn *kubequeryv1beta1.PostgresConnectionSpec, spec *kubequeryv1beta1.PostgresQuerySpec) (string, error) {
+	settings, err := json.Marshal(struct {
+		Connection    *kubequeryv1beta1.PostgresConnectionSpec `json:"connection"`
+		Options       *kubequeryv1beta1.QueryOptions           `json:"options"`
+		ExecutionMode kubequeryv1beta1.ExecutionMode           `json:"executionMode"`
+		Job           *kubequeryv1beta1.JobOptions             `json:"job"`
+	}{conn, spec.Options, spec.ExecutionMode, spec.Job})
+	if err != nil {
+		return "", fmt.Errorf("failed to compute approval hash: %w", err)
+	}
+	hash := sha256.New()
+	hash.Write([]byte(idempotencyHash + "|"))
+	hash.Write(settings)
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// loadSQL returns the SQL of pq from its sqlSource.
+func (r *PostgresQueryReconciler) loadSQL(ctx context.Context, pq *kubequeryv1beta1.PostgresQuery) (string, error) {
+	src := pq.Spec.SQLSource
+	sources := 0
+	for _, set := range []bool{src.Inline != "", src.ConfigMapKeyRef != nil, src.SecretKeyRef != nil} {
+		if set {
+			sources++
 		}
 	}
+	switch {
+	case sources != 1:
+		return "", errors.New("sqlSource must set exactly one of inline, configMapKeyRef or secretKeyRef")
+	case src.SecretKeyRef != nil:
+		var sqlSecret corev1.Secret
+		if err := r.Get(ctx, client.ObjectKey{Namespace: pq.Namespace, Name: src.SecretKeyRef.Name}, &sqlSecret); err != nil {
+			return "", fmt.Errorf("failed to get sql secret: %w", err)
+		}
+		val, ok := sqlSecret.Data[src.SecretKeyRef.Key]
+		if !ok {
+			return "", errors.New("sql key not found in secret")
+		}
+		return string(val), nil
+	case src.ConfigMapKeyRef != nil:
+		var sqlCM corev1.ConfigMap
+		if err := r.Get(ctx, client.ObjectKey{Namespace: pq.Namespace, Name: src.ConfigMapKeyRef.Name}, &sqlCM); err != nil {
+			return "", fmt.Errorf("failed to get sql configmap: %w", err)
+		}
+		val, ok := sqlCM.Data[src.ConfigMapKeyRef.Key]
+		if !ok {
+			return "", errors.New("sql key not found in configmap")
+		}
+		return val, nil
+	default:
+		return src.Inline, nil
+	}
+}
 
-	// Prepare DB config
-	dbCfg := db.ConnConfig{
-		Host:     pq.Spec.Connection.Host,
-		Port:     pq.Spec.Connection.Port,
-		Database: pq.Spec.Connection.Database,
-		User:     pq.Spec.Connection.User,
-		Password: string(password),
-		SSL:      sslCfg,
+// connect opens a pool for dbCfg with the driver of its engine.
+func (r *PostgresQueryReconciler) connect(ctx context.Context, dbCfg db.ConnConfig) (db.Pool, error) {
+	engine := dbCfg.Engine
+	if engine == "" {
+		engine = db.EnginePostgres
 	}
+	if d, ok := r.Drivers[engine]; ok {
+		return d.Connect(ctx, dbCfg)
+	}
+	return db.Connect(ctx, dbCfg)
+}
+
+// cancelGracePeriod returns r.CancelGracePeriod, applying the default.
+func (r *PostgresQueryReconciler) cancelGracePeriod() time.Duration {
+	if r.CancelGracePeriod > 0 {
+		return r.CancelGracePeriod
+	}
+	return defaultCancelGracePeriod
+}
+
+// tracker returns the executions started by this reconciler.
+func (r *PostgresQueryReconciler) tracker() *executionTracker {
+	r.trackerOnce.Do(func() { r.executions = newExecutionTracker() })
+	return r.executions
+}
+
+// startExecution connects to the database, records the backend PID in status
+// and runs sql in the background. The execution is bounded by
+// options.timeoutSeconds but not by ctx, so it outlives this reconcile.
+func (r *PostgresQueryReconciler) startExecution(ctx context.Context, pq *kubequeryv1beta1.PostgresQuery, dbCfg db.ConnConfig, sql, hash string) (ctrl.Result, error) {
+	red := redact.FromContext(ctx)
 
 	// Set timeout
 	timeout := 30 * time.Second
 	if pq.Spec.Options != nil && pq.Spec.Options.TimeoutSeconds != nil {
 		timeout = time.Duration(*pq.Spec.Options.TimeoutSeconds) * time.Second
 	}
-	ctxTimeout, cancel := context.WithTimeout(ctx, timeout)
-	defer cancel()
+	execCtx := redact.NewContext(logf.IntoContext(context.Background(), logf.FromContext(ctx)), red)
+	execCtx, cancel := context.WithTimeout(execCtx, timeout)
+
+	pool, err := r.connect(execCtx, dbCfg)
+	if err != nil {
+		cancel()
+		return r.updateStatus(ctx, pq, false, fmt.Sprintf("db connect error: %v", err), "", hash)
+	}
+
+	// Pin a connection so that its backend PID can be recorded and signalled.
+	conn, err := pool.Acquire(execCtx)
+	if err != nil {
+		pool.Close()
+		cancel()
+		return r.updateStatus(ctx, pq, false, fmt.Sprintf("db connect error: %v", err), "", hash)
+	}
+
+	pid := conn.SessionID()
+	setRunning(pq, hash, fmt.Sprintf("Query started on backend %d", pid))
+	pq.Status.BackendPID = int32(pid)
+	if err := r.writeStatus(ctx, pq); err != nil {
+		conn.Release()
+		pool.Close()
+		cancel()
+		return ctrl.Result{}, err
+	}
 
-	pool, err := db.Connect(ctxTimeout, dbCfg)
+	opts := pq.Spec.Options
+	// Retries are reported as events on a copy, as pq is not used after
+	// this reconcile returns but the execution outlives it.
+	ref := pq.DeepCopy()
+	notify := func(msg string) { r.event(ref, corev1.EventTypeWarning, "LockTimeout", msg) }
+	run := func(ctx context.Context, conn db.Conn) (string, error) {
+		return execWithRetry(ctx, conn, sql, opts, notify)
+	}
+	if explainOpts := explainOptions(pq); explainOpts != nil {
+		// Without ANALYZE nothing is executed, so the plan is read-only.
+		run = func(ctx context.Context, conn db.Conn) (string, error) {
+			plan, err := explain.Run(ctx, conn, sql, nil, *explainOpts, !explainOpts.Analyze)
+			return string(plan), err
+		}
+	}
+	r.tracker().start(execCtx, cancel, pq, hash, red, pool, conn, run)
+	r.event(pq, corev1.EventTypeNormal, "Started", fmt.Sprintf("Query started on backend %d", pq.Status.BackendPID))
+	return ctrl.Result{RequeueAfter: executionPollInterval}, nil
+}
+
+// reconcileRunning records the outcome of a finished execution, or forwards
+// a cancel request to one that is still running.
+func (r *PostgresQueryReconciler) reconcileRunning(ctx context.Context, pq *kubequeryv1beta1.PostgresQuery, e *execution) (ctrl.Result, error) {
+	ctx = redact.NewContext(ctx, e.redactor)
+	if !e.finished() {
+		if pq.CancelRequested() {
+			e.requestCancel(ctx, r.cancelGracePeriod())
+		}
+		return ctrl.Result{RequeueAfter: executionPollInterval}, nil
+	}
+
+	r.tracker().remove(client.ObjectKeyFromObject(pq))
+	if e.cancelled() {
+		return r.markCancelled(ctx, pq, e.hash, "query cancelled while running")
+	}
+	if e.err != nil {
+		return r.updateStatus(ctx, pq, false, fmt.Sprintf("sql exec error: %v", e.err), "", e.hash)
+	}
+	if e.explain {
+		return r.recordPlan(ctx, pq, e)
+	}
+	return r.updateStatus(ctx, pq, true, "", e.result, e.hash)
+}
+
+// recordPlan stores the plan returned by an explain execution in status,
+// with its summary as the result.
+func (r *PostgresQueryReconciler) recordPlan(ctx context.Context, pq *kubequeryv1beta1.PostgresQuery, e *execution) (ctrl.Result, error) {
+	// The plan repeats literals of the SQL in filter conditions.
+	data := redact.FromContext(ctx).String(e.result)
+	plan, err := explain.Parse([]byte(data))
 	if err != nil {
-		return r.updateStatus(ctx, &pq, false, fmt.Sprintf("db connect error: %v", err), "", idempotencyHash)
+		return r.updateStatus(ctx, pq, false, fmt.Sprintf("explain error: %v", err), "", e.hash)
+	}
+	result := plan.Summary()
+	if len(data) > maxPlanSize {
+		result += fmt.Sprintf("; plan of %d bytes not recorded, over the %d byte limit", len(data), maxPlanSize)
+		data = ""
+	}
+	pq.Status.Plan = data
+	return r.updateStatus(ctx, pq, true, "", result, e.hash)
+}
+
+// explainOptions returns the EXPLAIN options of pq, or nil if it is to be
+// executed.
+func explainOptions(pq *kubequeryv1beta1.PostgresQuery) *explain.Options {
+	opts := pq.Spec.Options
+	if opts == nil || opts.Explain == nil {
+		return nil
+	}
+	explainOpts := &explain.Options{Analyze: opts.Explain.Analyze, Buffers: opts.Explain.Buffers}
+	if opts.StatementTimeout != nil {
+		explainOpts.StatementTimeout = opts.StatementTimeout.Duration
+	}
+	if opts.LockTimeout != nil {
+		explainOpts.LockTimeout = opts.LockTimeout.Duration
+	}
+	return explainOpts
+}
+
+// forgetStale drops a tracked execution whose object has been deleted or
+// re-created once it has finished.
+func (r *PostgresQueryReconciler) forgetStale(key types.NamespacedName, e *execution) (ctrl.Result, error) {
+	if !e.finished() {
+		return ctrl.Result{RequeueAfter: executionPollInterval}, nil
+	}
+	r.tracker().remove(key)
+	return ctrl.Result{}, nil
+}
+
+// reconcileOrphan reports a query that status records as running but that
+// this process is not tracking. The backend is looked up on the server
+// so the status says whether it is still running; cancel requests are
+// honoured while it is.
+func (r *PostgresQueryReconciler) reconcileOrphan(ctx context.Context, pq *kubequeryv1beta1.PostgresQuery, conn *kubequeryv1beta1.PostgresConnectionSpec) (ctrl.Result, error) {
+	red := redact.FromContext(ctx)
+	pid := uint32(pq.Status.BackendPID)
+	if pid == 0 || pq.Status.StartTime == nil {
+		return r.markOrphaned(ctx, pq, "query was interrupted before it started; its outcome is unknown")
+	}
+
+	dbCfg, err := r.connConfig(ctx, pq, conn, red)
+	if err != nil {
+		return r.orphanCheckFailed(ctx, pq, err)
+	}
+	ctxTimeout, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+	pool, err := r.connect(ctxTimeout, dbCfg)
+	if err != nil {
+		return r.orphanCheckFailed(ctx, pq, err)
 	}
 	defer pool.Close()
 
-	result, err := db.ExecSQL(ctxTimeout, pool, sql)
+	active, err := pool.SessionActive(ctxTimeout, pid, dbCfg.RuntimeParams["application_name"], pq.Status.StartTime.Time)
 	if err != nil {
-		return r.updateStatus(ctx, &pq, false, fmt.Sprintf("sql exec error: %v", err), "", idempotencyHash)
+		return r.orphanCheckFailed(ctx, pq, err)
+	}
+
+	if !active {
+		cancelled := r.tracker().orphanCancelled(pq.UID)
+		r.tracker().forgetOrphan(pq.UID)
+		if cancelled {
+			return r.markCancelled(ctx, pq, pq.Status.IdempotencyHash, "orphaned query cancelled")
+		}
+		pq.Status.CompletionTime = &metav1.Time{Time: time.Now()}
+		return r.markOrphaned(ctx, pq, fmt.Sprintf("backend %d is no longer running; the query's outcome is unknown", pid))
+	}
+
+	if pq.CancelRequested() {
+		log := logf.FromContext(ctx)
+		if first := r.tracker().orphanCancelRequested(pq.UID); time.Since(first) > r.cancelGracePeriod() {
+			log.Info("Orphaned query did not stop after cancel, terminating backend", "pid", pid)
+			if _, err := pool.Terminate(ctxTimeout, pid); err != nil {
+				log.Error(err, "Failed to terminate backend", "pid", pid)
+			}
+		} else {
+			log.Info("Cancelling orphaned query", "pid", pid)
+			if _, err := pool.Cancel(ctxTimeout, pid); err != nil {
+				log.Error(err, "Failed to cancel query", "pid", pid)
+			}
+		}
+		if _, err := r.markOrphaned(ctx, pq, fmt.Sprintf("backend %d is still running; cancelling", pid)); err != nil {
+			return ctrl.Result{}, err
+		}
+		return ctrl.Result{RequeueAfter: executionPollInterval}, nil
+	}
+
+	if _, err := r.markOrphaned(ctx, pq, fmt.Sprintf("backend %d is still running but is no longer tracked by the controller", pid)); err != nil {
+		return ctrl.Result{}, err
 	}
+	return ctrl.Result{RequeueAfter: orphanPollInterval}, nil
+}
 
-	return r.updateStatus(ctx, &pq, true, "", result, idempotencyHash)
+// orphanCheckFailed records that an orphaned backend could not be looked up
+// and returns err so the check is retried with backoff.
+func (r *PostgresQueryReconciler) orphanCheckFailed(ctx context.Context, pq *kubequeryv1beta1.PostgresQuery, err error) (ctrl.Result, error) {
+	msg := redact.FromContext(ctx).Error(err)
+	if _, werr := r.markOrphaned(ctx, pq, "could not check whether the orphaned query is still running: "+msg); werr != nil {
+		return ctrl.Result{}, werr
+	}
+	return ctrl.Result{}, errors.New(msg)
 }
 
-// updateStatus updates the CR status and returns a reconcile result.
-func (r *PostgresQueryReconciler) updateStatus(ctx context.Context, pq *kubequeryv1alpha1.PostgresQuery, executed bool, errMsg, result, hash string) (ctrl.Result, error) {
-	pq.Status.Executed = executed
-	pq.Status.Error = errMsg
+// updateStatus updates the CR status and returns a reconcile result. Messages
+// are scrubbed by the redactor carried in ctx before they are logged or stored.
+func (r *PostgresQueryReconciler) updateStatus(ctx context.Context, pq *kubequeryv1beta1.PostgresQuery, executed bool, errMsg, result, hash string) (ctrl.Result, error) {
+	red := redact.FromContext(ctx)
+	errMsg, result = red.String(errMsg), red.String(result)
+	if errMsg != "" {
+		logf.FromContext(ctx).Info("Query failed", "name", pq.Name, "error", errMsg)
+		r.event(pq, corev1.EventTypeWarning, "Failed", errMsg)
+	} else if executed {
+		r.event(pq, corev1.EventTypeNormal, "Succeeded", result)
+	}
+	phase := kubequeryv1beta1.PhaseFailed
+	if executed {
+		phase = kubequeryv1beta1.PhaseSucceeded
+	}
+	now := metav1.Now()
+	pq.SetPhase(phase, errMsg, now)
 	pq.Status.Result = result
 	pq.Status.IdempotencyHash = hash
-	if err := r.Status().Update(ctx, pq); err != nil {
-		return ctrl.Result{}, err
+	pq.Status.CompletionTime = &now
+	return ctrl.Result{}, r.writeStatus(ctx, pq)
+}
+
+// setRunning records in pq's status that execution started now.
+func setRunning(pq *kubequeryv1beta1.PostgresQuery, hash, msg string) {
+	now := metav1.Now()
+	pq.SetPhase(kubequeryv1beta1.PhaseRunning, msg, now)
+	if pq.Spec.RequireApproval {
+		pq.SetApproved(now)
 	}
-	return ctrl.Result{}, nil
+	pq.Status.StartTime = &now
+	pq.Status.CompletionTime = nil
+	pq.Status.IdempotencyHash = hash
+	pq.Status.Plan = ""
+}
+
+// markCancelled records that the query was cancelled.
+func (r *PostgresQueryReconciler) markCancelled(ctx context.Context, pq *kubequeryv1beta1.PostgresQuery, hash, msg string) (ctrl.Result, error) {
+	logf.FromContext(ctx).Info("Query cancelled", "name", pq.Name)
+	r.event(pq, corev1.EventTypeNormal, "Cancelled", msg)
+	now := metav1.Now()
+	pq.SetPhase(kubequeryv1beta1.PhaseCancelled, msg, now)
+	pq.Status.Result = ""
+	pq.Status.Plan = ""
+	pq.Status.IdempotencyHash = hash
+	pq.Status.CompletionTime = &now
+	return ctrl.Result{}, r.writeStatus(ctx, pq)
+}
+
+// markPendingApproval records that the query waits for an approval of
+// approval.
+func (r *PostgresQueryReconciler) markPendingApproval(ctx context.Context, pq *kubequeryv1beta1.PostgresQuery, hash, approval string) (ctrl.Result, error) {
+	if pq.Status.Phase == kubequeryv1beta1.PhasePendingApproval && pq.Status.IdempotencyHash == hash &&
+		pq.Status.ApprovalHash == approval {
+		return ctrl.Result{}, nil
+	}
+	logf.FromContext(ctx).Info("Query awaiting approval", "name", pq.Name)
+	r.event(pq, corev1.EventTypeNormal, "PendingApproval", "Query requires approval before it runs")
+	pq.SetPhase(kubequeryv1beta1.PhasePendingApproval, "", metav1.Now())
+	pq.Status.Result = ""
+	pq.Status.IdempotencyHash = hash
+	pq.Status.ApprovalHash = approval
+	return ctrl.Result{}, r.writeStatus(ctx, pq)
+}
+
+// markOrphaned records msg on an orphaned query. The status is only written,
+// and an event emitted, when the phase or message changes.
+func (r *PostgresQueryReconciler) markOrphaned(ctx context.Context, pq *kubequeryv1beta1.PostgresQuery, msg string) (ctrl.Result, error) {
+	msg = redact.FromContext(ctx).String(msg)
+	if pq.Status.Phase == kubequeryv1beta1.PhaseOrphaned && pq.Message() == msg {
+		return ctrl.Result{}, nil
+	}
+	logf.FromContext(ctx).Info("Query orphaned", "name", pq.Name, "reason", msg)
+	r.event(pq, corev1.EventTypeWarning, "Orphaned", msg)
+	pq.SetPhase(kubequeryv1beta1.PhaseOrphaned, msg, metav1.Now())
+	return ctrl.Result{}, r.writeStatus(ctx, pq)
+}
+
+// event records an event on pq if a Recorder is configured.
+func (r *PostgresQueryReconciler) event(pq *kubequeryv1beta1.PostgresQuery, eventType, reason, msg string) {
+	if r.Recorder != nil {
+		r.Recorder.Event(pq, eventType, reason, msg)
+	}
+}
+
+// writeStatus persists pq.Status. The object may have been changed while a
+// query was running (e.g. to add the cancel annotation), so on a conflict the
+// latest version is fetched and the status applied to it.
+func (r *PostgresQueryReconciler) writeStatus(ctx context.Context, pq *kubequeryv1beta1.PostgresQuery) error {
+	status := pq.Status
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		err := r.Status().Update(ctx, pq)
+		if apierrors.IsConflict(err) {
+			if getErr := r.Get(ctx, client.ObjectKeyFromObject(pq), pq); getErr != nil {
+				return getErr
+			}
+			pq.Status = status
+		}
+		return err
+	})
+	if err != nil {
+		return errors.New(redact.FromContext(ctx).Error(err))
+	}
+	return nil
 }
 
 // SetupWithManager sets up the controller with the Manager.
 func (r *PostgresQueryReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	return ctrl.NewControllerManagedBy(mgr).
-		For(&kubequeryv1alpha1.PostgresQuery{}).
+		// Status writes by this controller do not trigger a reconcile;
+		// running queries are polled with RequeueAfter instead. Annotation
+		// changes are watched for cancel requests.
+		For(&kubequeryv1beta1.PostgresQuery{}, builder.WithPredicates(
+			predicate.Or(predicate.GenerationChangedPredicate{}, predicate.AnnotationChangedPredicate{}))).
+		Owns(&batchv1.Job{}).
 		Named("postgresquery").
 		Complete(r)
 }
@@ -18,16 +18,26 @@ package controller
 
 import (
 	"context"
+	"fmt"
+	"time"
 
+	"github.com/jackc/pgx/v5/pgconn"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-
-	kubequeryv1alpha1 "github.com/rsavage/KubeQuery/api/v1alpha1"
+	kubequeryv1beta1 "github.com/rsavage/KubeQuery/api/v1beta1"
+	"github.com/rsavage/KubeQuery/pkg/db"
+	"github.com/rsavage/KubeQuery/pkg/db/dbtest"
+	"github.com/rsavage/KubeQuery/pkg/runner"
 )
 
 var _ = Describe("PostgresQuery Controller", func() {
@@ -40,18 +50,27 @@ var _ = Describe("PostgresQuery Controller", func() {
 			Name:      resourceName,
 			Namespace: "default", // TODO(user):Modify as needed
 		}
-		postgresquery := &kubequeryv1alpha1.PostgresQuery{}
+		postgresquery := &kubequeryv1beta1.PostgresQuery{}
 
 		BeforeEach(func() {
 			By("creating the custom resource for the Kind PostgresQuery")
 			err := k8sClient.Get(ctx, typeNamespacedName, postgresquery)
 			if err != nil && errors.IsNotFound(err) {
-				resource := &kubequeryv1alpha1.PostgresQuery{
+				resource := &kubequeryv1beta1.PostgresQuery{
 					ObjectMeta: metav1.ObjectMeta{
 						Name:      resourceName,
 						Namespace: "default",
 					},
-					// TODO(user): Specify other spec details if needed.
+					Spec: kubequeryv1beta1.PostgresQuerySpec{
+						Connection: &kubequeryv1beta1.PostgresConnectionSpec{
+							Host:     "db.invalid",
+							Port:     5432,
+							Database: "app",
+							User:     "app",
+						},
+						SQLSource: kubequeryv1beta1.SQLSource{Inline: "SELECT 1"},
+						Options:   &kubequeryv1beta1.QueryOptions{TimeoutSeconds: ptr.To(5)},
+					},
 				}
 				Expect(k8sClient.Create(ctx, resource)).To(Succeed())
 			}
@@ -59,7 +78,7 @@ var _ = Describe("PostgresQuery Controller", func() {
 
 		AfterEach(func() {
 			// TODO(user): Cleanup logic after each test, like removing the resource instance.
-			resource := &kubequeryv1alpha1.PostgresQuery{}
+			resource := &kubequeryv1beta1.PostgresQuery{}
 			err := k8sClient.Get(ctx, typeNamespacedName, resource)
 			Expect(err).NotTo(HaveOccurred())
 
@@ -81,4 +100,588 @@ var _ = Describe("PostgresQuery Controller", func() {
 			// Example: If you expect a certain status condition after reconciliation, verify it here.
 		})
 	})
+
+	Context("When the database connection fails", func() {
+		const (
+			resourceName = "redaction-test"
+			secretName   = "redaction-test-password"
+			password     = "s3cr3t-Passw0rd"
+		)
+
+		ctx := context.Background()
+		typeNamespacedName := types.NamespacedName{Name: resourceName, Namespace: "default"}
+
+		BeforeEach(func() {
+			By("creating the password secret")
+			secret := &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Name: secretName, Namespace: "default"},
+				StringData: map[string]string{"password": password},
+			}
+			Expect(k8sClient.Create(ctx, secret)).To(Succeed())
+
+			By("creating a PostgresQuery whose host was pasted from a DSN")
+			resource := &kubequeryv1beta1.PostgresQuery{
+				ObjectMeta: metav1.ObjectMeta{Name: resourceName, Namespace: "default"},
+				Spec: kubequeryv1beta1.PostgresQuerySpec{
+					Connection: &kubequeryv1beta1.PostgresConnectionSpec{
+						Host:     "db.invalid password=" + password,
+						Port:     5432,
+						Database: "app",
+						User:     "app",
+						PasswordSecretRef: &kubequeryv1beta1.SecretKeySelector{
+							Name: secretName,
+							Key:  "password",
+						},
+					},
+					SQLSource: kubequeryv1beta1.SQLSource{Inline: "SELECT 1"},
+					Options:   &kubequeryv1beta1.QueryOptions{TimeoutSeconds: ptr.To(5)},
+				},
+			}
+			Expect(k8sClient.Create(ctx, resource)).To(Succeed())
+		})
+
+		AfterEach(func() {
+			resource := &kubequeryv1beta1.PostgresQuery{}
+			if err := k8sClient.Get(ctx, typeNamespacedName, resource); err == nil {
+				Expect(k8sClient.Delete(ctx, resource)).To(Succeed())
+			} else {
+				Expect(errors.IsNotFound(err)).To(BeTrue())
+			}
+			secret := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: secretName, Namespace: "default"}}
+			Expect(k8sClient.Delete(ctx, secret)).To(Succeed())
+		})
+
+		It("should never write the password to the status", func() {
+			controllerReconciler := &PostgresQueryReconciler{
+				Client: k8sClient,
+				Scheme: k8sClient.Scheme(),
+			}
+
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+
+			resource := &kubequeryv1beta1.PostgresQuery{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, resource)).To(Succeed())
+			Expect(resource.Status.Phase).To(Equal(kubequeryv1beta1.PhaseFailed))
+			Expect(resource.Message()).NotTo(BeEmpty())
+			Expect(resource.Message()).NotTo(ContainSubstring(password))
+		})
+	})
+
+	Context("With an in-memory database driver", func() {
+		const (
+			secretName = "dbtest-password"
+			password   = "dbtest-Passw0rd"
+		)
+
+		ctx := context.Background()
+		var (
+			driver     *dbtest.Driver
+			recorder   *record.FakeRecorder
+			reconciler *PostgresQueryReconciler
+		)
+
+		newQuery := func(name, sql string, opts *kubequeryv1beta1.QueryOptions) *kubequeryv1beta1.PostgresQuery {
+			pq := &kubequeryv1beta1.PostgresQuery{
+				ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+				Spec: kubequeryv1beta1.PostgresQuerySpec{
+					Connection: &kubequeryv1beta1.PostgresConnectionSpec{
+						Host:     "db.example.com",
+						Port:     5432,
+						Database: "app",
+						User:     "app",
+						PasswordSecretRef: &kubequeryv1beta1.SecretKeySelector{
+							Name: secretName,
+							Key:  "password",
+						},
+					},
+					SQLSource: kubequeryv1beta1.SQLSource{Inline: sql},
+					Options:   opts,
+				},
+			}
+			Expect(k8sClient.Create(ctx, pq)).To(Succeed())
+			return pq
+		}
+
+		// reconcileUntil reconciles name until its status reaches phase, as
+		// the requeues of a running query would.
+		reconcileUntil := func(name string, phase kubequeryv1beta1.QueryPhase) *kubequeryv1beta1.PostgresQuery {
+			key := types.NamespacedName{Name: name, Namespace: "default"}
+			pq := &kubequeryv1beta1.PostgresQuery{}
+			Eventually(func(g Gomega) {
+				_, err := reconciler.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+				g.Expect(err).NotTo(HaveOccurred())
+				g.Expect(k8sClient.Get(ctx, key, pq)).To(Succeed())
+				g.Expect(pq.Status.Phase).To(Equal(phase))
+			}).WithTimeout(10 * time.Second).WithPolling(50 * time.Millisecond).Should(Succeed())
+			return pq
+		}
+
+		BeforeEach(func() {
+			secret := &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Name: secretName, Namespace: "default"},
+				StringData: map[string]string{"password": password},
+			}
+			Expect(k8sClient.Create(ctx, secret)).To(Succeed())
+
+			driver = &dbtest.Driver{}
+			recorder = record.NewFakeRecorder(100)
+			reconciler = &PostgresQueryReconciler{
+				Client:   k8sClient,
+				Scheme:   k8sClient.Scheme(),
+				Recorder: recorder,
+				Drivers:  map[db.Engine]db.Driver{db.EnginePostgres: driver},
+			}
+		})
+
+		AfterEach(func() {
+			Expect(k8sClient.DeleteAllOf(ctx, &kubequeryv1beta1.PostgresQuery{}, client.InNamespace("default"))).To(Succeed())
+			secret := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: secretName, Namespace: "default"}}
+			Expect(k8sClient.Delete(ctx, secret)).To(Succeed())
+		})
+
+		It("should run the query and record its result", func() {
+			driver.Handler = func(context.Context, string, []any) (dbtest.Result, error) {
+				return dbtest.Result{Tag: "UPDATE 3"}, nil
+			}
+			newQuery("dbtest-happy", "UPDATE users SET active = true", nil)
+
+			pq := reconcileUntil("dbtest-happy", kubequeryv1beta1.PhaseSucceeded)
+			Expect(pq.Status.Result).To(Equal("UPDATE 3"))
+			Expect(pq.Status.IdempotencyHash).NotTo(BeEmpty())
+			Expect(pq.Status.BackendPID).NotTo(BeZero())
+			Expect(pq.Status.CompletionTime).NotTo(BeNil())
+			Expect(driver.Statements()).To(Equal([]string{"UPDATE users SET active = true"}))
+			Expect(driver.OpenSessions()).To(BeZero())
+
+			cfg := driver.Configs()[0]
+			Expect(cfg.Host).To(Equal("db.example.com"))
+			Expect(cfg.RuntimeParams).To(HaveKeyWithValue("application_name", "kubequery"))
+			Expect(cfg.Credentials.Password(ctx)).To(Equal(password))
+		})
+
+		It("should fail without connecting when the password secret is missing", func() {
+			pq := newQuery("dbtest-no-secret", "SELECT 1", nil)
+			pq.Spec.Connection.PasswordSecretRef.Name = "does-not-exist"
+			Expect(k8sClient.Update(ctx, pq)).To(Succeed())
+
+			pq = reconcileUntil("dbtest-no-secret", kubequeryv1beta1.PhaseFailed)
+			Expect(pq.Message()).To(ContainSubstring("failed to get password secret"))
+			Expect(driver.Configs()).To(BeEmpty())
+		})
+
+		It("should record SQL errors", func() {
+			driver.Handler = func(context.Context, string, []any) (dbtest.Result, error) {
+				return dbtest.Result{}, &pgconn.PgError{Severity: "ERROR", Code: "42P01", Message: `relation "missing" does not exist`}
+			}
+			newQuery("dbtest-sql-error", "DELETE FROM missing", nil)
+
+			pq := reconcileUntil("dbtest-sql-error", kubequeryv1beta1.PhaseFailed)
+			Expect(pq.Message()).To(ContainSubstring("sql exec error"))
+			Expect(pq.Message()).To(ContainSubstring(`relation "missing" does not exist`))
+			Expect(pq.Status.Result).To(BeEmpty())
+		})
+
+		It("should fail a query that runs past timeoutSeconds", func() {
+			driver.Handler = func(ctx context.Context, _ string, _ []any) (dbtest.Result, error) {
+				<-ctx.Done()
+				return dbtest.Result{}, ctx.Err()
+			}
+			newQuery("dbtest-timeout", "SELECT pg_sleep(3600)", &kubequeryv1beta1.QueryOptions{TimeoutSeconds: ptr.To(1)})
+
+			pq := reconcileUntil("dbtest-timeout", kubequeryv1beta1.PhaseFailed)
+			Expect(pq.Message()).To(ContainSubstring("deadline exceeded"))
+			Expect(driver.OpenSessions()).To(BeZero())
+		})
+
+		It("should not run a succeeded query again", func() {
+			newQuery("dbtest-idempotent", "INSERT INTO audit VALUES (1)", nil)
+			first := reconcileUntil("dbtest-idempotent", kubequeryv1beta1.PhaseSucceeded)
+
+			for range 3 {
+				_, err := reconciler.Reconcile(ctx, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(first)})
+				Expect(err).NotTo(HaveOccurred())
+			}
+			pq := &kubequeryv1beta1.PostgresQuery{}
+			Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(first), pq)).To(Succeed())
+			Expect(pq.Status.Phase).To(Equal(kubequeryv1beta1.PhaseSucceeded))
+			Expect(pq.Status.IdempotencyHash).To(Equal(first.Status.IdempotencyHash))
+			Expect(driver.Statements()).To(HaveLen(1))
+		})
+
+		It("should retry after lock timeouts when retryOnLockTimeout is set", func() {
+			attempts := 0
+			driver.Handler = func(context.Context, string, []any) (dbtest.Result, error) {
+				attempts++
+				if attempts < 3 {
+					return dbtest.Result{}, dbtest.ErrLockTimeout
+				}
+				return dbtest.Result{Tag: "ALTER TABLE"}, nil
+			}
+			newQuery("dbtest-retry", "ALTER TABLE users ADD COLUMN last_login timestamptz", &kubequeryv1beta1.QueryOptions{
+				LockTimeout: &metav1.Duration{Duration: time.Second},
+				RetryOnLockTimeout: &kubequeryv1beta1.LockTimeoutRetry{
+					MaxAttempts: ptr.To(3),
+					Backoff:     &metav1.Duration{Duration: 10 * time.Millisecond},
+				},
+			})
+
+			pq := reconcileUntil("dbtest-retry", kubequeryv1beta1.PhaseSucceeded)
+			Expect(pq.Status.Result).To(Equal("ALTER TABLE"))
+			Expect(driver.Statements()).To(HaveLen(3))
+			Expect(driver.Configs()[0].RuntimeParams).To(HaveKeyWithValue("lock_timeout", "1000ms"))
+			var events []string
+			for len(recorder.Events) > 0 {
+				events = append(events, <-recorder.Events)
+			}
+			Expect(events).To(ContainElements(
+				"Warning LockTimeout Lock timeout on attempt 1 of 3, retrying in 10ms",
+				"Warning LockTimeout Lock timeout on attempt 2 of 3, retrying in 20ms",
+			))
+		})
+
+		It("should fail once lock timeout retries are exhausted", func() {
+			driver.Handler = func(context.Context, string, []any) (dbtest.Result, error) {
+				return dbtest.Result{}, dbtest.ErrLockTimeout
+			}
+			newQuery("dbtest-retry-exhausted", "ALTER TABLE users DROP COLUMN legacy", &kubequeryv1beta1.QueryOptions{
+				RetryOnLockTimeout: &kubequeryv1beta1.LockTimeoutRetry{
+					MaxAttempts: ptr.To(2),
+					Backoff:     &metav1.Duration{Duration: 10 * time.Millisecond},
+				},
+			})
+
+			pq := reconcileUntil("dbtest-retry-exhausted", kubequeryv1beta1.PhaseFailed)
+			Expect(pq.Message()).To(ContainSubstring("lock timeout"))
+			Expect(driver.Statements()).To(HaveLen(2))
+		})
+
+		// requestCancel sets the cancel annotation on name once its statement
+		// has reached the server.
+		requestCancel := func(name string) {
+			Eventually(driver.Statements).ShouldNot(BeEmpty())
+			pq := &kubequeryv1beta1.PostgresQuery{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: name, Namespace: "default"}, pq)).To(Succeed())
+			pq.Annotations = map[string]string{kubequeryv1beta1.CancelAnnotation: "true"}
+			Expect(k8sClient.Update(ctx, pq)).To(Succeed())
+		}
+
+		It("should cancel a running query on the server", func() {
+			driver.Handler = func(ctx context.Context, _ string, _ []any) (dbtest.Result, error) {
+				<-ctx.Done()
+				return dbtest.Result{}, &pgconn.PgError{Severity: "ERROR", Code: "57014", Message: "canceling statement due to user request"}
+			}
+			newQuery("dbtest-cancel", "SELECT pg_sleep(3600)", nil)
+			pq := reconcileUntil("dbtest-cancel", kubequeryv1beta1.PhaseRunning)
+			pid := uint32(pq.Status.BackendPID)
+
+			requestCancel("dbtest-cancel")
+			pq = reconcileUntil("dbtest-cancel", kubequeryv1beta1.PhaseCancelled)
+			Expect(pq.Message()).To(ContainSubstring("query cancelled while running"))
+			Expect(driver.Cancelled()).To(Equal([]uint32{pid}))
+			Expect(driver.Terminated()).To(BeEmpty())
+			Expect(driver.OpenSessions()).To(BeZero())
+		})
+
+		It("should terminate a query that ignores the cancel after the grace period", func() {
+			reconciler.CancelGracePeriod = 100 * time.Millisecond
+			// The statement ignores cancel requests and only stops once its
+			// session has been terminated.
+			driver.Handler = func(context.Context, string, []any) (dbtest.Result, error) {
+				for len(driver.Terminated()) == 0 {
+					time.Sleep(10 * time.Millisecond)
+				}
+				return dbtest.Result{}, &pgconn.PgError{Severity: "FATAL", Code: "57P01", Message: "terminating connection due to administrator command"}
+			}
+			newQuery("dbtest-terminate", "SELECT pg_sleep(3600)", nil)
+			pq := reconcileUntil("dbtest-terminate", kubequeryv1beta1.PhaseRunning)
+			pid := uint32(pq.Status.BackendPID)
+
+			requestCancel("dbtest-terminate")
+			start := time.Now()
+			pq = reconcileUntil("dbtest-terminate", kubequeryv1beta1.PhaseCancelled)
+			Expect(time.Since(start)).To(BeNumerically(">=", reconciler.CancelGracePeriod))
+			Expect(driver.Cancelled()).To(Equal([]uint32{pid}))
+			Expect(driver.Terminated()).To(Equal([]uint32{pid}))
+		})
+
+		// restart replaces the reconciler with one that has not started any
+		// query, as after a manager restart. Queries started before keep
+		// running on their sessions.
+		restart := func() {
+			reconciler = &PostgresQueryReconciler{
+				Client:            k8sClient,
+				Scheme:            k8sClient.Scheme(),
+				Recorder:          recorder,
+				Drivers:           reconciler.Drivers,
+				CancelGracePeriod: reconciler.CancelGracePeriod,
+			}
+		}
+
+		It("should report an untracked query whose backend is still running", func() {
+			release := make(chan struct{})
+			defer close(release)
+			driver.Handler = func(context.Context, string, []any) (dbtest.Result, error) {
+				<-release
+				return dbtest.Result{Tag: "UPDATE 1"}, nil
+			}
+			newQuery("dbtest-orphan-active", "UPDATE users SET active = true", nil)
+			pq := reconcileUntil("dbtest-orphan-active", kubequeryv1beta1.PhaseRunning)
+			Eventually(driver.Statements).ShouldNot(BeEmpty())
+
+			restart()
+			pq = reconcileUntil("dbtest-orphan-active", kubequeryv1beta1.PhaseOrphaned)
+			Expect(pq.Message()).To(ContainSubstring(fmt.Sprintf("backend %d is still running but is no longer tracked", pq.Status.BackendPID)))
+			Expect(pq.Status.CompletionTime).To(BeNil())
+			Expect(driver.Statements()).To(HaveLen(1))
+		})
+
+		It("should report an untracked query whose backend has gone", func() {
+			release := make(chan struct{})
+			driver.Handler = func(context.Context, string, []any) (dbtest.Result, error) {
+				<-release
+				return dbtest.Result{Tag: "UPDATE 1"}, nil
+			}
+			newQuery("dbtest-orphan-gone", "UPDATE users SET active = true", nil)
+			pq := reconcileUntil("dbtest-orphan-gone", kubequeryv1beta1.PhaseRunning)
+
+			restart()
+			close(release)
+			Eventually(driver.OpenSessions).Should(BeZero())
+			pq = reconcileUntil("dbtest-orphan-gone", kubequeryv1beta1.PhaseOrphaned)
+			Expect(pq.Message()).To(ContainSubstring(fmt.Sprintf("backend %d is no longer running", pq.Status.BackendPID)))
+			Expect(pq.Status.CompletionTime).NotTo(BeNil())
+			Expect(pq.Status.Result).To(BeEmpty())
+		})
+
+		It("should cancel an untracked query and terminate it after the grace period", func() {
+			reconciler.CancelGracePeriod = 100 * time.Millisecond
+			driver.Handler = func(context.Context, string, []any) (dbtest.Result, error) {
+				for len(driver.Terminated()) == 0 {
+					time.Sleep(10 * time.Millisecond)
+				}
+				return dbtest.Result{}, &pgconn.PgError{Severity: "FATAL", Code: "57P01", Message: "terminating connection due to administrator command"}
+			}
+			newQuery("dbtest-orphan-cancel", "SELECT pg_sleep(3600)", nil)
+			pq := reconcileUntil("dbtest-orphan-cancel", kubequeryv1beta1.PhaseRunning)
+			pid := uint32(pq.Status.BackendPID)
+
+			restart()
+			requestCancel("dbtest-orphan-cancel")
+			pq = reconcileUntil("dbtest-orphan-cancel", kubequeryv1beta1.PhaseCancelled)
+			Expect(pq.Message()).To(ContainSubstring("orphaned query cancelled"))
+			Expect(driver.Cancelled()).NotTo(BeEmpty())
+			Expect(driver.Cancelled()).To(HaveEach(pid))
+			Expect(driver.Terminated()).To(Equal([]uint32{pid}))
+			Expect(reconciler.tracker().orphanCancelled(pq.UID)).To(BeFalse())
+		})
+
+		It("should send the timeouts as session parameters", func() {
+			newQuery("dbtest-timeouts", "UPDATE users SET active = true", &kubequeryv1beta1.QueryOptions{
+				LockTimeout:                     &metav1.Duration{Duration: 1500 * time.Millisecond},
+				StatementTimeout:                &metav1.Duration{Duration: 2 * time.Minute},
+				IdleInTransactionSessionTimeout: &metav1.Duration{Duration: 10 * time.Second},
+			})
+
+			reconcileUntil("dbtest-timeouts", kubequeryv1beta1.PhaseSucceeded)
+			params := driver.Configs()[0].RuntimeParams
+			Expect(params).To(HaveKeyWithValue("lock_timeout", "1500ms"))
+			Expect(params).To(HaveKeyWithValue("statement_timeout", "120000ms"))
+			Expect(params).To(HaveKeyWithValue("idle_in_transaction_session_timeout", "10000ms"))
+		})
+
+		It("should translate the timeouts to MySQL session variables", func() {
+			reconciler.Drivers[db.EngineMySQL] = driver
+			pq := newQuery("dbtest-mysql-timeouts", "UPDATE users SET active = 1", &kubequeryv1beta1.QueryOptions{
+				LockTimeout:      &metav1.Duration{Duration: 1500 * time.Millisecond},
+				StatementTimeout: &metav1.Duration{Duration: 2 * time.Second},
+			})
+			pq.Spec.Connection.Engine = string(db.EngineMySQL)
+			Expect(k8sClient.Update(ctx, pq)).To(Succeed())
+
+			reconcileUntil("dbtest-mysql-timeouts", kubequeryv1beta1.PhaseSucceeded)
+			params := driver.Configs()[0].RuntimeParams
+			Expect(params).To(HaveKeyWithValue("innodb_lock_wait_timeout", "2"))
+			Expect(params).To(HaveKeyWithValue("lock_wait_timeout", "2"))
+			Expect(params).To(HaveKeyWithValue("max_execution_time", "2000"))
+			Expect(params).NotTo(HaveKey("lock_timeout"))
+		})
+
+		It("should reject idleInTransactionSessionTimeout for MySQL", func() {
+			reconciler.Drivers[db.EngineMySQL] = driver
+			pq := newQuery("dbtest-mysql-idle", "UPDATE users SET active = 1", &kubequeryv1beta1.QueryOptions{
+				IdleInTransactionSessionTimeout: &metav1.Duration{Duration: time.Second},
+			})
+			pq.Spec.Connection.Engine = string(db.EngineMySQL)
+			Expect(k8sClient.Update(ctx, pq)).To(Succeed())
+
+			pq = reconcileUntil("dbtest-mysql-idle", kubequeryv1beta1.PhaseFailed)
+			Expect(pq.Message()).To(ContainSubstring("idleInTransactionSessionTimeout is not supported for MySQL"))
+			Expect(driver.Configs()).To(BeEmpty())
+		})
+
+		It("should apply the lock retry defaults", func() {
+			Expect(lockRetryPolicy(nil).MaxAttempts).To(Equal(1))
+			policy := lockRetryPolicy(&kubequeryv1beta1.QueryOptions{RetryOnLockTimeout: &kubequeryv1beta1.LockTimeoutRetry{}})
+			Expect(policy.MaxAttempts).To(Equal(defaultLockRetryAttempts))
+			Expect(policy.Backoff).To(Equal(defaultLockRetryBackoff))
+			policy = lockRetryPolicy(&kubequeryv1beta1.QueryOptions{RetryOnLockTimeout: &kubequeryv1beta1.LockTimeoutRetry{
+				MaxAttempts: ptr.To(5),
+				Backoff:     &metav1.Duration{Duration: time.Second},
+			}})
+			Expect(policy.MaxAttempts).To(Equal(5))
+			Expect(policy.Backoff).To(Equal(time.Second))
+		})
+
+		It("should record the plan of an explained query without keeping its changes", func() {
+			driver.Handler = func(_ context.Context, sql string, _ []any) (dbtest.Result, error) {
+				plan := `[{"Plan": {"Node Type": "ModifyTable", "Operation": "Delete", "Relation Name": "orders",
+					"Startup Cost": 0, "Total Cost": 35, "Plan Rows": 10, "Plan Width": 6,
+					"Actual Startup Time": 0.5, "Actual Total Time": 0.5, "Actual Rows": 0, "Actual Loops": 1,
+					"Plans": [{"Node Type": "Seq Scan", "Relation Name": "orders", "Filter": "(status = 'void'::text)",
+						"Startup Cost": 0, "Total Cost": 35, "Plan Rows": 10, "Plan Width": 6,
+						"Actual Startup Time": 0.1, "Actual Total Time": 0.4, "Actual Rows": 2, "Actual Loops": 1}]},
+					"Execution Time": 0.6}]`
+				return dbtest.Result{Columns: []db.Column{{Name: "QUERY PLAN", Type: "json"}}, Rows: [][]any{{plan}}}, nil
+			}
+			newQuery("dbtest-explain", "DELETE FROM orders WHERE status = 'void'", &kubequeryv1beta1.QueryOptions{
+				RedactSQLLiterals: true,
+				Explain:           &kubequeryv1beta1.ExplainOptions{Analyze: true},
+			})
+
+			pq := reconcileUntil("dbtest-explain", kubequeryv1beta1.PhaseSucceeded)
+			Expect(pq.Status.Result).To(HavePrefix("Delete on orders: cost 0.00..35.00, 10 rows estimated, 0 rows in 0.600 ms"))
+			Expect(pq.Status.Plan).To(ContainSubstring(`"Node Type":"Seq Scan"`))
+			Expect(pq.Status.Plan).NotTo(ContainSubstring("void"))
+			Expect(driver.Statements()).To(Equal([]string{
+				"BEGIN",
+				"EXPLAIN (FORMAT JSON, ANALYZE) DELETE FROM orders WHERE status = 'void'",
+				"ROLLBACK",
+			}))
+		})
+
+		It("should apply the timeouts to an explained query", func() {
+			driver.Handler = func(context.Context, string, []any) (dbtest.Result, error) {
+				return dbtest.Result{Columns: []db.Column{{Name: "QUERY PLAN", Type: "json"}},
+					Rows: [][]any{{`[{"Plan": {"Node Type": "Result", "Total Cost": 0.01, "Plan Rows": 1}}]`}}}, nil
+			}
+			newQuery("dbtest-explain-timeouts", "UPDATE users SET active = true", &kubequeryv1beta1.QueryOptions{
+				LockTimeout:      &metav1.Duration{Duration: 1500 * time.Millisecond},
+				StatementTimeout: &metav1.Duration{Duration: 2 * time.Minute},
+				Explain:          &kubequeryv1beta1.ExplainOptions{Analyze: true},
+			})
+
+			reconcileUntil("dbtest-explain-timeouts", kubequeryv1beta1.PhaseSucceeded)
+			Expect(driver.Statements()).To(Equal([]string{
+				"BEGIN",
+				"SET LOCAL statement_timeout = 120000",
+				"SET LOCAL lock_timeout = 1500",
+				"EXPLAIN (FORMAT JSON, ANALYZE) UPDATE users SET active = true",
+				"ROLLBACK",
+			}))
+			params := driver.Configs()[0].RuntimeParams
+			Expect(params).To(HaveKeyWithValue("lock_timeout", "1500ms"))
+			Expect(params).To(HaveKeyWithValue("statement_timeout", "120000ms"))
+		})
+
+		It("should reject explain in executionMode Job", func() {
+			pq := newQuery("dbtest-explain-job", "SELECT 1", &kubequeryv1beta1.QueryOptions{
+				Explain: &kubequeryv1beta1.ExplainOptions{},
+			})
+			pq.Spec.ExecutionMode = kubequeryv1beta1.ExecutionModeJob
+			Expect(k8sClient.Update(ctx, pq)).To(Succeed())
+
+			pq = reconcileUntil("dbtest-explain-job", kubequeryv1beta1.PhaseFailed)
+			Expect(pq.Message()).To(ContainSubstring("not supported with executionMode Job"))
+			Expect(driver.Configs()).To(BeEmpty())
+		})
+
+		// newJobQuery creates a query with executionMode Job and reconciles
+		// it until its runner Job and Secret exist.
+		newJobQuery := func(name string) *kubequeryv1beta1.PostgresQuery {
+			reconciler.RunnerImage = "kubequery-runner:test"
+			pq := newQuery(name, "UPDATE users SET active = true", nil)
+			pq.Spec.ExecutionMode = kubequeryv1beta1.ExecutionModeJob
+			Expect(k8sClient.Update(ctx, pq)).To(Succeed())
+			pq = reconcileUntil(name, kubequeryv1beta1.PhaseRunning)
+			Expect(pq.Status.JobName).NotTo(BeEmpty())
+			secret := &corev1.Secret{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: pq.Status.JobName, Namespace: "default"}, secret)).To(Succeed())
+			Expect(string(secret.Data[runner.SpecKey])).To(ContainSubstring(password))
+			return pq
+		}
+
+		// runnerSecretDeleted reports whether the runner Secret of pq is gone.
+		runnerSecretDeleted := func(pq *kubequeryv1beta1.PostgresQuery) bool {
+			err := k8sClient.Get(ctx, types.NamespacedName{Name: pq.Status.JobName, Namespace: "default"}, &corev1.Secret{})
+			return errors.IsNotFound(err)
+		}
+
+		It("should require a new approval when the runner ServiceAccount changes", func() {
+			reconciler.RunnerImage = "kubequery-runner:test"
+			pq := newQuery("dbtest-approval", "UPDATE users SET active = true", nil)
+			pq.Spec.ExecutionMode = kubequeryv1beta1.ExecutionModeJob
+			pq.Spec.Job = &kubequeryv1beta1.JobOptions{ServiceAccountName: "runner"}
+			pq.Spec.RequireApproval = true
+			Expect(k8sClient.Update(ctx, pq)).To(Succeed())
+
+			pq = reconcileUntil("dbtest-approval", kubequeryv1beta1.PhasePendingApproval)
+			approved := pq.Status.ApprovalHash
+			Expect(approved).NotTo(BeEmpty())
+			pq.Annotations = map[string]string{
+				kubequeryv1beta1.ApprovedByAnnotation:   "alice",
+				kubequeryv1beta1.ApprovedHashAnnotation: approved,
+			}
+			Expect(k8sClient.Update(ctx, pq)).To(Succeed())
+			pq.Spec.Job.ServiceAccountName = "cluster-admin"
+			Expect(k8sClient.Update(ctx, pq)).To(Succeed())
+
+			pq = reconcileUntil("dbtest-approval", kubequeryv1beta1.PhasePendingApproval)
+			Expect(pq.Status.ApprovalHash).NotTo(Equal(approved))
+			Expect(pq.Status.JobName).To(BeEmpty())
+		})
+
+		It("should delete the runner Secret once the Job has finished", func() {
+			pq := newJobQuery("dbtest-job")
+			jobName := pq.Status.JobName
+
+			msg, err := runner.EncodeResult(runner.Result{Succeeded: true, Result: "UPDATE 3"})
+			Expect(err).NotTo(HaveOccurred())
+			pod := &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{Name: jobName + "-pod", Namespace: "default", Labels: map[string]string{batchv1.JobNameLabel: jobName}},
+				Spec:       corev1.PodSpec{Containers: []corev1.Container{{Name: runnerContainerName, Image: reconciler.RunnerImage}}},
+			}
+			Expect(k8sClient.Create(ctx, pod)).To(Succeed())
+			pod.Status.ContainerStatuses = []corev1.ContainerStatus{{
+				Name:  runnerContainerName,
+				State: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{Message: string(msg)}},
+			}}
+			Expect(k8sClient.Status().Update(ctx, pod)).To(Succeed())
+
+			job := &batchv1.Job{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: jobName, Namespace: "default"}, job)).To(Succeed())
+			now := metav1.Now()
+			job.Status.StartTime, job.Status.CompletionTime, job.Status.Succeeded = &now, &now, 1
+			job.Status.Conditions = []batchv1.JobCondition{
+				{Type: batchv1.JobSuccessCriteriaMet, Status: corev1.ConditionTrue},
+				{Type: batchv1.JobComplete, Status: corev1.ConditionTrue},
+			}
+			Expect(k8sClient.Status().Update(ctx, job)).To(Succeed())
+
+			pq = reconcileUntil("dbtest-job", kubequeryv1beta1.PhaseSucceeded)
+			Expect(pq.Status.Result).To(Equal("UPDATE 3"))
+			Expect(runnerSecretDeleted(pq)).To(BeTrue())
+		})
+
+		It("should delete the runner Job and Secret when the query is cancelled", func() {
+			pq := newJobQuery("dbtest-job-cancel")
+			pq.Annotations = map[string]string{kubequeryv1beta1.CancelAnnotation: "true"}
+			Expect(k8sClient.Update(ctx, pq)).To(Succeed())
+
+			pq = reconcileUntil("dbtest-job-cancel", kubequeryv1beta1.PhaseCancelled)
+			Expect(runnerSecretDeleted(pq)).To(BeTrue())
+			err := k8sClient.Get(ctx, types.NamespacedName{Name: pq.Status.JobName, Namespace: "default"}, &batchv1.Job{})
+			Expect(errors.IsNotFound(err)).To(BeTrue())
+		})
+	})
 })
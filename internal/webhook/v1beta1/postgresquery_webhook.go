@@ -0,0 +1,256 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	kubequeryv1beta1 "github.com/rsavage/KubeQuery/api/v1beta1"
+)
+
+// ApproveVerb is the custom RBAC verb on postgresqueries that allows a user
+// to approve queries.
+const ApproveVerb = "approve"
+
+// UseVerb is the custom RBAC verb on serviceaccounts that allows a user to
+// run the runner pods of their queries as a ServiceAccount.
+const UseVerb = "use"
+
+// SetupPostgresQueryWebhookWithManager registers the conversion webhook that
+// serves PostgresQuery v1alpha1 from the v1beta1 storage version, and the
+// admission webhooks that enforce approvals. Both versions must be registered
+// in the manager's scheme. delegates lists the users, such as ui-service's
+// ServiceAccount, trusted to submit and approve queries on behalf of others.
+func SetupPostgresQueryWebhookWithManager(mgr ctrl.Manager, delegates []string) error {
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(&kubequeryv1beta1.PostgresQuery{}).
+		WithDefaulter(&PostgresQueryCustomDefaulter{Delegates: delegates}).
+		WithValidator(&PostgresQueryCustomValidator{Client: mgr.GetClient(), Delegates: delegates}).
+		Complete()
+}
+
+// +kubebuilder:webhook:path=/mutate-kubequery-cloudnexus-io-v1beta1-postgresquery,mutating=true,failurePolicy=fail,sideEffects=None,groups=kubequery.cloudnexus.io,resources=postgresqueries,verbs=create,versions=v1beta1,name=mpostgresquery-v1beta1.kb.io,admissionReviewVersions=v1
+
+// PostgresQueryCustomDefaulter records who created a PostgresQuery in the
+// submitted-by annotation.
+type PostgresQueryCustomDefaulter struct {
+	// Delegates may set submitted-by to the user they act for.
+	Delegates []string
+}
+
+var _ admission.CustomDefaulter = &PostgresQueryCustomDefaulter{}
+
+// Default sets submitted-by to the requesting user, unless a delegate has
+// already named the user it submits for.
+func (d *PostgresQueryCustomDefaulter) Default(ctx context.Context, obj runtime.Object) error {
+	pq, ok := obj.(*kubequeryv1beta1.PostgresQuery)
+	if !ok {
+		return fmt.Errorf("expected a PostgresQuery object but got %T", obj)
+	}
+	req, err := admission.RequestFromContext(ctx)
+	if err != nil {
+		return err
+	}
+	if slices.Contains(d.Delegates, req.UserInfo.Username) && pq.Annotations[kubequeryv1beta1.SubmittedByAnnotation] != "" {
+		return nil
+	}
+	if pq.Annotations == nil {
+		pq.Annotations = map[string]string{}
+	}
+	pq.Annotations[kubequeryv1beta1.SubmittedByAnnotation] = req.UserInfo.Username
+	return nil
+}
+
+// +kubebuilder:webhook:path=/validate-kubequery-cloudnexus-io-v1beta1-postgresquery,mutating=false,failurePolicy=fail,sideEffects=None,groups=kubequery.cloudnexus.io,resources=postgresqueries,verbs=create;update,versions=v1beta1,name=vpostgresquery-v1beta1.kb.io,admissionReviewVersions=v1
+// +kubebuilder:rbac:groups=authorization.k8s.io,resources=subjectaccessreviews,verbs=create
+
+// PostgresQueryCustomValidator enforces approvals: only users granted the
+// approve verb may approve a query, nobody may approve their own, and
+// spec.requireApproval cannot be turned off once set. It also requires the
+// use verb on the ServiceAccount named in spec.job.serviceAccountName.
+type PostgresQueryCustomValidator struct {
+	// Client creates SubjectAccessReviews.
+	Client client.Client
+	// Delegates may approve in the name of another user; they are trusted
+	// to have checked that the user holds the approve verb.
+	Delegates []string
+}
+
+var _ admission.CustomValidator = &PostgresQueryCustomValidator{}
+
+var (
+	annotationsPath        = field.NewPath("metadata", "annotations")
+	serviceAccountNamePath = field.NewPath("spec", "job", "serviceAccountName")
+)
+
+// ValidateCreate rejects queries that are approved when they are created,
+// and checks the runner ServiceAccount.
+func (v *PostgresQueryCustomValidator) ValidateCreate(ctx context.Context, obj runtime.Object) (admission.Warnings, error) {
+	pq, ok := obj.(*kubequeryv1beta1.PostgresQuery)
+	if !ok {
+		return nil, fmt.Errorf("expected a PostgresQuery object but got %T", obj)
+	}
+	for _, key := range []string{kubequeryv1beta1.ApprovedByAnnotation, kubequeryv1beta1.ApprovedHashAnnotation} {
+		if pq.Annotations[key] != "" {
+			return nil, invalid(pq, field.Forbidden(annotationsPath.Key(key), "a query cannot be approved when it is created"))
+		}
+	}
+	return nil, v.validateServiceAccount(ctx, nil, pq)
+}
+
+// ValidateUpdate checks changes to the approval annotations and the
+// settings they depend on.
+func (v *PostgresQueryCustomValidator) ValidateUpdate(ctx context.Context, oldObj, newObj runtime.Object) (admission.Warnings, error) {
+	old, ok := oldObj.(*kubequeryv1beta1.PostgresQuery)
+	if !ok {
+		return nil, fmt.Errorf("expected a PostgresQuery object but got %T", oldObj)
+	}
+	pq, ok := newObj.(*kubequeryv1beta1.PostgresQuery)
+	if !ok {
+		return nil, fmt.Errorf("expected a PostgresQuery object but got %T", newObj)
+	}
+
+	if old.Spec.RequireApproval && !pq.Spec.RequireApproval {
+		return nil, invalid(pq, field.Forbidden(field.NewPath("spec", "requireApproval"), "cannot be unset once set"))
+	}
+	submittedBy := pq.Annotations[kubequeryv1beta1.SubmittedByAnnotation]
+	if submittedBy != old.Annotations[kubequeryv1beta1.SubmittedByAnnotation] {
+		return nil, invalid(pq, field.Forbidden(annotationsPath.Key(kubequeryv1beta1.SubmittedByAnnotation), "is immutable"))
+	}
+	if err := v.validateServiceAccount(ctx, old, pq); err != nil {
+		return nil, err
+	}
+
+	approvedBy := pq.Annotations[kubequeryv1beta1.ApprovedByAnnotation]
+	if approvedBy == "" || (approvedBy == old.Annotations[kubequeryv1beta1.ApprovedByAnnotation] &&
+		pq.Annotations[kubequeryv1beta1.ApprovedHashAnnotation] == old.Annotations[kubequeryv1beta1.ApprovedHashAnnotation]) {
+		return nil, nil
+	}
+	approvedByPath := annotationsPath.Key(kubequeryv1beta1.ApprovedByAnnotation)
+	if approvedBy == submittedBy {
+		return nil, invalid(pq, field.Forbidden(approvedByPath, "a query cannot be approved by the user who submitted it"))
+	}
+
+	req, err := admission.RequestFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if slices.Contains(v.Delegates, req.UserInfo.Username) {
+		return nil, nil
+	}
+	if approvedBy != req.UserInfo.Username {
+		return nil, invalid(pq, field.Invalid(approvedByPath, approvedBy, "must be the approving user, "+req.UserInfo.Username))
+	}
+	allowed, err := v.mayApprove(ctx, req.UserInfo, pq)
+	if err != nil {
+		return nil, err
+	}
+	if !allowed {
+		return nil, invalid(pq, field.Forbidden(approvedByPath,
+			fmt.Sprintf("user %s may not approve postgresqueries in namespace %s", approvedBy, pq.Namespace)))
+	}
+	return nil, nil
+}
+
+// ValidateDelete allows every deletion.
+func (v *PostgresQueryCustomValidator) ValidateDelete(context.Context, runtime.Object) (admission.Warnings, error) {
+	return nil, nil
+}
+
+// validateServiceAccount requires the requesting user to hold the use verb
+// on the ServiceAccount that the runner pod of pq runs as, unless it is the
+// one of old.
+func (v *PostgresQueryCustomValidator) validateServiceAccount(ctx context.Context, old, pq *kubequeryv1beta1.PostgresQuery) error {
+	name := serviceAccountName(pq)
+	if name == "" || (old != nil && serviceAccountName(old) == name) {
+		return nil
+	}
+	req, err := admission.RequestFromContext(ctx)
+	if err != nil {
+		return err
+	}
+	allowed, err := v.allowed(ctx, req.UserInfo, &authorizationv1.ResourceAttributes{
+		Namespace: pq.Namespace,
+		Verb:      UseVerb,
+		Resource:  "serviceaccounts",
+		Name:      name,
+	})
+	if err != nil {
+		return err
+	}
+	if !allowed {
+		return invalid(pq, field.Forbidden(serviceAccountNamePath,
+			fmt.Sprintf("user %s may not use serviceaccount %s in namespace %s", req.UserInfo.Username, name, pq.Namespace)))
+	}
+	return nil
+}
+
+// serviceAccountName returns spec.job.serviceAccountName of pq.
+func serviceAccountName(pq *kubequeryv1beta1.PostgresQuery) string {
+	if pq.Spec.Job == nil {
+		return ""
+	}
+	return pq.Spec.Job.ServiceAccountName
+}
+
+// mayApprove asks the API server whether user holds the approve verb on pq.
+func (v *PostgresQueryCustomValidator) mayApprove(ctx context.Context, user authenticationv1.UserInfo, pq *kubequeryv1beta1.PostgresQuery) (bool, error) {
+	return v.allowed(ctx, user, &authorizationv1.ResourceAttributes{
+		Namespace: pq.Namespace,
+		Verb:      ApproveVerb,
+		Group:     kubequeryv1beta1.GroupVersion.Group,
+		Resource:  "postgresqueries",
+		Name:      pq.Name,
+	})
+}
+
+// allowed asks the API server whether user may perform attrs.
+func (v *PostgresQueryCustomValidator) allowed(ctx context.Context, user authenticationv1.UserInfo, attrs *authorizationv1.ResourceAttributes) (bool, error) {
+	extra := make(map[string]authorizationv1.ExtraValue, len(user.Extra))
+	for k, vals := range user.Extra {
+		extra[k] = authorizationv1.ExtraValue(vals)
+	}
+	sar := &authorizationv1.SubjectAccessReview{
+		Spec: authorizationv1.SubjectAccessReviewSpec{
+			User:               user.Username,
+			UID:                user.UID,
+			Groups:             user.Groups,
+			Extra:              extra,
+			ResourceAttributes: attrs,
+		},
+	}
+	if err := v.Client.Create(ctx, sar); err != nil {
+		return false, fmt.Errorf("failed to check %s permission: %w", attrs.Verb, err)
+	}
+	return sar.Status.Allowed, nil
+}
+
+// invalid wraps errs in the Invalid error the API server returns to the user.
+func invalid(pq *kubequeryv1beta1.PostgresQuery, errs ...*field.Error) error {
+	return apierrors.NewInvalid(kubequeryv1beta1.GroupVersion.WithKind("PostgresQuery").GroupKind(), pq.Name, errs)
+}
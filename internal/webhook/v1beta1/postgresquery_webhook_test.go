@@ -0,0 +1,232 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	kubequeryv1beta1 "github.com/rsavage/KubeQuery/api/v1beta1"
+)
+
+const delegate = "system:serviceaccount:kubequery:ui-service"
+
+// requestBy returns a context carrying an admission request made by user.
+func requestBy(user string, groups ...string) context.Context {
+	return admission.NewContextWithRequest(context.Background(), admission.Request{
+		AdmissionRequest: admissionv1.AdmissionRequest{UserInfo: authenticationv1.UserInfo{Username: user, Groups: groups}},
+	})
+}
+
+// newValidator returns a validator whose SubjectAccessReviews allow the
+// approve verb to members of the approvers group only, and the use verb to
+// members of the runners group only.
+func newValidator(t *testing.T, reviews *[]authorizationv1.SubjectAccessReviewSpec) *PostgresQueryCustomValidator {
+	t.Helper()
+	c := fake.NewClientBuilder().WithInterceptorFuncs(interceptor.Funcs{
+		Create: func(_ context.Context, _ client.WithWatch, obj client.Object, _ ...client.CreateOption) error {
+			sar := obj.(*authorizationv1.SubjectAccessReview)
+			*reviews = append(*reviews, sar.Spec)
+			switch sar.Spec.ResourceAttributes.Verb {
+			case ApproveVerb:
+				sar.Status.Allowed = slices.Contains(sar.Spec.Groups, "approvers")
+			case UseVerb:
+				sar.Status.Allowed = slices.Contains(sar.Spec.Groups, "runners")
+			}
+			return nil
+		},
+	}).Build()
+	return &PostgresQueryCustomValidator{Client: c, Delegates: []string{delegate}}
+}
+
+func pendingQuery(annotations map[string]string) *kubequeryv1beta1.PostgresQuery {
+	return &kubequeryv1beta1.PostgresQuery{
+		ObjectMeta: metav1.ObjectMeta{Name: "drop", Namespace: "team-a", Annotations: annotations},
+		Spec: kubequeryv1beta1.PostgresQuerySpec{
+			SQLSource:       kubequeryv1beta1.SQLSource{Inline: "DROP TABLE t"},
+			RequireApproval: true,
+		},
+	}
+}
+
+// approved returns a copy of pq approved by user.
+func approved(pq *kubequeryv1beta1.PostgresQuery, user string) *kubequeryv1beta1.PostgresQuery {
+	pq = pq.DeepCopy()
+	pq.Annotations[kubequeryv1beta1.ApprovedByAnnotation] = user
+	pq.Annotations[kubequeryv1beta1.ApprovedHashAnnotation] = "abc123"
+	return pq
+}
+
+func TestDefault(t *testing.T) {
+	d := &PostgresQueryCustomDefaulter{Delegates: []string{delegate}}
+
+	pq := pendingQuery(map[string]string{kubequeryv1beta1.SubmittedByAnnotation: "someone-else"})
+	if err := d.Default(requestBy("alice"), pq); err != nil {
+		t.Fatal(err)
+	}
+	if got := pq.Annotations[kubequeryv1beta1.SubmittedByAnnotation]; got != "alice" {
+		t.Errorf("submitted-by = %q, want the requesting user", got)
+	}
+
+	pq = pendingQuery(map[string]string{kubequeryv1beta1.SubmittedByAnnotation: "bob"})
+	if err := d.Default(requestBy(delegate), pq); err != nil {
+		t.Fatal(err)
+	}
+	if got := pq.Annotations[kubequeryv1beta1.SubmittedByAnnotation]; got != "bob" {
+		t.Errorf("submitted-by = %q, want the user named by the delegate", got)
+	}
+
+	pq = pendingQuery(nil)
+	if err := d.Default(requestBy(delegate), pq); err != nil {
+		t.Fatal(err)
+	}
+	if got := pq.Annotations[kubequeryv1beta1.SubmittedByAnnotation]; got != delegate {
+		t.Errorf("submitted-by = %q, want the delegate when it names nobody", got)
+	}
+}
+
+func TestValidateCreate(t *testing.T) {
+	var reviews []authorizationv1.SubjectAccessReviewSpec
+	v := newValidator(t, &reviews)
+
+	if _, err := v.ValidateCreate(requestBy("alice"), pendingQuery(nil)); err != nil {
+		t.Errorf("create = %v, want allowed", err)
+	}
+	pq := approved(pendingQuery(map[string]string{}), "bob")
+	if _, err := v.ValidateCreate(requestBy("alice"), pq); !apierrors.IsInvalid(err) {
+		t.Errorf("create approved = %v, want Invalid", err)
+	}
+}
+
+func TestValidateUpdate(t *testing.T) {
+	submitted := map[string]string{kubequeryv1beta1.SubmittedByAnnotation: "alice"}
+	pending := pendingQuery(submitted)
+
+	unset := pending.DeepCopy()
+	unset.Spec.RequireApproval = false
+	resubmitted := pending.DeepCopy()
+	resubmitted.Annotations[kubequeryv1beta1.SubmittedByAnnotation] = "carol"
+	rehashed := approved(pending, "bob")
+	rehashed.Annotations[kubequeryv1beta1.ApprovedHashAnnotation] = "def456"
+	edited := approved(pending, "bob")
+	edited.Spec.SQLSource.Inline = "DROP TABLE u"
+
+	tests := []struct {
+		name     string
+		ctx      context.Context
+		old, new *kubequeryv1beta1.PostgresQuery
+		allowed  bool
+		reviewed bool
+	}{
+		{"approver with the approve verb", requestBy("bob", "approvers"), pending, approved(pending, "bob"), true, true},
+		{"approver without the approve verb", requestBy("bob", "developers"), pending, approved(pending, "bob"), false, true},
+		{"approval in another user's name", requestBy("bob", "approvers"), pending, approved(pending, "dave"), false, false},
+		{"self-approval", requestBy("alice", "approvers"), pending, approved(pending, "alice"), false, false},
+		{"self-approval through a delegate", requestBy(delegate), pending, approved(pending, "alice"), false, false},
+		{"approval through a delegate", requestBy(delegate), pending, approved(pending, "bob"), true, false},
+		{"re-approval of a new hash", requestBy("carol"), approved(pending, "bob"), rehashed, false, false},
+		{"unchanged approval", requestBy("alice"), approved(pending, "bob"), edited, true, false},
+		{"unsetting requireApproval", requestBy("alice"), pending, unset, false, false},
+		{"setting requireApproval", requestBy("alice"), unset, pending, true, false},
+		{"changing submitted-by", requestBy("alice"), pending, resubmitted, false, false},
+	}
+	for _, tt := range tests {
+		var reviews []authorizationv1.SubjectAccessReviewSpec
+		v := newValidator(t, &reviews)
+		_, err := v.ValidateUpdate(tt.ctx, tt.old, tt.new)
+		if tt.allowed && err != nil {
+			t.Errorf("%s: err = %v, want allowed", tt.name, err)
+		}
+		if !tt.allowed && !apierrors.IsInvalid(err) {
+			t.Errorf("%s: err = %v, want Invalid", tt.name, err)
+		}
+		if reviewed := len(reviews) > 0; reviewed != tt.reviewed {
+			t.Errorf("%s: SubjectAccessReview sent = %t, want %t", tt.name, reviewed, tt.reviewed)
+		}
+		for _, r := range reviews {
+			attrs := r.ResourceAttributes
+			if attrs.Namespace != "team-a" || attrs.Name != "drop" || attrs.Resource != "postgresqueries" ||
+				attrs.Group != kubequeryv1beta1.GroupVersion.Group {
+				t.Errorf("%s: review attributes = %+v", tt.name, attrs)
+			}
+		}
+	}
+}
+
+func TestValidateServiceAccount(t *testing.T) {
+	submitted := map[string]string{kubequeryv1beta1.SubmittedByAnnotation: "alice"}
+	plain := pendingQuery(submitted)
+	runner := plain.DeepCopy()
+	runner.Spec.Job = &kubequeryv1beta1.JobOptions{ServiceAccountName: "runner"}
+	edited := runner.DeepCopy()
+	edited.Spec.SQLSource.Inline = "DROP TABLE u"
+	admin := runner.DeepCopy()
+	admin.Spec.Job.ServiceAccountName = "cluster-admin"
+
+	tests := []struct {
+		name     string
+		ctx      context.Context
+		old, new *kubequeryv1beta1.PostgresQuery
+		allowed  bool
+		reviewed bool
+	}{
+		{"create with the use verb", requestBy("alice", "runners"), nil, runner, true, true},
+		{"create without the use verb", requestBy("alice", "developers"), nil, runner, false, true},
+		{"create without a ServiceAccount", requestBy("alice"), nil, plain, true, false},
+		{"setting the ServiceAccount without the use verb", requestBy("alice"), plain, runner, false, true},
+		{"changing the ServiceAccount with the use verb", requestBy("alice", "runners"), runner, admin, true, true},
+		{"changing the ServiceAccount without the use verb", requestBy("alice"), runner, admin, false, true},
+		{"unchanged ServiceAccount", requestBy("alice"), runner, edited, true, false},
+	}
+	for _, tt := range tests {
+		var reviews []authorizationv1.SubjectAccessReviewSpec
+		v := newValidator(t, &reviews)
+		var err error
+		if tt.old == nil {
+			_, err = v.ValidateCreate(tt.ctx, tt.new)
+		} else {
+			_, err = v.ValidateUpdate(tt.ctx, tt.old, tt.new)
+		}
+		if tt.allowed && err != nil {
+			t.Errorf("%s: err = %v, want allowed", tt.name, err)
+		}
+		if !tt.allowed && !apierrors.IsInvalid(err) {
+			t.Errorf("%s: err = %v, want Invalid", tt.name, err)
+		}
+		if reviewed := len(reviews) > 0; reviewed != tt.reviewed {
+			t.Errorf("%s: SubjectAccessReview sent = %t, want %t", tt.name, reviewed, tt.reviewed)
+		}
+		for _, r := range reviews {
+			attrs := r.ResourceAttributes
+			if attrs.Namespace != "team-a" || attrs.Name != tt.new.Spec.Job.ServiceAccountName ||
+				attrs.Resource != "serviceaccounts" || attrs.Group != "" || attrs.Verb != UseVerb {
+				t.Errorf("%s: review attributes = %+v", tt.name, attrs)
+			}
+		}
+	}
+}
@@ -0,0 +1,63 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+// Code generated by applyconfiguration-gen. DO NOT EDIT.
+
+package v1alpha1
+
+// ExecAuthApplyConfiguration represents a declarative configuration of the ExecAuth type for use
+// with apply.
+type ExecAuthApplyConfiguration struct {
+	Command *string                        `json:"command,omitempty"`
+	Args    []string                       `json:"args,omitempty"`
+	Env     []ExecEnvVarApplyConfiguration `json:"env,omitempty"`
+}
+
+// ExecAuthApplyConfiguration constructs a declarative configuration of the ExecAuth type for use with
+// apply.
+func ExecAuth() *ExecAuthApplyConfiguration {
+	return &ExecAuthApplyConfiguration{}
+}
+
+// WithCommand sets the Command field in the declarative configuration to the given value
+// and returns the receiver, so that objects can be built by chaining "With" function invocations.
+// If called multiple times, the Command field is set to the value of the last call.
+func (b *ExecAuthApplyConfiguration) WithCommand(value string) *ExecAuthApplyConfiguration {
+	b.Command = &value
+	return b
+}
+
+// WithArgs adds the given value to the Args field in the declarative configuration
+// and returns the receiver, so that objects can be build by chaining "With" function invocations.
+// If called multiple times, values provided by each call will be appended to the Args field.
+func (b *ExecAuthApplyConfiguration) WithArgs(values ...string) *ExecAuthApplyConfiguration {
+	for i := range values {
+		b.Args = append(b.Args, values[i])
+	}
+	return b
+}
+
+// WithEnv adds the given value to the Env field in the declarative configuration
+// and returns the receiver, so that objects can be build by chaining "With" function invocations.
+// If called multiple times, values provided by each call will be appended to the Env field.
+func (b *ExecAuthApplyConfiguration) WithEnv(values ...*ExecEnvVarApplyConfiguration) *ExecAuthApplyConfiguration {
+	for i := range values {
+		if values[i] == nil {
+			panic("nil value passed to WithEnv")
+		}
+		b.Env = append(b.Env, *values[i])
+	}
+	return b
+}
@@ -0,0 +1,51 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+// Code generated by applyconfiguration-gen. DO NOT EDIT.
+
+package v1alpha1
+
+import (
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// LockTimeoutRetryApplyConfiguration represents a declarative configuration of the LockTimeoutRetry type for use
+// with apply.
+type LockTimeoutRetryApplyConfiguration struct {
+	MaxAttempts *int         `json:"maxAttempts,omitempty"`
+	Backoff     *v1.Duration `json:"backoff,omitempty"`
+}
+
+// LockTimeoutRetryApplyConfiguration constructs a declarative configuration of the LockTimeoutRetry type for use with
+// apply.
+func LockTimeoutRetry() *LockTimeoutRetryApplyConfiguration {
+	return &LockTimeoutRetryApplyConfiguration{}
+}
+
+// WithMaxAttempts sets the MaxAttempts field in the declarative configuration to the given value
+// and returns the receiver, so that objects can be built by chaining "With" function invocations.
+// If called multiple times, the MaxAttempts field is set to the value of the last call.
+func (b *LockTimeoutRetryApplyConfiguration) WithMaxAttempts(value int) *LockTimeoutRetryApplyConfiguration {
+	b.MaxAttempts = &value
+	return b
+}
+
+// WithBackoff sets the Backoff field in the declarative configuration to the given value
+// and returns the receiver, so that objects can be built by chaining "With" function invocations.
+// If called multiple times, the Backoff field is set to the value of the last call.
+func (b *LockTimeoutRetryApplyConfiguration) WithBackoff(value v1.Duration) *LockTimeoutRetryApplyConfiguration {
+	b.Backoff = &value
+	return b
+}
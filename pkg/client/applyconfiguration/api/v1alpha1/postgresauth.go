@@ -0,0 +1,56 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+// Code generated by applyconfiguration-gen. DO NOT EDIT.
+
+package v1alpha1
+
+// PostgresAuthApplyConfiguration represents a declarative configuration of the PostgresAuth type for use
+// with apply.
+type PostgresAuthApplyConfiguration struct {
+	Password *PasswordAuthApplyConfiguration `json:"password,omitempty"`
+	Token    *TokenAuthApplyConfiguration    `json:"token,omitempty"`
+	Exec     *ExecAuthApplyConfiguration     `json:"exec,omitempty"`
+}
+
+// PostgresAuthApplyConfiguration constructs a declarative configuration of the PostgresAuth type for use with
+// apply.
+func PostgresAuth() *PostgresAuthApplyConfiguration {
+	return &PostgresAuthApplyConfiguration{}
+}
+
+// WithPassword sets the Password field in the declarative configuration to the given value
+// and returns the receiver, so that objects can be built by chaining "With" function invocations.
+// If called multiple times, the Password field is set to the value of the last call.
+func (b *PostgresAuthApplyConfiguration) WithPassword(value *PasswordAuthApplyConfiguration) *PostgresAuthApplyConfiguration {
+	b.Password = value
+	return b
+}
+
+// WithToken sets the Token field in the declarative configuration to the given value
+// and returns the receiver, so that objects can be built by chaining "With" function invocations.
+// If called multiple times, the Token field is set to the value of the last call.
+func (b *PostgresAuthApplyConfiguration) WithToken(value *TokenAuthApplyConfiguration) *PostgresAuthApplyConfiguration {
+	b.Token = value
+	return b
+}
+
+// WithExec sets the Exec field in the declarative configuration to the given value
+// and returns the receiver, so that objects can be built by chaining "With" function invocations.
+// If called multiple times, the Exec field is set to the value of the last call.
+func (b *PostgresAuthApplyConfiguration) WithExec(value *ExecAuthApplyConfiguration) *PostgresAuthApplyConfiguration {
+	b.Exec = value
+	return b
+}
@@ -0,0 +1,139 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+// Code generated by applyconfiguration-gen. DO NOT EDIT.
+
+package v1alpha1
+
+// PostgresConnectionApplyConfiguration represents a declarative configuration of the PostgresConnection type for use
+// with apply.
+type PostgresConnectionApplyConfiguration struct {
+	Engine             *string                              `json:"engine,omitempty"`
+	Host               *string                              `json:"host,omitempty"`
+	Port               *int                                 `json:"port,omitempty"`
+	Hosts              []PostgresHostApplyConfiguration     `json:"hosts,omitempty"`
+	TargetSessionAttrs *string                              `json:"targetSessionAttrs,omitempty"`
+	RuntimeParams      map[string]string                    `json:"runtimeParams,omitempty"`
+	Database           *string                              `json:"database,omitempty"`
+	User               *string                              `json:"user,omitempty"`
+	PasswordSecretRef  *SecretKeySelectorApplyConfiguration `json:"passwordSecretRef,omitempty"`
+	Auth               *PostgresAuthApplyConfiguration      `json:"auth,omitempty"`
+	SSL                *PostgresSSLApplyConfiguration       `json:"ssl,omitempty"`
+}
+
+// PostgresConnectionApplyConfiguration constructs a declarative configuration of the PostgresConnection type for use with
+// apply.
+func PostgresConnection() *PostgresConnectionApplyConfiguration {
+	return &PostgresConnectionApplyConfiguration{}
+}
+
+// WithEngine sets the Engine field in the declarative configuration to the given value
+// and returns the receiver, so that objects can be built by chaining "With" function invocations.
+// If called multiple times, the Engine field is set to the value of the last call.
+func (b *PostgresConnectionApplyConfiguration) WithEngine(value string) *PostgresConnectionApplyConfiguration {
+	b.Engine = &value
+	return b
+}
+
+// WithHost sets the Host field in the declarative configuration to the given value
+// and returns the receiver, so that objects can be built by chaining "With" function invocations.
+// If called multiple times, the Host field is set to the value of the last call.
+func (b *PostgresConnectionApplyConfiguration) WithHost(value string) *PostgresConnectionApplyConfiguration {
+	b.Host = &value
+	return b
+}
+
+// WithPort sets the Port field in the declarative configuration to the given value
+// and returns the receiver, so that objects can be built by chaining "With" function invocations.
+// If called multiple times, the Port field is set to the value of the last call.
+func (b *PostgresConnectionApplyConfiguration) WithPort(value int) *PostgresConnectionApplyConfiguration {
+	b.Port = &value
+	return b
+}
+
+// WithHosts adds the given value to the Hosts field in the declarative configuration
+// and returns the receiver, so that objects can be build by chaining "With" function invocations.
+// If called multiple times, values provided by each call will be appended to the Hosts field.
+func (b *PostgresConnectionApplyConfiguration) WithHosts(values ...*PostgresHostApplyConfiguration) *PostgresConnectionApplyConfiguration {
+	for i := range values {
+		if values[i] == nil {
+			panic("nil value passed to WithHosts")
+		}
+		b.Hosts = append(b.Hosts, *values[i])
+	}
+	return b
+}
+
+// WithTargetSessionAttrs sets the TargetSessionAttrs field in the declarative configuration to the given value
+// and returns the receiver, so that objects can be built by chaining "With" function invocations.
+// If called multiple times, the TargetSessionAttrs field is set to the value of the last call.
+func (b *PostgresConnectionApplyConfiguration) WithTargetSessionAttrs(value string) *PostgresConnectionApplyConfiguration {
+	b.TargetSessionAttrs = &value
+	return b
+}
+
+// WithRuntimeParams puts the entries into the RuntimeParams field in the declarative configuration
+// and returns the receiver, so that objects can be build by chaining "With" function invocations.
+// If called multiple times, the entries provided by each call will be put on the RuntimeParams field,
+// overwriting an existing map entries in RuntimeParams field with the same key.
+func (b *PostgresConnectionApplyConfiguration) WithRuntimeParams(entries map[string]string) *PostgresConnectionApplyConfiguration {
+	if b.RuntimeParams == nil && len(entries) > 0 {
+		b.RuntimeParams = make(map[string]string, len(entries))
+	}
+	for k, v := range entries {
+		b.RuntimeParams[k] = v
+	}
+	return b
+}
+
+// WithDatabase sets the Database field in the declarative configuration to the given value
+// and returns the receiver, so that objects can be built by chaining "With" function invocations.
+// If called multiple times, the Database field is set to the value of the last call.
+func (b *PostgresConnectionApplyConfiguration) WithDatabase(value string) *PostgresConnectionApplyConfiguration {
+	b.Database = &value
+	return b
+}
+
+// WithUser sets the User field in the declarative configuration to the given value
+// and returns the receiver, so that objects can be built by chaining "With" function invocations.
+// If called multiple times, the User field is set to the value of the last call.
+func (b *PostgresConnectionApplyConfiguration) WithUser(value string) *PostgresConnectionApplyConfiguration {
+	b.User = &value
+	return b
+}
+
+// WithPasswordSecretRef sets the PasswordSecretRef field in the declarative configuration to the given value
+// and returns the receiver, so that objects can be built by chaining "With" function invocations.
+// If called multiple times, the PasswordSecretRef field is set to the value of the last call.
+func (b *PostgresConnectionApplyConfiguration) WithPasswordSecretRef(value *SecretKeySelectorApplyConfiguration) *PostgresConnectionApplyConfiguration {
+	b.PasswordSecretRef = value
+	return b
+}
+
+// WithAuth sets the Auth field in the declarative configuration to the given value
+// and returns the receiver, so that objects can be built by chaining "With" function invocations.
+// If called multiple times, the Auth field is set to the value of the last call.
+func (b *PostgresConnectionApplyConfiguration) WithAuth(value *PostgresAuthApplyConfiguration) *PostgresConnectionApplyConfiguration {
+	b.Auth = value
+	return b
+}
+
+// WithSSL sets the SSL field in the declarative configuration to the given value
+// and returns the receiver, so that objects can be built by chaining "With" function invocations.
+// If called multiple times, the SSL field is set to the value of the last call.
+func (b *PostgresConnectionApplyConfiguration) WithSSL(value *PostgresSSLApplyConfiguration) *PostgresConnectionApplyConfiguration {
+	b.SSL = value
+	return b
+}
@@ -0,0 +1,47 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+// Code generated by applyconfiguration-gen. DO NOT EDIT.
+
+package v1alpha1
+
+// PostgresHostApplyConfiguration represents a declarative configuration of the PostgresHost type for use
+// with apply.
+type PostgresHostApplyConfiguration struct {
+	Host *string `json:"host,omitempty"`
+	Port *int    `json:"port,omitempty"`
+}
+
+// PostgresHostApplyConfiguration constructs a declarative configuration of the PostgresHost type for use with
+// apply.
+func PostgresHost() *PostgresHostApplyConfiguration {
+	return &PostgresHostApplyConfiguration{}
+}
+
+// WithHost sets the Host field in the declarative configuration to the given value
+// and returns the receiver, so that objects can be built by chaining "With" function invocations.
+// If called multiple times, the Host field is set to the value of the last call.
+func (b *PostgresHostApplyConfiguration) WithHost(value string) *PostgresHostApplyConfiguration {
+	b.Host = &value
+	return b
+}
+
+// WithPort sets the Port field in the declarative configuration to the given value
+// and returns the receiver, so that objects can be built by chaining "With" function invocations.
+// If called multiple times, the Port field is set to the value of the last call.
+func (b *PostgresHostApplyConfiguration) WithPort(value int) *PostgresHostApplyConfiguration {
+	b.Port = &value
+	return b
+}
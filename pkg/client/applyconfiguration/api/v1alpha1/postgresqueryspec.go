@@ -0,0 +1,114 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+// Code generated by applyconfiguration-gen. DO NOT EDIT.
+
+package v1alpha1
+
+import (
+	apiv1alpha1 "github.com/rsavage/KubeQuery/api/v1alpha1"
+)
+
+// PostgresQuerySpecApplyConfiguration represents a declarative configuration of the PostgresQuerySpec type for use
+// with apply.
+type PostgresQuerySpecApplyConfiguration struct {
+	Connection      *PostgresConnectionApplyConfiguration   `json:"connection,omitempty"`
+	SQL             *string                                 `json:"sql,omitempty"`
+	SQLConfigMapRef *ConfigMapKeySelectorApplyConfiguration `json:"sqlConfigMapRef,omitempty"`
+	SQLSecretRef    *SecretKeySelectorApplyConfiguration    `json:"sqlSecretRef,omitempty"`
+	Options         *QueryOptionsApplyConfiguration         `json:"options,omitempty"`
+	Cancel          *bool                                   `json:"cancel,omitempty"`
+	ExecutionMode   *apiv1alpha1.ExecutionMode              `json:"executionMode,omitempty"`
+	Job             *JobOptionsApplyConfiguration           `json:"job,omitempty"`
+	RequireApproval *bool                                   `json:"requireApproval,omitempty"`
+}
+
+// PostgresQuerySpecApplyConfiguration constructs a declarative configuration of the PostgresQuerySpec type for use with
+// apply.
+func PostgresQuerySpec() *PostgresQuerySpecApplyConfiguration {
+	return &PostgresQuerySpecApplyConfiguration{}
+}
+
+// WithConnection sets the Connection field in the declarative configuration to the given value
+// and returns the receiver, so that objects can be built by chaining "With" function invocations.
+// If called multiple times, the Connection field is set to the value of the last call.
+func (b *PostgresQuerySpecApplyConfiguration) WithConnection(value *PostgresConnectionApplyConfiguration) *PostgresQuerySpecApplyConfiguration {
+	b.Connection = value
+	return b
+}
+
+// WithSQL sets the SQL field in the declarative configuration to the given value
+// and returns the receiver, so that objects can be built by chaining "With" function invocations.
+// If called multiple times, the SQL field is set to the value of the last call.
+func (b *PostgresQuerySpecApplyConfiguration) WithSQL(value string) *PostgresQuerySpecApplyConfiguration {
+	b.SQL = &value
+	return b
+}
+
+// WithSQLConfigMapRef sets the SQLConfigMapRef field in the declarative configuration to the given value
+// and returns the receiver, so that objects can be built by chaining "With" function invocations.
+// If called multiple times, the SQLConfigMapRef field is set to the value of the last call.
+func (b *PostgresQuerySpecApplyConfiguration) WithSQLConfigMapRef(value *ConfigMapKeySelectorApplyConfiguration) *PostgresQuerySpecApplyConfiguration {
+	b.SQLConfigMapRef = value
+	return b
+}
+
+// WithSQLSecretRef sets the SQLSecretRef field in the declarative configuration to the given value
+// and returns the receiver, so that objects can be built by chaining "With" function invocations.
+// If called multiple times, the SQLSecretRef field is set to the value of the last call.
+func (b *PostgresQuerySpecApplyConfiguration) WithSQLSecretRef(value *SecretKeySelectorApplyConfiguration) *PostgresQuerySpecApplyConfiguration {
+	b.SQLSecretRef = value
+	return b
+}
+
+// WithOptions sets the Options field in the declarative configuration to the given value
+// and returns the receiver, so that objects can be built by chaining "With" function invocations.
+// If called multiple times, the Options field is set to the value of the last call.
+func (b *PostgresQuerySpecApplyConfiguration) WithOptions(value *QueryOptionsApplyConfiguration) *PostgresQuerySpecApplyConfiguration {
+	b.Options = value
+	return b
+}
+
+// WithCancel sets the Cancel field in the declarative configuration to the given value
+// and returns the receiver, so that objects can be built by chaining "With" function invocations.
+// If called multiple times, the Cancel field is set to the value of the last call.
+func (b *PostgresQuerySpecApplyConfiguration) WithCancel(value bool) *PostgresQuerySpecApplyConfiguration {
+	b.Cancel = &value
+	return b
+}
+
+// WithExecutionMode sets the ExecutionMode field in the declarative configuration to the given value
+// and returns the receiver, so that objects can be built by chaining "With" function invocations.
+// If called multiple times, the ExecutionMode field is set to the value of the last call.
+func (b *PostgresQuerySpecApplyConfiguration) WithExecutionMode(value apiv1alpha1.ExecutionMode) *PostgresQuerySpecApplyConfiguration {
+	b.ExecutionMode = &value
+	return b
+}
+
+// WithJob sets the Job field in the declarative configuration to the given value
+// and returns the receiver, so that objects can be built by chaining "With" function invocations.
+// If called multiple times, the Job field is set to the value of the last call.
+func (b *PostgresQuerySpecApplyConfiguration) WithJob(value *JobOptionsApplyConfiguration) *PostgresQuerySpecApplyConfiguration {
+	b.Job = value
+	return b
+}
+
+// WithRequireApproval sets the RequireApproval field in the declarative configuration to the given value
+// and returns the receiver, so that objects can be built by chaining "With" function invocations.
+// If called multiple times, the RequireApproval field is set to the value of the last call.
+func (b *PostgresQuerySpecApplyConfiguration) WithRequireApproval(value bool) *PostgresQuerySpecApplyConfiguration {
+	b.RequireApproval = &value
+	return b
+}
@@ -0,0 +1,142 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+// Code generated by applyconfiguration-gen. DO NOT EDIT.
+
+package v1alpha1
+
+import (
+	apiv1alpha1 "github.com/rsavage/KubeQuery/api/v1alpha1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// PostgresQueryStatusApplyConfiguration represents a declarative configuration of the PostgresQueryStatus type for use
+// with apply.
+type PostgresQueryStatusApplyConfiguration struct {
+	Phase           *apiv1alpha1.QueryPhase             `json:"phase,omitempty"`
+	Executed        *bool                               `json:"executed,omitempty"`
+	Error           *string                             `json:"error,omitempty"`
+	Result          *string                             `json:"result,omitempty"`
+	IdempotencyHash *string                             `json:"idempotencyHash,omitempty"`
+	ApprovalHash    *string                             `json:"approvalHash,omitempty"`
+	Connection      *ConnectionStatusApplyConfiguration `json:"connection,omitempty"`
+	BackendPID      *int32                              `json:"backendPID,omitempty"`
+	StartTime       *v1.Time                            `json:"startTime,omitempty"`
+	CompletionTime  *v1.Time                            `json:"completionTime,omitempty"`
+	JobName         *string                             `json:"jobName,omitempty"`
+	Plan            *string                             `json:"plan,omitempty"`
+}
+
+// PostgresQueryStatusApplyConfiguration constructs a declarative configuration of the PostgresQueryStatus type for use with
+// apply.
+func PostgresQueryStatus() *PostgresQueryStatusApplyConfiguration {
+	return &PostgresQueryStatusApplyConfiguration{}
+}
+
+// WithPhase sets the Phase field in the declarative configuration to the given value
+// and returns the receiver, so that objects can be built by chaining "With" function invocations.
+// If called multiple times, the Phase field is set to the value of the last call.
+func (b *PostgresQueryStatusApplyConfiguration) WithPhase(value apiv1alpha1.QueryPhase) *PostgresQueryStatusApplyConfiguration {
+	b.Phase = &value
+	return b
+}
+
+// WithExecuted sets the Executed field in the declarative configuration to the given value
+// and returns the receiver, so that objects can be built by chaining "With" function invocations.
+// If called multiple times, the Executed field is set to the value of the last call.
+func (b *PostgresQueryStatusApplyConfiguration) WithExecuted(value bool) *PostgresQueryStatusApplyConfiguration {
+	b.Executed = &value
+	return b
+}
+
+// WithError sets the Error field in the declarative configuration to the given value
+// and returns the receiver, so that objects can be built by chaining "With" function invocations.
+// If called multiple times, the Error field is set to the value of the last call.
+func (b *PostgresQueryStatusApplyConfiguration) WithError(value string) *PostgresQueryStatusApplyConfiguration {
+	b.Error = &value
+	return b
+}
+
+// WithResult sets the Result field in the declarative configuration to the given value
+// and returns the receiver, so that objects can be built by chaining "With" function invocations.
+// If called multiple times, the Result field is set to the value of the last call.
+func (b *PostgresQueryStatusApplyConfiguration) WithResult(value string) *PostgresQueryStatusApplyConfiguration {
+	b.Result = &value
+	return b
+}
+
+// WithIdempotencyHash sets the IdempotencyHash field in the declarative configuration to the given value
+// and returns the receiver, so that objects can be built by chaining "With" function invocations.
+// If called multiple times, the IdempotencyHash field is set to the value of the last call.
+func (b *PostgresQueryStatusApplyConfiguration) WithIdempotencyHash(value string) *PostgresQueryStatusApplyConfiguration {
+	b.IdempotencyHash = &value
+	return b
+}
+
+// WithApprovalHash sets the ApprovalHash field in the declarative configuration to the given value
+// and returns the receiver, so that objects can be built by chaining "With" function invocations.
+// If called multiple times, the ApprovalHash field is set to the value of the last call.
+func (b *PostgresQueryStatusApplyConfiguration) WithApprovalHash(value string) *PostgresQueryStatusApplyConfiguration {
+	b.ApprovalHash = &value
+	return b
+}
+
+// WithConnection sets the Connection field in the declarative configuration to the given value
+// and returns the receiver, so that objects can be built by chaining "With" function invocations.
+// If called multiple times, the Connection field is set to the value of the last call.
+func (b *PostgresQueryStatusApplyConfiguration) WithConnection(value *ConnectionStatusApplyConfiguration) *PostgresQueryStatusApplyConfiguration {
+	b.Connection = value
+	return b
+}
+
+// WithBackendPID sets the BackendPID field in the declarative configuration to the given value
+// and returns the receiver, so that objects can be built by chaining "With" function invocations.
+// If called multiple times, the BackendPID field is set to the value of the last call.
+func (b *PostgresQueryStatusApplyConfiguration) WithBackendPID(value int32) *PostgresQueryStatusApplyConfiguration {
+	b.BackendPID = &value
+	return b
+}
+
+// WithStartTime sets the StartTime field in the declarative configuration to the given value
+// and returns the receiver, so that objects can be built by chaining "With" function invocations.
+// If called multiple times, the StartTime field is set to the value of the last call.
+func (b *PostgresQueryStatusApplyConfiguration) WithStartTime(value v1.Time) *PostgresQueryStatusApplyConfiguration {
+	b.StartTime = &value
+	return b
+}
+
+// WithCompletionTime sets the CompletionTime field in the declarative configuration to the given value
+// and returns the receiver, so that objects can be built by chaining "With" function invocations.
+// If called multiple times, the CompletionTime field is set to the value of the last call.
+func (b *PostgresQueryStatusApplyConfiguration) WithCompletionTime(value v1.Time) *PostgresQueryStatusApplyConfiguration {
+	b.CompletionTime = &value
+	return b
+}
+
+// WithJobName sets the JobName field in the declarative configuration to the given value
+// and returns the receiver, so that objects can be built by chaining "With" function invocations.
+// If called multiple times, the JobName field is set to the value of the last call.
+func (b *PostgresQueryStatusApplyConfiguration) WithJobName(value string) *PostgresQueryStatusApplyConfiguration {
+	b.JobName = &value
+	return b
+}
+
+// WithPlan sets the Plan field in the declarative configuration to the given value
+// and returns the receiver, so that objects can be built by chaining "With" function invocations.
+// If called multiple times, the Plan field is set to the value of the last call.
+func (b *PostgresQueryStatusApplyConfiguration) WithPlan(value string) *PostgresQueryStatusApplyConfiguration {
+	b.Plan = &value
+	return b
+}
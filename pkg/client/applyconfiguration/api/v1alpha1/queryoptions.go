@@ -0,0 +1,96 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+// Code generated by applyconfiguration-gen. DO NOT EDIT.
+
+package v1alpha1
+
+import (
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// QueryOptionsApplyConfiguration represents a declarative configuration of the QueryOptions type for use
+// with apply.
+type QueryOptionsApplyConfiguration struct {
+	TimeoutSeconds                  *int                                `json:"timeoutSeconds,omitempty"`
+	LockTimeout                     *v1.Duration                        `json:"lockTimeout,omitempty"`
+	StatementTimeout                *v1.Duration                        `json:"statementTimeout,omitempty"`
+	IdleInTransactionSessionTimeout *v1.Duration                        `json:"idleInTransactionSessionTimeout,omitempty"`
+	RetryOnLockTimeout              *LockTimeoutRetryApplyConfiguration `json:"retryOnLockTimeout,omitempty"`
+	RedactSQLLiterals               *bool                               `json:"redactSQLLiterals,omitempty"`
+	Explain                         *ExplainOptionsApplyConfiguration   `json:"explain,omitempty"`
+}
+
+// QueryOptionsApplyConfiguration constructs a declarative configuration of the QueryOptions type for use with
+// apply.
+func QueryOptions() *QueryOptionsApplyConfiguration {
+	return &QueryOptionsApplyConfiguration{}
+}
+
+// WithTimeoutSeconds sets the TimeoutSeconds field in the declarative configuration to the given value
+// and returns the receiver, so that objects can be built by chaining "With" function invocations.
+// If called multiple times, the TimeoutSeconds field is set to the value of the last call.
+func (b *QueryOptionsApplyConfiguration) WithTimeoutSeconds(value int) *QueryOptionsApplyConfiguration {
+	b.TimeoutSeconds = &value
+	return b
+}
+
+// WithLockTimeout sets the LockTimeout field in the declarative configuration to the given value
+// and returns the receiver, so that objects can be built by chaining "With" function invocations.
+// If called multiple times, the LockTimeout field is set to the value of the last call.
+func (b *QueryOptionsApplyConfiguration) WithLockTimeout(value v1.Duration) *QueryOptionsApplyConfiguration {
+	b.LockTimeout = &value
+	return b
+}
+
+// WithStatementTimeout sets the StatementTimeout field in the declarative configuration to the given value
+// and returns the receiver, so that objects can be built by chaining "With" function invocations.
+// If called multiple times, the StatementTimeout field is set to the value of the last call.
+func (b *QueryOptionsApplyConfiguration) WithStatementTimeout(value v1.Duration) *QueryOptionsApplyConfiguration {
+	b.StatementTimeout = &value
+	return b
+}
+
+// WithIdleInTransactionSessionTimeout sets the IdleInTransactionSessionTimeout field in the declarative configuration to the given value
+// and returns the receiver, so that objects can be built by chaining "With" function invocations.
+// If called multiple times, the IdleInTransactionSessionTimeout field is set to the value of the last call.
+func (b *QueryOptionsApplyConfiguration) WithIdleInTransactionSessionTimeout(value v1.Duration) *QueryOptionsApplyConfiguration {
+	b.IdleInTransactionSessionTimeout = &value
+	return b
+}
+
+// WithRetryOnLockTimeout sets the RetryOnLockTimeout field in the declarative configuration to the given value
+// and returns the receiver, so that objects can be built by chaining "With" function invocations.
+// If called multiple times, the RetryOnLockTimeout field is set to the value of the last call.
+func (b *QueryOptionsApplyConfiguration) WithRetryOnLockTimeout(value *LockTimeoutRetryApplyConfiguration) *QueryOptionsApplyConfiguration {
+	b.RetryOnLockTimeout = value
+	return b
+}
+
+// WithRedactSQLLiterals sets the RedactSQLLiterals field in the declarative configuration to the given value
+// and returns the receiver, so that objects can be built by chaining "With" function invocations.
+// If called multiple times, the RedactSQLLiterals field is set to the value of the last call.
+func (b *QueryOptionsApplyConfiguration) WithRedactSQLLiterals(value bool) *QueryOptionsApplyConfiguration {
+	b.RedactSQLLiterals = &value
+	return b
+}
+
+// WithExplain sets the Explain field in the declarative configuration to the given value
+// and returns the receiver, so that objects can be built by chaining "With" function invocations.
+// If called multiple times, the Explain field is set to the value of the last call.
+func (b *QueryOptionsApplyConfiguration) WithExplain(value *ExplainOptionsApplyConfiguration) *QueryOptionsApplyConfiguration {
+	b.Explain = value
+	return b
+}
@@ -0,0 +1,66 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+// Code generated by applyconfiguration-gen. DO NOT EDIT.
+
+package v1beta1
+
+import (
+	v1 "k8s.io/api/core/v1"
+)
+
+// JobOptionsApplyConfiguration represents a declarative configuration of the JobOptions type for use
+// with apply.
+type JobOptionsApplyConfiguration struct {
+	Resources          *v1.ResourceRequirements `json:"resources,omitempty"`
+	ServiceAccountName *string                  `json:"serviceAccountName,omitempty"`
+	PodLabels          map[string]string        `json:"podLabels,omitempty"`
+}
+
+// JobOptionsApplyConfiguration constructs a declarative configuration of the JobOptions type for use with
+// apply.
+func JobOptions() *JobOptionsApplyConfiguration {
+	return &JobOptionsApplyConfiguration{}
+}
+
+// WithResources sets the Resources field in the declarative configuration to the given value
+// and returns the receiver, so that objects can be built by chaining "With" function invocations.
+// If called multiple times, the Resources field is set to the value of the last call.
+func (b *JobOptionsApplyConfiguration) WithResources(value v1.ResourceRequirements) *JobOptionsApplyConfiguration {
+	b.Resources = &value
+	return b
+}
+
+// WithServiceAccountName sets the ServiceAccountName field in the declarative configuration to the given value
+// and returns the receiver, so that objects can be built by chaining "With" function invocations.
+// If called multiple times, the ServiceAccountName field is set to the value of the last call.
+func (b *JobOptionsApplyConfiguration) WithServiceAccountName(value string) *JobOptionsApplyConfiguration {
+	b.ServiceAccountName = &value
+	return b
+}
+
+// WithPodLabels puts the entries into the PodLabels field in the declarative configuration
+// and returns the receiver, so that objects can be build by chaining "With" function invocations.
+// If called multiple times, the entries provided by each call will be put on the PodLabels field,
+// overwriting an existing map entries in PodLabels field with the same key.
+func (b *JobOptionsApplyConfiguration) WithPodLabels(entries map[string]string) *JobOptionsApplyConfiguration {
+	if b.PodLabels == nil && len(entries) > 0 {
+		b.PodLabels = make(map[string]string, len(entries))
+	}
+	for k, v := range entries {
+		b.PodLabels[k] = v
+	}
+	return b
+}
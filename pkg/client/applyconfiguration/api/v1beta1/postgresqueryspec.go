@@ -0,0 +1,105 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+// Code generated by applyconfiguration-gen. DO NOT EDIT.
+
+package v1beta1
+
+import (
+	apiv1beta1 "github.com/rsavage/KubeQuery/api/v1beta1"
+)
+
+// PostgresQuerySpecApplyConfiguration represents a declarative configuration of the PostgresQuerySpec type for use
+// with apply.
+type PostgresQuerySpecApplyConfiguration struct {
+	Connection      *PostgresConnectionSpecApplyConfiguration `json:"connection,omitempty"`
+	ConnectionRef   *ConnectionReferenceApplyConfiguration    `json:"connectionRef,omitempty"`
+	SQLSource       *SQLSourceApplyConfiguration              `json:"sqlSource,omitempty"`
+	Options         *QueryOptionsApplyConfiguration           `json:"options,omitempty"`
+	Cancel          *bool                                     `json:"cancel,omitempty"`
+	ExecutionMode   *apiv1beta1.ExecutionMode                 `json:"executionMode,omitempty"`
+	Job             *JobOptionsApplyConfiguration             `json:"job,omitempty"`
+	RequireApproval *bool                                     `json:"requireApproval,omitempty"`
+}
+
+// PostgresQuerySpecApplyConfiguration constructs a declarative configuration of the PostgresQuerySpec type for use with
+// apply.
+func PostgresQuerySpec() *PostgresQuerySpecApplyConfiguration {
+	return &PostgresQuerySpecApplyConfiguration{}
+}
+
+// WithConnection sets the Connection field in the declarative configuration to the given value
+// and returns the receiver, so that objects can be built by chaining "With" function invocations.
+// If called multiple times, the Connection field is set to the value of the last call.
+func (b *PostgresQuerySpecApplyConfiguration) WithConnection(value *PostgresConnectionSpecApplyConfiguration) *PostgresQuerySpecApplyConfiguration {
+	b.Connection = value
+	return b
+}
+
+// WithConnectionRef sets the ConnectionRef field in the declarative configuration to the given value
+// and returns the receiver, so that objects can be built by chaining "With" function invocations.
+// If called multiple times, the ConnectionRef field is set to the value of the last call.
+func (b *PostgresQuerySpecApplyConfiguration) WithConnectionRef(value *ConnectionReferenceApplyConfiguration) *PostgresQuerySpecApplyConfiguration {
+	b.ConnectionRef = value
+	return b
+}
+
+// WithSQLSource sets the SQLSource field in the declarative configuration to the given value
+// and returns the receiver, so that objects can be built by chaining "With" function invocations.
+// If called multiple times, the SQLSource field is set to the value of the last call.
+func (b *PostgresQuerySpecApplyConfiguration) WithSQLSource(value *SQLSourceApplyConfiguration) *PostgresQuerySpecApplyConfiguration {
+	b.SQLSource = value
+	return b
+}
+
+// WithOptions sets the Options field in the declarative configuration to the given value
+// and returns the receiver, so that objects can be built by chaining "With" function invocations.
+// If called multiple times, the Options field is set to the value of the last call.
+func (b *PostgresQuerySpecApplyConfiguration) WithOptions(value *QueryOptionsApplyConfiguration) *PostgresQuerySpecApplyConfiguration {
+	b.Options = value
+	return b
+}
+
+// WithCancel sets the Cancel field in the declarative configuration to the given value
+// and returns the receiver, so that objects can be built by chaining "With" function invocations.
+// If called multiple times, the Cancel field is set to the value of the last call.
+func (b *PostgresQuerySpecApplyConfiguration) WithCancel(value bool) *PostgresQuerySpecApplyConfiguration {
+	b.Cancel = &value
+	return b
+}
+
+// WithExecutionMode sets the ExecutionMode field in the declarative configuration to the given value
+// and returns the receiver, so that objects can be built by chaining "With" function invocations.
+// If called multiple times, the ExecutionMode field is set to the value of the last call.
+func (b *PostgresQuerySpecApplyConfiguration) WithExecutionMode(value apiv1beta1.ExecutionMode) *PostgresQuerySpecApplyConfiguration {
+	b.ExecutionMode = &value
+	return b
+}
+
+// WithJob sets the Job field in the declarative configuration to the given value
+// and returns the receiver, so that objects can be built by chaining "With" function invocations.
+// If called multiple times, the Job field is set to the value of the last call.
+func (b *PostgresQuerySpecApplyConfiguration) WithJob(value *JobOptionsApplyConfiguration) *PostgresQuerySpecApplyConfiguration {
+	b.Job = value
+	return b
+}
+
+// WithRequireApproval sets the RequireApproval field in the declarative configuration to the given value
+// and returns the receiver, so that objects can be built by chaining "With" function invocations.
+// If called multiple times, the RequireApproval field is set to the value of the last call.
+func (b *PostgresQuerySpecApplyConfiguration) WithRequireApproval(value bool) *PostgresQuerySpecApplyConfiguration {
+	b.RequireApproval = &value
+	return b
+}
@@ -0,0 +1,139 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+// Code generated by applyconfiguration-gen. DO NOT EDIT.
+
+package v1beta1
+
+import (
+	apiv1beta1 "github.com/rsavage/KubeQuery/api/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	v1 "k8s.io/client-go/applyconfigurations/meta/v1"
+)
+
+// PostgresQueryStatusApplyConfiguration represents a declarative configuration of the PostgresQueryStatus type for use
+// with apply.
+type PostgresQueryStatusApplyConfiguration struct {
+	Conditions      []v1.ConditionApplyConfiguration    `json:"conditions,omitempty"`
+	Phase           *apiv1beta1.QueryPhase              `json:"phase,omitempty"`
+	Result          *string                             `json:"result,omitempty"`
+	IdempotencyHash *string                             `json:"idempotencyHash,omitempty"`
+	ApprovalHash    *string                             `json:"approvalHash,omitempty"`
+	Connection      *ConnectionStatusApplyConfiguration `json:"connection,omitempty"`
+	BackendPID      *int32                              `json:"backendPID,omitempty"`
+	StartTime       *metav1.Time                        `json:"startTime,omitempty"`
+	CompletionTime  *metav1.Time                        `json:"completionTime,omitempty"`
+	JobName         *string                             `json:"jobName,omitempty"`
+	Plan            *string                             `json:"plan,omitempty"`
+}
+
+// PostgresQueryStatusApplyConfiguration constructs a declarative configuration of the PostgresQueryStatus type for use with
+// apply.
+func PostgresQueryStatus() *PostgresQueryStatusApplyConfiguration {
+	return &PostgresQueryStatusApplyConfiguration{}
+}
+
+// WithConditions adds the given value to the Conditions field in the declarative configuration
+// and returns the receiver, so that objects can be build by chaining "With" function invocations.
+// If called multiple times, values provided by each call will be appended to the Conditions field.
+func (b *PostgresQueryStatusApplyConfiguration) WithConditions(values ...*v1.ConditionApplyConfiguration) *PostgresQueryStatusApplyConfiguration {
+	for i := range values {
+		if values[i] == nil {
+			panic("nil value passed to WithConditions")
+		}
+		b.Conditions = append(b.Conditions, *values[i])
+	}
+	return b
+}
+
+// WithPhase sets the Phase field in the declarative configuration to the given value
+// and returns the receiver, so that objects can be built by chaining "With" function invocations.
+// If called multiple times, the Phase field is set to the value of the last call.
+func (b *PostgresQueryStatusApplyConfiguration) WithPhase(value apiv1beta1.QueryPhase) *PostgresQueryStatusApplyConfiguration {
+	b.Phase = &value
+	return b
+}
+
+// WithResult sets the Result field in the declarative configuration to the given value
+// and returns the receiver, so that objects can be built by chaining "With" function invocations.
+// If called multiple times, the Result field is set to the value of the last call.
+func (b *PostgresQueryStatusApplyConfiguration) WithResult(value string) *PostgresQueryStatusApplyConfiguration {
+	b.Result = &value
+	return b
+}
+
+// WithIdempotencyHash sets the IdempotencyHash field in the declarative configuration to the given value
+// and returns the receiver, so that objects can be built by chaining "With" function invocations.
+// If called multiple times, the IdempotencyHash field is set to the value of the last call.
+func (b *PostgresQueryStatusApplyConfiguration) WithIdempotencyHash(value string) *PostgresQueryStatusApplyConfiguration {
+	b.IdempotencyHash = &value
+	return b
+}
+
+// WithApprovalHash sets the ApprovalHash field in the declarative configuration to the given value
+// and returns the receiver, so that objects can be built by chaining "With" function invocations.
+// If called multiple times, the ApprovalHash field is set to the value of the last call.
+func (b *PostgresQueryStatusApplyConfiguration) WithApprovalHash(value string) *PostgresQueryStatusApplyConfiguration {
+	b.ApprovalHash = &value
+	return b
+}
+
+// WithConnection sets the Connection field in the declarative configuration to the given value
+// and returns the receiver, so that objects can be built by chaining "With" function invocations.
+// If called multiple times, the Connection field is set to the value of the last call.
+func (b *PostgresQueryStatusApplyConfiguration) WithConnection(value *ConnectionStatusApplyConfiguration) *PostgresQueryStatusApplyConfiguration {
+	b.Connection = value
+	return b
+}
+
+// WithBackendPID sets the BackendPID field in the declarative configuration to the given value
+// and returns the receiver, so that objects can be built by chaining "With" function invocations.
+// If called multiple times, the BackendPID field is set to the value of the last call.
+func (b *PostgresQueryStatusApplyConfiguration) WithBackendPID(value int32) *PostgresQueryStatusApplyConfiguration {
+	b.BackendPID = &value
+	return b
+}
+
+// WithStartTime sets the StartTime field in the declarative configuration to the given value
+// and returns the receiver, so that objects can be built by chaining "With" function invocations.
+// If called multiple times, the StartTime field is set to the value of the last call.
+func (b *PostgresQueryStatusApplyConfiguration) WithStartTime(value metav1.Time) *PostgresQueryStatusApplyConfiguration {
+	b.StartTime = &value
+	return b
+}
+
+// WithCompletionTime sets the CompletionTime field in the declarative configuration to the given value
+// and returns the receiver, so that objects can be built by chaining "With" function invocations.
+// If called multiple times, the CompletionTime field is set to the value of the last call.
+func (b *PostgresQueryStatusApplyConfiguration) WithCompletionTime(value metav1.Time) *PostgresQueryStatusApplyConfiguration {
+	b.CompletionTime = &value
+	return b
+}
+
+// WithJobName sets the JobName field in the declarative configuration to the given value
+// and returns the receiver, so that objects can be built by chaining "With" function invocations.
+// If called multiple times, the JobName field is set to the value of the last call.
+func (b *PostgresQueryStatusApplyConfiguration) WithJobName(value string) *PostgresQueryStatusApplyConfiguration {
+	b.JobName = &value
+	return b
+}
+
+// WithPlan sets the Plan field in the declarative configuration to the given value
+// and returns the receiver, so that objects can be built by chaining "With" function invocations.
+// If called multiple times, the Plan field is set to the value of the last call.
+func (b *PostgresQueryStatusApplyConfiguration) WithPlan(value string) *PostgresQueryStatusApplyConfiguration {
+	b.Plan = &value
+	return b
+}
@@ -0,0 +1,74 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+// Code generated by applyconfiguration-gen. DO NOT EDIT.
+
+package v1beta1
+
+// PostgresSSLApplyConfiguration represents a declarative configuration of the PostgresSSL type for use
+// with apply.
+type PostgresSSLApplyConfiguration struct {
+	Mode                *string                              `json:"mode,omitempty"`
+	CaSecretRef         *SecretKeySelectorApplyConfiguration `json:"caSecretRef,omitempty"`
+	ClientCertSecretRef *SecretReferenceApplyConfiguration   `json:"clientCertSecretRef,omitempty"`
+	ServerName          *string                              `json:"serverName,omitempty"`
+	CRLSecretRef        *SecretKeySelectorApplyConfiguration `json:"crlSecretRef,omitempty"`
+}
+
+// PostgresSSLApplyConfiguration constructs a declarative configuration of the PostgresSSL type for use with
+// apply.
+func PostgresSSL() *PostgresSSLApplyConfiguration {
+	return &PostgresSSLApplyConfiguration{}
+}
+
+// WithMode sets the Mode field in the declarative configuration to the given value
+// and returns the receiver, so that objects can be built by chaining "With" function invocations.
+// If called multiple times, the Mode field is set to the value of the last call.
+func (b *PostgresSSLApplyConfiguration) WithMode(value string) *PostgresSSLApplyConfiguration {
+	b.Mode = &value
+	return b
+}
+
+// WithCaSecretRef sets the CaSecretRef field in the declarative configuration to the given value
+// and returns the receiver, so that objects can be built by chaining "With" function invocations.
+// If called multiple times, the CaSecretRef field is set to the value of the last call.
+func (b *PostgresSSLApplyConfiguration) WithCaSecretRef(value *SecretKeySelectorApplyConfiguration) *PostgresSSLApplyConfiguration {
+	b.CaSecretRef = value
+	return b
+}
+
+// WithClientCertSecretRef sets the ClientCertSecretRef field in the declarative configuration to the given value
+// and returns the receiver, so that objects can be built by chaining "With" function invocations.
+// If called multiple times, the ClientCertSecretRef field is set to the value of the last call.
+func (b *PostgresSSLApplyConfiguration) WithClientCertSecretRef(value *SecretReferenceApplyConfiguration) *PostgresSSLApplyConfiguration {
+	b.ClientCertSecretRef = value
+	return b
+}
+
+// WithServerName sets the ServerName field in the declarative configuration to the given value
+// and returns the receiver, so that objects can be built by chaining "With" function invocations.
+// If called multiple times, the ServerName field is set to the value of the last call.
+func (b *PostgresSSLApplyConfiguration) WithServerName(value string) *PostgresSSLApplyConfiguration {
+	b.ServerName = &value
+	return b
+}
+
+// WithCRLSecretRef sets the CRLSecretRef field in the declarative configuration to the given value
+// and returns the receiver, so that objects can be built by chaining "With" function invocations.
+// If called multiple times, the CRLSecretRef field is set to the value of the last call.
+func (b *PostgresSSLApplyConfiguration) WithCRLSecretRef(value *SecretKeySelectorApplyConfiguration) *PostgresSSLApplyConfiguration {
+	b.CRLSecretRef = value
+	return b
+}
@@ -0,0 +1,56 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+// Code generated by applyconfiguration-gen. DO NOT EDIT.
+
+package v1beta1
+
+// SQLSourceApplyConfiguration represents a declarative configuration of the SQLSource type for use
+// with apply.
+type SQLSourceApplyConfiguration struct {
+	Inline          *string                                 `json:"inline,omitempty"`
+	ConfigMapKeyRef *ConfigMapKeySelectorApplyConfiguration `json:"configMapKeyRef,omitempty"`
+	SecretKeyRef    *SecretKeySelectorApplyConfiguration    `json:"secretKeyRef,omitempty"`
+}
+
+// SQLSourceApplyConfiguration constructs a declarative configuration of the SQLSource type for use with
+// apply.
+func SQLSource() *SQLSourceApplyConfiguration {
+	return &SQLSourceApplyConfiguration{}
+}
+
+// WithInline sets the Inline field in the declarative configuration to the given value
+// and returns the receiver, so that objects can be built by chaining "With" function invocations.
+// If called multiple times, the Inline field is set to the value of the last call.
+func (b *SQLSourceApplyConfiguration) WithInline(value string) *SQLSourceApplyConfiguration {
+	b.Inline = &value
+	return b
+}
+
+// WithConfigMapKeyRef sets the ConfigMapKeyRef field in the declarative configuration to the given value
+// and returns the receiver, so that objects can be built by chaining "With" function invocations.
+// If called multiple times, the ConfigMapKeyRef field is set to the value of the last call.
+func (b *SQLSourceApplyConfiguration) WithConfigMapKeyRef(value *ConfigMapKeySelectorApplyConfiguration) *SQLSourceApplyConfiguration {
+	b.ConfigMapKeyRef = value
+	return b
+}
+
+// WithSecretKeyRef sets the SecretKeyRef field in the declarative configuration to the given value
+// and returns the receiver, so that objects can be built by chaining "With" function invocations.
+// If called multiple times, the SecretKeyRef field is set to the value of the last call.
+func (b *SQLSourceApplyConfiguration) WithSecretKeyRef(value *SecretKeySelectorApplyConfiguration) *SQLSourceApplyConfiguration {
+	b.SecretKeyRef = value
+	return b
+}
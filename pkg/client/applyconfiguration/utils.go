@@ -0,0 +1,127 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+// Code generated by applyconfiguration-gen. DO NOT EDIT.
+
+package applyconfiguration
+
+import (
+	v1alpha1 "github.com/rsavage/KubeQuery/api/v1alpha1"
+	v1beta1 "github.com/rsavage/KubeQuery/api/v1beta1"
+	apiv1alpha1 "github.com/rsavage/KubeQuery/pkg/client/applyconfiguration/api/v1alpha1"
+	apiv1beta1 "github.com/rsavage/KubeQuery/pkg/client/applyconfiguration/api/v1beta1"
+	internal "github.com/rsavage/KubeQuery/pkg/client/applyconfiguration/internal"
+	runtime "k8s.io/apimachinery/pkg/runtime"
+	schema "k8s.io/apimachinery/pkg/runtime/schema"
+	testing "k8s.io/client-go/testing"
+)
+
+// ForKind returns an apply configuration type for the given GroupVersionKind, or nil if no
+// apply configuration type exists for the given GroupVersionKind.
+func ForKind(kind schema.GroupVersionKind) interface{} {
+	switch kind {
+	// Group=kubequery.cloudnexus.io, Version=v1alpha1
+	case v1alpha1.SchemeGroupVersion.WithKind("ConfigMapKeySelector"):
+		return &apiv1alpha1.ConfigMapKeySelectorApplyConfiguration{}
+	case v1alpha1.SchemeGroupVersion.WithKind("ConnectionStatus"):
+		return &apiv1alpha1.ConnectionStatusApplyConfiguration{}
+	case v1alpha1.SchemeGroupVersion.WithKind("ExecAuth"):
+		return &apiv1alpha1.ExecAuthApplyConfiguration{}
+	case v1alpha1.SchemeGroupVersion.WithKind("ExecEnvVar"):
+		return &apiv1alpha1.ExecEnvVarApplyConfiguration{}
+	case v1alpha1.SchemeGroupVersion.WithKind("ExplainOptions"):
+		return &apiv1alpha1.ExplainOptionsApplyConfiguration{}
+	case v1alpha1.SchemeGroupVersion.WithKind("JobOptions"):
+		return &apiv1alpha1.JobOptionsApplyConfiguration{}
+	case v1alpha1.SchemeGroupVersion.WithKind("LockTimeoutRetry"):
+		return &apiv1alpha1.LockTimeoutRetryApplyConfiguration{}
+	case v1alpha1.SchemeGroupVersion.WithKind("PasswordAuth"):
+		return &apiv1alpha1.PasswordAuthApplyConfiguration{}
+	case v1alpha1.SchemeGroupVersion.WithKind("PostgresAuth"):
+		return &apiv1alpha1.PostgresAuthApplyConfiguration{}
+	case v1alpha1.SchemeGroupVersion.WithKind("PostgresConnection"):
+		return &apiv1alpha1.PostgresConnectionApplyConfiguration{}
+	case v1alpha1.SchemeGroupVersion.WithKind("PostgresHost"):
+		return &apiv1alpha1.PostgresHostApplyConfiguration{}
+	case v1alpha1.SchemeGroupVersion.WithKind("PostgresQuery"):
+		return &apiv1alpha1.PostgresQueryApplyConfiguration{}
+	case v1alpha1.SchemeGroupVersion.WithKind("PostgresQuerySpec"):
+		return &apiv1alpha1.PostgresQuerySpecApplyConfiguration{}
+	case v1alpha1.SchemeGroupVersion.WithKind("PostgresQueryStatus"):
+		return &apiv1alpha1.PostgresQueryStatusApplyConfiguration{}
+	case v1alpha1.SchemeGroupVersion.WithKind("PostgresSSL"):
+		return &apiv1alpha1.PostgresSSLApplyConfiguration{}
+	case v1alpha1.SchemeGroupVersion.WithKind("QueryOptions"):
+		return &apiv1alpha1.QueryOptionsApplyConfiguration{}
+	case v1alpha1.SchemeGroupVersion.WithKind("SecretKeySelector"):
+		return &apiv1alpha1.SecretKeySelectorApplyConfiguration{}
+	case v1alpha1.SchemeGroupVersion.WithKind("SecretReference"):
+		return &apiv1alpha1.SecretReferenceApplyConfiguration{}
+	case v1alpha1.SchemeGroupVersion.WithKind("TokenAuth"):
+		return &apiv1alpha1.TokenAuthApplyConfiguration{}
+
+		// Group=kubequery.cloudnexus.io, Version=v1beta1
+	case v1beta1.SchemeGroupVersion.WithKind("ConfigMapKeySelector"):
+		return &apiv1beta1.ConfigMapKeySelectorApplyConfiguration{}
+	case v1beta1.SchemeGroupVersion.WithKind("ConnectionReference"):
+		return &apiv1beta1.ConnectionReferenceApplyConfiguration{}
+	case v1beta1.SchemeGroupVersion.WithKind("ConnectionStatus"):
+		return &apiv1beta1.ConnectionStatusApplyConfiguration{}
+	case v1beta1.SchemeGroupVersion.WithKind("ExecAuth"):
+		return &apiv1beta1.ExecAuthApplyConfiguration{}
+	case v1beta1.SchemeGroupVersion.WithKind("ExecEnvVar"):
+		return &apiv1beta1.ExecEnvVarApplyConfiguration{}
+	case v1beta1.SchemeGroupVersion.WithKind("ExplainOptions"):
+		return &apiv1beta1.ExplainOptionsApplyConfiguration{}
+	case v1beta1.SchemeGroupVersion.WithKind("JobOptions"):
+		return &apiv1beta1.JobOptionsApplyConfiguration{}
+	case v1beta1.SchemeGroupVersion.WithKind("LockTimeoutRetry"):
+		return &apiv1beta1.LockTimeoutRetryApplyConfiguration{}
+	case v1beta1.SchemeGroupVersion.WithKind("PasswordAuth"):
+		return &apiv1beta1.PasswordAuthApplyConfiguration{}
+	case v1beta1.SchemeGroupVersion.WithKind("PostgresAuth"):
+		return &apiv1beta1.PostgresAuthApplyConfiguration{}
+	case v1beta1.SchemeGroupVersion.WithKind("PostgresConnection"):
+		return &apiv1beta1.PostgresConnectionApplyConfiguration{}
+	case v1beta1.SchemeGroupVersion.WithKind("PostgresConnectionSpec"):
+		return &apiv1beta1.PostgresConnectionSpecApplyConfiguration{}
+	case v1beta1.SchemeGroupVersion.WithKind("PostgresHost"):
+		return &apiv1beta1.PostgresHostApplyConfiguration{}
+	case v1beta1.SchemeGroupVersion.WithKind("PostgresQuery"):
+		return &apiv1beta1.PostgresQueryApplyConfiguration{}
+	case v1beta1.SchemeGroupVersion.WithKind("PostgresQuerySpec"):
+		return &apiv1beta1.PostgresQuerySpecApplyConfiguration{}
+	case v1beta1.SchemeGroupVersion.WithKind("PostgresQueryStatus"):
+		return &apiv1beta1.PostgresQueryStatusApplyConfiguration{}
+	case v1beta1.SchemeGroupVersion.WithKind("PostgresSSL"):
+		return &apiv1beta1.PostgresSSLApplyConfiguration{}
+	case v1beta1.SchemeGroupVersion.WithKind("QueryOptions"):
+		return &apiv1beta1.QueryOptionsApplyConfiguration{}
+	case v1beta1.SchemeGroupVersion.WithKind("SecretKeySelector"):
+		return &apiv1beta1.SecretKeySelectorApplyConfiguration{}
+	case v1beta1.SchemeGroupVersion.WithKind("SecretReference"):
+		return &apiv1beta1.SecretReferenceApplyConfiguration{}
+	case v1beta1.SchemeGroupVersion.WithKind("SQLSource"):
+		return &apiv1beta1.SQLSourceApplyConfiguration{}
+	case v1beta1.SchemeGroupVersion.WithKind("TokenAuth"):
+		return &apiv1beta1.TokenAuthApplyConfiguration{}
+
+	}
+	return nil
+}
+
+func NewTypeConverter(scheme *runtime.Scheme) *testing.TypeConverter {
+	return &testing.TypeConverter{Scheme: scheme, TypeResolver: internal.Parser()}
+}
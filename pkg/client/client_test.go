@@ -0,0 +1,63 @@
+package client_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+
+	kubequeryv1alpha1 "github.com/rsavage/KubeQuery/api/v1alpha1"
+	applyv1alpha1 "github.com/rsavage/KubeQuery/pkg/client/applyconfiguration/api/v1alpha1"
+	"github.com/rsavage/KubeQuery/pkg/client/clientset/versioned/fake"
+	"github.com/rsavage/KubeQuery/pkg/client/informers/externalversions"
+)
+
+func TestFakeClientsetInformerAndLister(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	cs := fake.NewSimpleClientset()
+	factory := externalversions.NewSharedInformerFactoryWithOptions(cs, 0, externalversions.WithNamespace("default"))
+	informer := factory.Kubequery().V1alpha1().PostgresQueries()
+	lister := informer.Lister()
+	factory.Start(ctx.Done())
+	if !cache.WaitForCacheSync(ctx.Done(), informer.Informer().HasSynced) {
+		t.Fatal("informer did not sync")
+	}
+
+	pq := &kubequeryv1alpha1.PostgresQuery{
+		ObjectMeta: metav1.ObjectMeta{Name: "migrate", Namespace: "default"},
+		Spec:       kubequeryv1alpha1.PostgresQuerySpec{SQL: "SELECT 1"},
+	}
+	if _, err := cs.KubequeryV1alpha1().PostgresQueries("default").Create(ctx, pq, metav1.CreateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	var got *kubequeryv1alpha1.PostgresQuery
+	for got == nil {
+		select {
+		case <-ctx.Done():
+			t.Fatal("created PostgresQuery did not reach the lister")
+		case <-time.After(10 * time.Millisecond):
+		}
+		got, _ = lister.PostgresQueries("default").Get("migrate")
+	}
+	if got.Spec.SQL != "SELECT 1" {
+		t.Errorf("sql = %q", got.Spec.SQL)
+	}
+}
+
+func TestApplyConfiguration(t *testing.T) {
+	ac := applyv1alpha1.PostgresQuery("migrate", "default").
+		WithSpec(applyv1alpha1.PostgresQuerySpec().
+			WithSQL("SELECT 1").
+			WithConnection(applyv1alpha1.PostgresConnection().WithHost("db").WithPort(5432)))
+	if *ac.Name != "migrate" || *ac.Kind != "PostgresQuery" || *ac.APIVersion != "kubequery.cloudnexus.io/v1alpha1" {
+		t.Errorf("unexpected metadata: %+v", ac)
+	}
+	if *ac.Spec.SQL != "SELECT 1" || *ac.Spec.Connection.Host != "db" {
+		t.Errorf("unexpected spec: %+v", ac.Spec)
+	}
+}
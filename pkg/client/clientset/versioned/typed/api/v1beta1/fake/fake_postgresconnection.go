@@ -0,0 +1,52 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+// Code generated by client-gen. DO NOT EDIT.
+
+package fake
+
+import (
+	v1beta1 "github.com/rsavage/KubeQuery/api/v1beta1"
+	apiv1beta1 "github.com/rsavage/KubeQuery/pkg/client/applyconfiguration/api/v1beta1"
+	typedapiv1beta1 "github.com/rsavage/KubeQuery/pkg/client/clientset/versioned/typed/api/v1beta1"
+	gentype "k8s.io/client-go/gentype"
+)
+
+// fakePostgresConnections implements PostgresConnectionInterface
+type fakePostgresConnections struct {
+	*gentype.FakeClientWithListAndApply[*v1beta1.PostgresConnection, *v1beta1.PostgresConnectionList, *apiv1beta1.PostgresConnectionApplyConfiguration]
+	Fake *FakeKubequeryV1beta1
+}
+
+func newFakePostgresConnections(fake *FakeKubequeryV1beta1, namespace string) typedapiv1beta1.PostgresConnectionInterface {
+	return &fakePostgresConnections{
+		gentype.NewFakeClientWithListAndApply[*v1beta1.PostgresConnection, *v1beta1.PostgresConnectionList, *apiv1beta1.PostgresConnectionApplyConfiguration](
+			fake.Fake,
+			namespace,
+			v1beta1.SchemeGroupVersion.WithResource("postgresconnections"),
+			v1beta1.SchemeGroupVersion.WithKind("PostgresConnection"),
+			func() *v1beta1.PostgresConnection { return &v1beta1.PostgresConnection{} },
+			func() *v1beta1.PostgresConnectionList { return &v1beta1.PostgresConnectionList{} },
+			func(dst, src *v1beta1.PostgresConnectionList) { dst.ListMeta = src.ListMeta },
+			func(list *v1beta1.PostgresConnectionList) []*v1beta1.PostgresConnection {
+				return gentype.ToPointerSlice(list.Items)
+			},
+			func(list *v1beta1.PostgresConnectionList, items []*v1beta1.PostgresConnection) {
+				list.Items = gentype.FromPointerSlice(items)
+			},
+		),
+		fake,
+	}
+}
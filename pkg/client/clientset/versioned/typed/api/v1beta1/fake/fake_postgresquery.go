@@ -0,0 +1,52 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+// Code generated by client-gen. DO NOT EDIT.
+
+package fake
+
+import (
+	v1beta1 "github.com/rsavage/KubeQuery/api/v1beta1"
+	apiv1beta1 "github.com/rsavage/KubeQuery/pkg/client/applyconfiguration/api/v1beta1"
+	typedapiv1beta1 "github.com/rsavage/KubeQuery/pkg/client/clientset/versioned/typed/api/v1beta1"
+	gentype "k8s.io/client-go/gentype"
+)
+
+// fakePostgresQueries implements PostgresQueryInterface
+type fakePostgresQueries struct {
+	*gentype.FakeClientWithListAndApply[*v1beta1.PostgresQuery, *v1beta1.PostgresQueryList, *apiv1beta1.PostgresQueryApplyConfiguration]
+	Fake *FakeKubequeryV1beta1
+}
+
+func newFakePostgresQueries(fake *FakeKubequeryV1beta1, namespace string) typedapiv1beta1.PostgresQueryInterface {
+	return &fakePostgresQueries{
+		gentype.NewFakeClientWithListAndApply[*v1beta1.PostgresQuery, *v1beta1.PostgresQueryList, *apiv1beta1.PostgresQueryApplyConfiguration](
+			fake.Fake,
+			namespace,
+			v1beta1.SchemeGroupVersion.WithResource("postgresqueries"),
+			v1beta1.SchemeGroupVersion.WithKind("PostgresQuery"),
+			func() *v1beta1.PostgresQuery { return &v1beta1.PostgresQuery{} },
+			func() *v1beta1.PostgresQueryList { return &v1beta1.PostgresQueryList{} },
+			func(dst, src *v1beta1.PostgresQueryList) { dst.ListMeta = src.ListMeta },
+			func(list *v1beta1.PostgresQueryList) []*v1beta1.PostgresQuery {
+				return gentype.ToPointerSlice(list.Items)
+			},
+			func(list *v1beta1.PostgresQueryList, items []*v1beta1.PostgresQuery) {
+				list.Items = gentype.FromPointerSlice(items)
+			},
+		),
+		fake,
+	}
+}
@@ -0,0 +1,69 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+// Code generated by client-gen. DO NOT EDIT.
+
+package v1beta1
+
+import (
+	context "context"
+
+	apiv1beta1 "github.com/rsavage/KubeQuery/api/v1beta1"
+	applyconfigurationapiv1beta1 "github.com/rsavage/KubeQuery/pkg/client/applyconfiguration/api/v1beta1"
+	scheme "github.com/rsavage/KubeQuery/pkg/client/clientset/versioned/scheme"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	types "k8s.io/apimachinery/pkg/types"
+	watch "k8s.io/apimachinery/pkg/watch"
+	gentype "k8s.io/client-go/gentype"
+)
+
+// PostgresConnectionsGetter has a method to return a PostgresConnectionInterface.
+// A group's client should implement this interface.
+type PostgresConnectionsGetter interface {
+	PostgresConnections(namespace string) PostgresConnectionInterface
+}
+
+// PostgresConnectionInterface has methods to work with PostgresConnection resources.
+type PostgresConnectionInterface interface {
+	Create(ctx context.Context, postgresConnection *apiv1beta1.PostgresConnection, opts v1.CreateOptions) (*apiv1beta1.PostgresConnection, error)
+	Update(ctx context.Context, postgresConnection *apiv1beta1.PostgresConnection, opts v1.UpdateOptions) (*apiv1beta1.PostgresConnection, error)
+	Delete(ctx context.Context, name string, opts v1.DeleteOptions) error
+	DeleteCollection(ctx context.Context, opts v1.DeleteOptions, listOpts v1.ListOptions) error
+	Get(ctx context.Context, name string, opts v1.GetOptions) (*apiv1beta1.PostgresConnection, error)
+	List(ctx context.Context, opts v1.ListOptions) (*apiv1beta1.PostgresConnectionList, error)
+	Watch(ctx context.Context, opts v1.ListOptions) (watch.Interface, error)
+	Patch(ctx context.Context, name string, pt types.PatchType, data []byte, opts v1.PatchOptions, subresources ...string) (result *apiv1beta1.PostgresConnection, err error)
+	Apply(ctx context.Context, postgresConnection *applyconfigurationapiv1beta1.PostgresConnectionApplyConfiguration, opts v1.ApplyOptions) (result *apiv1beta1.PostgresConnection, err error)
+	PostgresConnectionExpansion
+}
+
+// postgresConnections implements PostgresConnectionInterface
+type postgresConnections struct {
+	*gentype.ClientWithListAndApply[*apiv1beta1.PostgresConnection, *apiv1beta1.PostgresConnectionList, *applyconfigurationapiv1beta1.PostgresConnectionApplyConfiguration]
+}
+
+// newPostgresConnections returns a PostgresConnections
+func newPostgresConnections(c *KubequeryV1beta1Client, namespace string) *postgresConnections {
+	return &postgresConnections{
+		gentype.NewClientWithListAndApply[*apiv1beta1.PostgresConnection, *apiv1beta1.PostgresConnectionList, *applyconfigurationapiv1beta1.PostgresConnectionApplyConfiguration](
+			"postgresconnections",
+			c.RESTClient(),
+			scheme.ParameterCodec,
+			namespace,
+			func() *apiv1beta1.PostgresConnection { return &apiv1beta1.PostgresConnection{} },
+			func() *apiv1beta1.PostgresConnectionList { return &apiv1beta1.PostgresConnectionList{} },
+		),
+	}
+}
@@ -0,0 +1,73 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+// Code generated by client-gen. DO NOT EDIT.
+
+package v1beta1
+
+import (
+	context "context"
+
+	apiv1beta1 "github.com/rsavage/KubeQuery/api/v1beta1"
+	applyconfigurationapiv1beta1 "github.com/rsavage/KubeQuery/pkg/client/applyconfiguration/api/v1beta1"
+	scheme "github.com/rsavage/KubeQuery/pkg/client/clientset/versioned/scheme"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	types "k8s.io/apimachinery/pkg/types"
+	watch "k8s.io/apimachinery/pkg/watch"
+	gentype "k8s.io/client-go/gentype"
+)
+
+// PostgresQueriesGetter has a method to return a PostgresQueryInterface.
+// A group's client should implement this interface.
+type PostgresQueriesGetter interface {
+	PostgresQueries(namespace string) PostgresQueryInterface
+}
+
+// PostgresQueryInterface has methods to work with PostgresQuery resources.
+type PostgresQueryInterface interface {
+	Create(ctx context.Context, postgresQuery *apiv1beta1.PostgresQuery, opts v1.CreateOptions) (*apiv1beta1.PostgresQuery, error)
+	Update(ctx context.Context, postgresQuery *apiv1beta1.PostgresQuery, opts v1.UpdateOptions) (*apiv1beta1.PostgresQuery, error)
+	// Add a +genclient:noStatus comment above the type to avoid generating UpdateStatus().
+	UpdateStatus(ctx context.Context, postgresQuery *apiv1beta1.PostgresQuery, opts v1.UpdateOptions) (*apiv1beta1.PostgresQuery, error)
+	Delete(ctx context.Context, name string, opts v1.DeleteOptions) error
+	DeleteCollection(ctx context.Context, opts v1.DeleteOptions, listOpts v1.ListOptions) error
+	Get(ctx context.Context, name string, opts v1.GetOptions) (*apiv1beta1.PostgresQuery, error)
+	List(ctx context.Context, opts v1.ListOptions) (*apiv1beta1.PostgresQueryList, error)
+	Watch(ctx context.Context, opts v1.ListOptions) (watch.Interface, error)
+	Patch(ctx context.Context, name string, pt types.PatchType, data []byte, opts v1.PatchOptions, subresources ...string) (result *apiv1beta1.PostgresQuery, err error)
+	Apply(ctx context.Context, postgresQuery *applyconfigurationapiv1beta1.PostgresQueryApplyConfiguration, opts v1.ApplyOptions) (result *apiv1beta1.PostgresQuery, err error)
+	// Add a +genclient:noStatus comment above the type to avoid generating ApplyStatus().
+	ApplyStatus(ctx context.Context, postgresQuery *applyconfigurationapiv1beta1.PostgresQueryApplyConfiguration, opts v1.ApplyOptions) (result *apiv1beta1.PostgresQuery, err error)
+	PostgresQueryExpansion
+}
+
+// postgresQueries implements PostgresQueryInterface
+type postgresQueries struct {
+	*gentype.ClientWithListAndApply[*apiv1beta1.PostgresQuery, *apiv1beta1.PostgresQueryList, *applyconfigurationapiv1beta1.PostgresQueryApplyConfiguration]
+}
+
+// newPostgresQueries returns a PostgresQueries
+func newPostgresQueries(c *KubequeryV1beta1Client, namespace string) *postgresQueries {
+	return &postgresQueries{
+		gentype.NewClientWithListAndApply[*apiv1beta1.PostgresQuery, *apiv1beta1.PostgresQueryList, *applyconfigurationapiv1beta1.PostgresQueryApplyConfiguration](
+			"postgresqueries",
+			c.RESTClient(),
+			scheme.ParameterCodec,
+			namespace,
+			func() *apiv1beta1.PostgresQuery { return &apiv1beta1.PostgresQuery{} },
+			func() *apiv1beta1.PostgresQueryList { return &apiv1beta1.PostgresQueryList{} },
+		),
+	}
+}
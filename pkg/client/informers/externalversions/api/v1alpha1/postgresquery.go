@@ -0,0 +1,89 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+// Code generated by informer-gen. DO NOT EDIT.
+
+package v1alpha1
+
+import (
+	context "context"
+	time "time"
+
+	KubeQueryapiv1alpha1 "github.com/rsavage/KubeQuery/api/v1alpha1"
+	versioned "github.com/rsavage/KubeQuery/pkg/client/clientset/versioned"
+	internalinterfaces "github.com/rsavage/KubeQuery/pkg/client/informers/externalversions/internalinterfaces"
+	apiv1alpha1 "github.com/rsavage/KubeQuery/pkg/client/listers/api/v1alpha1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	runtime "k8s.io/apimachinery/pkg/runtime"
+	watch "k8s.io/apimachinery/pkg/watch"
+	cache "k8s.io/client-go/tools/cache"
+)
+
+// PostgresQueryInformer provides access to a shared informer and lister for
+// PostgresQueries.
+type PostgresQueryInformer interface {
+	Informer() cache.SharedIndexInformer
+	Lister() apiv1alpha1.PostgresQueryLister
+}
+
+type postgresQueryInformer struct {
+	factory          internalinterfaces.SharedInformerFactory
+	tweakListOptions internalinterfaces.TweakListOptionsFunc
+	namespace        string
+}
+
+// NewPostgresQueryInformer constructs a new informer for PostgresQuery type.
+// Always prefer using an informer factory to get a shared informer instead of getting an independent
+// one. This reduces memory footprint and number of connections to the server.
+func NewPostgresQueryInformer(client versioned.Interface, namespace string, resyncPeriod time.Duration, indexers cache.Indexers) cache.SharedIndexInformer {
+	return NewFilteredPostgresQueryInformer(client, namespace, resyncPeriod, indexers, nil)
+}
+
+// NewFilteredPostgresQueryInformer constructs a new informer for PostgresQuery type.
+// Always prefer using an informer factory to get a shared informer instead of getting an independent
+// one. This reduces memory footprint and number of connections to the server.
+func NewFilteredPostgresQueryInformer(client versioned.Interface, namespace string, resyncPeriod time.Duration, indexers cache.Indexers, tweakListOptions internalinterfaces.TweakListOptionsFunc) cache.SharedIndexInformer {
+	return cache.NewSharedIndexInformer(
+		&cache.ListWatch{
+			ListFunc: func(options v1.ListOptions) (runtime.Object, error) {
+				if tweakListOptions != nil {
+					tweakListOptions(&options)
+				}
+				return client.KubequeryV1alpha1().PostgresQueries(namespace).List(context.TODO(), options)
+			},
+			WatchFunc: func(options v1.ListOptions) (watch.Interface, error) {
+				if tweakListOptions != nil {
+					tweakListOptions(&options)
+				}
+				return client.KubequeryV1alpha1().PostgresQueries(namespace).Watch(context.TODO(), options)
+			},
+		},
+		&KubeQueryapiv1alpha1.PostgresQuery{},
+		resyncPeriod,
+		indexers,
+	)
+}
+
+func (f *postgresQueryInformer) defaultInformer(client versioned.Interface, resyncPeriod time.Duration) cache.SharedIndexInformer {
+	return NewFilteredPostgresQueryInformer(client, f.namespace, resyncPeriod, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc}, f.tweakListOptions)
+}
+
+func (f *postgresQueryInformer) Informer() cache.SharedIndexInformer {
+	return f.factory.InformerFor(&KubeQueryapiv1alpha1.PostgresQuery{}, f.defaultInformer)
+}
+
+func (f *postgresQueryInformer) Lister() apiv1alpha1.PostgresQueryLister {
+	return apiv1alpha1.NewPostgresQueryLister(f.Informer().GetIndexer())
+}
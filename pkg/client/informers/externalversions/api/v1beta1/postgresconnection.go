@@ -0,0 +1,89 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+// Code generated by informer-gen. DO NOT EDIT.
+
+package v1beta1
+
+import (
+	context "context"
+	time "time"
+
+	KubeQueryapiv1beta1 "github.com/rsavage/KubeQuery/api/v1beta1"
+	versioned "github.com/rsavage/KubeQuery/pkg/client/clientset/versioned"
+	internalinterfaces "github.com/rsavage/KubeQuery/pkg/client/informers/externalversions/internalinterfaces"
+	apiv1beta1 "github.com/rsavage/KubeQuery/pkg/client/listers/api/v1beta1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	runtime "k8s.io/apimachinery/pkg/runtime"
+	watch "k8s.io/apimachinery/pkg/watch"
+	cache "k8s.io/client-go/tools/cache"
+)
+
+// PostgresConnectionInformer provides access to a shared informer and lister for
+// PostgresConnections.
+type PostgresConnectionInformer interface {
+	Informer() cache.SharedIndexInformer
+	Lister() apiv1beta1.PostgresConnectionLister
+}
+
+type postgresConnectionInformer struct {
+	factory          internalinterfaces.SharedInformerFactory
+	tweakListOptions internalinterfaces.TweakListOptionsFunc
+	namespace        string
+}
+
+// NewPostgresConnectionInformer constructs a new informer for PostgresConnection type.
+// Always prefer using an informer factory to get a shared informer instead of getting an independent
+// one. This reduces memory footprint and number of connections to the server.
+func NewPostgresConnectionInformer(client versioned.Interface, namespace string, resyncPeriod time.Duration, indexers cache.Indexers) cache.SharedIndexInformer {
+	return NewFilteredPostgresConnectionInformer(client, namespace, resyncPeriod, indexers, nil)
+}
+
+// NewFilteredPostgresConnectionInformer constructs a new informer for PostgresConnection type.
+// Always prefer using an informer factory to get a shared informer instead of getting an independent
+// one. This reduces memory footprint and number of connections to the server.
+func NewFilteredPostgresConnectionInformer(client versioned.Interface, namespace string, resyncPeriod time.Duration, indexers cache.Indexers, tweakListOptions internalinterfaces.TweakListOptionsFunc) cache.SharedIndexInformer {
+	return cache.NewSharedIndexInformer(
+		&cache.ListWatch{
+			ListFunc: func(options v1.ListOptions) (runtime.Object, error) {
+				if tweakListOptions != nil {
+					tweakListOptions(&options)
+				}
+				return client.KubequeryV1beta1().PostgresConnections(namespace).List(context.TODO(), options)
+			},
+			WatchFunc: func(options v1.ListOptions) (watch.Interface, error) {
+				if tweakListOptions != nil {
+					tweakListOptions(&options)
+				}
+				return client.KubequeryV1beta1().PostgresConnections(namespace).Watch(context.TODO(), options)
+			},
+		},
+		&KubeQueryapiv1beta1.PostgresConnection{},
+		resyncPeriod,
+		indexers,
+	)
+}
+
+func (f *postgresConnectionInformer) defaultInformer(client versioned.Interface, resyncPeriod time.Duration) cache.SharedIndexInformer {
+	return NewFilteredPostgresConnectionInformer(client, f.namespace, resyncPeriod, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc}, f.tweakListOptions)
+}
+
+func (f *postgresConnectionInformer) Informer() cache.SharedIndexInformer {
+	return f.factory.InformerFor(&KubeQueryapiv1beta1.PostgresConnection{}, f.defaultInformer)
+}
+
+func (f *postgresConnectionInformer) Lister() apiv1beta1.PostgresConnectionLister {
+	return apiv1beta1.NewPostgresConnectionLister(f.Informer().GetIndexer())
+}
@@ -0,0 +1,69 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+// Code generated by lister-gen. DO NOT EDIT.
+
+package v1alpha1
+
+import (
+	apiv1alpha1 "github.com/rsavage/KubeQuery/api/v1alpha1"
+	labels "k8s.io/apimachinery/pkg/labels"
+	listers "k8s.io/client-go/listers"
+	cache "k8s.io/client-go/tools/cache"
+)
+
+// PostgresQueryLister helps list PostgresQueries.
+// All objects returned here must be treated as read-only.
+type PostgresQueryLister interface {
+	// List lists all PostgresQueries in the indexer.
+	// Objects returned here must be treated as read-only.
+	List(selector labels.Selector) (ret []*apiv1alpha1.PostgresQuery, err error)
+	// PostgresQueries returns an object that can list and get PostgresQueries.
+	PostgresQueries(namespace string) PostgresQueryNamespaceLister
+	PostgresQueryListerExpansion
+}
+
+// postgresQueryLister implements the PostgresQueryLister interface.
+type postgresQueryLister struct {
+	listers.ResourceIndexer[*apiv1alpha1.PostgresQuery]
+}
+
+// NewPostgresQueryLister returns a new PostgresQueryLister.
+func NewPostgresQueryLister(indexer cache.Indexer) PostgresQueryLister {
+	return &postgresQueryLister{listers.New[*apiv1alpha1.PostgresQuery](indexer, apiv1alpha1.Resource("postgresquery"))}
+}
+
+// PostgresQueries returns an object that can list and get PostgresQueries.
+func (s *postgresQueryLister) PostgresQueries(namespace string) PostgresQueryNamespaceLister {
+	return postgresQueryNamespaceLister{listers.NewNamespaced[*apiv1alpha1.PostgresQuery](s.ResourceIndexer, namespace)}
+}
+
+// PostgresQueryNamespaceLister helps list and get PostgresQueries.
+// All objects returned here must be treated as read-only.
+type PostgresQueryNamespaceLister interface {
+	// List lists all PostgresQueries in the indexer for a given namespace.
+	// Objects returned here must be treated as read-only.
+	List(selector labels.Selector) (ret []*apiv1alpha1.PostgresQuery, err error)
+	// Get retrieves the PostgresQuery from the indexer for a given namespace and name.
+	// Objects returned here must be treated as read-only.
+	Get(name string) (*apiv1alpha1.PostgresQuery, error)
+	PostgresQueryNamespaceListerExpansion
+}
+
+// postgresQueryNamespaceLister implements the PostgresQueryNamespaceLister
+// interface.
+type postgresQueryNamespaceLister struct {
+	listers.ResourceIndexer[*apiv1alpha1.PostgresQuery]
+}
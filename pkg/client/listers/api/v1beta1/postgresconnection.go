@@ -0,0 +1,69 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+// Code generated by lister-gen. DO NOT EDIT.
+
+package v1beta1
+
+import (
+	apiv1beta1 "github.com/rsavage/KubeQuery/api/v1beta1"
+	labels "k8s.io/apimachinery/pkg/labels"
+	listers "k8s.io/client-go/listers"
+	cache "k8s.io/client-go/tools/cache"
+)
+
+// PostgresConnectionLister helps list PostgresConnections.
+// All objects returned here must be treated as read-only.
+type PostgresConnectionLister interface {
+	// List lists all PostgresConnections in the indexer.
+	// Objects returned here must be treated as read-only.
+	List(selector labels.Selector) (ret []*apiv1beta1.PostgresConnection, err error)
+	// PostgresConnections returns an object that can list and get PostgresConnections.
+	PostgresConnections(namespace string) PostgresConnectionNamespaceLister
+	PostgresConnectionListerExpansion
+}
+
+// postgresConnectionLister implements the PostgresConnectionLister interface.
+type postgresConnectionLister struct {
+	listers.ResourceIndexer[*apiv1beta1.PostgresConnection]
+}
+
+// NewPostgresConnectionLister returns a new PostgresConnectionLister.
+func NewPostgresConnectionLister(indexer cache.Indexer) PostgresConnectionLister {
+	return &postgresConnectionLister{listers.New[*apiv1beta1.PostgresConnection](indexer, apiv1beta1.Resource("postgresconnection"))}
+}
+
+// PostgresConnections returns an object that can list and get PostgresConnections.
+func (s *postgresConnectionLister) PostgresConnections(namespace string) PostgresConnectionNamespaceLister {
+	return postgresConnectionNamespaceLister{listers.NewNamespaced[*apiv1beta1.PostgresConnection](s.ResourceIndexer, namespace)}
+}
+
+// PostgresConnectionNamespaceLister helps list and get PostgresConnections.
+// All objects returned here must be treated as read-only.
+type PostgresConnectionNamespaceLister interface {
+	// List lists all PostgresConnections in the indexer for a given namespace.
+	// Objects returned here must be treated as read-only.
+	List(selector labels.Selector) (ret []*apiv1beta1.PostgresConnection, err error)
+	// Get retrieves the PostgresConnection from the indexer for a given namespace and name.
+	// Objects returned here must be treated as read-only.
+	Get(name string) (*apiv1beta1.PostgresConnection, error)
+	PostgresConnectionNamespaceListerExpansion
+}
+
+// postgresConnectionNamespaceLister implements the PostgresConnectionNamespaceLister
+// interface.
+type postgresConnectionNamespaceLister struct {
+	listers.ResourceIndexer[*apiv1beta1.PostgresConnection]
+}
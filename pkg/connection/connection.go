@@ -0,0 +1,231 @@
+// Package connection resolves the connection settings of a PostgresConnection,
+// including the credentials and TLS material held in Secrets, into a
+// db.ConnConfig. It is shared by the controller and ui-service, so both
+// connect to a database in the same way.
+package connection
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	kubequeryv1beta1 "github.com/rsavage/KubeQuery/api/v1beta1"
+	"github.com/rsavage/KubeQuery/pkg/db"
+	"github.com/rsavage/KubeQuery/pkg/redact"
+)
+
+// ErrPluginsDisabled is returned for token file and exec authentication
+// when the Resolver does not allow credential plugins.
+var ErrPluginsDisabled = errors.New("credential plugins are disabled")
+
+// ErrPluginNotAllowed is returned for a token file or exec command that is not
+// on the Resolver's allowlist.
+var ErrPluginNotAllowed = errors.New("credential plugin is not allowed")
+
+// Resolver resolves connection settings with the Secrets of their namespace.
+type Resolver struct {
+	// Reader reads Secrets.
+	Reader client.Reader
+	// EnableCredentialPlugins allows token files and exec plugins, which run
+	// in the resolving process, to be used for database authentication.
+	EnableCredentialPlugins bool
+	// AllowedTokenPaths lists the token files, or directories containing
+	// them, that auth.token.path may name. Nothing is allowed if it is empty.
+	AllowedTokenPaths []string
+	// AllowedExecCommands lists the commands auth.exec may run. Commands
+	// must match an entry exactly. Nothing is allowed if it is empty.
+	AllowedExecCommands []string
+	// ApplicationName is the default application_name of the sessions
+	// (optional). conn.RuntimeParams take precedence.
+	ApplicationName string
+}
+
+// Resolve resolves conn, with Secrets read from namespace, into a
+// db.ConnConfig. Every password it obtains is registered with red, which may
+// be nil. When a CA bundle is configured, the returned status records its
+// expiry, even if Resolve fails because the bundle has expired.
+func (r *Resolver) Resolve(ctx context.Context, namespace string, conn *kubequeryv1beta1.PostgresConnectionSpec, red *redact.Redactor) (db.ConnConfig, *kubequeryv1beta1.ConnectionStatus, error) {
+	// Resolve credentials
+	creds, err := r.Credentials(ctx, namespace, conn)
+	if err != nil {
+		return db.ConnConfig{}, nil, err
+	}
+	if red != nil {
+		creds = RedactedCredentials(creds, red)
+	}
+
+	// Handle SSL config
+	var status *kubequeryv1beta1.ConnectionStatus
+	var sslCfg *db.SSLConfig
+	if ssl := conn.SSL; ssl != nil && ssl.Mode != db.SSLModeDisable {
+		sslCfg = &db.SSLConfig{Mode: ssl.Mode, ServerName: ssl.ServerName}
+		if ssl.CaSecretRef != nil {
+			var caSecret corev1.Secret
+			if err := r.Reader.Get(ctx, client.ObjectKey{Namespace: namespace, Name: ssl.CaSecretRef.Name}, &caSecret); err != nil {
+				return db.ConnConfig{}, nil, fmt.Errorf("failed to get CA secret: %w", err)
+			}
+			ca, ok := caSecret.Data[ssl.CaSecretRef.Key]
+			if !ok {
+				return db.ConnConfig{}, nil, errors.New("CA key not found in secret")
+			}
+			cas, err := db.ParseCABundle(ca)
+			if err != nil {
+				return db.ConnConfig{}, nil, fmt.Errorf("invalid CA certificate: %w", err)
+			}
+			caNotAfter := db.CABundleExpiry(cas)
+			status = &kubequeryv1beta1.ConnectionStatus{
+				CANotAfter: &metav1.Time{Time: caNotAfter},
+			}
+			if time.Now().After(caNotAfter) {
+				return db.ConnConfig{}, status, fmt.Errorf("CA certificate expired at %s", caNotAfter.UTC().Format(time.RFC3339))
+			}
+			sslCfg.CA = ca
+		}
+		if ssl.ClientCertSecretRef != nil {
+			var certSecret corev1.Secret
+			if err := r.Reader.Get(ctx, client.ObjectKey{Namespace: namespace, Name: ssl.ClientCertSecretRef.Name}, &certSecret); err != nil {
+				return db.ConnConfig{}, status, fmt.Errorf("failed to get client certificate secret: %w", err)
+			}
+			sslCfg.ClientCert = certSecret.Data[corev1.TLSCertKey]
+			sslCfg.ClientKey = certSecret.Data[corev1.TLSPrivateKeyKey]
+			if len(sslCfg.ClientCert) == 0 || len(sslCfg.ClientKey) == 0 {
+				return db.ConnConfig{}, status, errors.New("client certificate secret must contain tls.crt and tls.key")
+			}
+		}
+		if ssl.CRLSecretRef != nil {
+			crl, err := r.SecretValue(ctx, namespace, *ssl.CRLSecretRef, "CRL")
+			if err != nil {
+				return db.ConnConfig{}, status, err
+			}
+			sslCfg.CRL = []byte(crl)
+		}
+	}
+
+	// Prepare DB config
+	dbCfg := db.ConnConfig{
+		Engine:             db.Engine(conn.Engine),
+		Host:               conn.Host,
+		Port:               conn.Port,
+		Database:           conn.Database,
+		User:               conn.User,
+		Credentials:        creds,
+		SSL:                sslCfg,
+		TargetSessionAttrs: conn.TargetSessionAttrs,
+		RuntimeParams:      map[string]string{},
+	}
+	if r.ApplicationName != "" {
+		dbCfg.RuntimeParams["application_name"] = r.ApplicationName
+	}
+	for _, h := range conn.Hosts {
+		dbCfg.Hosts = append(dbCfg.Hosts, db.HostPort{Host: h.Host, Port: h.Port})
+	}
+	for k, v := range conn.RuntimeParams {
+		dbCfg.RuntimeParams[k] = v
+	}
+	return dbCfg, status, nil
+}
+
+// Credentials resolves the auth configuration of conn, with Secrets read from
+// namespace, into a db.Credentials. Static passwords are read once; tokens referenced from a
+// Secret are re-read for every new connection so rotations are honoured.
+func (r *Resolver) Credentials(ctx context.Context, namespace string, conn *kubequeryv1beta1.PostgresConnectionSpec) (db.Credentials, error) {
+	auth := conn.Auth
+	if auth == nil {
+		if conn.PasswordSecretRef == nil {
+			return nil, errors.New("no credentials configured: set connection.auth or connection.passwordSecretRef")
+		}
+		auth = &kubequeryv1beta1.PostgresAuth{
+			Password: &kubequeryv1beta1.PasswordAuth{SecretRef: *conn.PasswordSecretRef},
+		}
+	}
+
+	switch {
+	case auth.Password != nil:
+		password, err := r.SecretValue(ctx, namespace, auth.Password.SecretRef, "password")
+		if err != nil {
+			return nil, err
+		}
+		return db.StaticPassword(password), nil
+	case auth.Token != nil && auth.Token.SecretRef != nil:
+		ref := *auth.Token.SecretRef
+		return db.CredentialsFunc(func(ctx context.Context) (string, error) {
+			return r.SecretValue(ctx, namespace, ref, "token")
+		}), nil
+	case auth.Token != nil && auth.Token.Path != "":
+		if !r.EnableCredentialPlugins {
+			return nil, fmt.Errorf("token file authentication is not available: %w", ErrPluginsDisabled)
+		}
+		path := filepath.Clean(auth.Token.Path)
+		if !r.tokenPathAllowed(path) {
+			return nil, fmt.Errorf("token file %s: %w", auth.Token.Path, ErrPluginNotAllowed)
+		}
+		return db.TokenFile(path), nil
+	case auth.Exec != nil:
+		if !r.EnableCredentialPlugins {
+			return nil, fmt.Errorf("exec authentication is not available: %w", ErrPluginsDisabled)
+		}
+		if !slices.Contains(r.AllowedExecCommands, auth.Exec.Command) {
+			return nil, fmt.Errorf("exec command %q: %w", auth.Exec.Command, ErrPluginNotAllowed)
+		}
+		env := make([]string, 0, len(auth.Exec.Env))
+		for _, e := range auth.Exec.Env {
+			env = append(env, e.Name+"="+e.Value)
+		}
+		return &db.ExecCredentials{Command: auth.Exec.Command, Args: auth.Exec.Args, Env: env}, nil
+	default:
+		return nil, errors.New("connection.auth must set one of password, token.secretRef, token.path or exec")
+	}
+}
+
+// tokenPathAllowed reports whether the clean, absolute path is one of
+// r.AllowedTokenPaths or lies below one of them.
+func (r *Resolver) tokenPathAllowed(path string) bool {
+	if !filepath.IsAbs(path) {
+		return false
+	}
+	for _, allowed := range r.AllowedTokenPaths {
+		allowed = filepath.Clean(allowed)
+		if path == allowed || strings.HasPrefix(path, strings.TrimSuffix(allowed, "/")+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// SecretValue reads a single key from a Secret in the given namespace. what
+// names the value in error messages.
+func (r *Resolver) SecretValue(ctx context.Context, namespace string, ref kubequeryv1beta1.SecretKeySelector, what string) (string, error) {
+	var secret corev1.Secret
+	if err := r.Reader.Get(ctx, client.ObjectKey{Namespace: namespace, Name: ref.Name}, &secret); err != nil {
+		return "", fmt.Errorf("failed to get %s secret: %w", what, err)
+	}
+	val, ok := secret.Data[ref.Key]
+	if !ok {
+		return "", fmt.Errorf("%s key not found in secret", what)
+	}
+	return string(val), nil
+}
+
+// RedactedCredentials registers every password returned by creds with red, so
+// that refreshed tokens are scrubbed from messages as well.
+func RedactedCredentials(creds db.Credentials, red *redact.Redactor) db.Credentials {
+	if static, ok := creds.(db.StaticPassword); ok {
+		red.Add(string(static))
+		return creds
+	}
+	return db.CredentialsFunc(func(ctx context.Context) (string, error) {
+		password, err := creds.Password(ctx)
+		if err == nil {
+			red.Add(password)
+		}
+		return password, err
+	})
+}
@@ -0,0 +1,143 @@
+package connection
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	kubequeryv1beta1 "github.com/rsavage/KubeQuery/api/v1beta1"
+	"github.com/rsavage/KubeQuery/pkg/db"
+	"github.com/rsavage/KubeQuery/pkg/redact"
+)
+
+func secret(name, key, value string) *corev1.Secret {
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "team-a", Name: name},
+		Data:       map[string][]byte{key: []byte(value)},
+	}
+}
+
+func TestResolve(t *testing.T) {
+	ctx := context.Background()
+	c := fake.NewClientBuilder().WithObjects(secret("db", "password", "s3cr3t-pass")).Build()
+	r := &Resolver{Reader: c, ApplicationName: "kubequery"}
+	conn := &kubequeryv1beta1.PostgresConnectionSpec{
+		Host:              "db",
+		Port:              5432,
+		Hosts:             []kubequeryv1beta1.PostgresHost{{Host: "replica", Port: 5433}},
+		Database:          "app",
+		User:              "app",
+		PasswordSecretRef: &kubequeryv1beta1.SecretKeySelector{Name: "db", Key: "password"},
+		RuntimeParams:     map[string]string{"search_path": "app"},
+	}
+
+	red := redact.New()
+	cfg, status, err := r.Resolve(ctx, "team-a", conn, red)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status != nil {
+		t.Errorf("status = %+v, want nil without a CA", status)
+	}
+	if cfg.Host != "db" || cfg.Port != 5432 || len(cfg.Hosts) != 1 || cfg.Hosts[0] != (db.HostPort{Host: "replica", Port: 5433}) {
+		t.Errorf("hosts = %s:%d %v", cfg.Host, cfg.Port, cfg.Hosts)
+	}
+	if cfg.RuntimeParams["application_name"] != "kubequery" || cfg.RuntimeParams["search_path"] != "app" {
+		t.Errorf("runtime params = %v", cfg.RuntimeParams)
+	}
+	if password, err := cfg.Credentials.Password(ctx); err != nil || password != "s3cr3t-pass" {
+		t.Errorf("password = %q, %v", password, err)
+	}
+	if got := red.String("login with s3cr3t-pass"); got != "login with "+redact.Placeholder {
+		t.Errorf("password not redacted: %q", got)
+	}
+
+	conn.RuntimeParams["application_name"] = "reports"
+	if cfg, _, err := r.Resolve(ctx, "team-a", conn, nil); err != nil || cfg.RuntimeParams["application_name"] != "reports" {
+		t.Errorf("application_name = %q, %v; want the connection's", cfg.RuntimeParams["application_name"], err)
+	}
+	if _, _, err := r.Resolve(ctx, "team-b", conn, nil); err == nil {
+		t.Error("resolved a Secret from another namespace")
+	}
+}
+
+func TestCredentials(t *testing.T) {
+	ctx := context.Background()
+	token := secret("token", "token", "first")
+	c := fake.NewClientBuilder().WithObjects(token).Build()
+	r := &Resolver{Reader: c}
+
+	creds, err := r.Credentials(ctx, "team-a", &kubequeryv1beta1.PostgresConnectionSpec{
+		Auth: &kubequeryv1beta1.PostgresAuth{Token: &kubequeryv1beta1.TokenAuth{
+			SecretRef: &kubequeryv1beta1.SecretKeySelector{Name: "token", Key: "token"},
+		}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	token.Data["token"] = []byte("rotated")
+	if err := c.Update(ctx, token); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := creds.Password(ctx); err != nil || got != "rotated" {
+		t.Errorf("token = %q, %v; want the rotated token", got, err)
+	}
+
+	for name, auth := range map[string]*kubequeryv1beta1.PostgresAuth{
+		"token path": {Token: &kubequeryv1beta1.TokenAuth{Path: "/var/run/token"}},
+		"exec":       {Exec: &kubequeryv1beta1.ExecAuth{Command: "get-token"}},
+	} {
+		_, err := r.Credentials(ctx, "team-a", &kubequeryv1beta1.PostgresConnectionSpec{Auth: auth})
+		if !errors.Is(err, ErrPluginsDisabled) {
+			t.Errorf("%s: err = %v, want ErrPluginsDisabled", name, err)
+		}
+	}
+	if _, err := r.Credentials(ctx, "team-a", &kubequeryv1beta1.PostgresConnectionSpec{}); err == nil {
+		t.Error("resolved credentials without auth")
+	}
+}
+
+func TestCredentialPluginAllowlist(t *testing.T) {
+	ctx := context.Background()
+	r := &Resolver{
+		Reader:                  fake.NewClientBuilder().Build(),
+		EnableCredentialPlugins: true,
+		AllowedTokenPaths:       []string{"/var/run/secrets/db/", "/etc/token"},
+		AllowedExecCommands:     []string{"aws"},
+	}
+	tests := []struct {
+		name    string
+		auth    *kubequeryv1beta1.PostgresAuth
+		allowed bool
+	}{
+		{"file in allowed directory", &kubequeryv1beta1.PostgresAuth{Token: &kubequeryv1beta1.TokenAuth{Path: "/var/run/secrets/db/token"}}, true},
+		{"allowed file", &kubequeryv1beta1.PostgresAuth{Token: &kubequeryv1beta1.TokenAuth{Path: "/etc/token"}}, true},
+		{"sibling of allowed file", &kubequeryv1beta1.PostgresAuth{Token: &kubequeryv1beta1.TokenAuth{Path: "/etc/token2"}}, false},
+		{"traversal", &kubequeryv1beta1.PostgresAuth{Token: &kubequeryv1beta1.TokenAuth{Path: "/var/run/secrets/db/../../../../etc/shadow"}}, false},
+		{"relative path", &kubequeryv1beta1.PostgresAuth{Token: &kubequeryv1beta1.TokenAuth{Path: "var/run/secrets/db/token"}}, false},
+		{"allowed command", &kubequeryv1beta1.PostgresAuth{Exec: &kubequeryv1beta1.ExecAuth{Command: "aws"}}, true},
+		{"other command", &kubequeryv1beta1.PostgresAuth{Exec: &kubequeryv1beta1.ExecAuth{Command: "sh"}}, false},
+		{"command by path", &kubequeryv1beta1.PostgresAuth{Exec: &kubequeryv1beta1.ExecAuth{Command: "/tmp/aws"}}, false},
+	}
+	for _, tt := range tests {
+		_, err := r.Credentials(ctx, "team-a", &kubequeryv1beta1.PostgresConnectionSpec{Auth: tt.auth})
+		if tt.allowed && err != nil {
+			t.Errorf("%s: err = %v, want allowed", tt.name, err)
+		}
+		if !tt.allowed && !errors.Is(err, ErrPluginNotAllowed) {
+			t.Errorf("%s: err = %v, want ErrPluginNotAllowed", tt.name, err)
+		}
+	}
+
+	r.AllowedTokenPaths, r.AllowedExecCommands = nil, nil
+	_, err := r.Credentials(ctx, "team-a", &kubequeryv1beta1.PostgresConnectionSpec{
+		Auth: &kubequeryv1beta1.PostgresAuth{Exec: &kubequeryv1beta1.ExecAuth{Command: "aws"}},
+	})
+	if !errors.Is(err, ErrPluginNotAllowed) {
+		t.Errorf("err = %v, want ErrPluginNotAllowed without an allowlist", err)
+	}
+}
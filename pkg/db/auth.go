@@ -0,0 +1,116 @@
+package db
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Credentials supplies the password sent when a new connection is opened.
+// Connect asks for it on every connection, so implementations may return
+// short-lived tokens.
+type Credentials interface {
+	Password(ctx context.Context) (string, error)
+}
+
+// CredentialsFunc adapts a function to the Credentials interface.
+type CredentialsFunc func(ctx context.Context) (string, error)
+
+// Password calls f(ctx).
+func (f CredentialsFunc) Password(ctx context.Context) (string, error) {
+	return f(ctx)
+}
+
+// StaticPassword is a fixed password.
+type StaticPassword string
+
+// Password returns the password unchanged.
+func (p StaticPassword) Password(context.Context) (string, error) {
+	return string(p), nil
+}
+
+// TokenFile reads a token from a file each time a connection is opened, e.g.
+// a projected ServiceAccount token that the kubelet rotates in place.
+type TokenFile string
+
+// Password returns the trimmed contents of the file.
+func (f TokenFile) Password(context.Context) (string, error) {
+	b, err := os.ReadFile(string(f))
+	if err != nil {
+		return "", fmt.Errorf("failed to read token file: %w", err)
+	}
+	token := strings.TrimSpace(string(b))
+	if token == "" {
+		return "", fmt.Errorf("token file %s is empty", f)
+	}
+	return token, nil
+}
+
+// execExpirySkew is how long before its expiry a cached exec token is refreshed.
+const execExpirySkew = 30 * time.Second
+
+// ExecCredentials runs an external command and uses its output as the
+// password. The command may print the token as plain text, or a JSON object
+// {"token": "...", "expirationTimestamp": "<RFC 3339>"}; in the latter case
+// the token is cached until shortly before it expires.
+type ExecCredentials struct {
+	Command string
+	Args    []string
+	// Env holds additional KEY=VALUE pairs appended to the manager's environment.
+	Env []string
+
+	mu     sync.Mutex
+	token  string
+	expiry time.Time
+}
+
+type execCredentialOutput struct {
+	Token               string     `json:"token"`
+	ExpirationTimestamp *time.Time `json:"expirationTimestamp,omitempty"`
+}
+
+// Password returns a cached token if it is still valid, otherwise it runs the command.
+func (e *ExecCredentials) Password(ctx context.Context) (string, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.token != "" && time.Now().Add(execExpirySkew).Before(e.expiry) {
+		return e.token, nil
+	}
+
+	cmd := exec.CommandContext(ctx, e.Command, e.Args...)
+	cmd.Env = append(os.Environ(), e.Env...)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		// stderr is not included: plugins may echo credentials on failure.
+		return "", fmt.Errorf("exec credential plugin %q failed: %w", e.Command, err)
+	}
+
+	out := bytes.TrimSpace(stdout.Bytes())
+	if len(out) == 0 {
+		return "", fmt.Errorf("exec credential plugin %q returned no token", e.Command)
+	}
+	if out[0] != '{' {
+		e.token, e.expiry = "", time.Time{}
+		return string(out), nil
+	}
+	var parsed execCredentialOutput
+	if err := json.Unmarshal(out, &parsed); err != nil {
+		return "", fmt.Errorf("exec credential plugin %q returned invalid JSON: %w", e.Command, err)
+	}
+	if parsed.Token == "" {
+		return "", fmt.Errorf("exec credential plugin %q returned no token", e.Command)
+	}
+	e.token, e.expiry = parsed.Token, time.Time{}
+	if parsed.ExpirationTimestamp != nil {
+		e.expiry = *parsed.ExpirationTimestamp
+	}
+	return parsed.Token, nil
+}
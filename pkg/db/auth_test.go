@@ -0,0 +1,101 @@
+package db
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTokenFile(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(path, []byte("  first\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := TokenFile(path).Password(ctx); err != nil || got != "first" {
+		t.Errorf("token = %q, %v; want the trimmed contents", got, err)
+	}
+	// The file is read again for every connection, so rotations are picked up.
+	if err := os.WriteFile(path, []byte("rotated"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := TokenFile(path).Password(ctx); err != nil || got != "rotated" {
+		t.Errorf("token = %q, %v; want the rotated token", got, err)
+	}
+
+	if err := os.WriteFile(path, []byte("\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := TokenFile(path).Password(ctx); err == nil || !strings.Contains(err.Error(), "empty") {
+		t.Errorf("err = %v, want an empty token file error", err)
+	}
+	if _, err := TokenFile(filepath.Join(t.TempDir(), "missing")).Password(ctx); err == nil {
+		t.Error("read a missing token file")
+	}
+}
+
+// shell returns ExecCredentials that run script with sh.
+func shell(script string, env ...string) *ExecCredentials {
+	return &ExecCredentials{Command: "sh", Args: []string{"-c", script}, Env: env}
+}
+
+func TestExecCredentials(t *testing.T) {
+	ctx := context.Background()
+	if _, err := os.Stat("/bin/sh"); err != nil {
+		t.Skip("sh is not available")
+	}
+
+	t.Run("plain text", func(t *testing.T) {
+		if got, err := shell(`echo "  $TOKEN  "`, "TOKEN=plain").Password(ctx); err != nil || got != "plain" {
+			t.Errorf("token = %q, %v; want the trimmed output with Env applied", got, err)
+		}
+	})
+
+	t.Run("cached until expiry", func(t *testing.T) {
+		calls := filepath.Join(t.TempDir(), "calls")
+		expiry := time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+		e := shell(`echo x >> "$CALLS"; echo '{"token": "cached", "expirationTimestamp": "`+expiry+`"}'`, "CALLS="+calls)
+		for range 3 {
+			if got, err := e.Password(ctx); err != nil || got != "cached" {
+				t.Fatalf("token = %q, %v; want cached", got, err)
+			}
+		}
+		if b, _ := os.ReadFile(calls); strings.Count(string(b), "x") != 1 {
+			t.Errorf("command ran %d times, want once", strings.Count(string(b), "x"))
+		}
+	})
+
+	t.Run("refreshed near expiry", func(t *testing.T) {
+		calls := filepath.Join(t.TempDir(), "calls")
+		expiry := time.Now().Add(execExpirySkew / 2).UTC().Format(time.RFC3339)
+		e := shell(`echo x >> "$CALLS"; echo '{"token": "short", "expirationTimestamp": "`+expiry+`"}'`, "CALLS="+calls)
+		for range 2 {
+			if _, err := e.Password(ctx); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if b, _ := os.ReadFile(calls); strings.Count(string(b), "x") != 2 {
+			t.Errorf("command ran %d times, want a refresh for every connection", strings.Count(string(b), "x"))
+		}
+	})
+
+	for name, script := range map[string]string{
+		"no output":     `true`,
+		"invalid JSON":  `echo '{"token":'`,
+		"empty token":   `echo '{"token": ""}'`,
+		"command fails": `echo secret-on-stderr >&2; exit 3`,
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := shell(script).Password(ctx)
+			if err == nil {
+				t.Fatal("Password succeeded")
+			}
+			if strings.Contains(err.Error(), "secret-on-stderr") {
+				t.Errorf("err = %v, must not include stderr", err)
+			}
+		})
+	}
+}
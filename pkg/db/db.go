@@ -2,74 +2,88 @@ package db
 
 import (
 	"context"
-	"crypto/tls"
-	"crypto/x509"
-	"fmt"
-	"os"
-
-	"github.com/jackc/pgx/v5/pgxpool"
+	"time"
 )
 
-type SSLConfig struct {
-	Mode   string
-	CAPath string
-}
-
+// ConnConfig describes how to connect to a database.
 type ConnConfig struct {
-	Host     string
-	Port     int
+	// Engine selects the driver used by Connect. Empty means PostgreSQL.
+	Engine Engine
+	Host   string
+	Port   int
+	// Hosts are additional servers tried in order after Host, for example the
+	// members of a highly-available cluster.
+	Hosts    []HostPort
 	Database string
 	User     string
 	Password string
-	SSL      *SSLConfig
+	// Credentials, if set, is asked for the password on every new connection
+	// and takes precedence over Password.
+	Credentials Credentials
+	SSL         *SSLConfig
+	// TargetSessionAttrs selects which server is accepted when several hosts
+	// are configured, as in libpq: any, read-write, read-only, primary,
+	// standby or prefer-standby. Empty means any. PostgreSQL only.
+	TargetSessionAttrs string
+	// RuntimeParams are sent as session parameters when connecting, e.g.
+	// application_name, search_path or statement_timeout. For MySQL they are
+	// set as session system variables, except application_name, which is
+	// sent as the program_name connection attribute.
+	RuntimeParams map[string]string
+}
+
+// HostPort is a single database server address.
+type HostPort struct {
+	Host string
+	Port int
+}
+
+// Execer runs SQL. It is implemented by Conn and Tx.
+type Execer interface {
+	// Exec runs sql and returns a summary of its outcome: the command tag
+	// for PostgreSQL, e.g. "UPDATE 3", or the affected row count for MySQL.
+	Exec(ctx context.Context, sql string, arguments ...any) (string, error)
+}
+
+// ExecSQL executes sql and returns the summary reported by q.
+func ExecSQL(ctx context.Context, q Execer, sql string) (string, error) {
+	return q.Exec(ctx, sql)
 }
 
-// Connect returns a pgxpool.Pool for the given config, supporting SSL/TLS.
-func Connect(ctx context.Context, cfg ConnConfig) (*pgxpool.Pool, error) {
-	connStr := fmt.Sprintf(
-		"host=%s port=%d dbname=%s user=%s password=%s sslmode=%s",
-		cfg.Host, cfg.Port, cfg.Database, cfg.User, cfg.Password,
-		"disable",
-	)
-	var tlsConfig *tls.Config
-	sslmode := "disable"
-	if cfg.SSL != nil && cfg.SSL.Mode != "disable" {
-		sslmode = cfg.SSL.Mode
-		if cfg.SSL.CAPath != "" {
-			caCert, err := os.ReadFile(cfg.SSL.CAPath)
-			if err != nil {
-				return nil, fmt.Errorf("failed to read CA cert: %w", err)
-			}
-			roots := x509.NewCertPool()
-			if !roots.AppendCertsFromPEM(caCert) {
-				return nil, fmt.Errorf("failed to append CA cert")
-			}
-			tlsConfig = &tls.Config{RootCAs: roots}
+// LockRetry controls how ExecWithRetry retries after lock timeouts.
+type LockRetry struct {
+	// MaxAttempts is the total number of attempts; values below 2 disable retries.
+	MaxAttempts int
+	// Backoff is the wait before the first retry. It doubles after each attempt.
+	Backoff time.Duration
+	// OnRetry, if set, is called before each wait.
+	OnRetry func(attempt int, backoff time.Duration)
+}
+
+// ExecWithRetry executes sql with ExecSQL, retrying as configured by policy
+// when it fails with a lock timeout.
+func ExecWithRetry(ctx context.Context, q Execer, sql string, policy LockRetry) (string, error) {
+	backoff := policy.Backoff
+	for attempt := 1; ; attempt++ {
+		result, err := ExecSQL(ctx, q, sql)
+		if err == nil || !IsLockTimeout(err) || attempt >= policy.MaxAttempts {
+			return result, err
 		}
+		if policy.OnRetry != nil {
+			policy.OnRetry(attempt, backoff)
+		}
+		select {
+		case <-ctx.Done():
+			return "", err
+		case <-time.After(backoff):
+		}
+		backoff *= 2
 	}
-	connStr = fmt.Sprintf(
-		"host=%s port=%d dbname=%s user=%s password=%s sslmode=%s",
-		cfg.Host, cfg.Port, cfg.Database, cfg.User, cfg.Password, sslmode,
-	)
-	poolConfig, err := pgxpool.ParseConfig(connStr)
-	if err != nil {
-		return nil, fmt.Errorf("failed to parse pool config: %w", err)
-	}
-	if tlsConfig != nil {
-		poolConfig.ConnConfig.TLSConfig = tlsConfig
-	}
-	pool, err := pgxpool.NewWithConfig(ctx, poolConfig)
-	if err != nil {
-		return nil, fmt.Errorf("failed to connect to db: %w", err)
-	}
-	return pool, nil
 }
 
-// ExecSQL executes a single SQL statement and returns the command tag or error.
-func ExecSQL(ctx context.Context, pool *pgxpool.Pool, sql string) (string, error) {
-	ct, err := pool.Exec(ctx, sql)
-	if err != nil {
-		return "", err
-	}
-	return ct.String(), nil
+// IsLockTimeout reports whether err was caused by a lock wait timing out:
+// lock_timeout in PostgreSQL, innodb_lock_wait_timeout or lock_wait_timeout
+// in MySQL.
+func IsLockTimeout(err error) bool {
+	return isPostgresLockTimeout(err) || isMySQLLockTimeout(err)
 }
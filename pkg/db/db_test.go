@@ -0,0 +1,145 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+func TestNewPoolConfig(t *testing.T) {
+	cfg := ConnConfig{
+		Host:               "primary.db",
+		Port:               5432,
+		Hosts:              []HostPort{{Host: "replica-1.db", Port: 5433}, {Host: "replica-2.db", Port: 5434}},
+		Database:           "my db='x'",
+		User:               "app user",
+		Password:           "pa ss='word' host=evil",
+		SSL:                &SSLConfig{Mode: SSLModePrefer},
+		TargetSessionAttrs: "read-write",
+		RuntimeParams:      map[string]string{"application_name": "kubequery", "search_path": "app, public"},
+	}
+	poolConfig, err := newPoolConfig(cfg)
+	if err != nil {
+		t.Fatalf("newPoolConfig: %v", err)
+	}
+	cc := poolConfig.ConnConfig
+	if cc.Host != "primary.db" || cc.Port != 5432 || cc.TLSConfig == nil {
+		t.Errorf("first attempt = %s:%d tls=%v, want primary.db:5432 over TLS", cc.Host, cc.Port, cc.TLSConfig != nil)
+	}
+	if cc.Database != cfg.Database || cc.User != cfg.User || cc.Password != cfg.Password {
+		t.Errorf("values with spaces and quotes were altered: %q %q %q", cc.Database, cc.User, cc.Password)
+	}
+	if cc.RuntimeParams["search_path"] != "app, public" {
+		t.Errorf("runtime params = %v", cc.RuntimeParams)
+	}
+	if cc.ValidateConnect == nil {
+		t.Error("read-write target_session_attrs should install a ValidateConnect hook")
+	}
+
+	// prefer yields TLS then plaintext for every host.
+	want := []struct {
+		host string
+		port uint16
+		tls  bool
+	}{
+		{"primary.db", 5432, false},
+		{"replica-1.db", 5433, true},
+		{"replica-1.db", 5433, false},
+		{"replica-2.db", 5434, true},
+		{"replica-2.db", 5434, false},
+	}
+	if len(cc.Fallbacks) != len(want) {
+		t.Fatalf("got %d fallbacks, want %d", len(cc.Fallbacks), len(want))
+	}
+	for i, w := range want {
+		fb := cc.Fallbacks[i]
+		if fb.Host != w.host || fb.Port != w.port || (fb.TLSConfig != nil) != w.tls {
+			t.Errorf("fallback %d = %s:%d tls=%v, want %s:%d tls=%v", i, fb.Host, fb.Port, fb.TLSConfig != nil, w.host, w.port, w.tls)
+		}
+	}
+	if fb := cc.Fallbacks[1]; fb.TLSConfig.ServerName != "replica-1.db" {
+		t.Errorf("fallback TLS server name = %q, want the fallback host", fb.TLSConfig.ServerName)
+	}
+}
+
+func TestNewPoolConfigRejectsInvalidInput(t *testing.T) {
+	for name, cfg := range map[string]ConnConfig{
+		"port out of range":   {Host: "db", Port: 70000},
+		"empty fallback host": {Host: "db", Port: 5432, Hosts: []HostPort{{Port: 5432}}},
+		"unknown attrs":       {Host: "db", Port: 5432, TargetSessionAttrs: "leader"},
+	} {
+		if _, err := newPoolConfig(cfg); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+// execerFunc adapts a function to the Execer interface.
+type execerFunc func(sql string) (string, error)
+
+func (f execerFunc) Exec(_ context.Context, sql string, _ ...any) (string, error) {
+	return f(sql)
+}
+
+func TestExecWithRetry(t *testing.T) {
+	ctx := context.Background()
+	lockTimeout := &pgconn.PgError{Code: lockNotAvailable, Message: "canceling statement due to lock timeout"}
+
+	attempts := 0
+	var backoffs []time.Duration
+	q := execerFunc(func(string) (string, error) {
+		if attempts++; attempts < 3 {
+			return "", lockTimeout
+		}
+		return "ALTER TABLE", nil
+	})
+	got, err := ExecWithRetry(ctx, q, "ALTER TABLE t ADD c int", LockRetry{
+		MaxAttempts: 3,
+		Backoff:     time.Millisecond,
+		OnRetry:     func(_ int, backoff time.Duration) { backoffs = append(backoffs, backoff) },
+	})
+	if err != nil || got != "ALTER TABLE" || attempts != 3 {
+		t.Errorf("ExecWithRetry = %q, %v after %d attempts; want success on the third", got, err, attempts)
+	}
+	if len(backoffs) != 2 || backoffs[0] != time.Millisecond || backoffs[1] != 2*time.Millisecond {
+		t.Errorf("backoffs = %v, want [1ms 2ms]", backoffs)
+	}
+
+	tests := []struct {
+		name     string
+		err      error
+		policy   LockRetry
+		attempts int
+	}{
+		{"exhausted", lockTimeout, LockRetry{MaxAttempts: 2, Backoff: time.Millisecond}, 2},
+		{"retries disabled", lockTimeout, LockRetry{MaxAttempts: 1, Backoff: time.Millisecond}, 1},
+		{"other errors", errors.New("syntax error"), LockRetry{MaxAttempts: 3, Backoff: time.Millisecond}, 1},
+	}
+	for _, tt := range tests {
+		attempts := 0
+		q := execerFunc(func(string) (string, error) {
+			attempts++
+			return "", tt.err
+		})
+		if _, err := ExecWithRetry(ctx, q, "UPDATE t SET c = 1", tt.policy); !errors.Is(err, tt.err) {
+			t.Errorf("%s: err = %v, want %v", tt.name, err, tt.err)
+		}
+		if attempts != tt.attempts {
+			t.Errorf("%s: %d attempts, want %d", tt.name, attempts, tt.attempts)
+		}
+	}
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	attempts = 0
+	q = execerFunc(func(string) (string, error) {
+		attempts++
+		return "", lockTimeout
+	})
+	if _, err := ExecWithRetry(cancelled, q, "UPDATE t SET c = 1", LockRetry{MaxAttempts: 3, Backoff: time.Hour}); !errors.Is(err, lockTimeout) || attempts != 1 {
+		t.Errorf("err = %v after %d attempts; want the lock timeout without waiting out the backoff", err, attempts)
+	}
+}
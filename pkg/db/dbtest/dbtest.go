@@ -0,0 +1,304 @@
+// Package dbtest provides an in-memory db.Driver for tests. It does not parse
+// SQL: every statement is answered by a Handler, and the statements, sessions
+// and signals seen by the driver are recorded for assertions.
+package dbtest
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+
+	"github.com/rsavage/KubeQuery/pkg/db"
+)
+
+// ErrLockTimeout is the error PostgreSQL returns when lock_timeout expires.
+// db.IsLockTimeout reports true for it.
+var ErrLockTimeout error = &pgconn.PgError{Code: "55P03", Message: "canceling statement due to lock timeout"}
+
+// Result is what a statement returns.
+type Result struct {
+	// Tag is the summary returned by Exec, e.g. "UPDATE 3".
+	Tag string
+	// Columns and Rows are returned by Query.
+	Columns []db.Column
+	Rows    [][]any
+}
+
+// Handler answers a statement. ctx is cancelled when the statement is
+// cancelled through the pool or its session is terminated, so a Handler that
+// blocks on ctx simulates a long-running query.
+type Handler func(ctx context.Context, sql string, args []any) (Result, error)
+
+// Driver is an in-memory db.Driver. The zero value answers every statement
+// with an empty Result.
+type Driver struct {
+	// Handler answers statements; nil returns an empty Result.
+	Handler Handler
+	// ConnectErr, if set, is returned by Connect.
+	ConnectErr error
+
+	mu         sync.Mutex
+	configs    []db.ConnConfig
+	statements []string
+	nextID     uint32
+	sessions   map[uint32]*session
+	cancelled  []uint32
+	terminated []uint32
+}
+
+var _ db.Driver = (*Driver)(nil)
+
+// session is an open fake server session.
+type session struct {
+	// cancel cancels the statement running on the session, if any.
+	cancel context.CancelFunc
+}
+
+// Connect records cfg and returns a pool of fake sessions.
+func (d *Driver) Connect(_ context.Context, cfg db.ConnConfig) (db.Pool, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.configs = append(d.configs, cfg)
+	if d.ConnectErr != nil {
+		return nil, d.ConnectErr
+	}
+	return &pool{d: d}, nil
+}
+
+// Configs returns the configurations passed to Connect, in order.
+func (d *Driver) Configs() []db.ConnConfig {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return append([]db.ConnConfig(nil), d.configs...)
+}
+
+// Statements returns the statements run so far, in order. Transaction
+// control is recorded as BEGIN, BEGIN READ ONLY, COMMIT and ROLLBACK.
+func (d *Driver) Statements() []string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return append([]string(nil), d.statements...)
+}
+
+// Cancelled returns the sessions a cancel was requested for.
+func (d *Driver) Cancelled() []uint32 {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return append([]uint32(nil), d.cancelled...)
+}
+
+// Terminated returns the sessions that were terminated.
+func (d *Driver) Terminated() []uint32 {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return append([]uint32(nil), d.terminated...)
+}
+
+// OpenSessions returns the number of sessions acquired and not yet released
+// or terminated.
+func (d *Driver) OpenSessions() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return len(d.sessions)
+}
+
+func (d *Driver) record(sql string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.statements = append(d.statements, sql)
+}
+
+// run records sql and answers it on session id. The statement can be
+// cancelled through the pool while the Handler runs.
+func (d *Driver) run(ctx context.Context, id uint32, sql string, args []any) (Result, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	d.mu.Lock()
+	s, ok := d.sessions[id]
+	if !ok {
+		d.mu.Unlock()
+		return Result{}, errors.New("dbtest: session is closed")
+	}
+	s.cancel = cancel
+	d.statements = append(d.statements, sql)
+	d.mu.Unlock()
+	defer func() {
+		d.mu.Lock()
+		s.cancel = nil
+		d.mu.Unlock()
+	}()
+
+	if d.Handler == nil {
+		return Result{}, nil
+	}
+	return d.Handler(ctx, sql, args)
+}
+
+// signal cancels the statement running on session id and, if terminate is
+// set, closes the session. It reports whether the session exists.
+func (d *Driver) signal(id uint32, terminate bool) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if terminate {
+		d.terminated = append(d.terminated, id)
+	} else {
+		d.cancelled = append(d.cancelled, id)
+	}
+	s, ok := d.sessions[id]
+	if !ok {
+		return false
+	}
+	if s.cancel != nil {
+		s.cancel()
+	}
+	if terminate {
+		delete(d.sessions, id)
+	}
+	return true
+}
+
+// pool implements db.Pool.
+type pool struct {
+	d *Driver
+}
+
+func (p *pool) Acquire(ctx context.Context) (db.Conn, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	d := p.d
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.sessions == nil {
+		d.sessions = map[uint32]*session{}
+	}
+	d.nextID++
+	d.sessions[d.nextID] = &session{}
+	return &conn{querier{d: d, id: d.nextID}}, nil
+}
+
+func (p *pool) Cancel(_ context.Context, id uint32) (bool, error) {
+	return p.d.signal(id, false), nil
+}
+
+func (p *pool) Terminate(_ context.Context, id uint32) (bool, error) {
+	return p.d.signal(id, true), nil
+}
+
+// SessionActive reports whether session id is open; the application name
+// and start time are not checked.
+func (p *pool) SessionActive(_ context.Context, id uint32, _ string, _ time.Time) (bool, error) {
+	p.d.mu.Lock()
+	defer p.d.mu.Unlock()
+	_, ok := p.d.sessions[id]
+	return ok, nil
+}
+
+func (p *pool) Close() {}
+
+// querier implements db.Querier on a session.
+type querier struct {
+	d  *Driver
+	id uint32
+}
+
+func (q querier) Exec(ctx context.Context, sql string, arguments ...any) (string, error) {
+	res, err := q.d.run(ctx, q.id, sql, arguments)
+	if err != nil {
+		return "", err
+	}
+	return res.Tag, nil
+}
+
+func (q querier) Query(ctx context.Context, sql string, args ...any) (db.Rows, error) {
+	res, err := q.d.run(ctx, q.id, sql, args)
+	if err != nil {
+		return nil, err
+	}
+	return &rows{columns: res.Columns, rows: res.Rows, i: -1}, nil
+}
+
+// conn implements db.Conn.
+type conn struct {
+	querier
+}
+
+func (c *conn) SessionID() uint32 {
+	return c.id
+}
+
+func (c *conn) Begin(ctx context.Context, opts db.TxOptions) (db.Tx, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if opts.ReadOnly {
+		c.d.record("BEGIN READ ONLY")
+	} else {
+		c.d.record("BEGIN")
+	}
+	return &tx{querier: c.querier}, nil
+}
+
+func (c *conn) Release() {
+	c.d.mu.Lock()
+	defer c.d.mu.Unlock()
+	delete(c.d.sessions, c.id)
+}
+
+// tx implements db.Tx.
+type tx struct {
+	querier
+	done bool
+}
+
+func (t *tx) Commit(context.Context) error {
+	return t.end("COMMIT")
+}
+
+func (t *tx) Rollback(context.Context) error {
+	return t.end("ROLLBACK")
+}
+
+func (t *tx) end(stmt string) error {
+	if t.done {
+		return errors.New("dbtest: transaction already closed")
+	}
+	t.done = true
+	t.d.record(stmt)
+	return nil
+}
+
+// rows implements db.Rows over a fixed result.
+type rows struct {
+	columns []db.Column
+	rows    [][]any
+	i       int
+}
+
+func (r *rows) Columns() []db.Column {
+	return r.columns
+}
+
+func (r *rows) Next() bool {
+	if r.i+1 >= len(r.rows) {
+		return false
+	}
+	r.i++
+	return true
+}
+
+func (r *rows) Values() ([]any, error) {
+	if r.i < 0 || r.i >= len(r.rows) {
+		return nil, errors.New("dbtest: no current row")
+	}
+	return r.rows[r.i], nil
+}
+
+func (r *rows) Err() error {
+	return nil
+}
+
+func (r *rows) Close() {}
@@ -0,0 +1,93 @@
+package dbtest
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/rsavage/KubeQuery/pkg/db"
+)
+
+func TestCancelInterruptsStatement(t *testing.T) {
+	d := &Driver{Handler: func(ctx context.Context, sql string, _ []any) (Result, error) {
+		if sql == "SELECT pg_sleep(60)" {
+			<-ctx.Done()
+			return Result{}, ctx.Err()
+		}
+		return Result{Tag: "SELECT 1"}, nil
+	}}
+	ctx := context.Background()
+	pool, err := d.Connect(ctx, db.ConnConfig{Host: "db", Port: 5432})
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn, err := pool.Acquire(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Release()
+
+	done := make(chan error)
+	go func() {
+		_, err := conn.Exec(ctx, "SELECT pg_sleep(60)")
+		done <- err
+	}()
+	// Wait for the statement to start before cancelling it.
+	for len(d.Statements()) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	if ok, _ := pool.Cancel(ctx, conn.SessionID()); !ok {
+		t.Fatal("cancel was not delivered")
+	}
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Fatalf("err = %v, want context.Canceled", err)
+	}
+	if tag, err := conn.Exec(ctx, "SELECT 1"); err != nil || tag != "SELECT 1" {
+		t.Errorf("session unusable after cancel: %q, %v", tag, err)
+	}
+}
+
+func TestTransactionsAndRows(t *testing.T) {
+	d := &Driver{Handler: func(context.Context, string, []any) (Result, error) {
+		return Result{Columns: []db.Column{{Name: "n", Type: "int4"}}, Rows: [][]any{{int32(1)}, {int32(2)}}}, nil
+	}}
+	ctx := context.Background()
+	pool, _ := d.Connect(ctx, db.ConnConfig{})
+	conn, _ := pool.Acquire(ctx)
+	tx, err := conn.Begin(ctx, db.TxOptions{ReadOnly: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows, err := tx.Query(ctx, "SELECT n FROM t")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []any
+	for rows.Next() {
+		v, err := rows.Values()
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, v[0])
+	}
+	rows.Close()
+	if err := tx.Rollback(ctx); err != nil {
+		t.Fatal(err)
+	}
+	conn.Release()
+
+	if len(got) != 2 || got[1] != int32(2) {
+		t.Errorf("rows = %v", got)
+	}
+	want := []string{"BEGIN READ ONLY", "SELECT n FROM t", "ROLLBACK"}
+	if stmts := d.Statements(); len(stmts) != len(want) || stmts[0] != want[0] || stmts[2] != want[2] {
+		t.Errorf("statements = %q, want %q", stmts, want)
+	}
+	if d.OpenSessions() != 0 {
+		t.Errorf("%d sessions still open", d.OpenSessions())
+	}
+	if !db.IsLockTimeout(ErrLockTimeout) {
+		t.Error("ErrLockTimeout should be a lock timeout")
+	}
+}
@@ -0,0 +1,127 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Engine identifies a database server family.
+type Engine string
+
+// Engines with a built-in driver.
+const (
+	EnginePostgres Engine = "postgres"
+	// EngineMySQL covers MySQL and MariaDB.
+	EngineMySQL Engine = "mysql"
+)
+
+// Driver opens connections to one database engine.
+type Driver interface {
+	// Connect returns a pool of connections described by cfg. It does not
+	// necessarily contact the server; Acquire does.
+	Connect(ctx context.Context, cfg ConnConfig) (Pool, error)
+}
+
+// Pool is a set of connections to one database.
+type Pool interface {
+	// Acquire pins a single server session, so that the statements run on it
+	// can be identified and signalled from another session. The caller must
+	// Release it.
+	Acquire(ctx context.Context) (Conn, error)
+	// Cancel asks the server to cancel the statement running on session id.
+	// It reports whether the signal was delivered.
+	Cancel(ctx context.Context, id uint32) (bool, error)
+	// Terminate ends session id, rolling back its open transaction. It
+	// reports whether the signal was delivered.
+	Terminate(ctx context.Context, id uint32) (bool, error)
+	// SessionActive reports whether session id is still open, was opened with
+	// the given application name and no later than startedBefore. The checks
+	// guard against the id having been reused by a newer session.
+	SessionActive(ctx context.Context, id uint32, applicationName string, startedBefore time.Time) (bool, error)
+	// Close closes every connection of the pool.
+	Close()
+}
+
+// Querier runs SQL and returns rows.
+type Querier interface {
+	Execer
+	// Query runs sql and returns its result set. The caller must close it.
+	Query(ctx context.Context, sql string, args ...any) (Rows, error)
+}
+
+// Conn is a single server session taken from a Pool.
+type Conn interface {
+	Querier
+	// SessionID identifies the session on the server: the backend PID for
+	// PostgreSQL, the connection ID for MySQL.
+	SessionID() uint32
+	// Begin starts a transaction.
+	Begin(ctx context.Context, opts TxOptions) (Tx, error)
+	// Release returns the session to its pool.
+	Release()
+}
+
+// TxOptions configures a transaction started with Conn.Begin.
+type TxOptions struct {
+	// ReadOnly starts the transaction in read-only mode, so the server
+	// rejects writes.
+	ReadOnly bool
+}
+
+// Tx is a transaction on a Conn. It must end with Commit or Rollback.
+type Tx interface {
+	Querier
+	Commit(ctx context.Context) error
+	Rollback(ctx context.Context) error
+}
+
+// Column describes a column of a result set.
+type Column struct {
+	Name string
+	// Type is the database type name, e.g. int4 or VARCHAR.
+	Type string
+	// OID is the PostgreSQL type OID; zero for MySQL.
+	OID uint32
+}
+
+// Rows is a result set read one row at a time.
+type Rows interface {
+	Columns() []Column
+	// Next advances to the next row; it returns false once the rows are
+	// exhausted or an error occurred.
+	Next() bool
+	// Values returns the values of the current row, decoded to Go types.
+	Values() ([]any, error)
+	// Err returns the error, if any, that ended iteration.
+	Err() error
+	Close()
+}
+
+// drivers holds the built-in driver of each engine.
+var drivers = map[Engine]Driver{
+	EnginePostgres: PostgresDriver{},
+	EngineMySQL:    MySQLDriver{},
+}
+
+// DriverFor returns the built-in driver for engine. An empty engine means
+// PostgreSQL.
+func DriverFor(engine Engine) (Driver, error) {
+	if engine == "" {
+		engine = EnginePostgres
+	}
+	d, ok := drivers[engine]
+	if !ok {
+		return nil, fmt.Errorf("unsupported database engine %q", engine)
+	}
+	return d, nil
+}
+
+// Connect returns a pool for cfg using the built-in driver of cfg.Engine.
+func Connect(ctx context.Context, cfg ConnConfig) (Pool, error) {
+	d, err := DriverFor(cfg.Engine)
+	if err != nil {
+		return nil, err
+	}
+	return d.Connect(ctx, cfg)
+}
@@ -0,0 +1,372 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// MySQL error numbers handled by the driver.
+const (
+	mysqlLockWaitTimeout = 1205 // ER_LOCK_WAIT_TIMEOUT
+	mysqlNoSuchThread    = 1094 // ER_NO_SUCH_THREAD
+)
+
+// mysqlKillTimeout bounds the KILL QUERY sent when a statement's context ends.
+const mysqlKillTimeout = 10 * time.Second
+
+// MySQLDriver connects to MySQL and MariaDB with go-sql-driver/mysql.
+type MySQLDriver struct{}
+
+// Connect returns a database/sql-backed Pool for cfg. Hosts and TLS
+// attempts are tried in the same order as for PostgreSQL.
+func (MySQLDriver) Connect(_ context.Context, cfg ConnConfig) (Pool, error) {
+	connector, err := newMySQLConnector(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &mysqlPool{db: sql.OpenDB(connector)}, nil
+}
+
+// mysqlConnector opens a connection with the first attempt that succeeds.
+type mysqlConnector struct {
+	attempts []driver.Connector
+}
+
+// newMySQLConnector builds one connector per host and TLS attempt.
+func newMySQLConnector(cfg ConnConfig) (*mysqlConnector, error) {
+	configs, err := mysqlConfigs(cfg)
+	if err != nil {
+		return nil, err
+	}
+	c := &mysqlConnector{}
+	for _, mc := range configs {
+		connector, err := mysql.NewConnector(mc)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create connector for %s: %w", mc.Addr, err)
+		}
+		c.attempts = append(c.attempts, connector)
+	}
+	return c, nil
+}
+
+// mysqlConfigs returns the driver configuration of every connection attempt,
+// in order: each host with each of its TLS attempts.
+func mysqlConfigs(cfg ConnConfig) ([]*mysql.Config, error) {
+	if cfg.TargetSessionAttrs != "" && cfg.TargetSessionAttrs != "any" {
+		return nil, fmt.Errorf("target_session_attrs %q is not supported for MySQL", cfg.TargetSessionAttrs)
+	}
+	base := mysql.NewConfig()
+	base.Net = "tcp"
+	base.User = cfg.User
+	base.Passwd = cfg.Password
+	base.DBName = cfg.Database
+	// Scripts may hold several statements, as they can for PostgreSQL.
+	base.MultiStatements = true
+	base.ParseTime = true
+	base.Params = make(map[string]string, len(cfg.RuntimeParams))
+	for k, v := range cfg.RuntimeParams {
+		if k == "application_name" {
+			if strings.ContainsAny(v, ",:") {
+				return nil, fmt.Errorf("application_name %q must not contain ',' or ':' for MySQL", v)
+			}
+			base.ConnectionAttributes = "program_name:" + v
+			continue
+		}
+		if !mysqlVariableName.MatchString(k) {
+			return nil, fmt.Errorf("invalid MySQL session variable name %q", k)
+		}
+		base.Params[k] = mysqlLiteral(v)
+	}
+	if cfg.Credentials != nil {
+		creds := cfg.Credentials
+		err := base.Apply(mysql.BeforeConnect(func(ctx context.Context, c *mysql.Config) error {
+			password, err := creds.Password(ctx)
+			if err != nil {
+				return fmt.Errorf("failed to obtain database credentials: %w", err)
+			}
+			c.Passwd = password
+			return nil
+		}))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var configs []*mysql.Config
+	hosts := append([]HostPort{{Host: cfg.Host, Port: cfg.Port}}, cfg.Hosts...)
+	for i, h := range hosts {
+		if h.Host == "" {
+			return nil, fmt.Errorf("host %d is empty", i)
+		}
+		if h.Port < 1 || h.Port > 65535 {
+			return nil, fmt.Errorf("invalid port %d for host %s", h.Port, h.Host)
+		}
+		attempts, err := tlsConfigs(cfg.SSL, h.Host)
+		if err != nil {
+			return nil, err
+		}
+		for _, tlsConfig := range attempts {
+			mc := base.Clone()
+			mc.Addr = net.JoinHostPort(h.Host, strconv.Itoa(h.Port))
+			mc.TLS = tlsConfig
+			configs = append(configs, mc)
+		}
+	}
+	return configs, nil
+}
+
+// Connect implements driver.Connector.
+func (c *mysqlConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	var errs []error
+	for _, attempt := range c.attempts {
+		conn, err := attempt.Connect(ctx)
+		if err == nil {
+			return conn, nil
+		}
+		errs = append(errs, err)
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return nil, fmt.Errorf("failed to connect to db: %w", errors.Join(errs...))
+}
+
+// Driver implements driver.Connector.
+func (c *mysqlConnector) Driver() driver.Driver {
+	return mysql.MySQLDriver{}
+}
+
+// mysqlVariableName matches the session variable names accepted in RuntimeParams.
+var mysqlVariableName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// mysqlNumber matches values that are set unquoted.
+var mysqlNumber = regexp.MustCompile(`^-?[0-9]+(\.[0-9]+)?$`)
+
+// mysqlLiteral renders v for a SET statement: numbers as they are, anything
+// else as a quoted string.
+func mysqlLiteral(v string) string {
+	if mysqlNumber.MatchString(v) {
+		return v
+	}
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `''`).Replace(v) + "'"
+}
+
+// isMySQLLockTimeout reports whether err was caused by a lock wait timeout.
+func isMySQLLockTimeout(err error) bool {
+	var myErr *mysql.MySQLError
+	return errors.As(err, &myErr) && myErr.Number == mysqlLockWaitTimeout
+}
+
+// mysqlPool implements Pool on top of a sql.DB.
+type mysqlPool struct {
+	db *sql.DB
+}
+
+func (p *mysqlPool) Acquire(ctx context.Context) (Conn, error) {
+	conn, err := p.db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var id uint64
+	if err := conn.QueryRowContext(ctx, "SELECT CONNECTION_ID()").Scan(&id); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	c := &mysqlConn{conn: conn, id: uint32(id), pool: p}
+	c.mysqlQuerier = mysqlQuerier{q: conn, conn: c}
+	return c, nil
+}
+
+// Cancel runs KILL QUERY, which ends the statement but keeps the session.
+func (p *mysqlPool) Cancel(ctx context.Context, id uint32) (bool, error) {
+	return p.kill(ctx, "KILL QUERY", id)
+}
+
+// Terminate runs KILL CONNECTION.
+func (p *mysqlPool) Terminate(ctx context.Context, id uint32) (bool, error) {
+	return p.kill(ctx, "KILL CONNECTION", id)
+}
+
+func (p *mysqlPool) kill(ctx context.Context, stmt string, id uint32) (bool, error) {
+	// KILL does not accept placeholders; id is a number.
+	_, err := p.db.ExecContext(ctx, fmt.Sprintf("%s %d", stmt, id))
+	var myErr *mysql.MySQLError
+	if errors.As(err, &myErr) && myErr.Number == mysqlNoSuchThread {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// SessionActive looks the connection up in the process list. MySQL only
+// reuses connection IDs after a restart, so instead of the application name
+// it checks that the server has been up since startedBefore.
+func (p *mysqlPool) SessionActive(ctx context.Context, id uint32, _ string, startedBefore time.Time) (bool, error) {
+	var name string
+	var uptime int64
+	if err := p.db.QueryRowContext(ctx, "SHOW GLOBAL STATUS LIKE 'Uptime'").Scan(&name, &uptime); err != nil {
+		return false, err
+	}
+	if time.Now().Add(-time.Duration(uptime) * time.Second).After(startedBefore) {
+		return false, nil
+	}
+	var active bool
+	err := p.db.QueryRowContext(ctx,
+		"SELECT EXISTS (SELECT 1 FROM information_schema.PROCESSLIST WHERE ID = ?)", id).Scan(&active)
+	return active, err
+}
+
+func (p *mysqlPool) Close() {
+	p.db.Close()
+}
+
+// sqlQuerier is implemented by *sql.Conn and *sql.Tx.
+type sqlQuerier interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}
+
+// mysqlQuerier adapts a sqlQuerier to Querier.
+type mysqlQuerier struct {
+	q    sqlQuerier
+	conn *mysqlConn
+}
+
+func (m mysqlQuerier) Exec(ctx context.Context, query string, arguments ...any) (string, error) {
+	defer m.conn.killOnDone(ctx)()
+	res, err := m.q.ExecContext(ctx, query, arguments...)
+	if err != nil {
+		return "", err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return "", err
+	}
+	if n == 1 {
+		return "1 row affected", nil
+	}
+	return fmt.Sprintf("%d rows affected", n), nil
+}
+
+func (m mysqlQuerier) Query(ctx context.Context, query string, args ...any) (Rows, error) {
+	stop := m.conn.killOnDone(ctx)
+	rows, err := m.q.QueryContext(ctx, query, args...)
+	if err != nil {
+		stop()
+		return nil, err
+	}
+	return &mysqlRows{rows: rows, stop: stop}, nil
+}
+
+// mysqlConn is a connection pinned from a mysqlPool.
+type mysqlConn struct {
+	mysqlQuerier
+	conn *sql.Conn
+	id   uint32
+	pool *mysqlPool
+}
+
+// killOnDone arranges for the running statement to be killed if ctx ends
+// before the returned function is called: go-sql-driver only closes the
+// connection, which leaves the statement running on the server.
+func (c *mysqlConn) killOnDone(ctx context.Context) func() {
+	stop := context.AfterFunc(ctx, func() {
+		killCtx, cancel := context.WithTimeout(context.Background(), mysqlKillTimeout)
+		defer cancel()
+		_, _ = c.pool.Cancel(killCtx, c.id)
+	})
+	return func() { stop() }
+}
+
+func (c *mysqlConn) SessionID() uint32 {
+	return c.id
+}
+
+func (c *mysqlConn) Begin(ctx context.Context, opts TxOptions) (Tx, error) {
+	tx, err := c.conn.BeginTx(ctx, &sql.TxOptions{ReadOnly: opts.ReadOnly})
+	if err != nil {
+		return nil, err
+	}
+	return mysqlTx{mysqlQuerier: mysqlQuerier{q: tx, conn: c}, tx: tx}, nil
+}
+
+func (c *mysqlConn) Release() {
+	c.conn.Close()
+}
+
+// mysqlTx adapts a sql.Tx to Tx.
+type mysqlTx struct {
+	mysqlQuerier
+	tx *sql.Tx
+}
+
+func (t mysqlTx) Commit(context.Context) error {
+	return t.tx.Commit()
+}
+
+func (t mysqlTx) Rollback(context.Context) error {
+	return t.tx.Rollback()
+}
+
+// mysqlRows adapts sql.Rows to Rows.
+type mysqlRows struct {
+	rows *sql.Rows
+	stop func()
+	cols []Column
+}
+
+func (r *mysqlRows) Columns() []Column {
+	if r.cols == nil {
+		types, err := r.rows.ColumnTypes()
+		if err != nil {
+			return nil
+		}
+		r.cols = make([]Column, len(types))
+		for i, t := range types {
+			r.cols[i] = Column{Name: t.Name(), Type: t.DatabaseTypeName()}
+		}
+	}
+	return r.cols
+}
+
+func (r *mysqlRows) Next() bool {
+	return r.rows.Next()
+}
+
+// Values scans the current row. go-sql-driver returns text columns as
+// []byte; they are converted to strings.
+func (r *mysqlRows) Values() ([]any, error) {
+	n := len(r.Columns())
+	values := make([]any, n)
+	dest := make([]any, n)
+	for i := range values {
+		dest[i] = &values[i]
+	}
+	if err := r.rows.Scan(dest...); err != nil {
+		return nil, err
+	}
+	for i, v := range values {
+		if b, ok := v.([]byte); ok {
+			values[i] = string(b)
+		}
+	}
+	return values, nil
+}
+
+func (r *mysqlRows) Err() error {
+	return r.rows.Err()
+}
+
+func (r *mysqlRows) Close() {
+	r.rows.Close()
+	r.stop()
+}
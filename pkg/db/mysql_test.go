@@ -0,0 +1,257 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/go-sql-driver/mysql"
+)
+
+func TestMySQLConfigs(t *testing.T) {
+	cfg := ConnConfig{
+		Engine:   EngineMySQL,
+		Host:     "primary.db",
+		Port:     3306,
+		Hosts:    []HostPort{{Host: "replica.db", Port: 3307}},
+		Database: "app",
+		User:     "app user",
+		Password: "pa'ss",
+		SSL:      &SSLConfig{Mode: SSLModePrefer},
+		RuntimeParams: map[string]string{
+			"application_name":   "kubequery",
+			"max_execution_time": "5000",
+			"sql_mode":           "ANSI_QUOTES,it's",
+		},
+	}
+	configs, err := mysqlConfigs(cfg)
+	if err != nil {
+		t.Fatalf("mysqlConfigs: %v", err)
+	}
+
+	// prefer yields TLS then plaintext for every host.
+	want := []struct {
+		addr string
+		tls  bool
+	}{
+		{"primary.db:3306", true},
+		{"primary.db:3306", false},
+		{"replica.db:3307", true},
+		{"replica.db:3307", false},
+	}
+	if len(configs) != len(want) {
+		t.Fatalf("got %d attempts, want %d", len(configs), len(want))
+	}
+	for i, w := range want {
+		if c := configs[i]; c.Addr != w.addr || (c.TLS != nil) != w.tls {
+			t.Errorf("attempt %d = %s tls=%v, want %s tls=%v", i, c.Addr, c.TLS != nil, w.addr, w.tls)
+		}
+	}
+
+	c := configs[0]
+	if c.User != cfg.User || c.Passwd != cfg.Password || c.DBName != "app" || !c.MultiStatements {
+		t.Errorf("config = %+v", c)
+	}
+	if c.ConnectionAttributes != "program_name:kubequery" {
+		t.Errorf("connection attributes = %q", c.ConnectionAttributes)
+	}
+	if _, ok := c.Params["application_name"]; ok {
+		t.Error("application_name must not be sent as a session variable")
+	}
+	if got := c.Params["max_execution_time"]; got != "5000" {
+		t.Errorf("max_execution_time = %q, want it unquoted", got)
+	}
+	if got := c.Params["sql_mode"]; got != `'ANSI_QUOTES,it''s'` {
+		t.Errorf("sql_mode = %q, want a quoted string", got)
+	}
+}
+
+func TestMySQLConfigsRejectsInvalidInput(t *testing.T) {
+	for name, cfg := range map[string]ConnConfig{
+		"port out of range":      {Host: "db", Port: 70000},
+		"empty fallback host":    {Host: "db", Port: 3306, Hosts: []HostPort{{Port: 3306}}},
+		"target session attrs":   {Host: "db", Port: 3306, TargetSessionAttrs: "read-write"},
+		"variable name":          {Host: "db", Port: 3306, RuntimeParams: map[string]string{"x = 1; DROP": "1"}},
+		"application name comma": {Host: "db", Port: 3306, RuntimeParams: map[string]string{"application_name": "a,b"}},
+	} {
+		if _, err := mysqlConfigs(cfg); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestIsLockTimeout(t *testing.T) {
+	if !IsLockTimeout(fmt.Errorf("exec: %w", &mysql.MySQLError{Number: mysqlLockWaitTimeout})) {
+		t.Error("MySQL error 1205 should be a lock timeout")
+	}
+	if IsLockTimeout(&mysql.MySQLError{Number: 1062}) {
+		t.Error("MySQL duplicate key error should not be a lock timeout")
+	}
+}
+
+func TestDriverFor(t *testing.T) {
+	if d, err := DriverFor(""); err != nil || d != (PostgresDriver{}) {
+		t.Errorf("DriverFor(\"\") = %v, %v; want the PostgreSQL driver", d, err)
+	}
+	if _, err := DriverFor("oracle"); err == nil {
+		t.Error("expected an error for an unknown engine")
+	}
+}
+
+// newMockConn returns a session with connection ID 42 acquired from a pool
+// backed by sqlmock. Statements must match exactly.
+func newMockConn(t *testing.T) (*mysqlPool, Conn, sqlmock.Sqlmock) {
+	t.Helper()
+	sqlDB, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool := &mysqlPool{db: sqlDB}
+	t.Cleanup(pool.Close)
+	mock.ExpectQuery("SELECT CONNECTION_ID()").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(42))
+	conn, err := pool.Acquire(context.Background())
+	if err != nil {
+		t.Fatalf("Acquire: %v", err)
+	}
+	if conn.SessionID() != 42 {
+		t.Fatalf("session id = %d, want 42", conn.SessionID())
+	}
+	return pool, conn, mock
+}
+
+func TestMySQLExec(t *testing.T) {
+	ctx := context.Background()
+	_, conn, mock := newMockConn(t)
+
+	mock.ExpectExec("UPDATE t SET a = ?").WithArgs(1).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("DELETE FROM t").WillReturnResult(sqlmock.NewResult(0, 3))
+	mock.ExpectExec("DELETE FROM u").WillReturnError(&mysql.MySQLError{Number: mysqlLockWaitTimeout})
+
+	if tag, err := conn.Exec(ctx, "UPDATE t SET a = ?", 1); err != nil || tag != "1 row affected" {
+		t.Errorf("Exec = %q, %v; want 1 row affected", tag, err)
+	}
+	if tag, err := conn.Exec(ctx, "DELETE FROM t"); err != nil || tag != "3 rows affected" {
+		t.Errorf("Exec = %q, %v; want 3 rows affected", tag, err)
+	}
+	if _, err := conn.Exec(ctx, "DELETE FROM u"); !IsLockTimeout(err) {
+		t.Errorf("err = %v, want a lock timeout", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestMySQLQuery(t *testing.T) {
+	ctx := context.Background()
+	_, conn, mock := newMockConn(t)
+
+	rows := sqlmock.NewRowsWithColumnDefinition(
+		sqlmock.NewColumn("id").OfType("BIGINT", int64(0)),
+		sqlmock.NewColumn("name").OfType("VARCHAR", ""),
+		sqlmock.NewColumn("note").OfType("TEXT", nil).Nullable(true),
+	).AddRow(int64(1), []byte("alice"), nil).AddRow(int64(2), []byte("bob"), []byte("admin"))
+	mock.ExpectQuery("SELECT id, name, note FROM users WHERE id > ?").WithArgs(0).WillReturnRows(rows)
+
+	r, err := conn.Query(ctx, "SELECT id, name, note FROM users WHERE id > ?", 0)
+	if err != nil {
+		t.Fatalf("Query: %v", err)
+	}
+	defer r.Close()
+	want := []Column{{Name: "id", Type: "BIGINT"}, {Name: "name", Type: "VARCHAR"}, {Name: "note", Type: "TEXT"}}
+	if cols := r.Columns(); len(cols) != len(want) || cols[0] != want[0] || cols[1] != want[1] || cols[2] != want[2] {
+		t.Errorf("columns = %+v, want %+v", cols, want)
+	}
+	var got [][]any
+	for r.Next() {
+		values, err := r.Values()
+		if err != nil {
+			t.Fatalf("Values: %v", err)
+		}
+		got = append(got, values)
+	}
+	if err := r.Err(); err != nil {
+		t.Fatal(err)
+	}
+	// Text columns are returned as strings rather than []byte.
+	if len(got) != 2 || got[0][1] != "alice" || got[0][2] != nil || got[1][1] != "bob" || got[1][2] != "admin" {
+		t.Errorf("rows = %v", got)
+	}
+
+	mock.ExpectQuery("SELECT nope").WillReturnError(errors.New("table not found"))
+	if _, err := conn.Query(ctx, "SELECT nope"); err == nil {
+		t.Error("Query succeeded, want the server error")
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestMySQLTx(t *testing.T) {
+	ctx := context.Background()
+	_, conn, mock := newMockConn(t)
+
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO t VALUES (1)").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectRollback()
+
+	tx, err := conn.Begin(ctx, TxOptions{})
+	if err != nil {
+		t.Fatalf("Begin: %v", err)
+	}
+	if tag, err := tx.Exec(ctx, "INSERT INTO t VALUES (1)"); err != nil || tag != "1 row affected" {
+		t.Errorf("Exec = %q, %v", tag, err)
+	}
+	if err := tx.Rollback(ctx); err != nil {
+		t.Errorf("Rollback: %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestMySQLExecKillsCancelledStatement(t *testing.T) {
+	_, conn, mock := newMockConn(t)
+	mock.MatchExpectationsInOrder(false)
+
+	mock.ExpectExec("SELECT SLEEP(60)").WillDelayFor(time.Minute).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("KILL QUERY 42").WillReturnResult(sqlmock.NewResult(0, 0))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := conn.Exec(ctx, "SELECT SLEEP(60)"); err == nil {
+		t.Fatal("Exec succeeded, want the context error")
+	}
+	// The KILL runs in the background once ctx ends.
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		err := mock.ExpectationsWereMet()
+		if err == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal(err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestMySQLCancel(t *testing.T) {
+	ctx := context.Background()
+	pool, _, mock := newMockConn(t)
+
+	mock.ExpectExec("KILL QUERY 42").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("KILL CONNECTION 7").WillReturnError(&mysql.MySQLError{Number: mysqlNoSuchThread})
+
+	if ok, err := pool.Cancel(ctx, 42); err != nil || !ok {
+		t.Errorf("Cancel = %t, %v; want delivered", ok, err)
+	}
+	if ok, err := pool.Terminate(ctx, 7); err != nil || ok {
+		t.Errorf("Terminate = %t, %v; want not delivered for an unknown session", ok, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,246 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// PostgresDriver connects to PostgreSQL with pgx.
+type PostgresDriver struct{}
+
+// Connect returns a pgxpool-backed Pool for cfg, supporting SSL/TLS.
+func (PostgresDriver) Connect(ctx context.Context, cfg ConnConfig) (Pool, error) {
+	poolConfig, err := newPoolConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+	pool, err := pgxpool.NewWithConfig(ctx, poolConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to db: %w", err)
+	}
+	return &postgresPool{pool: pool}, nil
+}
+
+// newPoolConfig builds the pool configuration field by field, so values
+// containing spaces, quotes or '=' need no escaping.
+func newPoolConfig(cfg ConnConfig) (*pgxpool.Config, error) {
+	// pgx only accepts configs created by ParseConfig. Every setting that
+	// matters is overwritten below, so the defaults it derives from the
+	// environment do not leak into the connection.
+	poolConfig, err := pgxpool.ParseConfig("")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create pool config: %w", err)
+	}
+	cc := poolConfig.ConnConfig
+	cc.Database = cfg.Database
+	cc.User = cfg.User
+	cc.Password = cfg.Password
+	cc.RuntimeParams = make(map[string]string, len(cfg.RuntimeParams))
+	for k, v := range cfg.RuntimeParams {
+		cc.RuntimeParams[k] = v
+	}
+
+	// Each host is tried with each of its TLS attempts before moving on to the
+	// next host, matching libpq's ordering.
+	cc.Fallbacks = nil
+	hosts := append([]HostPort{{Host: cfg.Host, Port: cfg.Port}}, cfg.Hosts...)
+	for i, h := range hosts {
+		if h.Host == "" {
+			return nil, fmt.Errorf("host %d is empty", i)
+		}
+		if h.Port < 1 || h.Port > 65535 {
+			return nil, fmt.Errorf("invalid port %d for host %s", h.Port, h.Host)
+		}
+		attempts, err := tlsConfigs(cfg.SSL, h.Host)
+		if err != nil {
+			return nil, err
+		}
+		for j, tlsConfig := range attempts {
+			if i == 0 && j == 0 {
+				cc.Host, cc.Port, cc.TLSConfig = h.Host, uint16(h.Port), tlsConfig
+				continue
+			}
+			cc.Fallbacks = append(cc.Fallbacks, &pgconn.FallbackConfig{
+				Host:      h.Host,
+				Port:      uint16(h.Port),
+				TLSConfig: tlsConfig,
+			})
+		}
+	}
+
+	validate, err := validateTargetSessionAttrs(cfg.TargetSessionAttrs)
+	if err != nil {
+		return nil, err
+	}
+	cc.ValidateConnect = validate
+
+	if cfg.Credentials != nil {
+		creds := cfg.Credentials
+		poolConfig.BeforeConnect = func(ctx context.Context, cc *pgx.ConnConfig) error {
+			password, err := creds.Password(ctx)
+			if err != nil {
+				return fmt.Errorf("failed to obtain database credentials: %w", err)
+			}
+			cc.Password = password
+			return nil
+		}
+	}
+	return poolConfig, nil
+}
+
+// validateTargetSessionAttrs maps a libpq target_session_attrs value to the
+// pgconn hook that enforces it.
+func validateTargetSessionAttrs(attrs string) (pgconn.ValidateConnectFunc, error) {
+	switch attrs {
+	case "", "any":
+		return nil, nil
+	case "read-write":
+		return pgconn.ValidateConnectTargetSessionAttrsReadWrite, nil
+	case "read-only":
+		return pgconn.ValidateConnectTargetSessionAttrsReadOnly, nil
+	case "primary":
+		return pgconn.ValidateConnectTargetSessionAttrsPrimary, nil
+	case "standby":
+		return pgconn.ValidateConnectTargetSessionAttrsStandby, nil
+	case "prefer-standby":
+		return pgconn.ValidateConnectTargetSessionAttrsPreferStandby, nil
+	default:
+		return nil, fmt.Errorf("unsupported target_session_attrs %q", attrs)
+	}
+}
+
+// lockNotAvailable is the SQLSTATE raised when lock_timeout expires.
+const lockNotAvailable = "55P03"
+
+// isPostgresLockTimeout reports whether err was caused by lock_timeout expiring.
+func isPostgresLockTimeout(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == lockNotAvailable
+}
+
+// postgresPool implements Pool on top of a pgxpool.Pool.
+type postgresPool struct {
+	pool *pgxpool.Pool
+}
+
+func (p *postgresPool) Acquire(ctx context.Context) (Conn, error) {
+	conn, err := p.pool.Acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &postgresConn{postgresQuerier: postgresQuerier{conn}, conn: conn}, nil
+}
+
+func (p *postgresPool) Cancel(ctx context.Context, id uint32) (bool, error) {
+	var ok bool
+	err := p.pool.QueryRow(ctx, "SELECT pg_cancel_backend($1)", int32(id)).Scan(&ok)
+	return ok, err
+}
+
+func (p *postgresPool) Terminate(ctx context.Context, id uint32) (bool, error) {
+	var ok bool
+	err := p.pool.QueryRow(ctx, "SELECT pg_terminate_backend($1)", int32(id)).Scan(&ok)
+	return ok, err
+}
+
+func (p *postgresPool) SessionActive(ctx context.Context, id uint32, applicationName string, startedBefore time.Time) (bool, error) {
+	var active bool
+	err := p.pool.QueryRow(ctx,
+		`SELECT EXISTS (SELECT 1 FROM pg_stat_activity WHERE pid = $1 AND application_name = $2 AND backend_start <= $3)`,
+		int32(id), applicationName, startedBefore).Scan(&active)
+	return active, err
+}
+
+func (p *postgresPool) Close() {
+	p.pool.Close()
+}
+
+// pgxQuerier is implemented by *pgxpool.Conn and pgx.Tx.
+type pgxQuerier interface {
+	Exec(ctx context.Context, sql string, arguments ...any) (pgconn.CommandTag, error)
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+}
+
+// postgresQuerier adapts a pgxQuerier to Querier.
+type postgresQuerier struct {
+	q pgxQuerier
+}
+
+func (p postgresQuerier) Exec(ctx context.Context, sql string, arguments ...any) (string, error) {
+	ct, err := p.q.Exec(ctx, sql, arguments...)
+	if err != nil {
+		return "", err
+	}
+	return ct.String(), nil
+}
+
+func (p postgresQuerier) Query(ctx context.Context, sql string, args ...any) (Rows, error) {
+	rows, err := p.q.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, err
+	}
+	return postgresRows{rows}, nil
+}
+
+// postgresConn is a connection pinned from a postgresPool.
+type postgresConn struct {
+	postgresQuerier
+	conn *pgxpool.Conn
+}
+
+func (c *postgresConn) SessionID() uint32 {
+	return c.conn.Conn().PgConn().PID()
+}
+
+func (c *postgresConn) Begin(ctx context.Context, opts TxOptions) (Tx, error) {
+	var txOptions pgx.TxOptions
+	if opts.ReadOnly {
+		txOptions.AccessMode = pgx.ReadOnly
+	}
+	tx, err := c.conn.BeginTx(ctx, txOptions)
+	if err != nil {
+		return nil, err
+	}
+	return postgresTx{postgresQuerier: postgresQuerier{tx}, tx: tx}, nil
+}
+
+func (c *postgresConn) Release() {
+	c.conn.Release()
+}
+
+// postgresTx adapts a pgx.Tx to Tx.
+type postgresTx struct {
+	postgresQuerier
+	tx pgx.Tx
+}
+
+func (t postgresTx) Commit(ctx context.Context) error {
+	return t.tx.Commit(ctx)
+}
+
+func (t postgresTx) Rollback(ctx context.Context) error {
+	return t.tx.Rollback(ctx)
+}
+
+// postgresRows adds Columns to pgx.Rows.
+type postgresRows struct {
+	pgx.Rows
+}
+
+func (r postgresRows) Columns() []Column {
+	fields := r.FieldDescriptions()
+	cols := make([]Column, len(fields))
+	for i, f := range fields {
+		cols[i] = Column{Name: f.Name, Type: fmt.Sprintf("oid:%d", f.DataTypeOID), OID: f.DataTypeOID}
+		if t, ok := r.Conn().TypeMap().TypeForOID(f.DataTypeOID); ok {
+			cols[i].Type = t.Name
+		}
+	}
+	return cols
+}
@@ -0,0 +1,229 @@
+package db
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// SSL modes understood by Connect. They follow the libpq sslmode semantics.
+const (
+	SSLModeDisable    = "disable"
+	SSLModeAllow      = "allow"
+	SSLModePrefer     = "prefer"
+	SSLModeRequire    = "require"
+	SSLModeVerifyCA   = "verify-ca"
+	SSLModeVerifyFull = "verify-full"
+)
+
+// SSLConfig describes how Connect secures the connection.
+type SSLConfig struct {
+	Mode string `json:"mode"`
+	// CA holds PEM-encoded CA certificates used to verify the server (optional).
+	CA []byte `json:"ca,omitempty"`
+	// ClientCert and ClientKey hold a PEM-encoded client certificate and
+	// private key presented to the server for mTLS (optional).
+	ClientCert []byte `json:"clientCert,omitempty"`
+	ClientKey  []byte `json:"clientKey,omitempty"`
+	// ServerName overrides the host name used for SNI and, in verify-full
+	// mode, for certificate verification (optional).
+	ServerName string `json:"serverName,omitempty"`
+	// CRL holds a PEM- or DER-encoded certificate revocation list checked
+	// against the server's chain in verify-ca and verify-full modes (optional).
+	CRL []byte `json:"crl,omitempty"`
+}
+
+// tlsConfigs returns the TLS configurations to try, in order, when connecting
+// to host. A nil entry means a plaintext attempt, so "allow" yields
+// [nil, tls] and "prefer" yields [tls, nil].
+func tlsConfigs(ssl *SSLConfig, host string) ([]*tls.Config, error) {
+	if ssl == nil || ssl.Mode == "" || ssl.Mode == SSLModeDisable {
+		return []*tls.Config{nil}, nil
+	}
+
+	var roots *x509.CertPool
+	if len(ssl.CA) > 0 {
+		cas, err := ParseCABundle(ssl.CA)
+		if err != nil {
+			return nil, err
+		}
+		roots = x509.NewCertPool()
+		for _, ca := range cas {
+			roots.AddCert(ca)
+		}
+	}
+
+	var crl *x509.RevocationList
+	if len(ssl.CRL) > 0 {
+		if ssl.Mode != SSLModeVerifyCA && ssl.Mode != SSLModeVerifyFull {
+			return nil, fmt.Errorf("a CRL requires sslmode verify-ca or verify-full, got %q", ssl.Mode)
+		}
+		var err error
+		if crl, err = parseCRL(ssl.CRL); err != nil {
+			return nil, err
+		}
+	}
+
+	serverName := host
+	if ssl.ServerName != "" {
+		serverName = ssl.ServerName
+	}
+	cfg := &tls.Config{ServerName: serverName, MinVersion: tls.VersionTLS12}
+	if len(ssl.ClientCert) > 0 || len(ssl.ClientKey) > 0 {
+		cert, err := tls.X509KeyPair(ssl.ClientCert, ssl.ClientKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %w", err)
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+
+	switch ssl.Mode {
+	case SSLModeAllow:
+		cfg.InsecureSkipVerify = true
+		return []*tls.Config{nil, cfg}, nil
+	case SSLModePrefer:
+		cfg.InsecureSkipVerify = true
+		return []*tls.Config{cfg, nil}, nil
+	case SSLModeRequire:
+		// As in libpq, require with a CA behaves like verify-ca.
+		if roots == nil {
+			cfg.InsecureSkipVerify = true
+			return []*tls.Config{cfg}, nil
+		}
+		fallthrough
+	case SSLModeVerifyCA:
+		if roots == nil {
+			pool, err := x509.SystemCertPool()
+			if err != nil {
+				return nil, fmt.Errorf("failed to load system CA pool: %w", err)
+			}
+			roots = pool
+		}
+		// Verify the chain but not the host name.
+		cfg.InsecureSkipVerify = true
+		cfg.VerifyPeerCertificate = func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+			chains, err := verifyChain(rawCerts, roots)
+			if err != nil {
+				return err
+			}
+			return checkRevoked(chains, crl)
+		}
+		return []*tls.Config{cfg}, nil
+	case SSLModeVerifyFull:
+		cfg.RootCAs = roots
+		if crl != nil {
+			cfg.VerifyPeerCertificate = func(_ [][]byte, chains [][]*x509.Certificate) error {
+				return checkRevoked(chains, crl)
+			}
+		}
+		return []*tls.Config{cfg}, nil
+	default:
+		return nil, fmt.Errorf("unsupported sslmode %q", ssl.Mode)
+	}
+}
+
+// ParseCABundle parses PEM-encoded CA certificates. It fails if the bundle
+// contains anything other than certificates or contains none.
+func ParseCABundle(data []byte) ([]*x509.Certificate, error) {
+	var certs []*x509.Certificate
+	for rest := data; ; {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			if len(bytes.TrimSpace(rest)) > 0 {
+				return nil, errors.New("CA bundle contains data that is not PEM-encoded")
+			}
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			return nil, fmt.Errorf("unexpected PEM block %q in CA bundle", block.Type)
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse CA certificate: %w", err)
+		}
+		certs = append(certs, cert)
+	}
+	if len(certs) == 0 {
+		return nil, errors.New("CA bundle contains no certificates")
+	}
+	return certs, nil
+}
+
+// CABundleExpiry returns the earliest NotAfter of the certificates in a
+// bundle returned by ParseCABundle.
+func CABundleExpiry(certs []*x509.Certificate) time.Time {
+	var earliest time.Time
+	for _, cert := range certs {
+		if earliest.IsZero() || cert.NotAfter.Before(earliest) {
+			earliest = cert.NotAfter
+		}
+	}
+	return earliest
+}
+
+// verifyChain verifies the server certificate against roots without checking
+// the host name, returning the verified chains.
+func verifyChain(rawCerts [][]byte, roots *x509.CertPool) ([][]*x509.Certificate, error) {
+	if len(rawCerts) == 0 {
+		return nil, errors.New("server presented no certificate")
+	}
+	certs := make([]*x509.Certificate, 0, len(rawCerts))
+	for _, raw := range rawCerts {
+		cert, err := x509.ParseCertificate(raw)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse server certificate: %w", err)
+		}
+		certs = append(certs, cert)
+	}
+	intermediates := x509.NewCertPool()
+	for _, cert := range certs[1:] {
+		intermediates.AddCert(cert)
+	}
+	return certs[0].Verify(x509.VerifyOptions{Roots: roots, Intermediates: intermediates})
+}
+
+// checkRevoked fails if any certificate issued by the CRL's issuer in the
+// verified chains is listed as revoked. A nil CRL disables the check.
+func checkRevoked(chains [][]*x509.Certificate, crl *x509.RevocationList) error {
+	if crl == nil {
+		return nil
+	}
+	for _, chain := range chains {
+		for i := 0; i+1 < len(chain); i++ {
+			cert, issuer := chain[i], chain[i+1]
+			if !bytes.Equal(cert.RawIssuer, crl.RawIssuer) {
+				continue
+			}
+			if err := crl.CheckSignatureFrom(issuer); err != nil {
+				return fmt.Errorf("CRL signature is not valid for issuer %s: %w", issuer.Subject, err)
+			}
+			for _, revoked := range crl.RevokedCertificateEntries {
+				if revoked.SerialNumber.Cmp(cert.SerialNumber) == 0 {
+					return fmt.Errorf("certificate %s has been revoked", cert.Subject)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// parseCRL accepts a PEM ("X509 CRL") or DER encoded revocation list.
+func parseCRL(data []byte) (*x509.RevocationList, error) {
+	der := data
+	if block, _ := pem.Decode(data); block != nil {
+		if block.Type != "X509 CRL" {
+			return nil, fmt.Errorf("unexpected PEM block %q in CRL", block.Type)
+		}
+		der = block.Bytes
+	}
+	crl, err := x509.ParseRevocationList(der)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse CRL: %w", err)
+	}
+	return crl, nil
+}
@@ -0,0 +1,130 @@
+package db
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"testing"
+	"time"
+)
+
+// selfSignedCA returns a PEM-encoded CA certificate valid until notAfter.
+func selfSignedCA(t *testing.T, name string, notAfter time.Time) []byte {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             notAfter.Add(-48 * time.Hour),
+		NotAfter:              notAfter,
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+}
+
+func TestTLSConfigsModeOrder(t *testing.T) {
+	tests := []struct {
+		mode string
+		// want lists, per attempt, whether TLS is used.
+		want []bool
+	}{
+		{mode: "", want: []bool{false}},
+		{mode: SSLModeDisable, want: []bool{false}},
+		{mode: SSLModeAllow, want: []bool{false, true}},
+		{mode: SSLModePrefer, want: []bool{true, false}},
+		{mode: SSLModeRequire, want: []bool{true}},
+		{mode: SSLModeVerifyCA, want: []bool{true}},
+		{mode: SSLModeVerifyFull, want: []bool{true}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.mode, func(t *testing.T) {
+			attempts, err := tlsConfigs(&SSLConfig{Mode: tt.mode}, "db.example.com")
+			if err != nil {
+				t.Fatalf("tlsConfigs: %v", err)
+			}
+			if len(attempts) != len(tt.want) {
+				t.Fatalf("got %d attempts, want %d", len(attempts), len(tt.want))
+			}
+			for i, useTLS := range tt.want {
+				if (attempts[i] != nil) != useTLS {
+					t.Errorf("attempt %d: TLS = %v, want %v", i, attempts[i] != nil, useTLS)
+				}
+			}
+		})
+	}
+}
+
+func TestTLSConfigsVerification(t *testing.T) {
+	full, err := tlsConfigs(&SSLConfig{Mode: SSLModeVerifyFull, ServerName: "primary.internal"}, "10.0.0.1")
+	if err != nil {
+		t.Fatalf("tlsConfigs: %v", err)
+	}
+	if full[0].InsecureSkipVerify || full[0].ServerName != "primary.internal" {
+		t.Errorf("verify-full must verify the host name against serverName, got %+v", full[0])
+	}
+
+	ca, err := tlsConfigs(&SSLConfig{Mode: SSLModeVerifyCA}, "10.0.0.1")
+	if err != nil {
+		t.Fatalf("tlsConfigs: %v", err)
+	}
+	if !ca[0].InsecureSkipVerify || ca[0].VerifyPeerCertificate == nil {
+		t.Error("verify-ca must skip host name checks but verify the chain itself")
+	}
+}
+
+func TestTLSConfigsRejectsInvalidInput(t *testing.T) {
+	for name, ssl := range map[string]*SSLConfig{
+		"unknown mode":       {Mode: "sometimes"},
+		"CRL without verify": {Mode: SSLModeRequire, CRL: []byte("irrelevant")},
+		"bad client cert":    {Mode: SSLModeRequire, ClientCert: []byte("x"), ClientKey: []byte("y")},
+	} {
+		if _, err := tlsConfigs(ssl, "db"); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestParseCABundle(t *testing.T) {
+	soon := time.Now().Add(24 * time.Hour).Truncate(time.Second)
+	later := time.Now().Add(365 * 24 * time.Hour).Truncate(time.Second)
+	bundle := append(selfSignedCA(t, "root", later), selfSignedCA(t, "intermediate", soon)...)
+
+	certs, err := ParseCABundle(bundle)
+	if err != nil {
+		t.Fatalf("ParseCABundle: %v", err)
+	}
+	if len(certs) != 2 {
+		t.Fatalf("got %d certificates, want 2", len(certs))
+	}
+	if got := CABundleExpiry(certs); !got.Equal(soon) {
+		t.Errorf("CABundleExpiry = %s, want %s", got, soon)
+	}
+
+	if _, err := tlsConfigs(&SSLConfig{Mode: SSLModeVerifyFull, CA: bundle}, "db"); err != nil {
+		t.Errorf("tlsConfigs with in-memory CA: %v", err)
+	}
+
+	for name, data := range map[string][]byte{
+		"empty":         nil,
+		"not PEM":       []byte("not a certificate"),
+		"trailing junk": append(selfSignedCA(t, "root", later), []byte("junk")...),
+		"private key":   pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: []byte{1}}),
+	} {
+		if _, err := ParseCABundle(data); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
@@ -0,0 +1,406 @@
+// Package explain runs EXPLAIN on PostgreSQL statements and turns the JSON
+// plans it returns into trees annotated for review: what each node costs and
+// takes on its own, how far its row estimate was from the rows it produced,
+// and whether it is a hot spot worth a closer look.
+package explain
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/rsavage/KubeQuery/pkg/db"
+)
+
+// Options are the EXPLAIN options besides FORMAT JSON, which is always set.
+type Options struct {
+	// Analyze executes the statement to report actual rows and times.
+	Analyze bool `json:"analyze,omitempty"`
+	// Buffers reports buffer usage.
+	Buffers bool `json:"buffers,omitempty"`
+	// StatementTimeout, if set, is set as statement_timeout for the EXPLAIN
+	// by Run; it is not an option of EXPLAIN itself.
+	StatementTimeout time.Duration `json:"-"`
+	// LockTimeout, if set, is set as lock_timeout for the EXPLAIN by Run,
+	// like StatementTimeout.
+	LockTimeout time.Duration `json:"-"`
+}
+
+// Thresholds above which a node is a hot spot.
+const (
+	// HotSpotShare is the share of the plan's time, or of its cost when it
+	// was not analyzed, a node takes on its own.
+	HotSpotShare = 0.2
+	// MisestimateFactor is how many times more, or fewer, rows a node
+	// produced than the planner estimated.
+	MisestimateFactor = 10
+)
+
+// Reasons a node is a hot spot.
+const (
+	HotSpotCost     = "cost"
+	HotSpotTime     = "time"
+	HotSpotEstimate = "estimate"
+)
+
+// Statement returns the EXPLAIN statement of sql.
+func Statement(sql string, opts Options) string {
+	options := []string{"FORMAT JSON"}
+	if opts.Analyze {
+		options = append(options, "ANALYZE")
+	}
+	if opts.Buffers {
+		options = append(options, "BUFFERS")
+	}
+	return fmt.Sprintf("EXPLAIN (%s) %s", strings.Join(options, ", "), strings.TrimSpace(sql))
+}
+
+// Run runs EXPLAIN of sql, with the values of its parameters in args, on
+// conn and returns the plan the server reported, as compact JSON. It runs in
+// a transaction that is always rolled back, read-only if readOnly is set, so
+// that EXPLAIN ANALYZE of a statement that writes executes it without
+// keeping its changes.
+func Run(ctx context.Context, conn db.Conn, sql string, args []any, opts Options, readOnly bool) (json.RawMessage, error) {
+	tx, err := conn.Begin(ctx, db.TxOptions{ReadOnly: readOnly})
+	if err != nil {
+		return nil, err
+	}
+	// The rollback is what undoes an analyzed statement, so it must run even
+	// if ctx is done; the server rolls back if the session is lost anyway.
+	defer func() { _ = tx.Rollback(context.WithoutCancel(ctx)) }()
+	if opts.StatementTimeout > 0 {
+		if _, err := tx.Exec(ctx, fmt.Sprintf("SET LOCAL statement_timeout = %d", opts.StatementTimeout.Milliseconds())); err != nil {
+			return nil, err
+		}
+	}
+	if opts.LockTimeout > 0 {
+		if _, err := tx.Exec(ctx, fmt.Sprintf("SET LOCAL lock_timeout = %d", opts.LockTimeout.Milliseconds())); err != nil {
+			return nil, err
+		}
+	}
+	rows, err := tx.Query(ctx, Statement(sql, opts), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var plan json.RawMessage
+	for rows.Next() {
+		values, err := rows.Values()
+		if err != nil {
+			return nil, err
+		}
+		if len(values) != 1 || plan != nil {
+			return nil, errors.New("EXPLAIN returned more than one value")
+		}
+		if plan, err = raw(values[0]); err != nil {
+			return nil, err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if plan == nil {
+		return nil, errors.New("EXPLAIN returned no plan")
+	}
+	return plan, nil
+}
+
+// raw returns the JSON of a plan value, which drivers return as text or
+// decoded.
+func raw(v any) (json.RawMessage, error) {
+	var b []byte
+	switch v := v.(type) {
+	case string:
+		b = []byte(v)
+	case []byte:
+		b = v
+	default:
+		var err error
+		if b, err = json.Marshal(v); err != nil {
+			return nil, err
+		}
+	}
+	var buf bytes.Buffer
+	if err := json.Compact(&buf, b); err != nil {
+		return nil, fmt.Errorf("invalid plan: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// Plan is a parsed EXPLAIN (FORMAT JSON) plan.
+type Plan struct {
+	Root *Node `json:"root"`
+	// Analyzed is set when the statement was executed, so that nodes report
+	// actual rows and times.
+	Analyzed bool `json:"analyzed"`
+	// PlanningMillis and ExecutionMillis are reported by ANALYZE.
+	PlanningMillis  *float64 `json:"planningMillis,omitempty"`
+	ExecutionMillis *float64 `json:"executionMillis,omitempty"`
+	// Triggers are the triggers fired by an analyzed statement, as reported
+	// by the server.
+	Triggers []map[string]any `json:"triggers,omitempty"`
+	// HotSpots lists the IDs of the hot spot nodes, costliest first.
+	HotSpots []int `json:"hotSpots"`
+}
+
+// Node is a node of a plan.
+type Node struct {
+	// ID numbers the nodes of the plan depth-first, from 0 at the root.
+	ID       int    `json:"id"`
+	NodeType string `json:"nodeType"`
+	// Operation is the operation of ModifyTable nodes: Insert, Update,
+	// Delete or Merge.
+	Operation          string `json:"operation,omitempty"`
+	Relation           string `json:"relation,omitempty"`
+	Alias              string `json:"alias,omitempty"`
+	Index              string `json:"index,omitempty"`
+	ParentRelationship string `json:"parentRelationship,omitempty"`
+
+	StartupCost float64 `json:"startupCost"`
+	TotalCost   float64 `json:"totalCost"`
+	// PlanRows is the estimated number of rows per loop.
+	PlanRows  float64 `json:"planRows"`
+	PlanWidth int     `json:"planWidth"`
+
+	// The actual values are set when the plan was analyzed. Rows and
+	// times are per loop, as the server reports them; nodes that never ran
+	// have no loops.
+	ActualStartupMillis *float64 `json:"actualStartupMillis,omitempty"`
+	ActualTotalMillis   *float64 `json:"actualTotalMillis,omitempty"`
+	ActualRows          *float64 `json:"actualRows,omitempty"`
+	ActualLoops         *float64 `json:"actualLoops,omitempty"`
+
+	// SelfCost is the node's total cost less its children's, and CostShare
+	// that as a share of the total cost of the costliest node of the plan.
+	SelfCost  float64 `json:"selfCost"`
+	CostShare float64 `json:"costShare"`
+	// SelfMillis is the time spent in the node itself over all its loops,
+	// and TimeShare that as a share of the root's time; analyzed plans only.
+	SelfMillis *float64 `json:"selfMillis,omitempty"`
+	TimeShare  *float64 `json:"timeShare,omitempty"`
+	// EstimateFactor is the actual rows per loop divided by the estimate,
+	// each at least 1: above 1 the planner underestimated, below it
+	// overestimated. Analyzed plans only.
+	EstimateFactor *float64 `json:"estimateFactor,omitempty"`
+	// HotSpot lists why the node is a hot spot, if it is: HotSpotCost,
+	// HotSpotTime or HotSpotEstimate.
+	HotSpot []string `json:"hotSpot,omitempty"`
+
+	// Details holds the other properties the server reported, such as
+	// filters, join conditions and buffer counts, under their EXPLAIN names.
+	Details  map[string]any `json:"details,omitempty"`
+	Children []*Node        `json:"children,omitempty"`
+}
+
+// Label describes the node in a few words, e.g. "Index Scan using
+// orders_pkey on orders o".
+func (n *Node) Label() string {
+	label := n.NodeType
+	if n.Operation != "" {
+		label = n.Operation
+	}
+	if n.Index != "" {
+		label += " using " + n.Index
+	}
+	if n.Relation != "" {
+		label += " on " + n.Relation
+		if n.Alias != "" && n.Alias != n.Relation {
+			label += " " + n.Alias
+		}
+	}
+	return label
+}
+
+// Parse parses the output of EXPLAIN (FORMAT JSON) and annotates its nodes.
+func Parse(data []byte) (*Plan, error) {
+	var out []map[string]any
+	if err := json.Unmarshal(data, &out); err != nil {
+		return nil, fmt.Errorf("invalid plan: %w", err)
+	}
+	if len(out) != 1 {
+		return nil, fmt.Errorf("invalid plan: want one plan, got %d", len(out))
+	}
+	top, ok := out[0]["Plan"].(map[string]any)
+	if !ok {
+		return nil, errors.New("invalid plan: no Plan")
+	}
+	p := &Plan{HotSpots: []int{}}
+	p.PlanningMillis = number(out[0], "Planning Time")
+	p.ExecutionMillis = number(out[0], "Execution Time")
+	if triggers, ok := out[0]["Triggers"].([]any); ok {
+		for _, t := range triggers {
+			if t, ok := t.(map[string]any); ok {
+				p.Triggers = append(p.Triggers, t)
+			}
+		}
+	}
+	id := 0
+	p.Root = parseNode(top, &id)
+	p.Analyzed = p.Root.ActualLoops != nil
+	p.annotate()
+	return p, nil
+}
+
+// parseNode converts the properties of a plan node, numbering it and its
+// children from *id.
+func parseNode(props map[string]any, id *int) *Node {
+	n := &Node{ID: *id}
+	*id++
+	for key, v := range props {
+		s, _ := v.(string)
+		f, _ := v.(float64)
+		switch key {
+		case "Node Type":
+			n.NodeType = s
+		case "Operation":
+			n.Operation = s
+		case "Relation Name":
+			n.Relation = s
+		case "Alias":
+			n.Alias = s
+		case "Index Name":
+			n.Index = s
+		case "Parent Relationship":
+			n.ParentRelationship = s
+		case "Startup Cost":
+			n.StartupCost = f
+		case "Total Cost":
+			n.TotalCost = f
+		case "Plan Rows":
+			n.PlanRows = f
+		case "Plan Width":
+			n.PlanWidth = int(f)
+		case "Actual Startup Time":
+			n.ActualStartupMillis = number(props, key)
+		case "Actual Total Time":
+			n.ActualTotalMillis = number(props, key)
+		case "Actual Rows":
+			n.ActualRows = number(props, key)
+		case "Actual Loops":
+			n.ActualLoops = number(props, key)
+		case "Plans":
+			children, _ := v.([]any)
+			for _, c := range children {
+				if c, ok := c.(map[string]any); ok {
+					n.Children = append(n.Children, parseNode(c, id))
+				}
+			}
+		default:
+			if n.Details == nil {
+				n.Details = map[string]any{}
+			}
+			n.Details[key] = v
+		}
+	}
+	return n
+}
+
+// number returns the number under key in props, if there is one.
+func number(props map[string]any, key string) *float64 {
+	if f, ok := props[key].(float64); ok {
+		return &f
+	}
+	return nil
+}
+
+// millis returns the time spent in n and its children over all its loops.
+func (n *Node) millis() float64 {
+	if n.ActualTotalMillis == nil || n.ActualLoops == nil {
+		return 0
+	}
+	return *n.ActualTotalMillis * *n.ActualLoops
+}
+
+// annotate sets the derived fields of every node and the plan's hot spots.
+func (p *Plan) annotate() {
+	// Cost shares are of the costliest node, usually the root: a Limit
+	// costs less than the children it stops reading early.
+	totalCost := 0.0
+	var costliest func(n *Node)
+	costliest = func(n *Node) {
+		totalCost = math.Max(totalCost, n.TotalCost)
+		for _, c := range n.Children {
+			costliest(c)
+		}
+	}
+	costliest(p.Root)
+	totalMillis := p.Root.millis()
+	var nodes []*Node
+	var walk func(n *Node)
+	walk = func(n *Node) {
+		nodes = append(nodes, n)
+		n.SelfCost = n.TotalCost
+		self := n.millis()
+		for _, c := range n.Children {
+			n.SelfCost -= c.TotalCost
+			self -= c.millis()
+			walk(c)
+		}
+		// A child's cost or time may exceed its parent's, e.g. under a
+		// Limit that stops reading it early.
+		n.SelfCost = math.Max(n.SelfCost, 0)
+		if totalCost > 0 {
+			n.CostShare = n.SelfCost / totalCost
+		}
+		if p.Analyzed && n.ActualLoops != nil {
+			self = math.Max(self, 0)
+			n.SelfMillis = &self
+			if totalMillis > 0 {
+				share := self / totalMillis
+				n.TimeShare = &share
+			}
+			// ModifyTable nodes return no rows without RETURNING, whatever
+			// the estimate.
+			if n.ActualRows != nil && *n.ActualLoops > 0 && n.NodeType != "ModifyTable" {
+				factor := math.Max(*n.ActualRows, 1) / math.Max(n.PlanRows, 1)
+				n.EstimateFactor = &factor
+			}
+		}
+		switch {
+		case p.Analyzed && n.TimeShare != nil && *n.TimeShare >= HotSpotShare:
+			n.HotSpot = append(n.HotSpot, HotSpotTime)
+		case !p.Analyzed && n.CostShare >= HotSpotShare:
+			n.HotSpot = append(n.HotSpot, HotSpotCost)
+		}
+		if f := n.EstimateFactor; f != nil && (*f >= MisestimateFactor || *f <= 1.0/MisestimateFactor) {
+			n.HotSpot = append(n.HotSpot, HotSpotEstimate)
+		}
+	}
+	walk(p.Root)
+
+	var hot []*Node
+	for _, n := range nodes {
+		if len(n.HotSpot) > 0 {
+			hot = append(hot, n)
+		}
+	}
+	weight := func(n *Node) float64 {
+		if n.TimeShare != nil {
+			return *n.TimeShare
+		}
+		return n.CostShare
+	}
+	sort.SliceStable(hot, func(i, j int) bool { return weight(hot[i]) > weight(hot[j]) })
+	for _, n := range hot {
+		p.HotSpots = append(p.HotSpots, n.ID)
+	}
+}
+
+// Summary describes the plan in one line, for status.result.
+func (p *Plan) Summary() string {
+	r := p.Root
+	s := fmt.Sprintf("%s: cost %.2f..%.2f, %.0f rows estimated", r.Label(), r.StartupCost, r.TotalCost, r.PlanRows)
+	if r.ActualRows != nil && p.ExecutionMillis != nil {
+		s += fmt.Sprintf(", %.0f rows in %.3f ms", *r.ActualRows, *p.ExecutionMillis)
+	}
+	if len(p.HotSpots) > 0 {
+		s += fmt.Sprintf(", %d hot spots", len(p.HotSpots))
+	}
+	return s
+}
@@ -0,0 +1,185 @@
+package explain
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/rsavage/KubeQuery/pkg/db"
+	"github.com/rsavage/KubeQuery/pkg/db/dbtest"
+)
+
+// analyzed is the plan of an UPDATE run with EXPLAIN (ANALYZE, BUFFERS):
+// the sequential scan takes most of the time and returns far more rows than
+// estimated.
+const analyzed = `[
+  {
+    "Plan": {
+      "Node Type": "ModifyTable",
+      "Operation": "Update",
+      "Relation Name": "orders",
+      "Alias": "orders",
+      "Startup Cost": 0.00,
+      "Total Cost": 40.00,
+      "Plan Rows": 10,
+      "Plan Width": 10,
+      "Actual Startup Time": 9.000,
+      "Actual Total Time": 10.000,
+      "Actual Rows": 0,
+      "Actual Loops": 1,
+      "Shared Hit Blocks": 120,
+      "Plans": [
+        {
+          "Node Type": "Seq Scan",
+          "Parent Relationship": "Outer",
+          "Relation Name": "orders",
+          "Alias": "orders",
+          "Startup Cost": 0.00,
+          "Total Cost": 35.00,
+          "Plan Rows": 10,
+          "Plan Width": 10,
+          "Actual Startup Time": 0.010,
+          "Actual Total Time": 8.000,
+          "Actual Rows": 500,
+          "Actual Loops": 1,
+          "Filter": "(status = 'open'::text)",
+          "Rows Removed by Filter": 1500
+        }
+      ]
+    },
+    "Planning Time": 0.100,
+    "Triggers": [{"Trigger Name": "orders_audit", "Time": 1.5, "Calls": 500}],
+    "Execution Time": 10.200
+  }
+]`
+
+// estimated is the plan of a join that was not analyzed.
+const estimated = `[{"Plan": {
+  "Node Type": "Limit", "Startup Cost": 0.5, "Total Cost": 10, "Plan Rows": 10, "Plan Width": 8,
+  "Plans": [{
+    "Node Type": "Nested Loop", "Parent Relationship": "Outer", "Join Type": "Inner",
+    "Startup Cost": 0.5, "Total Cost": 1000, "Plan Rows": 1000, "Plan Width": 8,
+    "Plans": [
+      {"Node Type": "Seq Scan", "Parent Relationship": "Outer", "Relation Name": "customers", "Alias": "c",
+       "Startup Cost": 0, "Total Cost": 100, "Plan Rows": 100, "Plan Width": 4},
+      {"Node Type": "Index Scan", "Parent Relationship": "Inner", "Index Name": "orders_customer_idx",
+       "Relation Name": "orders", "Alias": "o", "Startup Cost": 0.5, "Total Cost": 8, "Plan Rows": 10, "Plan Width": 4}
+    ]
+  }]
+}}]`
+
+func TestStatement(t *testing.T) {
+	for opts, want := range map[Options]string{
+		{}:                             "EXPLAIN (FORMAT JSON) SELECT 1",
+		{Analyze: true}:                "EXPLAIN (FORMAT JSON, ANALYZE) SELECT 1",
+		{Analyze: true, Buffers: true}: "EXPLAIN (FORMAT JSON, ANALYZE, BUFFERS) SELECT 1",
+	} {
+		if got := Statement("  SELECT 1\n", opts); got != want {
+			t.Errorf("Statement(%+v) = %q, want %q", opts, got, want)
+		}
+	}
+}
+
+func TestParseAnalyzed(t *testing.T) {
+	p, err := Parse([]byte(analyzed))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !p.Analyzed || *p.PlanningMillis != 0.1 || *p.ExecutionMillis != 10.2 || len(p.Triggers) != 1 {
+		t.Errorf("plan = %+v, want analyzed with times and a trigger", p)
+	}
+	root := p.Root
+	if root.Label() != "Update on orders" || root.SelfCost != 5 || *root.SelfMillis != 2 || root.Details["Shared Hit Blocks"] != 120.0 {
+		t.Errorf("root = %+v", root)
+	}
+	scan := root.Children[0]
+	if scan.ID != 1 || scan.Label() != "Seq Scan on orders" || scan.Details["Filter"] != "(status = 'open'::text)" {
+		t.Errorf("scan = %+v", scan)
+	}
+	if *scan.SelfMillis != 8 || *scan.TimeShare != 0.8 || *scan.EstimateFactor != 50 {
+		t.Errorf("scan self time %v, share %v, estimate factor %v; want 8, 0.8, 50", *scan.SelfMillis, *scan.TimeShare, *scan.EstimateFactor)
+	}
+	if !reflect.DeepEqual(scan.HotSpot, []string{HotSpotTime, HotSpotEstimate}) || !reflect.DeepEqual(root.HotSpot, []string{HotSpotTime}) || root.EstimateFactor != nil {
+		t.Errorf("hot spots: scan %v, root %v", scan.HotSpot, root.HotSpot)
+	}
+	if !reflect.DeepEqual(p.HotSpots, []int{1, 0}) {
+		t.Errorf("HotSpots = %v, want [1 0]", p.HotSpots)
+	}
+	if want := "Update on orders: cost 0.00..40.00, 10 rows estimated, 0 rows in 10.200 ms, 2 hot spots"; p.Summary() != want {
+		t.Errorf("Summary() = %q, want %q", p.Summary(), want)
+	}
+}
+
+func TestParseEstimated(t *testing.T) {
+	p, err := Parse([]byte(estimated))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Analyzed || p.ExecutionMillis != nil {
+		t.Errorf("plan = %+v, want not analyzed", p)
+	}
+	loop := p.Root.Children[0]
+	seq, idx := loop.Children[0], loop.Children[1]
+	if p.Root.SelfCost != 0 {
+		t.Errorf("Limit self cost = %v, want 0 as its child costs more", p.Root.SelfCost)
+	}
+	if loop.SelfCost != 892 || seq.ID != 2 || idx.ID != 3 || idx.Label() != "Index Scan using orders_customer_idx on orders o" {
+		t.Errorf("nodes = %+v %+v %+v", loop, seq, idx)
+	}
+	if idx.TimeShare != nil || idx.EstimateFactor != nil {
+		t.Errorf("estimated node has actual values: %+v", idx)
+	}
+	// Cost shares are of the Nested Loop's cost, which the Limit stops
+	// reading early.
+	if !reflect.DeepEqual(p.HotSpots, []int{1}) || !reflect.DeepEqual(loop.HotSpot, []string{HotSpotCost}) || loop.CostShare != 0.892 {
+		t.Errorf("HotSpots = %v, loop %v %v; want [1], cost and 0.892", p.HotSpots, loop.HotSpot, loop.CostShare)
+	}
+	for _, bad := range []string{"", "{}", "[]", `[{"Query Text": "x"}]`} {
+		if _, err := Parse([]byte(bad)); err == nil {
+			t.Errorf("Parse(%q) succeeded", bad)
+		}
+	}
+}
+
+func TestRun(t *testing.T) {
+	for _, decoded := range []bool{false, true} {
+		driver := &dbtest.Driver{Handler: func(_ context.Context, sql string, _ []any) (dbtest.Result, error) {
+			if !strings.HasPrefix(sql, "EXPLAIN (FORMAT JSON, ANALYZE) UPDATE") {
+				return dbtest.Result{}, errors.New("unexpected statement " + sql)
+			}
+			var v any = analyzed
+			if decoded {
+				// pgx returns json columns decoded.
+				if err := json.Unmarshal([]byte(analyzed), &v); err != nil {
+					return dbtest.Result{}, err
+				}
+			}
+			return dbtest.Result{Columns: []db.Column{{Name: "QUERY PLAN", Type: "json", OID: 114}}, Rows: [][]any{{v}}}, nil
+		}}
+		pool, err := driver.Connect(context.Background(), db.ConnConfig{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		conn, err := pool.Acquire(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		plan, err := Run(context.Background(), conn, "UPDATE orders SET status = 'closed'", nil, Options{Analyze: true}, false)
+		conn.Release()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.Contains(string(plan), "\n") {
+			t.Errorf("plan is not compact: %s", plan)
+		}
+		if _, err := Parse(plan); err != nil {
+			t.Errorf("Parse(Run()) = %v", err)
+		}
+		if got := driver.Statements(); len(got) != 3 || got[0] != "BEGIN" || got[2] != "ROLLBACK" {
+			t.Errorf("statements = %q, want the EXPLAIN in a rolled back transaction", got)
+		}
+	}
+}
@@ -0,0 +1,108 @@
+// Package redact scrubs credentials and other sensitive values from text
+// before it is logged, recorded as an event or written to a CR status.
+package redact
+
+import (
+	"context"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Placeholder replaces every redacted value.
+const Placeholder = "[REDACTED]"
+
+// minSecretLen is the shortest secret that is scrubbed verbatim. Shorter
+// values would match ordinary words and make messages unreadable.
+const minSecretLen = 4
+
+var (
+	// keywordValue matches password-like settings in a keyword/value DSN,
+	// quoted or not.
+	keywordValue = regexp.MustCompile(`(?i)\b(password|passwd|pwd|sslpassword|sslkey)(\s*=\s*)('(?:[^'\\]|\\.)*'|[^\s]+)`)
+	// urlUserinfo matches the password in a URL-style DSN.
+	urlUserinfo = regexp.MustCompile(`(?i)\b([a-z][a-z0-9+.-]*://[^:/@\s]*):([^@\s]*)@`)
+	// sqlLiteral matches a single-quoted SQL string literal.
+	sqlLiteral = regexp.MustCompile(`'(?:[^']|'')*'`)
+)
+
+// Redactor scrubs known secrets, DSN passwords and, optionally, SQL string
+// literals from text. It is safe for concurrent use; secrets learned while
+// connecting (e.g. refreshed tokens) can be added at any time.
+type Redactor struct {
+	// SQLLiterals also replaces single-quoted SQL literals.
+	SQLLiterals bool
+
+	mu      sync.RWMutex
+	secrets []string
+}
+
+// New returns a Redactor that scrubs the given secrets.
+func New(secrets ...string) *Redactor {
+	r := &Redactor{}
+	for _, s := range secrets {
+		r.Add(s)
+	}
+	return r
+}
+
+// Add registers a secret value to be scrubbed.
+func (r *Redactor) Add(secret string) {
+	if len(secret) < minSecretLen {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, s := range r.secrets {
+		if s == secret {
+			return
+		}
+	}
+	r.secrets = append(r.secrets, secret)
+	// Longest first, so a secret that contains another is replaced whole.
+	sort.Slice(r.secrets, func(i, j int) bool { return len(r.secrets[i]) > len(r.secrets[j]) })
+}
+
+// String returns s with all sensitive values replaced by Placeholder. A nil
+// Redactor still scrubs DSN passwords.
+func (r *Redactor) String(s string) string {
+	if s == "" {
+		return s
+	}
+	if r != nil {
+		r.mu.RLock()
+		for _, secret := range r.secrets {
+			s = strings.ReplaceAll(s, secret, Placeholder)
+		}
+		r.mu.RUnlock()
+	}
+	s = keywordValue.ReplaceAllString(s, "${1}${2}"+Placeholder)
+	s = urlUserinfo.ReplaceAllString(s, "${1}:"+Placeholder+"@")
+	if r != nil && r.SQLLiterals {
+		s = sqlLiteral.ReplaceAllString(s, "'"+Placeholder+"'")
+	}
+	return s
+}
+
+// Error returns the redacted message of err, or "" if err is nil.
+func (r *Redactor) Error(err error) string {
+	if err == nil {
+		return ""
+	}
+	return r.String(err.Error())
+}
+
+type contextKey struct{}
+
+// NewContext returns a copy of ctx carrying r.
+func NewContext(ctx context.Context, r *Redactor) context.Context {
+	return context.WithValue(ctx, contextKey{}, r)
+}
+
+// FromContext returns the Redactor stored in ctx, or nil. The nil Redactor is
+// usable and scrubs DSN passwords only.
+func FromContext(ctx context.Context) *Redactor {
+	r, _ := ctx.Value(contextKey{}).(*Redactor)
+	return r
+}
@@ -0,0 +1,86 @@
+package redact
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestString(t *testing.T) {
+	const secret = "s3cr3t-Passw0rd"
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{
+			name: "known secret",
+			in:   "authentication failed for token " + secret,
+			want: "authentication failed for token " + Placeholder,
+		},
+		{
+			name: "keyword value DSN",
+			in:   "cannot parse `host=db user=app password=other-pass sslmode=disable`",
+			want: "cannot parse `host=db user=app password=" + Placeholder + " sslmode=disable`",
+		},
+		{
+			name: "quoted keyword value",
+			in:   "password = 'with spaces' dbname=x",
+			want: "password = " + Placeholder + " dbname=x",
+		},
+		{
+			name: "URL DSN",
+			in:   "dial postgres://app:hunter22@db:5432/app failed",
+			want: "dial postgres://app:" + Placeholder + "@db:5432/app failed",
+		},
+		{
+			name: "SQL literals are kept by default",
+			in:   "ERROR near 'abc'",
+			want: "ERROR near 'abc'",
+		},
+	}
+	r := New(secret)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := r.String(tt.in); got != tt.want {
+				t.Errorf("String(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSQLLiterals(t *testing.T) {
+	r := New()
+	r.SQLLiterals = true
+	got := r.String(`ALTER ROLE app PASSWORD 'it''s secret'; SELECT 'x'`)
+	want := `ALTER ROLE app PASSWORD '` + Placeholder + `'; SELECT '` + Placeholder + `'`
+	if got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestAddIgnoresShortAndOverlappingSecrets(t *testing.T) {
+	r := New("abc", "token", "token-with-suffix")
+	if got := r.String("abc token-with-suffix"); got != "abc "+Placeholder {
+		t.Errorf("got %q", got)
+	}
+}
+
+func TestNilAndContext(t *testing.T) {
+	var r *Redactor
+	if got := r.Error(errors.New("password=x1234")); strings.Contains(got, "x1234") {
+		t.Errorf("nil Redactor leaked DSN password: %q", got)
+	}
+	if r.Error(nil) != "" {
+		t.Error("Error(nil) should be empty")
+	}
+
+	ctx := NewContext(context.Background(), New("ctx-secret"))
+	if got := FromContext(ctx).String("ctx-secret"); got != Placeholder {
+		t.Errorf("FromContext redactor did not scrub: %q", got)
+	}
+	if FromContext(context.Background()) != nil {
+		t.Error("FromContext on an empty context should be nil")
+	}
+}
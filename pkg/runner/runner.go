@@ -0,0 +1,197 @@
+// Package runner executes a single query outside the manager process. For
+// executionMode Job the controller serialises a Spec into a Secret that is
+// mounted into the runner pod, and the runner reports a Result through the
+// container's termination message.
+package runner
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/rsavage/KubeQuery/pkg/db"
+	"github.com/rsavage/KubeQuery/pkg/redact"
+)
+
+// SpecKey is the key holding the serialised Spec in the runner Secret.
+const SpecKey = "spec.json"
+
+// maxMessageBytes is the largest termination message Kubernetes keeps.
+const maxMessageBytes = 4096
+
+// Spec is everything the runner needs to execute one query. Credentials are
+// resolved by the controller, so the Spec must only be stored in a Secret.
+type Spec struct {
+	Connection Connection `json:"connection"`
+	SQL        string     `json:"sql"`
+	// Timeout bounds connecting and executing.
+	Timeout time.Duration `json:"timeout"`
+	// LockRetryAttempts and LockRetryBackoff configure retries after
+	// lock_timeout errors; see db.LockRetry.
+	LockRetryAttempts int           `json:"lockRetryAttempts,omitempty"`
+	LockRetryBackoff  time.Duration `json:"lockRetryBackoff,omitempty"`
+	// RedactSQLLiterals also redacts SQL string literals from the result.
+	RedactSQLLiterals bool `json:"redactSQLLiterals,omitempty"`
+}
+
+// Connection is the serialisable form of db.ConnConfig with the password
+// already resolved.
+type Connection struct {
+	// Engine is the database engine; empty means PostgreSQL.
+	Engine db.Engine `json:"engine,omitempty"`
+	// Hosts are tried in order.
+	Hosts              []Host            `json:"hosts"`
+	Database           string            `json:"database"`
+	User               string            `json:"user"`
+	Password           string            `json:"password,omitempty"`
+	SSL                *db.SSLConfig     `json:"ssl,omitempty"`
+	TargetSessionAttrs string            `json:"targetSessionAttrs,omitempty"`
+	RuntimeParams      map[string]string `json:"runtimeParams,omitempty"`
+}
+
+// Host is a server address.
+type Host struct {
+	Host string `json:"host"`
+	Port int    `json:"port"`
+}
+
+// NewConnection resolves the credentials of cfg and returns its serialisable form.
+func NewConnection(ctx context.Context, cfg db.ConnConfig) (Connection, error) {
+	conn := Connection{
+		Engine:             cfg.Engine,
+		Hosts:              []Host{{Host: cfg.Host, Port: cfg.Port}},
+		Database:           cfg.Database,
+		User:               cfg.User,
+		Password:           cfg.Password,
+		SSL:                cfg.SSL,
+		TargetSessionAttrs: cfg.TargetSessionAttrs,
+		RuntimeParams:      cfg.RuntimeParams,
+	}
+	for _, h := range cfg.Hosts {
+		conn.Hosts = append(conn.Hosts, Host{Host: h.Host, Port: h.Port})
+	}
+	if cfg.Credentials != nil {
+		password, err := cfg.Credentials.Password(ctx)
+		if err != nil {
+			return Connection{}, err
+		}
+		conn.Password = password
+	}
+	return conn, nil
+}
+
+// ConnConfig returns the db.ConnConfig described by c.
+func (c Connection) ConnConfig() (db.ConnConfig, error) {
+	if len(c.Hosts) == 0 {
+		return db.ConnConfig{}, errors.New("no hosts configured")
+	}
+	cfg := db.ConnConfig{
+		Engine:             c.Engine,
+		Host:               c.Hosts[0].Host,
+		Port:               c.Hosts[0].Port,
+		Database:           c.Database,
+		User:               c.User,
+		Password:           c.Password,
+		SSL:                c.SSL,
+		TargetSessionAttrs: c.TargetSessionAttrs,
+		RuntimeParams:      c.RuntimeParams,
+	}
+	for _, h := range c.Hosts[1:] {
+		cfg.Hosts = append(cfg.Hosts, db.HostPort{Host: h.Host, Port: h.Port})
+	}
+	return cfg, nil
+}
+
+// Result is the outcome reported by the runner.
+type Result struct {
+	Succeeded bool   `json:"succeeded"`
+	Result    string `json:"result,omitempty"`
+	Error     string `json:"error,omitempty"`
+	// BackendPID is the server session that ran the query, if connected: the
+	// PostgreSQL backend PID or the MySQL connection ID.
+	BackendPID uint32 `json:"backendPID,omitempty"`
+}
+
+// Run executes spec and returns its redacted outcome. Cancelling ctx cancels
+// the query on the server.
+func Run(ctx context.Context, spec Spec) Result {
+	red := redact.New(spec.Connection.Password)
+	red.SQLLiterals = spec.RedactSQLLiterals
+	fail := func(format string, err error) Result {
+		return Result{Error: red.String(fmt.Sprintf(format, err))}
+	}
+
+	cfg, err := spec.Connection.ConnConfig()
+	if err != nil {
+		return fail("invalid runner spec: %v", err)
+	}
+	if spec.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, spec.Timeout)
+		defer cancel()
+	}
+
+	pool, err := db.Connect(ctx, cfg)
+	if err != nil {
+		return fail("db connect error: %v", err)
+	}
+	defer pool.Close()
+	conn, err := pool.Acquire(ctx)
+	if err != nil {
+		return fail("db connect error: %v", err)
+	}
+	defer conn.Release()
+	pid := conn.SessionID()
+
+	result, err := db.ExecWithRetry(ctx, conn, spec.SQL, db.LockRetry{
+		MaxAttempts: spec.LockRetryAttempts,
+		Backoff:     spec.LockRetryBackoff,
+	})
+	if err != nil {
+		res := fail("sql exec error: %v", err)
+		res.BackendPID = pid
+		return res
+	}
+	return Result{Succeeded: true, Result: red.String(result), BackendPID: pid}
+}
+
+// EncodeResult serialises res for a termination message, truncating the
+// result and error text so that it fits the 4096 byte limit.
+func EncodeResult(res Result) ([]byte, error) {
+	for {
+		b, err := json.Marshal(res)
+		if err != nil || len(b) <= maxMessageBytes {
+			return b, err
+		}
+		over := len(b) - maxMessageBytes
+		switch {
+		case len(res.Error) > 0:
+			res.Error = truncate(res.Error, over)
+		case len(res.Result) > 0:
+			res.Result = truncate(res.Result, over)
+		default:
+			return nil, errors.New("result does not fit in a termination message")
+		}
+	}
+}
+
+// truncate shortens s by at least n bytes, marking the cut.
+func truncate(s string, n int) string {
+	const marker = "...(truncated)"
+	keep := len(s) - n - len(marker)
+	if keep <= 0 {
+		return ""
+	}
+	return s[:keep] + marker
+}
+
+// DecodeResult parses a termination message written by the runner.
+func DecodeResult(msg string) (Result, error) {
+	var res Result
+	if err := json.Unmarshal([]byte(msg), &res); err != nil {
+		return Result{}, fmt.Errorf("invalid runner result: %w", err)
+	}
+	return res, nil
+}
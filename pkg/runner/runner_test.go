@@ -0,0 +1,101 @@
+package runner
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rsavage/KubeQuery/pkg/db"
+)
+
+func TestConnectionRoundTrip(t *testing.T) {
+	cfg := db.ConnConfig{
+		Host:               "primary",
+		Port:               5432,
+		Hosts:              []db.HostPort{{Host: "replica", Port: 5433}},
+		Database:           "app",
+		User:               "app",
+		Credentials:        db.StaticPassword("s3cr3t"),
+		SSL:                &db.SSLConfig{Mode: db.SSLModeVerifyFull, CA: []byte("ca"), ServerName: "db.example.com"},
+		TargetSessionAttrs: "read-write",
+		RuntimeParams:      map[string]string{"application_name": "kubequery"},
+	}
+	conn, err := NewConnection(context.Background(), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := json.Marshal(Spec{Connection: conn, SQL: "SELECT 1", Timeout: time.Minute})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var spec Spec
+	if err := json.Unmarshal(b, &spec); err != nil {
+		t.Fatal(err)
+	}
+	got, err := spec.Connection.ConnConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Host != "primary" || got.Port != 5432 || len(got.Hosts) != 1 || got.Hosts[0].Host != "replica" {
+		t.Errorf("hosts = %s:%d %v", got.Host, got.Port, got.Hosts)
+	}
+	if got.Password != "s3cr3t" {
+		t.Errorf("password was not resolved")
+	}
+	if got.SSL == nil || got.SSL.Mode != db.SSLModeVerifyFull || string(got.SSL.CA) != "ca" || got.SSL.ServerName != "db.example.com" {
+		t.Errorf("ssl = %+v", got.SSL)
+	}
+	if got.TargetSessionAttrs != "read-write" || got.RuntimeParams["application_name"] != "kubequery" {
+		t.Errorf("session settings not preserved: %+v", got)
+	}
+	if spec.SQL != "SELECT 1" || spec.Timeout != time.Minute {
+		t.Errorf("spec = %+v", spec)
+	}
+}
+
+func TestConnConfigWithoutHosts(t *testing.T) {
+	if _, err := (Connection{}).ConnConfig(); err == nil {
+		t.Fatal("expected an error")
+	}
+}
+
+func TestEncodeResult(t *testing.T) {
+	res := Result{Succeeded: true, Result: "INSERT 0 1", BackendPID: 42}
+	b, err := EncodeResult(res)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := DecodeResult(string(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != res {
+		t.Errorf("got %+v, want %+v", got, res)
+	}
+}
+
+func TestEncodeResultTruncates(t *testing.T) {
+	res := Result{Error: strings.Repeat("x", 10000)}
+	b, err := EncodeResult(res)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(b) > maxMessageBytes {
+		t.Fatalf("encoded result is %d bytes", len(b))
+	}
+	got, err := DecodeResult(string(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasSuffix(got.Error, "...(truncated)") {
+		t.Errorf("error was not marked as truncated")
+	}
+}
+
+func TestDecodeResultInvalid(t *testing.T) {
+	if _, err := DecodeResult("panic: boom"); err == nil {
+		t.Fatal("expected an error")
+	}
+}
@@ -1,67 +1,108 @@
 package main
 
 import (
-	"database/sql"
+	"context"
+	"crypto/rand"
 	"fmt"
+	"log"
 	"mime"
 	"net/http"
 	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
-	_ "github.com/lib/pq"
-)
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/clientcmd"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	kubequeryv1beta1 "github.com/rsavage/KubeQuery/api/v1beta1"
 
-var db *sql.DB
+	"github.com/rsavage/KubeQuery/ui-service/internal/audit"
+	"github.com/rsavage/KubeQuery/ui-service/internal/auth"
+	"github.com/rsavage/KubeQuery/ui-service/internal/authz"
+	"github.com/rsavage/KubeQuery/ui-service/internal/connections"
+	"github.com/rsavage/KubeQuery/ui-service/internal/console"
+	"github.com/rsavage/KubeQuery/ui-service/internal/queries"
+	"github.com/rsavage/KubeQuery/ui-service/internal/saved"
+)
 
 func main() {
 	// Ensure .js files are served with the correct MIME type
 	mime.AddExtensionType(".js", "application/javascript")
 
-	// Read DB config from env
-	pgHost := os.Getenv("PGHOST")
-	pgPort := os.Getenv("PGPORT")
-	pgUser := os.Getenv("PGUSER")
-	pgPass := os.Getenv("PGPASSWORD")
-	pgDB := os.Getenv("PGDATABASE")
-	if pgHost == "" {
-		pgHost = "kubequery-postgres"
+	// KUBECONFIG is used when set, the in-cluster config otherwise.
+	kubeConfig, err := clientcmd.BuildConfigFromFlags("", os.Getenv("KUBECONFIG"))
+	if err != nil {
+		log.Fatalf("Failed to load Kubernetes config: %v", err)
 	}
-	if pgPort == "" {
-		pgPort = "5432"
+	kube, err := kubernetes.NewForConfig(kubeConfig)
+	if err != nil {
+		log.Fatalf("Failed to create Kubernetes client: %v", err)
 	}
-	if pgUser == "" {
-		pgUser = "kquser"
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		log.Fatalf("Failed to register API types: %v", err)
 	}
-	if pgPass == "" {
-		pgPass = "changeme"
+	if err := kubequeryv1beta1.AddToScheme(scheme); err != nil {
+		log.Fatalf("Failed to register API types: %v", err)
 	}
-	if pgDB == "" {
-		pgDB = "kqdb"
+	kubeClient, err := client.NewWithWatch(kubeConfig, client.Options{Scheme: scheme})
+	if err != nil {
+		log.Fatalf("Failed to create Kubernetes client: %v", err)
 	}
-	connStr := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=disable", pgHost, pgPort, pgUser, pgPass, pgDB)
 
-	var err error
-	db, err = sql.Open("postgres", connStr)
+	authn, err := newAuthenticator(context.Background(), kube)
+	if err != nil {
+		log.Fatalf("Failed to configure authentication: %v", err)
+	}
+	access := authz.New(kube.AuthorizationV1().SubjectAccessReviews())
+	queryAPI := &queries.Handler{Client: kubeClient, Events: kube.CoreV1(), Authz: access}
+	limits, err := consoleLimits("CONSOLE_", console.DefaultLimits)
+	if err != nil {
+		log.Fatalf("Failed to configure the console: %v", err)
+	}
+	exportLimits, err := consoleLimits("CONSOLE_EXPORT_", console.DefaultExportLimits)
+	if err != nil {
+		log.Fatalf("Failed to configure the console: %v", err)
+	}
+	pools := &connections.Pools{Reader: kubeClient, ApplicationName: console.ApplicationName}
+	if v := os.Getenv("CONSOLE_POOL_IDLE_TIMEOUT"); v != "" {
+		if pools.IdleTimeout, err = time.ParseDuration(v); err != nil || pools.IdleTimeout <= 0 {
+			log.Fatalf("Failed to configure the console: invalid CONSOLE_POOL_IDLE_TIMEOUT %q", v)
+		}
+	}
+	connectionAPI := &connections.Handler{Client: kubeClient, Authz: access}
+	consoleAPI := &console.Handler{
+		Client:       kubeClient,
+		Pools:        pools,
+		Authz:        access,
+		Limits:       limits,
+		ExportLimits: exportLimits,
+		Audit:        audit.New(os.Stdout),
+	}
+	store, err := newStore(kubeClient, pools)
 	if err != nil {
-		panic(fmt.Sprintf("Failed to open DB: %v", err))
+		log.Fatalf("Failed to configure saved queries: %v", err)
 	}
-	if err = db.Ping(); err != nil {
-		panic(fmt.Sprintf("Failed to connect to DB: %v", err))
+	var savedAPI *saved.Handler
+	if store != nil {
+		consoleAPI.History = store
+		savedAPI = &saved.Handler{Store: store, Client: kubeClient, Authz: access, Console: consoleAPI}
 	}
 
 	r := gin.Default()
 
-	// Allow CORS for local dev
-	r.Use(func(c *gin.Context) {
-		c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
-		c.Writer.Header().Set("Access-Control-Allow-Methods", "POST, GET, OPTIONS")
-		c.Writer.Header().Set("Access-Control-Allow-Headers", "Origin, Content-Type, Authorization")
-		if c.Request.Method == "OPTIONS" {
-			c.AbortWithStatus(204)
-			return
-		}
-		c.Next()
-	})
+	var origins []string
+	if v := os.Getenv("CORS_ALLOWED_ORIGINS"); v != "" {
+		origins = strings.Split(v, ",")
+	}
+	r.Use(cors(origins))
 
 	// Serve static files (adjust the directory as needed)
 	r.Static("/static", "./static")
@@ -71,88 +112,173 @@ func main() {
 		c.File("./static/index.html")
 	})
 
-	r.POST("/login", loginHandler)
-	r.POST("/submit-sql", submitSQLHandler)
+	// Login endpoints are public; every API route requires a session and
+	// is authorized with SubjectAccessReview.
+	authn.Register(r)
+	api := r.Group("/api", authn.Middleware())
+	api.GET("/me", authn.Me)
+	api.GET("/namespaces/:namespace/permissions", access.Permissions)
+	queryAPI.Register(api)
+	connectionAPI.Register(api)
+	consoleAPI.Register(api)
+	if savedAPI != nil {
+		savedAPI.Register(api)
+	}
 
 	r.Run(":8080")
 }
 
-type LoginRequest struct {
-	Username string `json:"username"`
-	Password string `json:"password"`
-}
-
-type LoginResponse struct {
-	Token string `json:"token"`
-	Error string `json:"error,omitempty"`
+// cors allows cross-origin requests from origins, e.g.
+// http://localhost:3000 for a UI in development, read from the
+// comma-separated CORS_ALLOWED_ORIGINS. Without any, only the UI's own
+// origin may call the API.
+func cors(origins []string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Writer.Header().Add("Vary", "Origin")
+		if origin := c.GetHeader("Origin"); origin != "" && slices.Contains(origins, origin) {
+			c.Writer.Header().Set("Access-Control-Allow-Origin", origin)
+			c.Writer.Header().Set("Access-Control-Allow-Methods", "POST, GET, PUT, DELETE, OPTIONS")
+			c.Writer.Header().Set("Access-Control-Allow-Headers", "Origin, Content-Type, Authorization")
+		}
+		if c.Request.Method == http.MethodOptions {
+			c.AbortWithStatus(http.StatusNoContent)
+			return
+		}
+		c.Next()
+	}
 }
 
-func loginHandler(c *gin.Context) {
-	var req LoginRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, LoginResponse{Error: "Invalid request"})
-		return
+// newStore returns the store of saved queries and history configured by
+// the environment, or nil if STORE_CONNECTION is not set:
+//
+//	STORE_CONNECTION   namespace/name of the PostgresConnection of the
+//	                   database to keep them in
+//	STORE_SCHEMA       schema of their tables (default kubequery_ui)
+//	HISTORY_RETENTION  how long executions are kept, e.g. 720h (default
+//	                   forever)
+func newStore(c client.Reader, pools *connections.Pools) (*saved.Store, error) {
+	ref := os.Getenv("STORE_CONNECTION")
+	if ref == "" {
+		return nil, nil
 	}
-	// TODO: Replace with real authentication
-	if req.Username == "admin" && req.Password == "admin" {
-		c.JSON(http.StatusOK, LoginResponse{Token: "fake-jwt-token"})
-	} else {
-		c.JSON(http.StatusUnauthorized, LoginResponse{Error: "Invalid credentials"})
+	ns, name, ok := strings.Cut(ref, "/")
+	if !ok || ns == "" || name == "" {
+		return nil, fmt.Errorf("STORE_CONNECTION %q is not namespace/name", ref)
 	}
+	store := &saved.Store{
+		Client:     c,
+		Pools:      pools,
+		Connection: types.NamespacedName{Namespace: ns, Name: name},
+		Schema:     os.Getenv("STORE_SCHEMA"),
+	}
+	if v := os.Getenv("HISTORY_RETENTION"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d < 0 {
+			return nil, fmt.Errorf("invalid HISTORY_RETENTION %q", v)
+		}
+		store.HistoryRetention = d
+	}
+	return store, nil
 }
 
-type SQLRequest struct {
-	SQL string `json:"sql"`
-}
-
-type SQLResponse struct {
-	Rows  []map[string]interface{} `json:"rows,omitempty"`
-	Error string                   `json:"error,omitempty"`
+// consoleLimits reads limits of the read-only console from the environment
+// variables named with prefix; unset limits take those of def. For the
+// console, the prefix is CONSOLE_ and def is console.DefaultLimits:
+//
+//	CONSOLE_STATEMENT_TIMEOUT  statement_timeout of console statements (default 30s)
+//	CONSOLE_MAX_ROWS           most rows returned (default 1000)
+//	CONSOLE_MAX_BYTES          most bytes of values returned (default 4 MiB)
+//
+// For exports, the prefix is CONSOLE_EXPORT_ and def is
+// console.DefaultExportLimits (5m, 1000000 rows and 1 GiB).
+func consoleLimits(prefix string, def console.Limits) (console.Limits, error) {
+	limits := def
+	if v := os.Getenv(prefix + "STATEMENT_TIMEOUT"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			return limits, fmt.Errorf("invalid %sSTATEMENT_TIMEOUT %q", prefix, v)
+		}
+		limits.StatementTimeout = d
+	}
+	for name, limit := range map[string]*int{prefix + "MAX_ROWS": &limits.MaxRows, prefix + "MAX_BYTES": &limits.MaxBytes} {
+		if v := os.Getenv(name); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n <= 0 {
+				return limits, fmt.Errorf("invalid %s %q", name, v)
+			}
+			*limit = n
+		}
+	}
+	return limits, nil
 }
 
-func submitSQLHandler(c *gin.Context) {
-	var req SQLRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, SQLResponse{Error: "Invalid request"})
-		return
+// newAuthenticator configures authentication from the environment:
+//
+//	SESSION_SECRET       key signing session tokens, at least 32 bytes; random if unset
+//	SESSION_TTL          session lifetime (default 8h)
+//	COOKIE_SECURE        set to false to allow cookies over plain HTTP
+//	STATIC_USERS_FILE    YAML file of users with bcrypt password hashes
+//	OIDC_ISSUER_URL      OpenID Connect issuer; enables OIDC login
+//	OIDC_CLIENT_ID, OIDC_CLIENT_SECRET, OIDC_REDIRECT_URL
+//	OIDC_SCOPES          comma-separated extra scopes (default profile,email)
+//	OIDC_USERNAME_CLAIM  ID token claim used as the user name (default email)
+//	OIDC_GROUPS_CLAIM    ID token claim holding the user's groups
+//	OIDC_USERNAME_PREFIX, OIDC_GROUPS_PREFIX
+//	                     prefixes matching the API server's OIDC settings
+//	KUBERNETES_TOKEN_AUTH      set to true to accept Kubernetes bearer tokens
+//	KUBERNETES_TOKEN_AUDIENCES comma-separated audiences accepted tokens must have
+func newAuthenticator(ctx context.Context, kube kubernetes.Interface) (*auth.Authenticator, error) {
+	key := []byte(os.Getenv("SESSION_SECRET"))
+	if len(key) == 0 {
+		log.Print("SESSION_SECRET is not set; using a random key, so sessions end when the service restarts")
+		key = make([]byte, auth.MinKeyLength)
+		if _, err := rand.Read(key); err != nil {
+			return nil, err
+		}
 	}
-	if req.SQL == "" {
-		c.JSON(http.StatusBadRequest, SQLResponse{Error: "SQL is required"})
-		return
+	ttl := 8 * time.Hour
+	if v := os.Getenv("SESSION_TTL"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid SESSION_TTL: %w", err)
+		}
+		ttl = d
 	}
-	rows, err := db.Query(req.SQL)
+	sessions, err := auth.NewSessions(key, ttl)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, SQLResponse{Error: err.Error()})
-		return
+		return nil, err
 	}
-	defer rows.Close()
-	cols, err := rows.Columns()
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, SQLResponse{Error: err.Error()})
-		return
-	}
-	results := []map[string]interface{}{}
-	for rows.Next() {
-		vals := make([]interface{}, len(cols))
-		valPtrs := make([]interface{}, len(cols))
-		for i := range vals {
-			valPtrs[i] = &vals[i]
+	a := &auth.Authenticator{Sessions: sessions, SecureCookies: os.Getenv("COOKIE_SECURE") != "false"}
+
+	if path := os.Getenv("STATIC_USERS_FILE"); path != "" {
+		if a.Static, err = auth.LoadStaticUsers(path); err != nil {
+			return nil, err
 		}
-		if err := rows.Scan(valPtrs...); err != nil {
-			c.JSON(http.StatusInternalServerError, SQLResponse{Error: err.Error()})
-			return
+	}
+	if issuer := os.Getenv("OIDC_ISSUER_URL"); issuer != "" {
+		cfg := auth.OIDCConfig{
+			IssuerURL:      issuer,
+			ClientID:       os.Getenv("OIDC_CLIENT_ID"),
+			ClientSecret:   os.Getenv("OIDC_CLIENT_SECRET"),
+			RedirectURL:    os.Getenv("OIDC_REDIRECT_URL"),
+			UsernameClaim:  os.Getenv("OIDC_USERNAME_CLAIM"),
+			GroupsClaim:    os.Getenv("OIDC_GROUPS_CLAIM"),
+			UsernamePrefix: os.Getenv("OIDC_USERNAME_PREFIX"),
+			GroupsPrefix:   os.Getenv("OIDC_GROUPS_PREFIX"),
 		}
-		rowMap := map[string]interface{}{}
-		for i, col := range cols {
-			v := vals[i]
-			b, ok := v.([]byte)
-			if ok {
-				rowMap[col] = string(b)
-			} else {
-				rowMap[col] = v
-			}
+		if scopes := os.Getenv("OIDC_SCOPES"); scopes != "" {
+			cfg.Scopes = strings.Split(scopes, ",")
+		}
+		if a.OIDC, err = auth.NewOIDC(ctx, cfg); err != nil {
+			return nil, err
+		}
+	}
+	if os.Getenv("KUBERNETES_TOKEN_AUTH") == "true" {
+		var audiences []string
+		if v := os.Getenv("KUBERNETES_TOKEN_AUDIENCES"); v != "" {
+			audiences = strings.Split(v, ",")
 		}
-		results = append(results, rowMap)
+		a.Kubernetes = auth.NewTokenReviewer(kube.AuthenticationV1().TokenReviews(), audiences...)
 	}
-	c.JSON(http.StatusOK, SQLResponse{Rows: results})
+	return a, a.Validate()
 }
@@ -0,0 +1,90 @@
+// Package audit keeps the audit trail of the data users read through
+// ui-service directly, rather than through PostgresQuery objects, which
+// record themselves: console statements, result exports and plans. Each is
+// written as a line of JSON, {"audit": {...}}, for the log pipeline to
+// collect.
+package audit
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// Actions recorded.
+const (
+	ActionConsole = "console"
+	ActionExport  = "export"
+	ActionExplain = "explain"
+	// ActionExplainAnalyze is an EXPLAIN ANALYZE, which executes the
+	// statement.
+	ActionExplainAnalyze = "explain-analyze"
+)
+
+// Record is an entry of the audit trail.
+type Record struct {
+	Time       time.Time `json:"time"`
+	User       string    `json:"user"`
+	Action     string    `json:"action"`
+	Namespace  string    `json:"namespace"`
+	Connection string    `json:"connection"`
+	SQL        string    `json:"sql"`
+	// Args are the values of the statement's parameters.
+	Args []string `json:"args,omitempty"`
+	// SQLHash identifies the statement; Log sets it from SQL.
+	SQLHash string `json:"sqlHash"`
+	// Format is the format of an export.
+	Format         string `json:"format,omitempty"`
+	RowCount       int    `json:"rowCount"`
+	Truncated      bool   `json:"truncated,omitempty"`
+	DurationMillis int64  `json:"durationMillis"`
+	// Error is why the statement failed, with secrets redacted.
+	Error string `json:"error,omitempty"`
+}
+
+// Hash returns the hex SHA-256 digest of sql, which identifies a statement
+// across records without comparing its text.
+func Hash(sql string) string {
+	sum := sha256.Sum256([]byte(sql))
+	return hex.EncodeToString(sum[:])
+}
+
+// Log writes records to an io.Writer, one JSON line each.
+type Log struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// New returns a Log writing to w.
+func New(w io.Writer) *Log {
+	return &Log{w: w}
+}
+
+// Default writes to standard output.
+var Default = New(os.Stdout)
+
+// Record writes r, setting its time and SQL hash. Failing to write is
+// logged rather than returned, since the data was already read.
+func (l *Log) Record(r Record) {
+	if r.Time.IsZero() {
+		r.Time = time.Now().UTC()
+	}
+	r.SQLHash = Hash(r.SQL)
+	b, err := json.Marshal(struct {
+		Audit Record `json:"audit"`
+	}{r})
+	if err != nil {
+		log.Printf("audit: %v", err)
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if _, err := l.w.Write(append(b, '\n')); err != nil {
+		log.Printf("audit: failed to record %s by %s: %v", r.Action, r.User, err)
+	}
+}
@@ -0,0 +1,34 @@
+package audit
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+)
+
+func TestRecord(t *testing.T) {
+	var buf bytes.Buffer
+	l := New(&buf)
+	l.Record(Record{User: "alice", Action: ActionExport, Namespace: "team-a", Connection: "orders", SQL: "SELECT 1", Format: "csv", RowCount: 1})
+	l.Record(Record{User: "bob", Action: ActionConsole, SQL: "SELECT 1", Error: "syntax error"})
+
+	lines := bytes.Split(bytes.TrimSpace(buf.Bytes()), []byte("\n"))
+	if len(lines) != 2 {
+		t.Fatalf("got %d lines, want one per record: %s", len(lines), buf.Bytes())
+	}
+	var entries []struct{ Audit Record }
+	for _, line := range lines {
+		var entry struct{ Audit Record }
+		if err := json.Unmarshal(line, &entry); err != nil {
+			t.Fatal(err)
+		}
+		entries = append(entries, entry)
+	}
+	first := entries[0].Audit
+	if first.Time.IsZero() || first.User != "alice" || first.Format != "csv" || first.RowCount != 1 {
+		t.Errorf("record = %+v", first)
+	}
+	if first.SQLHash != Hash("SELECT 1") || len(first.SQLHash) != 64 || entries[1].Audit.SQLHash != first.SQLHash {
+		t.Errorf("sqlHash = %q, want the same SHA-256 for the same statement", first.SQLHash)
+	}
+}
@@ -0,0 +1,407 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"golang.org/x/crypto/bcrypt"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+var testKey = []byte("0123456789abcdef0123456789abcdef")
+
+func TestSessions(t *testing.T) {
+	if _, err := NewSessions([]byte("short"), time.Hour); err == nil {
+		t.Error("NewSessions accepted a short key")
+	}
+	s, err := NewSessions(testKey, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, expires, err := s.Issue(User{Name: "alice", Groups: []string{"dba"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if time.Until(expires) <= 0 {
+		t.Errorf("expires = %v, want in the future", expires)
+	}
+	user, err := s.Verify(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.Name != "alice" || len(user.Groups) != 1 || user.Groups[0] != "dba" {
+		t.Errorf("Verify() = %+v", user)
+	}
+
+	other, _ := NewSessions([]byte("fedcba9876543210fedcba9876543210"), time.Hour)
+	if _, err := other.Verify(token); err == nil {
+		t.Error("Verify accepted a token signed with another key")
+	}
+	expired, _ := NewSessions(testKey, time.Nanosecond)
+	token, _, _ = expired.Issue(User{Name: "alice"})
+	time.Sleep(time.Second)
+	if _, err := s.Verify(token); err == nil {
+		t.Error("Verify accepted an expired token")
+	}
+	flowToken, _ := s.sign(&flow{RegisteredClaims: jwt.RegisteredClaims{
+		Issuer:    sessionIssuer,
+		Audience:  jwt.ClaimStrings{sessionIssuer},
+		Subject:   "alice",
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+	}, Purpose: "oidc-flow"})
+	if _, err := s.Verify(flowToken); err == nil {
+		t.Error("Verify accepted an OIDC flow token as a session")
+	}
+}
+
+func TestStaticUsers(t *testing.T) {
+	hash, err := bcrypt.GenerateFromPassword([]byte("s3cret"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	users, err := LoadStaticUsers(write("users.yaml", "users:\n- username: alice\n  passwordHash: '"+string(hash)+"'\n  groups: [dba]\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	user, err := users.Authenticate("alice", "s3cret")
+	if err != nil || user.Name != "alice" || len(user.Groups) != 1 {
+		t.Errorf("Authenticate() = %+v, %v", user, err)
+	}
+	if _, err := users.Authenticate("alice", "wrong"); err != ErrInvalidCredentials {
+		t.Errorf("wrong password: err = %v", err)
+	}
+	if _, err := users.Authenticate("bob", "s3cret"); err != ErrInvalidCredentials {
+		t.Errorf("unknown user: err = %v", err)
+	}
+
+	for name, content := range map[string]string{
+		"plaintext": "users:\n- username: alice\n  passwordHash: s3cret\n",
+		"duplicate": "users:\n- username: a\n  passwordHash: '" + string(hash) + "'\n- username: a\n  passwordHash: '" + string(hash) + "'\n",
+		"unknown":   "users:\n- username: a\n  password: s3cret\n",
+	} {
+		if _, err := LoadStaticUsers(write(name+".yaml", content)); err == nil {
+			t.Errorf("%s: LoadStaticUsers succeeded", name)
+		}
+	}
+}
+
+// mockIssuer is a minimal OpenID Connect provider: discovery, JWKS and a
+// token endpoint that checks the PKCE verifier and returns an ID token.
+type mockIssuer struct {
+	*httptest.Server
+	key       *rsa.PrivateKey
+	challenge string
+	nonce     string
+	// claims are added to or override the claims of the ID token.
+	claims jwt.MapClaims
+}
+
+func newMockIssuer(t *testing.T) *mockIssuer {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := &mockIssuer{key: key}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, _ *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"issuer":                                m.URL,
+			"authorization_endpoint":                m.URL + "/authorize",
+			"token_endpoint":                        m.URL + "/token",
+			"jwks_uri":                              m.URL + "/keys",
+			"id_token_signing_alg_values_supported": []string{"RS256"},
+		})
+	})
+	mux.HandleFunc("/keys", func(w http.ResponseWriter, _ *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]any{"keys": []map[string]string{{
+			"kty": "RSA", "alg": "RS256", "use": "sig", "kid": "test",
+			"n": base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e": base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		sum := sha256.Sum256([]byte(r.FormValue("code_verifier")))
+		if r.FormValue("code") != "good-code" || base64.RawURLEncoding.EncodeToString(sum[:]) != m.challenge {
+			http.Error(w, `{"error":"invalid_grant"}`, http.StatusBadRequest)
+			return
+		}
+		claims := jwt.MapClaims{
+			"iss":            m.URL,
+			"aud":            "kubequery",
+			"sub":            "1234",
+			"email":          "alice@example.com",
+			"email_verified": true,
+			"groups":         []string{"dba"},
+			"nonce":          m.nonce,
+			"iat":            time.Now().Unix(),
+			"exp":            time.Now().Add(time.Hour).Unix(),
+		}
+		for k, v := range m.claims {
+			if v == nil {
+				delete(claims, k)
+			} else {
+				claims[k] = v
+			}
+		}
+		idToken := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+		idToken.Header["kid"] = "test"
+		signed, err := idToken.SignedString(key)
+		if err != nil {
+			t.Error(err)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"access_token": "access", "token_type": "Bearer", "expires_in": 3600, "id_token": signed,
+		})
+	})
+	m.Server = httptest.NewServer(mux)
+	t.Cleanup(m.Close)
+	return m
+}
+
+func TestOIDCLogin(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	issuer := newMockIssuer(t)
+	o, err := NewOIDC(context.Background(), OIDCConfig{
+		IssuerURL:   issuer.URL,
+		ClientID:    "kubequery",
+		RedirectURL: "http://ui.example.com/auth/callback",
+		GroupsClaim: "groups",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sessions, _ := NewSessions(testKey, time.Hour)
+	a := &Authenticator{Sessions: sessions, OIDC: o}
+	r := gin.New()
+	a.Register(r)
+	r.GET("/api/me", a.Middleware(), a.Me)
+
+	// Unauthenticated API requests are rejected.
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/me", nil))
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("GET /api/me without session = %d, want 401", w.Code)
+	}
+
+	// Start the login: the user is redirected to the provider.
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/auth/login", nil))
+	if w.Code != http.StatusFound {
+		t.Fatalf("GET /auth/login = %d, want 302", w.Code)
+	}
+	loc, err := url.Parse(w.Header().Get("Location"))
+	if err != nil || !strings.HasPrefix(loc.String(), issuer.URL+"/authorize") {
+		t.Fatalf("redirect to %q", w.Header().Get("Location"))
+	}
+	q := loc.Query()
+	if q.Get("code_challenge_method") != "S256" || q.Get("state") == "" {
+		t.Fatalf("authorization request %v lacks PKCE or state", q)
+	}
+	issuer.challenge, issuer.nonce = q.Get("code_challenge"), q.Get("nonce")
+	flowCookie := cookie(t, w, flowCookie)
+
+	callback := func(state, code string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/auth/callback?"+url.Values{"state": {state}, "code": {code}}.Encode(), nil)
+		req.AddCookie(flowCookie)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+	if w := callback("forged", "good-code"); w.Code != http.StatusUnauthorized {
+		t.Errorf("callback with wrong state = %d, want 401", w.Code)
+	}
+	if w := callback(q.Get("state"), "bad-code"); w.Code != http.StatusUnauthorized {
+		t.Errorf("callback with bad code = %d, want 401", w.Code)
+	}
+	w = callback(q.Get("state"), "good-code")
+	if w.Code != http.StatusFound {
+		t.Fatalf("callback = %d: %s", w.Code, w.Body)
+	}
+	session := cookie(t, w, SessionCookie)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/me", nil)
+	req.AddCookie(session)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	var user User
+	if err := json.Unmarshal(w.Body.Bytes(), &user); err != nil || w.Code != http.StatusOK {
+		t.Fatalf("GET /api/me = %d: %s", w.Code, w.Body)
+	}
+	if user.Name != "alice@example.com" || len(user.Groups) != 1 || user.Groups[0] != "dba" {
+		t.Errorf("user = %+v", user)
+	}
+}
+
+func TestOIDCEmailVerified(t *testing.T) {
+	ctx := context.Background()
+	issuer := newMockIssuer(t)
+	login := func(usernameClaim string, claims jwt.MapClaims) (User, error) {
+		o, err := NewOIDC(ctx, OIDCConfig{
+			IssuerURL:     issuer.URL,
+			ClientID:      "kubequery",
+			RedirectURL:   "http://ui.example.com/auth/callback",
+			UsernameClaim: usernameClaim,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		authURL, f, err := o.start()
+		if err != nil {
+			t.Fatal(err)
+		}
+		loc, err := url.Parse(authURL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		issuer.challenge, issuer.nonce, issuer.claims = loc.Query().Get("code_challenge"), f.Nonce, claims
+		return o.finish(ctx, f, f.State, "good-code")
+	}
+
+	for name, claims := range map[string]jwt.MapClaims{
+		"unverified": {"email_verified": false},
+		"missing":    {"email_verified": nil},
+		"a string":   {"email_verified": "true"},
+	} {
+		if _, err := login("", claims); err == nil || !strings.Contains(err.Error(), "not verified") {
+			t.Errorf("email_verified %s: err = %v, want the login rejected", name, err)
+		}
+	}
+	if user, err := login("", nil); err != nil || user.Name != "alice@example.com" {
+		t.Errorf("verified email: user = %+v, %v", user, err)
+	}
+	// Other username claims do not depend on email_verified.
+	if user, err := login("sub", jwt.MapClaims{"email_verified": false}); err != nil || user.Name != "1234" {
+		t.Errorf("sub claim: user = %+v, %v", user, err)
+	}
+}
+
+func TestPasswordLogin(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	hash, _ := bcrypt.GenerateFromPassword([]byte("s3cret"), bcrypt.MinCost)
+	sessions, _ := NewSessions(testKey, time.Hour)
+	a := &Authenticator{Sessions: sessions, Static: &StaticUsers{users: map[string]StaticUser{
+		"alice": {Username: "alice", PasswordHash: string(hash)},
+	}}}
+	r := gin.New()
+	a.Register(r)
+	r.GET("/api/me", a.Middleware(), a.Me)
+
+	login := func(password string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/login", strings.NewReader(`{"username":"alice","password":"`+password+`"}`))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+	if w := login("wrong"); w.Code != http.StatusUnauthorized {
+		t.Errorf("login with wrong password = %d, want 401", w.Code)
+	}
+	w := login("s3cret")
+	var resp LoginResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil || w.Code != http.StatusOK || resp.Token == "" {
+		t.Fatalf("login = %d: %s", w.Code, w.Body)
+	}
+
+	for name, header := range map[string]string{
+		"bearer":  "Bearer " + resp.Token,
+		"garbage": "Bearer fake-jwt-token",
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/api/me", nil)
+		req.Header.Set("Authorization", header)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		want := http.StatusOK
+		if name == "garbage" {
+			want = http.StatusUnauthorized
+		}
+		if w.Code != want {
+			t.Errorf("%s: GET /api/me = %d, want %d", name, w.Code, want)
+		}
+	}
+}
+
+func TestKubernetesTokens(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	client := fake.NewSimpleClientset()
+	client.PrependReactor("create", "tokenreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		review := action.(k8stesting.CreateAction).GetObject().(*authenticationv1.TokenReview)
+		if review.Spec.Token == "sa-token" && len(review.Spec.Audiences) == 1 && review.Spec.Audiences[0] == "kubequery" {
+			review.Status.Authenticated = true
+			review.Status.User = authenticationv1.UserInfo{
+				Username: "system:serviceaccount:ci:deployer",
+				UID:      "42",
+				Groups:   []string{"system:serviceaccounts"},
+			}
+		}
+		return true, review, nil
+	})
+	sessions, _ := NewSessions(testKey, time.Hour)
+	a := &Authenticator{Sessions: sessions, Kubernetes: NewTokenReviewer(client.AuthenticationV1().TokenReviews(), "kubequery")}
+	if err := a.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	r := gin.New()
+	r.GET("/api/me", a.Middleware(), a.Me)
+
+	get := func(token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/api/me", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+	w := get("sa-token")
+	var user User
+	if err := json.Unmarshal(w.Body.Bytes(), &user); err != nil || w.Code != http.StatusOK {
+		t.Fatalf("GET /api/me = %d: %s", w.Code, w.Body)
+	}
+	if user.Name != "system:serviceaccount:ci:deployer" || user.UID != "42" {
+		t.Errorf("user = %+v", user)
+	}
+	if w := get("other-token"); w.Code != http.StatusUnauthorized {
+		t.Errorf("unauthenticated token = %d, want 401", w.Code)
+	}
+}
+
+// cookie returns the cookie name set by the response in w.
+func cookie(t *testing.T, w *httptest.ResponseRecorder, name string) *http.Cookie {
+	t.Helper()
+	for _, c := range w.Result().Cookies() {
+		if c.Name == name && c.Value != "" {
+			if !c.HttpOnly {
+				t.Errorf("cookie %s is not HttpOnly", name)
+			}
+			return c
+		}
+	}
+	t.Fatalf("response sets no %s cookie", name)
+	return nil
+}
@@ -0,0 +1,242 @@
+package auth
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// SessionCookie holds the session token of browser users.
+	SessionCookie = "kubequery_session"
+	// flowCookie holds the state of an OIDC login in progress.
+	flowCookie = "kubequery_oidc"
+	// userKey is the gin context key of the authenticated User.
+	userKey = "kubequery.user"
+)
+
+// Authenticator serves the login endpoints and guards the API. At least one
+// of OIDC, Static and Kubernetes must be set.
+type Authenticator struct {
+	Sessions *Sessions
+	OIDC     *OIDC
+	Static   *StaticUsers
+	// Kubernetes, if set, accepts Kubernetes bearer tokens in addition to
+	// session tokens.
+	Kubernetes *TokenReviewer
+	// SecureCookies marks cookies Secure; disable only for plain-HTTP development.
+	SecureCookies bool
+}
+
+// LoginRequest is the body of POST /login.
+type LoginRequest struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+// LoginResponse is returned by POST /login. The token is also set as the
+// session cookie; API clients may send it as a bearer token instead.
+type LoginResponse struct {
+	Token     string    `json:"token,omitempty"`
+	ExpiresAt time.Time `json:"expiresAt,omitempty"`
+	Error     string    `json:"error,omitempty"`
+}
+
+// Register adds the public authentication routes to r:
+//
+//	GET  /auth/config    which login methods are enabled
+//	POST /login          static users login
+//	GET  /auth/login     start OIDC login
+//	GET  /auth/callback  finish OIDC login
+//	POST /logout         clear the session cookie
+func (a *Authenticator) Register(r gin.IRouter) {
+	r.GET("/auth/config", a.config)
+	r.POST("/login", a.login)
+	r.GET("/auth/login", a.oidcLogin)
+	r.GET("/auth/callback", a.oidcCallback)
+	r.POST("/logout", a.logout)
+}
+
+// Middleware rejects requests without a valid session token, from the
+// Authorization header or the session cookie, and records the user for
+// UserFrom. With Kubernetes set, a bearer token that is not a session token
+// is checked with TokenReview.
+func (a *Authenticator) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token := bearerToken(c.Request)
+		bearer := token != ""
+		if !bearer {
+			token, _ = c.Cookie(SessionCookie)
+		}
+		if token == "" {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "authentication required"})
+			return
+		}
+		user, err := a.Sessions.Verify(token)
+		if err != nil && bearer && a.Kubernetes != nil {
+			user, err = a.Kubernetes.Review(c.Request.Context(), token)
+		}
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid or expired session"})
+			return
+		}
+		SetUser(c, user)
+		c.Next()
+	}
+}
+
+// SetUser records user as the authenticated user of c.
+func SetUser(c *gin.Context, user User) {
+	c.Set(userKey, user)
+}
+
+// UserFrom returns the user authenticated by Middleware.
+func UserFrom(c *gin.Context) (User, bool) {
+	v, ok := c.Get(userKey)
+	if !ok {
+		return User{}, false
+	}
+	user, ok := v.(User)
+	return user, ok
+}
+
+// Me returns the authenticated user; it must run behind Middleware.
+func (a *Authenticator) Me(c *gin.Context) {
+	user, _ := UserFrom(c)
+	c.JSON(http.StatusOK, user)
+}
+
+func (a *Authenticator) config(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"oidc": a.OIDC != nil, "static": a.Static != nil, "kubernetes": a.Kubernetes != nil})
+}
+
+func (a *Authenticator) login(c *gin.Context) {
+	if a.Static == nil {
+		c.JSON(http.StatusNotFound, LoginResponse{Error: "password login is not enabled"})
+		return
+	}
+	var req LoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, LoginResponse{Error: "Invalid request"})
+		return
+	}
+	user, err := a.Static.Authenticate(req.Username, req.Password)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, LoginResponse{Error: "Invalid credentials"})
+		return
+	}
+	token, expires, err := a.startSession(c, user)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, LoginResponse{Error: "failed to create session"})
+		return
+	}
+	c.JSON(http.StatusOK, LoginResponse{Token: token, ExpiresAt: expires})
+}
+
+func (a *Authenticator) oidcLogin(c *gin.Context) {
+	if a.OIDC == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "OIDC login is not enabled"})
+		return
+	}
+	url, f, err := a.OIDC.start()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to start login"})
+		return
+	}
+	cookie, err := a.Sessions.sign(f)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to start login"})
+		return
+	}
+	a.setCookie(c, flowCookie, cookie, "/auth", flowTTL)
+	c.Redirect(http.StatusFound, url)
+}
+
+func (a *Authenticator) oidcCallback(c *gin.Context) {
+	if a.OIDC == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "OIDC login is not enabled"})
+		return
+	}
+	if e := c.Query("error"); e != "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "login failed: " + e})
+		return
+	}
+	raw, err := c.Cookie(flowCookie)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "login expired, please try again"})
+		return
+	}
+	a.setCookie(c, flowCookie, "", "/auth", -1)
+	var f flow
+	if err := a.Sessions.parse(raw, &f); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "login expired, please try again"})
+		return
+	}
+	user, err := a.OIDC.finish(c.Request.Context(), &f, c.Query("state"), c.Query("code"))
+	if err != nil {
+		log.Printf("OIDC login failed: %v", err)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "login failed"})
+		return
+	}
+	if _, _, err := a.startSession(c, user); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create session"})
+		return
+	}
+	c.Redirect(http.StatusFound, "/")
+}
+
+func (a *Authenticator) logout(c *gin.Context) {
+	a.setCookie(c, SessionCookie, "", "/", -1)
+	c.Status(http.StatusNoContent)
+}
+
+// startSession issues a session token for user and sets it as a cookie.
+func (a *Authenticator) startSession(c *gin.Context, user User) (string, time.Time, error) {
+	token, expires, err := a.Sessions.Issue(user)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	a.setCookie(c, SessionCookie, token, "/", a.Sessions.TTL())
+	return token, expires, nil
+}
+
+// setCookie sets an HttpOnly, SameSite=Lax cookie; a negative maxAge deletes it.
+func (a *Authenticator) setCookie(c *gin.Context, name, value, path string, maxAge time.Duration) {
+	seconds := int(maxAge.Seconds())
+	if maxAge < 0 {
+		seconds = -1
+	}
+	http.SetCookie(c.Writer, &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     path,
+		MaxAge:   seconds,
+		Secure:   a.SecureCookies,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// bearerToken returns the token of an "Authorization: Bearer" header.
+func bearerToken(r *http.Request) string {
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return ""
+	}
+	return strings.TrimSpace(token)
+}
+
+// Validate reports whether a is usable.
+func (a *Authenticator) Validate() error {
+	switch {
+	case a.Sessions == nil:
+		return errors.New("no session signer configured")
+	case a.OIDC == nil && a.Static == nil && a.Kubernetes == nil:
+		return errors.New("no login method configured: set OIDC_ISSUER_URL, STATIC_USERS_FILE or KUBERNETES_TOKEN_AUTH")
+	}
+	return nil
+}
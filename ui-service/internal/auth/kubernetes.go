@@ -0,0 +1,50 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	authenticationv1client "k8s.io/client-go/kubernetes/typed/authentication/v1"
+)
+
+// TokenReviewer authenticates Kubernetes bearer tokens, e.g. service account
+// tokens or the tokens kubectl uses, with the TokenReview API.
+type TokenReviewer struct {
+	client authenticationv1client.TokenReviewInterface
+	// audiences, if set, must intersect the audiences of accepted tokens.
+	audiences []string
+}
+
+// NewTokenReviewer returns a TokenReviewer using client. With audiences set,
+// only tokens issued for one of them are accepted.
+func NewTokenReviewer(client authenticationv1client.TokenReviewInterface, audiences ...string) *TokenReviewer {
+	return &TokenReviewer{client: client, audiences: audiences}
+}
+
+// Review returns the user token belongs to.
+func (t *TokenReviewer) Review(ctx context.Context, token string) (User, error) {
+	review, err := t.client.Create(ctx, &authenticationv1.TokenReview{
+		Spec: authenticationv1.TokenReviewSpec{Token: token, Audiences: t.audiences},
+	}, metav1.CreateOptions{})
+	if err != nil {
+		return User{}, fmt.Errorf("failed to review token: %w", err)
+	}
+	if !review.Status.Authenticated {
+		if review.Status.Error != "" {
+			return User{}, errors.New(review.Status.Error)
+		}
+		return User{}, errors.New("token is not authenticated")
+	}
+	info := review.Status.User
+	user := User{Name: info.Username, UID: info.UID, Groups: info.Groups}
+	if len(info.Extra) > 0 {
+		user.Extra = make(map[string][]string, len(info.Extra))
+		for k, v := range info.Extra {
+			user.Extra[k] = v
+		}
+	}
+	return user, nil
+}
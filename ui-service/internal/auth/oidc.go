@@ -0,0 +1,167 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"github.com/golang-jwt/jwt/v5"
+	"golang.org/x/oauth2"
+)
+
+// flowTTL bounds how long a user may take to log in at the provider.
+const flowTTL = 10 * time.Minute
+
+// OIDCConfig configures login with an OpenID Connect provider.
+type OIDCConfig struct {
+	IssuerURL    string
+	ClientID     string
+	ClientSecret string
+	// RedirectURL is the externally reachable URL of /auth/callback.
+	RedirectURL string
+	// Scopes are requested in addition to openid. Defaults to profile and email.
+	Scopes []string
+	// UsernameClaim is the ID token claim used as the user name. Defaults to
+	// email, which is only accepted if the email_verified claim is true.
+	UsernameClaim string
+	// GroupsClaim is the ID token claim holding the user's groups (optional).
+	GroupsClaim string
+	// UsernamePrefix and GroupsPrefix are prepended to the claims, like the
+	// API server's --oidc-username-prefix and --oidc-groups-prefix, so that
+	// users match the same RBAC subjects in the UI as with kubectl.
+	UsernamePrefix string
+	GroupsPrefix   string
+}
+
+// OIDC runs the authorization code flow with PKCE against a provider.
+type OIDC struct {
+	cfg      OIDCConfig
+	oauth2   oauth2.Config
+	verifier *oidc.IDTokenVerifier
+}
+
+// NewOIDC discovers the provider at cfg.IssuerURL.
+func NewOIDC(ctx context.Context, cfg OIDCConfig) (*OIDC, error) {
+	if cfg.ClientID == "" || cfg.RedirectURL == "" {
+		return nil, errors.New("OIDC requires a client ID and a redirect URL")
+	}
+	if cfg.UsernameClaim == "" {
+		cfg.UsernameClaim = "email"
+	}
+	scopes := cfg.Scopes
+	if len(scopes) == 0 {
+		scopes = []string{"profile", "email"}
+	}
+	provider, err := oidc.NewProvider(ctx, cfg.IssuerURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to discover OIDC provider: %w", err)
+	}
+	return &OIDC{
+		cfg: cfg,
+		oauth2: oauth2.Config{
+			ClientID:     cfg.ClientID,
+			ClientSecret: cfg.ClientSecret,
+			RedirectURL:  cfg.RedirectURL,
+			Endpoint:     provider.Endpoint(),
+			Scopes:       append([]string{oidc.ScopeOpenID}, scopes...),
+		},
+		verifier: provider.Verifier(&oidc.Config{ClientID: cfg.ClientID}),
+	}, nil
+}
+
+// flow is the state of a login in progress, kept in a signed cookie
+// between the redirect to the provider and the callback.
+type flow struct {
+	jwt.RegisteredClaims
+	Purpose  string `json:"purpose"`
+	State    string `json:"state"`
+	Nonce    string `json:"nonce"`
+	Verifier string `json:"verifier"`
+}
+
+// start returns the provider URL to redirect to and the flow to remember.
+func (o *OIDC) start() (string, *flow, error) {
+	state, err := randomString()
+	if err != nil {
+		return "", nil, err
+	}
+	nonce, err := randomString()
+	if err != nil {
+		return "", nil, err
+	}
+	f := &flow{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    sessionIssuer,
+			Audience:  jwt.ClaimStrings{sessionIssuer},
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(flowTTL)),
+		},
+		Purpose:  "oidc-flow",
+		State:    state,
+		Nonce:    nonce,
+		Verifier: oauth2.GenerateVerifier(),
+	}
+	url := o.oauth2.AuthCodeURL(state, oidc.Nonce(nonce), oauth2.S256ChallengeOption(f.Verifier))
+	return url, f, nil
+}
+
+// finish exchanges code for tokens, verifies the ID token against f and
+// returns the user it identifies.
+func (o *OIDC) finish(ctx context.Context, f *flow, state, code string) (User, error) {
+	if f.Purpose != "oidc-flow" || state == "" || state != f.State {
+		return User{}, errors.New("login state does not match")
+	}
+	token, err := o.oauth2.Exchange(ctx, code, oauth2.VerifierOption(f.Verifier))
+	if err != nil {
+		return User{}, fmt.Errorf("failed to exchange authorization code: %w", err)
+	}
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok {
+		return User{}, errors.New("token response has no id_token")
+	}
+	idToken, err := o.verifier.Verify(ctx, rawIDToken)
+	if err != nil {
+		return User{}, fmt.Errorf("invalid ID token: %w", err)
+	}
+	if idToken.Nonce != f.Nonce {
+		return User{}, errors.New("ID token nonce does not match")
+	}
+
+	var claims map[string]any
+	if err := idToken.Claims(&claims); err != nil {
+		return User{}, fmt.Errorf("invalid ID token claims: %w", err)
+	}
+	name, _ := claims[o.cfg.UsernameClaim].(string)
+	if name == "" {
+		return User{}, fmt.Errorf("ID token has no %s claim", o.cfg.UsernameClaim)
+	}
+	// Anyone may register an unverified address with some providers, so
+	// like the API server only verified email addresses are accepted.
+	if o.cfg.UsernameClaim == "email" {
+		if verified, _ := claims["email_verified"].(bool); !verified {
+			return User{}, fmt.Errorf("email address %s is not verified", name)
+		}
+	}
+	user := User{Name: o.cfg.UsernamePrefix + name}
+	if o.cfg.GroupsClaim != "" {
+		groups, _ := claims[o.cfg.GroupsClaim].([]any)
+		for _, g := range groups {
+			if s, ok := g.(string); ok {
+				user.Groups = append(user.Groups, o.cfg.GroupsPrefix+s)
+			}
+		}
+	}
+	return user, nil
+}
+
+// randomString returns 32 random bytes, base64url-encoded.
+func randomString() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
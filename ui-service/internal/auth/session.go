@@ -0,0 +1,106 @@
+// Package auth authenticates ui-service users, with OIDC, a static users
+// file or Kubernetes bearer tokens, and issues the signed session tokens that
+// protect the API.
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// sessionIssuer is the iss and aud of session tokens, so that tokens signed
+// with the same key for another purpose are not accepted as sessions.
+const sessionIssuer = "kubequery-ui-service"
+
+// MinKeyLength is the minimum length of the session signing key.
+const MinKeyLength = 32
+
+// User is an authenticated user. Name and Groups are the subject of
+// authorization checks, so they must match the subjects of RBAC bindings.
+type User struct {
+	Name   string   `json:"name"`
+	Groups []string `json:"groups,omitempty"`
+	// UID and Extra are only set for users authenticated by TokenReview.
+	UID   string              `json:"uid,omitempty"`
+	Extra map[string][]string `json:"extra,omitempty"`
+}
+
+// sessionClaims are the claims of a session token.
+type sessionClaims struct {
+	jwt.RegisteredClaims
+	Groups []string `json:"groups,omitempty"`
+	// Purpose distinguishes sessions from other tokens signed with the key.
+	Purpose string `json:"purpose"`
+}
+
+// Sessions issues and verifies HMAC-signed JWT session tokens.
+type Sessions struct {
+	key []byte
+	ttl time.Duration
+}
+
+// NewSessions returns Sessions signing with key; tokens expire after ttl.
+func NewSessions(key []byte, ttl time.Duration) (*Sessions, error) {
+	if len(key) < MinKeyLength {
+		return nil, fmt.Errorf("session key must be at least %d bytes", MinKeyLength)
+	}
+	if ttl <= 0 {
+		return nil, errors.New("session lifetime must be positive")
+	}
+	return &Sessions{key: key, ttl: ttl}, nil
+}
+
+// TTL returns the lifetime of new sessions.
+func (s *Sessions) TTL() time.Duration {
+	return s.ttl
+}
+
+// Issue returns a session token for user and its expiry.
+func (s *Sessions) Issue(user User) (string, time.Time, error) {
+	now := time.Now()
+	expires := now.Add(s.ttl)
+	token, err := s.sign(&sessionClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    sessionIssuer,
+			Audience:  jwt.ClaimStrings{sessionIssuer},
+			Subject:   user.Name,
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(expires),
+		},
+		Groups:  user.Groups,
+		Purpose: "session",
+	})
+	return token, expires, err
+}
+
+// Verify checks the signature and expiry of a session token and returns its user.
+func (s *Sessions) Verify(token string) (User, error) {
+	var claims sessionClaims
+	if err := s.parse(token, &claims); err != nil {
+		return User{}, err
+	}
+	if claims.Purpose != "session" || claims.Subject == "" {
+		return User{}, errors.New("not a session token")
+	}
+	return User{Name: claims.Subject, Groups: claims.Groups}, nil
+}
+
+func (s *Sessions) sign(claims jwt.Claims) (string, error) {
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(s.key)
+}
+
+func (s *Sessions) parse(token string, claims jwt.Claims) error {
+	_, err := jwt.ParseWithClaims(token, claims, func(*jwt.Token) (any, error) {
+		return s.key, nil
+	},
+		jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}),
+		jwt.WithIssuer(sessionIssuer),
+		jwt.WithAudience(sessionIssuer),
+		jwt.WithExpirationRequired(),
+	)
+	return err
+}
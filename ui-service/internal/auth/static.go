@@ -0,0 +1,75 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"golang.org/x/crypto/bcrypt"
+	"sigs.k8s.io/yaml"
+)
+
+// ErrInvalidCredentials is returned for an unknown user or a wrong password.
+var ErrInvalidCredentials = errors.New("invalid credentials")
+
+// StaticUser is an entry of the static users file.
+type StaticUser struct {
+	Username string `json:"username"`
+	// PasswordHash is a bcrypt hash, e.g. from `htpasswd -nbBC 12 "" <password>`.
+	PasswordHash string   `json:"passwordHash"`
+	Groups       []string `json:"groups,omitempty"`
+}
+
+// staticUsersFile is the format of the static users file.
+type staticUsersFile struct {
+	Users []StaticUser `json:"users"`
+}
+
+// StaticUsers authenticates users against a file of bcrypt password hashes,
+// for installations without an identity provider.
+type StaticUsers struct {
+	users map[string]StaticUser
+}
+
+// dummyHash is compared against when the user does not exist, so that
+// unknown and known users take the same time to reject.
+var dummyHash, _ = bcrypt.GenerateFromPassword([]byte("kubequery"), bcrypt.DefaultCost)
+
+// LoadStaticUsers reads a YAML or JSON users file.
+func LoadStaticUsers(path string) (*StaticUsers, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read static users file: %w", err)
+	}
+	var f staticUsersFile
+	if err := yaml.UnmarshalStrict(b, &f); err != nil {
+		return nil, fmt.Errorf("invalid static users file: %w", err)
+	}
+	s := &StaticUsers{users: make(map[string]StaticUser, len(f.Users))}
+	for i, u := range f.Users {
+		if u.Username == "" {
+			return nil, fmt.Errorf("static user %d has no username", i)
+		}
+		if _, err := bcrypt.Cost([]byte(u.PasswordHash)); err != nil {
+			return nil, fmt.Errorf("static user %q: passwordHash is not a bcrypt hash", u.Username)
+		}
+		if _, dup := s.users[u.Username]; dup {
+			return nil, fmt.Errorf("static user %q is listed twice", u.Username)
+		}
+		s.users[u.Username] = u
+	}
+	return s, nil
+}
+
+// Authenticate checks password against the hash of username.
+func (s *StaticUsers) Authenticate(username, password string) (User, error) {
+	u, ok := s.users[username]
+	hash := []byte(u.PasswordHash)
+	if !ok {
+		hash = dummyHash
+	}
+	if err := bcrypt.CompareHashAndPassword(hash, []byte(password)); err != nil || !ok {
+		return User{}, ErrInvalidCredentials
+	}
+	return User{Name: u.Username, Groups: u.Groups}, nil
+}
@@ -0,0 +1,137 @@
+// Package authz decides what ui-service users may do by asking the Kubernetes
+// API server with SubjectAccessReview, so that the UI grants exactly what
+// the cluster's RBAC grants on PostgresQuery objects.
+package authz
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	authorizationv1client "k8s.io/client-go/kubernetes/typed/authorization/v1"
+
+	"github.com/rsavage/KubeQuery/ui-service/internal/auth"
+)
+
+const (
+	// Group and Resource identify PostgresQuery objects.
+	Group    = "kubequery.cloudnexus.io"
+	Resource = "postgresqueries"
+)
+
+// Action is something a user may do with PostgresQuery objects in a namespace.
+type Action string
+
+const (
+	// ActionGet allows viewing a query, its result, events and plan, as
+	// granted by postgresquery-viewer-role.
+	ActionGet Action = "get"
+	// ActionList allows listing queries, as granted by
+	// postgresquery-viewer-role.
+	ActionList Action = "list"
+	// ActionConsole allows running read-only SQL in the console. It is the
+	// custom verb "console", granted by postgresquery-console-role.
+	ActionConsole Action = "console"
+	// ActionCreate allows submitting queries, as granted by postgresquery-editor-role.
+	ActionCreate Action = "create"
+	// ActionApprove allows approving queries that require approval. It is
+	// the custom verb "approve", granted by postgresquery-admin-role.
+	ActionApprove Action = "approve"
+)
+
+// Actions lists every Action, in increasing order of privilege.
+var Actions = []Action{ActionGet, ActionList, ActionConsole, ActionCreate, ActionApprove}
+
+// Authorizer checks actions with SubjectAccessReview.
+type Authorizer struct {
+	client authorizationv1client.SubjectAccessReviewInterface
+}
+
+// New returns an Authorizer using client.
+func New(client authorizationv1client.SubjectAccessReviewInterface) *Authorizer {
+	return &Authorizer{client: client}
+}
+
+// Allowed reports whether user may perform action in namespace, and the
+// reason given by the API server when it may not.
+func (a *Authorizer) Allowed(ctx context.Context, user auth.User, action Action, namespace string) (bool, string, error) {
+	sar := &authorizationv1.SubjectAccessReview{
+		Spec: authorizationv1.SubjectAccessReviewSpec{
+			User:   user.Name,
+			Groups: user.Groups,
+			UID:    user.UID,
+			ResourceAttributes: &authorizationv1.ResourceAttributes{
+				Namespace: namespace,
+				Verb:      string(action),
+				Group:     Group,
+				Resource:  Resource,
+			},
+		},
+	}
+	if len(user.Extra) > 0 {
+		sar.Spec.Extra = make(map[string]authorizationv1.ExtraValue, len(user.Extra))
+		for k, v := range user.Extra {
+			sar.Spec.Extra[k] = v
+		}
+	}
+	res, err := a.client.Create(ctx, sar, metav1.CreateOptions{})
+	if err != nil {
+		return false, "", fmt.Errorf("failed to check access: %w", err)
+	}
+	return res.Status.Allowed && !res.Status.Denied, res.Status.Reason, nil
+}
+
+// Require returns middleware that aborts with 403 unless the user
+// authenticated by auth.Authenticator.Middleware may perform action in the
+// namespace returned by namespace.
+func (a *Authorizer) Require(action Action, namespace func(*gin.Context) string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, ok := auth.UserFrom(c)
+		if !ok {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "authentication required"})
+			return
+		}
+		ns := namespace(c)
+		allowed, reason, err := a.Allowed(c.Request.Context(), user, action, ns)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if !allowed {
+			msg := fmt.Sprintf("%s may not %s PostgresQuery objects in namespace %q", user.Name, action, ns)
+			if reason != "" {
+				msg += ": " + reason
+			}
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": msg})
+			return
+		}
+		c.Next()
+	}
+}
+
+// Param returns a namespace function reading the URL parameter name.
+func Param(name string) func(*gin.Context) string {
+	return func(c *gin.Context) string {
+		return c.Param(name)
+	}
+}
+
+// Permissions returns, for the namespace URL parameter, which actions the
+// user may perform, so the UI can hide what they cannot do.
+func (a *Authorizer) Permissions(c *gin.Context) {
+	user, _ := auth.UserFrom(c)
+	ns := c.Param("namespace")
+	perms := make(map[Action]bool, len(Actions))
+	for _, action := range Actions {
+		allowed, _, err := a.Allowed(c.Request.Context(), user, action, ns)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		perms[action] = allowed
+	}
+	c.JSON(http.StatusOK, gin.H{"namespace": ns, "permissions": perms})
+}
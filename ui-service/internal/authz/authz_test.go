@@ -0,0 +1,137 @@
+package authz
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+
+	"github.com/rsavage/KubeQuery/ui-service/internal/auth"
+)
+
+// rbac allows what the viewer, console, editor and admin roles would allow
+// to the users bound to them in namespace "team-a".
+var rbac = map[string][]string{
+	"viewer":  {"get", "list"},
+	"analyst": {"get", "list", "console"},
+	"editor":  {"get", "list", "create"},
+	"admin":   {"get", "list", "console", "create", "approve"},
+}
+
+func newAuthorizer(t *testing.T, reviews *[]authorizationv1.SubjectAccessReviewSpec) *Authorizer {
+	client := fake.NewSimpleClientset()
+	client.PrependReactor("create", "subjectaccessreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		sar := action.(k8stesting.CreateAction).GetObject().(*authorizationv1.SubjectAccessReview)
+		*reviews = append(*reviews, sar.Spec)
+		attrs := sar.Spec.ResourceAttributes
+		if attrs.Group != Group || attrs.Resource != Resource {
+			t.Errorf("review for %s/%s", attrs.Group, attrs.Resource)
+		}
+		for _, verb := range rbac[sar.Spec.User] {
+			if verb == attrs.Verb && attrs.Namespace == "team-a" {
+				sar.Status.Allowed = true
+			}
+		}
+		if !sar.Status.Allowed {
+			sar.Status.Reason = "no RBAC policy matched"
+		}
+		return true, sar, nil
+	})
+	return New(client.AuthorizationV1().SubjectAccessReviews())
+}
+
+func TestAllowed(t *testing.T) {
+	var reviews []authorizationv1.SubjectAccessReviewSpec
+	a := newAuthorizer(t, &reviews)
+	for _, tc := range []struct {
+		user      string
+		namespace string
+		want      map[Action]bool
+	}{
+		{"viewer", "team-a", map[Action]bool{ActionGet: true, ActionList: true}},
+		{"analyst", "team-a", map[Action]bool{ActionGet: true, ActionList: true, ActionConsole: true}},
+		{"editor", "team-a", map[Action]bool{ActionGet: true, ActionList: true, ActionCreate: true}},
+		{"admin", "team-a", map[Action]bool{ActionGet: true, ActionList: true, ActionConsole: true, ActionCreate: true, ActionApprove: true}},
+		{"admin", "team-b", map[Action]bool{}},
+		{"nobody", "team-a", map[Action]bool{}},
+	} {
+		for _, action := range Actions {
+			got, reason, err := a.Allowed(context.Background(), auth.User{Name: tc.user}, action, tc.namespace)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tc.want[action] {
+				t.Errorf("%s %s in %s = %v, want %v", tc.user, action, tc.namespace, got, tc.want[action])
+			}
+			if !got && reason == "" {
+				t.Errorf("%s %s in %s: denied without a reason", tc.user, action, tc.namespace)
+			}
+		}
+	}
+
+	reviews = nil
+	user := auth.User{Name: "alice", UID: "42", Groups: []string{"dba"}, Extra: map[string][]string{"scopes": {"read"}}}
+	if _, _, err := a.Allowed(context.Background(), user, ActionCreate, "team-a"); err != nil {
+		t.Fatal(err)
+	}
+	spec := reviews[0]
+	if spec.User != "alice" || spec.UID != "42" || len(spec.Groups) != 1 || spec.Groups[0] != "dba" || spec.Extra["scopes"][0] != "read" {
+		t.Errorf("review spec = %+v, want the user's identity", spec)
+	}
+}
+
+func TestRequire(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	var reviews []authorizationv1.SubjectAccessReviewSpec
+	a := newAuthorizer(t, &reviews)
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		// Stands in for auth.Authenticator.Middleware.
+		if name := c.GetHeader("X-User"); name != "" {
+			auth.SetUser(c, auth.User{Name: name})
+		}
+	})
+	r.POST("/api/namespaces/:namespace/queries", a.Require(ActionCreate, Param("namespace")), func(c *gin.Context) {
+		c.Status(http.StatusCreated)
+	})
+	r.GET("/api/namespaces/:namespace/permissions", a.Permissions)
+
+	for _, tc := range []struct {
+		user, namespace string
+		want            int
+	}{
+		{"editor", "team-a", http.StatusCreated},
+		{"viewer", "team-a", http.StatusForbidden},
+		{"editor", "team-b", http.StatusForbidden},
+		{"", "team-a", http.StatusUnauthorized},
+	} {
+		req := httptest.NewRequest(http.MethodPost, "/api/namespaces/"+tc.namespace+"/queries", nil)
+		req.Header.Set("X-User", tc.user)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != tc.want {
+			t.Errorf("%q creating in %s = %d, want %d: %s", tc.user, tc.namespace, w.Code, tc.want, w.Body)
+		}
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/namespaces/team-a/permissions", nil)
+	req.Header.Set("X-User", "editor")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	var resp struct {
+		Permissions map[Action]bool `json:"permissions"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if !resp.Permissions[ActionList] || !resp.Permissions[ActionCreate] || resp.Permissions[ActionApprove] {
+		t.Errorf("editor permissions = %v", resp.Permissions)
+	}
+}
@@ -0,0 +1,134 @@
+// Package connections lets UI users discover the databases they can target,
+// the PostgresConnections of the namespaces they may list, and keeps a pool
+// of database connections per target, opened when it is first used.
+package connections
+
+import (
+	"net/http"
+	"sort"
+
+	"github.com/gin-gonic/gin"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	kubequeryv1beta1 "github.com/rsavage/KubeQuery/api/v1beta1"
+	"github.com/rsavage/KubeQuery/pkg/db"
+
+	"github.com/rsavage/KubeQuery/ui-service/internal/auth"
+	"github.com/rsavage/KubeQuery/ui-service/internal/authz"
+)
+
+// Target describes a PostgresConnection a user can pick to run statements
+// against. Credentials are never included.
+type Target struct {
+	Namespace string    `json:"namespace"`
+	Name      string    `json:"name"`
+	Engine    db.Engine `json:"engine"`
+	Host      string    `json:"host"`
+	Port      int       `json:"port"`
+	Database  string    `json:"database"`
+	User      string    `json:"user"`
+	// Console reports whether the console can run statements against the
+	// target; it supports PostgreSQL only.
+	Console bool `json:"console"`
+}
+
+// NewTarget describes pc.
+func NewTarget(pc *kubequeryv1beta1.PostgresConnection) Target {
+	engine := db.Engine(pc.Spec.Engine)
+	if engine == "" {
+		engine = db.EnginePostgres
+	}
+	return Target{
+		Namespace: pc.Namespace,
+		Name:      pc.Name,
+		Engine:    engine,
+		Host:      pc.Spec.Host,
+		Port:      pc.Spec.Port,
+		Database:  pc.Spec.Database,
+		User:      pc.Spec.User,
+		Console:   engine == db.EnginePostgres,
+	}
+}
+
+// Handler serves the connection discovery endpoints.
+type Handler struct {
+	// Client lists PostgresConnections in every namespace.
+	Client client.Client
+	Authz  *authz.Authorizer
+}
+
+// Register adds the connection routes to r, which must run behind
+// auth.Authenticator.Middleware:
+//
+//	GET /connections             list the targets of every namespace the user
+//	                             may list queries in
+//	GET /connections/:namespace  list the targets of a namespace
+func (h *Handler) Register(r gin.IRouter) {
+	r.GET("/connections", h.listAll)
+	r.GET("/connections/:namespace", h.Authz.Require(authz.ActionList, authz.Param("namespace")), h.list)
+}
+
+func (h *Handler) list(c *gin.Context) {
+	var list kubequeryv1beta1.PostgresConnectionList
+	if err := h.Client.List(c.Request.Context(), &list, client.InNamespace(c.Param("namespace"))); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"items": targets(list.Items, nil)})
+}
+
+// listAll lists the PostgresConnections of all namespaces and keeps those
+// of the namespaces where the user may list queries, which is decided once
+// for the whole cluster when RBAC grants it cluster-wide.
+func (h *Handler) listAll(c *gin.Context) {
+	user, ok := auth.UserFrom(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "authentication required"})
+		return
+	}
+	ctx := c.Request.Context()
+	var list kubequeryv1beta1.PostgresConnectionList
+	if err := h.Client.List(ctx, &list); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	clusterWide, _, err := h.Authz.Allowed(ctx, user, authz.ActionList, "")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	var allowed map[string]bool
+	if !clusterWide {
+		allowed = map[string]bool{}
+		for _, pc := range list.Items {
+			if _, checked := allowed[pc.Namespace]; checked {
+				continue
+			}
+			ok, _, err := h.Authz.Allowed(ctx, user, authz.ActionList, pc.Namespace)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			allowed[pc.Namespace] = ok
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{"items": targets(list.Items, allowed)})
+}
+
+// targets describes conns, sorted by namespace and name, leaving out those
+// of namespaces not in allowed, unless allowed is nil.
+func targets(conns []kubequeryv1beta1.PostgresConnection, allowed map[string]bool) []Target {
+	items := make([]Target, 0, len(conns))
+	for i := range conns {
+		if allowed == nil || allowed[conns[i].Namespace] {
+			items = append(items, NewTarget(&conns[i]))
+		}
+	}
+	sort.Slice(items, func(i, j int) bool {
+		if items[i].Namespace != items[j].Namespace {
+			return items[i].Namespace < items[j].Namespace
+		}
+		return items[i].Name < items[j].Name
+	})
+	return items
+}
@@ -0,0 +1,88 @@
+package connections
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	kubequeryv1beta1 "github.com/rsavage/KubeQuery/api/v1beta1"
+
+	"github.com/rsavage/KubeQuery/ui-service/internal/auth"
+	"github.com/rsavage/KubeQuery/ui-service/internal/authz"
+)
+
+// grants maps users to the namespaces RBAC lets them list queries in; ""
+// grants every namespace.
+var grants = map[string][]string{
+	"alice": {"team-a"},
+	"admin": {""},
+}
+
+func TestList(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	scheme := runtime.NewScheme()
+	if err := kubequeryv1beta1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+		postgresConnection("team-a", "orders", ""),
+		postgresConnection("team-a", "legacy", "mysql"),
+		postgresConnection("team-b", "billing", ""),
+	).Build()
+
+	kube := kubefake.NewSimpleClientset()
+	kube.PrependReactor("create", "subjectaccessreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		sar := action.(k8stesting.CreateAction).GetObject().(*authorizationv1.SubjectAccessReview)
+		for _, ns := range grants[sar.Spec.User] {
+			sar.Status.Allowed = sar.Status.Allowed || ns == "" || ns == sar.Spec.ResourceAttributes.Namespace
+		}
+		return true, sar, nil
+	})
+
+	r := gin.New()
+	api := r.Group("/api", func(c *gin.Context) {
+		auth.SetUser(c, auth.User{Name: c.GetHeader("X-User")})
+	})
+	h := &Handler{Client: c, Authz: authz.New(kube.AuthorizationV1().SubjectAccessReviews())}
+	h.Register(api)
+
+	list := func(user, path string) (int, []Target) {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("X-User", user)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		var res struct{ Items []Target }
+		_ = json.Unmarshal(w.Body.Bytes(), &res)
+		return w.Code, res.Items
+	}
+
+	code, items := list("alice", "/api/connections/team-a")
+	if code != http.StatusOK || len(items) != 2 {
+		t.Fatalf("team-a = %d %+v", code, items)
+	}
+	want := Target{Namespace: "team-a", Name: "legacy", Engine: "mysql", Host: "legacy.db", Port: 5432, Database: "legacy", User: "reader"}
+	if items[0] != want || !items[1].Console || items[1].Engine != "postgres" {
+		t.Errorf("targets = %+v", items)
+	}
+	if code, _ := list("alice", "/api/connections/team-b"); code != http.StatusForbidden {
+		t.Errorf("team-b = %d, want 403", code)
+	}
+
+	if _, items := list("alice", "/api/connections"); len(items) != 2 || items[0].Namespace != "team-a" || items[1].Namespace != "team-a" {
+		t.Errorf("alice's targets = %+v, want team-a's only", items)
+	}
+	if _, items := list("admin", "/api/connections"); len(items) != 3 || items[2].Name != "billing" {
+		t.Errorf("admin's targets = %+v, want every namespace's", items)
+	}
+	if _, items := list("mallory", "/api/connections"); len(items) != 0 {
+		t.Errorf("mallory's targets = %+v, want none", items)
+	}
+}
@@ -0,0 +1,169 @@
+package connections
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	kubequeryv1beta1 "github.com/rsavage/KubeQuery/api/v1beta1"
+	"github.com/rsavage/KubeQuery/pkg/connection"
+	"github.com/rsavage/KubeQuery/pkg/db"
+	"github.com/rsavage/KubeQuery/pkg/redact"
+)
+
+// DefaultIdleTimeout is how long a pool may go unused before it is closed,
+// when Pools.IdleTimeout is not set.
+const DefaultIdleTimeout = 10 * time.Minute
+
+// Pools keeps a pool of database connections per PostgresConnection. A pool
+// is opened when its connection is first used and closed once it has gone
+// unused for IdleTimeout. The connection's settings, and the Secrets they
+// reference, are resolved on every Get, so a pool is replaced as soon as
+// they change: a rotated password or CA bundle takes effect with the next
+// statement, without restarting ui-service.
+type Pools struct {
+	// Reader reads the Secrets that PostgresConnections reference.
+	Reader client.Reader
+	// ApplicationName is the default application_name of the sessions.
+	ApplicationName string
+	// Connect opens a pool; db.Connect when nil.
+	Connect func(ctx context.Context, cfg db.ConnConfig) (db.Pool, error)
+	// IdleTimeout is how long a pool may go unused before it is closed;
+	// DefaultIdleTimeout when 0.
+	IdleTimeout time.Duration
+
+	mu    sync.Mutex
+	pools map[types.NamespacedName]*pool
+}
+
+type pool struct {
+	db.Pool
+	// settings identifies the resolved settings the pool was opened with.
+	settings string
+	red      *redact.Redactor
+	lastUsed time.Time
+}
+
+// Get returns the pool of pc, opening it if needed, and a redactor that
+// knows every password the pool has used. The pool is shared: callers must
+// release the connections they acquire, but not close it.
+func (p *Pools) Get(ctx context.Context, pc *kubequeryv1beta1.PostgresConnection) (db.Pool, *redact.Redactor, error) {
+	red := redact.New()
+	resolver := &connection.Resolver{Reader: p.Reader, ApplicationName: p.ApplicationName}
+	cfg, _, err := resolver.Resolve(ctx, pc.Namespace, &pc.Spec, red)
+	if err != nil {
+		return nil, red, err
+	}
+	settings, err := fingerprint(cfg)
+	if err != nil {
+		return nil, red, err
+	}
+
+	key := types.NamespacedName{Namespace: pc.Namespace, Name: pc.Name}
+	if cached := p.cached(key, settings); cached != nil {
+		return cached.Pool, cached.red, nil
+	}
+
+	// Connecting waits for the database, so it happens without the lock:
+	// an unreachable database must not hold up the pools of others.
+	connect := p.Connect
+	if connect == nil {
+		connect = db.Connect
+	}
+	opened, err := connect(ctx, cfg)
+	if err != nil {
+		return nil, red, err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if cached, ok := p.pools[key]; ok {
+		if cached.settings == settings {
+			// A concurrent Get opened the same pool first.
+			cached.lastUsed = time.Now()
+			go opened.Close()
+			return cached.Pool, cached.red, nil
+		}
+		delete(p.pools, key)
+		go cached.Close()
+	}
+	if p.pools == nil {
+		p.pools = map[types.NamespacedName]*pool{}
+	}
+	p.pools[key] = &pool{Pool: opened, settings: settings, red: red, lastUsed: time.Now()}
+	return opened, red, nil
+}
+
+// cached returns the pool of key if it was opened with settings, after
+// closing the idle pools.
+func (p *Pools) cached(key types.NamespacedName, settings string) *pool {
+	now := time.Now()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.closeIdle(now)
+	cached, ok := p.pools[key]
+	if !ok || cached.settings != settings {
+		return nil
+	}
+	cached.lastUsed = now
+	return cached
+}
+
+// closeIdle closes the pools unused since IdleTimeout before now. Pools are
+// closed in the background, since closing waits for the connections in use.
+func (p *Pools) closeIdle(now time.Time) {
+	timeout := p.IdleTimeout
+	if timeout <= 0 {
+		timeout = DefaultIdleTimeout
+	}
+	for key, cached := range p.pools {
+		if now.Sub(cached.lastUsed) > timeout {
+			delete(p.pools, key)
+			go cached.Close()
+		}
+	}
+}
+
+// Len returns the number of pools open.
+func (p *Pools) Len() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.pools)
+}
+
+// Close closes every pool.
+func (p *Pools) Close() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for key, cached := range p.pools {
+		delete(p.pools, key)
+		cached.Close()
+	}
+}
+
+// fingerprint returns a digest of the settings of cfg, including a static
+// password. Credentials read for every new connection, such as tokens, are
+// left out: pools pick up their changes by themselves.
+func fingerprint(cfg db.ConnConfig) (string, error) {
+	if static, ok := cfg.Credentials.(db.StaticPassword); ok {
+		cfg.Password = string(static)
+	}
+	credentials := fmt.Sprintf("%T", cfg.Credentials)
+	cfg.Credentials = nil
+	b, err := json.Marshal(struct {
+		Config      db.ConnConfig
+		Credentials string
+	}{cfg, credentials})
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:]), nil
+}
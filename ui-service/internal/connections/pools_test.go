@@ -0,0 +1,164 @@
+package connections
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	kubequeryv1beta1 "github.com/rsavage/KubeQuery/api/v1beta1"
+	"github.com/rsavage/KubeQuery/pkg/db"
+	"github.com/rsavage/KubeQuery/pkg/db/dbtest"
+	"github.com/rsavage/KubeQuery/pkg/redact"
+)
+
+func postgresConnection(namespace, name, engine string) *kubequeryv1beta1.PostgresConnection {
+	return &kubequeryv1beta1.PostgresConnection{
+		ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name},
+		Spec: kubequeryv1beta1.PostgresConnectionSpec{
+			Engine:            engine,
+			Host:              name + ".db",
+			Port:              5432,
+			Database:          name,
+			User:              "reader",
+			PasswordSecretRef: &kubequeryv1beta1.SecretKeySelector{Name: "db", Key: "password"},
+		},
+	}
+}
+
+func TestPools(t *testing.T) {
+	ctx := context.Background()
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "team-a", Name: "db"},
+		Data:       map[string][]byte{"password": []byte("first-pass")},
+	}
+	c := fake.NewClientBuilder().WithObjects(secret).Build()
+	driver := &dbtest.Driver{}
+	pools := &Pools{Reader: c, ApplicationName: "kubequery-ui", Connect: driver.Connect}
+	orders := postgresConnection("team-a", "orders", "")
+
+	first, _, err := pools.Get(ctx, orders)
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, red, err := pools.Get(ctx, orders)
+	if err != nil || again != first {
+		t.Fatalf("got a new pool for unchanged settings: %v", err)
+	}
+	if got := red.String("first-pass"); got != redact.Placeholder {
+		t.Errorf("redactor of a reused pool does not know its password: %q", got)
+	}
+	if len(driver.Configs()) != 1 {
+		t.Errorf("connected %d times, want once", len(driver.Configs()))
+	}
+
+	// Secrets are read on every Get, so a rotated password replaces the pool.
+	secret.Data["password"] = []byte("second-pass")
+	if err := c.Update(ctx, secret); err != nil {
+		t.Fatal(err)
+	}
+	if rotated, _, err := pools.Get(ctx, orders); err != nil || rotated == first {
+		t.Fatalf("pool not replaced after the password changed: %v", err)
+	}
+	cfgs := driver.Configs()
+	if password, _ := cfgs[len(cfgs)-1].Credentials.Password(ctx); password != "second-pass" {
+		t.Errorf("password = %q, want the rotated one", password)
+	}
+
+	if _, _, err := pools.Get(ctx, postgresConnection("team-a", "billing", "")); err != nil {
+		t.Fatal(err)
+	}
+	if pools.Len() != 2 {
+		t.Errorf("got %d pools, want one per connection", pools.Len())
+	}
+	if _, _, err := pools.Get(ctx, postgresConnection("team-b", "orders", "")); err == nil {
+		t.Error("resolved a Secret of another namespace")
+	}
+
+	pools.IdleTimeout = time.Millisecond
+	time.Sleep(5 * time.Millisecond)
+	if _, _, err := pools.Get(ctx, orders); err != nil {
+		t.Fatal(err)
+	}
+	if pools.Len() != 1 {
+		t.Errorf("got %d pools, want the idle ones closed", pools.Len())
+	}
+}
+
+// closeCounter is a pool that counts how often it is closed.
+type closeCounter struct {
+	db.Pool
+	closed *atomic.Int32
+}
+
+func (p closeCounter) Close() {
+	p.closed.Add(1)
+	p.Pool.Close()
+}
+
+func TestPoolsConnectWithoutLock(t *testing.T) {
+	ctx := context.Background()
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "team-a", Name: "db"},
+		Data:       map[string][]byte{"password": []byte("pass")},
+	}
+	driver := &dbtest.Driver{}
+	var closed atomic.Int32
+	connecting := make(chan struct{}, 2)
+	unblock := make(chan struct{})
+	pools := &Pools{
+		Reader: fake.NewClientBuilder().WithObjects(secret).Build(),
+		Connect: func(ctx context.Context, cfg db.ConnConfig) (db.Pool, error) {
+			if cfg.Host == "orders.db" {
+				connecting <- struct{}{}
+				<-unblock
+			}
+			opened, err := driver.Connect(ctx, cfg)
+			return closeCounter{opened, &closed}, err
+		},
+	}
+	orders := postgresConnection("team-a", "orders", "")
+
+	var wg sync.WaitGroup
+	got := make([]db.Pool, 2)
+	for i := range got {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			pool, _, err := pools.Get(ctx, orders)
+			if err != nil {
+				t.Error(err)
+			}
+			got[i] = pool
+		}()
+	}
+	<-connecting
+	<-connecting
+
+	// Both Gets wait for the database of orders; billing's pool opens
+	// meanwhile.
+	if _, _, err := pools.Get(ctx, postgresConnection("team-a", "billing", "")); err != nil {
+		t.Fatal(err)
+	}
+	close(unblock)
+	wg.Wait()
+
+	if got[0] == nil || got[0] != got[1] {
+		t.Errorf("concurrent Gets returned different pools")
+	}
+	if pools.Len() != 2 {
+		t.Errorf("got %d pools, want one per connection", pools.Len())
+	}
+	deadline := time.Now().Add(time.Second)
+	for closed.Load() != 1 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if n := closed.Load(); n != 1 {
+		t.Errorf("closed %d pools, want the one that lost the race", n)
+	}
+}
@@ -0,0 +1,433 @@
+// Package console serves the read-only SQL console of the UI. Unlike queries
+// submitted as PostgresQuery objects, console statements run directly from
+// ui-service, so they are confined to what cannot change data: each one runs
+// in a read-only transaction that is always rolled back, bounded by a
+// server-side statement_timeout, and its result is cut off at a row and a
+// byte limit. Changes to data must go through the PostgresQuery workflow.
+package console
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	kubequeryv1beta1 "github.com/rsavage/KubeQuery/api/v1beta1"
+	"github.com/rsavage/KubeQuery/pkg/db"
+	"github.com/rsavage/KubeQuery/pkg/redact"
+
+	"github.com/rsavage/KubeQuery/ui-service/internal/audit"
+	"github.com/rsavage/KubeQuery/ui-service/internal/auth"
+	"github.com/rsavage/KubeQuery/ui-service/internal/authz"
+	"github.com/rsavage/KubeQuery/ui-service/internal/connections"
+)
+
+// ApplicationName identifies console sessions in pg_stat_activity.
+const ApplicationName = "kubequery-ui"
+
+// connectTimeout bounds connecting to the database when a statement needs
+// a new session, on top of the statement timeout.
+const connectTimeout = 10 * time.Second
+
+// Limits bound what a console statement may cost.
+type Limits struct {
+	// StatementTimeout is set as statement_timeout for every statement.
+	StatementTimeout time.Duration
+	// MaxRows is the most rows returned.
+	MaxRows int
+	// MaxBytes is the most bytes of values returned, counted as text.
+	MaxBytes int
+}
+
+// DefaultLimits are used for limits that are not set.
+var DefaultLimits = Limits{
+	StatementTimeout: 30 * time.Second,
+	MaxRows:          1000,
+	MaxBytes:         4 << 20,
+}
+
+// DefaultExportLimits are used for export limits that are not set. Exports
+// are written to files rather than shown, so they allow far more.
+var DefaultExportLimits = Limits{
+	StatementTimeout: 5 * time.Minute,
+	MaxRows:          1_000_000,
+	MaxBytes:         1 << 30,
+}
+
+// Column describes a column of a result.
+type Column struct {
+	Name string `json:"name"`
+	// Type is the database type name, e.g. int4 or text.
+	Type string `json:"type"`
+	// OID is the PostgreSQL type OID.
+	OID uint32 `json:"oid"`
+}
+
+// Writer receives a result as it is read.
+type Writer interface {
+	// Columns is called once, before any row.
+	Columns(cols []Column) error
+	// Row is called for each row with its values in column order, both as
+	// returned by db.Rows.Values (raw) and encoded by Value.
+	Row(raw, values []any) error
+}
+
+// Summary describes a result passed to a Writer.
+type Summary struct {
+	RowCount int `json:"rowCount"`
+	// Truncated is set when rows were left out to stay within the limits.
+	Truncated bool `json:"truncated,omitempty"`
+	// DurationMillis is how long the statement took.
+	DurationMillis int64 `json:"durationMillis"`
+}
+
+// Result is a console result read into memory, as returned by the console
+// endpoint by default.
+type Result struct {
+	Columns []Column `json:"columns"`
+	Rows    [][]any  `json:"rows"`
+	Summary
+}
+
+// resultWriter is a Writer filling a Result.
+type resultWriter struct {
+	res *Result
+}
+
+func (w resultWriter) Columns(cols []Column) error {
+	w.res.Columns = cols
+	return nil
+}
+
+func (w resultWriter) Row(_, values []any) error {
+	w.res.Rows = append(w.res.Rows, values)
+	return nil
+}
+
+// Stream runs sql with args on pool in a read-only transaction, which the server
+// rejects writes in, rolls it back, and passes the result to w row by row,
+// so that results of any size are never held in memory. Rows beyond
+// limits.MaxRows or limits.MaxBytes are left out and the statement is
+// cancelled. The PostgreSQL driver sends sql as a single extended-protocol
+// statement, so it cannot end the transaction and write in a second
+// statement.
+func Stream(ctx context.Context, pool db.Pool, sql string, args []any, limits Limits, w Writer) (Summary, error) {
+	start := time.Now()
+	conn, err := pool.Acquire(ctx)
+	if err != nil {
+		return Summary{}, err
+	}
+	defer conn.Release()
+	tx, err := conn.Begin(ctx, db.TxOptions{ReadOnly: true})
+	if err != nil {
+		return Summary{}, err
+	}
+	// Nothing was written, so a failed rollback loses nothing.
+	defer func() { _ = tx.Rollback(context.WithoutCancel(ctx)) }()
+	if _, err := tx.Exec(ctx, fmt.Sprintf("SET LOCAL statement_timeout = %d", limits.StatementTimeout.Milliseconds())); err != nil {
+		return Summary{}, err
+	}
+
+	// Cancelling the statement once the limits are reached spares reading
+	// the rows left out.
+	queryCtx, cancelQuery := context.WithCancel(ctx)
+	defer cancelQuery()
+	rows, err := tx.Query(queryCtx, sql, args...)
+	if err != nil {
+		return Summary{}, err
+	}
+	defer rows.Close()
+	cols := make([]Column, 0, len(rows.Columns()))
+	for _, col := range rows.Columns() {
+		cols = append(cols, Column{Name: col.Name, Type: col.Type, OID: col.OID})
+	}
+	if err := w.Columns(cols); err != nil {
+		return Summary{}, err
+	}
+	var summary Summary
+	size := 0
+	for rows.Next() {
+		if summary.RowCount == limits.MaxRows {
+			summary.Truncated = true
+			break
+		}
+		raw, err := rows.Values()
+		if err != nil {
+			return Summary{}, err
+		}
+		values := make([]any, len(raw))
+		for i, v := range raw {
+			values[i] = Value(v, cols[i])
+			size += len(text(values[i]))
+		}
+		if size > limits.MaxBytes {
+			summary.Truncated = true
+			break
+		}
+		if err := w.Row(raw, values); err != nil {
+			return Summary{}, err
+		}
+		summary.RowCount++
+	}
+	if summary.Truncated {
+		cancelQuery()
+	} else if err := rows.Err(); err != nil {
+		return Summary{}, err
+	}
+	summary.DurationMillis = time.Since(start).Milliseconds()
+	return summary, nil
+}
+
+// Handler serves the console endpoint.
+type Handler struct {
+	// Client reads PostgresConnections.
+	Client client.Client
+	Authz  *authz.Authorizer
+	Limits Limits
+	// ExportLimits bound exports; DefaultExportLimits for those not set.
+	ExportLimits Limits
+	// Audit records statements and exports; audit.Default when nil.
+	Audit *audit.Log
+	// History, if set, records statements and exports for their users.
+	History History
+	// Pools holds the pools of the connections statements run against.
+	Pools *connections.Pools
+}
+
+// Register adds the console route to r, which must run behind
+// auth.Authenticator.Middleware:
+//
+//	POST /console/:namespace  run a read-only statement; with ?format=ndjson
+//	                          or Accept: application/x-ndjson, the result is
+//	                          streamed as newline-delimited JSON
+//	POST /console/:namespace/export?format=csv|ndjson|parquet
+//	                          run a read-only statement and download its
+//	                          result as a file
+//	POST /console/:namespace/explain
+//	                          run EXPLAIN of a statement and return its
+//	                          annotated plan
+func (h *Handler) Register(r gin.IRouter) {
+	require := h.Authz.Require(authz.ActionConsole, authz.Param("namespace"))
+	r.POST("/console/:namespace", require, h.run)
+	r.POST("/console/:namespace/export", require, h.export)
+	r.POST("/console/:namespace/explain", require, h.explain)
+}
+
+// Request is the body of POST /console/:namespace. Exports accept it as
+// form fields as well, so that browsers can download them with a plain form.
+type Request struct {
+	// ConnectionRef names a PostgresConnection in the namespace.
+	ConnectionRef string `json:"connectionRef" form:"connectionRef"`
+	SQL           string `json:"sql" form:"sql"`
+	// Args are the values of the statement's $1, $2, ... parameters. They
+	// are sent as text, which the server converts to the parameters' types.
+	Args []string `json:"args,omitempty" form:"args"`
+}
+
+// History keeps the statements users ran, for them to look back on.
+type History interface {
+	// Add records r, the audit record of a statement.
+	Add(ctx context.Context, r audit.Record) error
+}
+
+// historyTimeout bounds recording a statement in the History.
+const historyTimeout = 5 * time.Second
+
+func (h *Handler) run(c *gin.Context) {
+	var req Request
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+	h.Execute(c, c.Param("namespace"), req)
+}
+
+// Execute runs req against a PostgresConnection of namespace and writes its
+// result, as POST /console/:namespace does. The caller must have checked
+// that the user may use the console in namespace.
+func (h *Handler) Execute(c *gin.Context, namespace string, req Request) {
+	limits := h.limits()
+	pool, red, ok := h.connect(c, namespace, req)
+	if !ok {
+		return
+	}
+	ctx, cancel := context.WithTimeout(c.Request.Context(), connectTimeout+limits.StatementTimeout)
+	defer cancel()
+
+	var w Writer = resultWriter{&Result{Rows: [][]any{}}}
+	if c.Query("format") == "ndjson" || strings.Contains(c.GetHeader("Accept"), ndjsonContentType) {
+		w = &ndjsonWriter{c: c, enc: json.NewEncoder(c.Writer)}
+	}
+	summary, err := Stream(ctx, pool, req.SQL, req.args(), limits, w)
+	h.record(c, namespace, audit.ActionConsole, "", req, summary, red, err)
+	if err != nil {
+		if nd, ok := w.(*ndjsonWriter); ok && nd.started {
+			_ = nd.enc.Encode(gin.H{"error": red.Error(err)})
+			return
+		}
+		c.JSON(errorStatus(err), gin.H{"error": red.Error(err)})
+		return
+	}
+	switch w := w.(type) {
+	case *ndjsonWriter:
+		_ = w.enc.Encode(summary)
+	case resultWriter:
+		w.res.Summary = summary
+		c.JSON(http.StatusOK, w.res)
+	}
+}
+
+// connect validates req and returns the pool of its PostgresConnection in
+// namespace ns. If
+// it cannot, it writes the error response and returns false. The redactor
+// returned knows the connection's secrets.
+func (h *Handler) connect(c *gin.Context, ns string, req Request) (db.Pool, *redact.Redactor, bool) {
+	if strings.TrimSpace(req.SQL) == "" || req.ConnectionRef == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "sql and connectionRef are required"})
+		return nil, nil, false
+	}
+	ctx := c.Request.Context()
+	var pc kubequeryv1beta1.PostgresConnection
+	if err := h.Client.Get(ctx, types.NamespacedName{Namespace: ns, Name: req.ConnectionRef}, &pc); err != nil {
+		status := http.StatusInternalServerError
+		if apierrors.IsNotFound(err) {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, gin.H{"error": fmt.Sprintf("failed to get PostgresConnection: %v", err)})
+		return nil, nil, false
+	}
+	// MySQL connections accept several statements at once, which could
+	// commit the read-only transaction and write after it.
+	if engine := db.Engine(pc.Spec.Engine); engine != "" && engine != db.EnginePostgres {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("the console does not support %s connections", engine)})
+		return nil, nil, false
+	}
+
+	pool, red, err := h.Pools.Get(ctx, &pc)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": red.Error(err)})
+		return nil, nil, false
+	}
+	return pool, red, true
+}
+
+// errorStatus returns the status of a response to a statement that failed
+// with err.
+func errorStatus(err error) int {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return http.StatusGatewayTimeout
+	}
+	return http.StatusBadRequest
+}
+
+// args returns r.Args as statement arguments.
+func (r Request) args() []any {
+	args := make([]any, len(r.Args))
+	for i, a := range r.Args {
+		args[i] = a
+	}
+	return args
+}
+
+// record adds a statement run by action in namespace ns to the audit trail
+// and the History.
+func (h *Handler) record(c *gin.Context, ns, action, format string, req Request, summary Summary, red *redact.Redactor, err error) {
+	user, _ := auth.UserFrom(c)
+	r := audit.Record{
+		Time:           time.Now().UTC(),
+		User:           user.Name,
+		Action:         action,
+		Namespace:      ns,
+		Connection:     req.ConnectionRef,
+		SQL:            req.SQL,
+		SQLHash:        audit.Hash(req.SQL),
+		Args:           req.Args,
+		Format:         format,
+		RowCount:       summary.RowCount,
+		Truncated:      summary.Truncated,
+		DurationMillis: summary.DurationMillis,
+	}
+	if err != nil {
+		r.Error = red.Error(err)
+	}
+	l := h.Audit
+	if l == nil {
+		l = audit.Default
+	}
+	l.Record(r)
+	if h.History != nil {
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(c.Request.Context()), historyTimeout)
+		defer cancel()
+		if err := h.History.Add(ctx, r); err != nil {
+			log.Printf("console: failed to record the history of %s: %v", user.Name, err)
+		}
+	}
+}
+
+// ndjsonContentType is the media type of newline-delimited JSON.
+const ndjsonContentType = "application/x-ndjson"
+
+// ndjsonFlushRows is how many rows an NDJSON stream sends between flushes.
+const ndjsonFlushRows = 100
+
+// ndjsonWriter streams a result as newline-delimited JSON: an object with
+// the columns, an array of values per row, and a final object with the
+// Summary or, if the statement failed midway, the error.
+type ndjsonWriter struct {
+	c       *gin.Context
+	enc     *json.Encoder
+	rows    int
+	started bool
+}
+
+func (w *ndjsonWriter) Columns(cols []Column) error {
+	w.c.Header("Content-Type", ndjsonContentType)
+	w.c.Header("X-Accel-Buffering", "no")
+	w.c.Status(http.StatusOK)
+	w.started = true
+	return w.enc.Encode(gin.H{"columns": cols})
+}
+
+func (w *ndjsonWriter) Row(_, values []any) error {
+	if err := w.enc.Encode(values); err != nil {
+		return err
+	}
+	if w.rows++; w.rows%ndjsonFlushRows == 0 {
+		w.c.Writer.Flush()
+	}
+	return nil
+}
+
+// limits returns h.Limits with DefaultLimits for those not set.
+func (h *Handler) limits() Limits {
+	return h.Limits.orDefault(DefaultLimits)
+}
+
+// exportLimits returns h.ExportLimits with DefaultExportLimits for those
+// not set.
+func (h *Handler) exportLimits() Limits {
+	return h.ExportLimits.orDefault(DefaultExportLimits)
+}
+
+// orDefault returns l with the limits of def for those not set.
+func (l Limits) orDefault(def Limits) Limits {
+	if l.StatementTimeout <= 0 {
+		l.StatementTimeout = def.StatementTimeout
+	}
+	if l.MaxRows <= 0 {
+		l.MaxRows = def.MaxRows
+	}
+	if l.MaxBytes <= 0 {
+		l.MaxBytes = def.MaxBytes
+	}
+	return l
+}
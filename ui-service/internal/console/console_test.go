@@ -0,0 +1,237 @@
+package console
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	k8stesting "k8s.io/client-go/testing"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	kubequeryv1beta1 "github.com/rsavage/KubeQuery/api/v1beta1"
+	"github.com/rsavage/KubeQuery/pkg/db"
+	"github.com/rsavage/KubeQuery/pkg/db/dbtest"
+
+	"github.com/rsavage/KubeQuery/ui-service/internal/audit"
+	"github.com/rsavage/KubeQuery/ui-service/internal/auth"
+	"github.com/rsavage/KubeQuery/ui-service/internal/authz"
+	"github.com/rsavage/KubeQuery/ui-service/internal/connections"
+)
+
+// grants maps users to the verbs RBAC allows them in every namespace.
+var grants = map[string][]string{
+	"viewer":    {"list"},
+	"analyst":   {"list", "console"},
+	"developer": {"list", "console", "create"},
+}
+
+func postgresConnection(name, engine string) *kubequeryv1beta1.PostgresConnection {
+	return &kubequeryv1beta1.PostgresConnection{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "team-a", Name: name},
+		Spec: kubequeryv1beta1.PostgresConnectionSpec{
+			Engine:            engine,
+			Host:              "db",
+			Port:              5432,
+			Database:          "orders",
+			User:              "reader",
+			PasswordSecretRef: &kubequeryv1beta1.SecretKeySelector{Name: "orders-db", Key: "password"},
+		},
+	}
+}
+
+func newServer(t *testing.T, driver *dbtest.Driver, limits Limits, auditLog io.Writer) http.Handler {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	if err := kubequeryv1beta1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+		postgresConnection("orders", ""),
+		postgresConnection("legacy", "mysql"),
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "team-a", Name: "orders-db"},
+			Data:       map[string][]byte{"password": []byte("s3cr3t-pass")},
+		},
+	).Build()
+
+	kube := kubefake.NewSimpleClientset()
+	kube.PrependReactor("create", "subjectaccessreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		sar := action.(k8stesting.CreateAction).GetObject().(*authorizationv1.SubjectAccessReview)
+		for _, verb := range grants[sar.Spec.User] {
+			sar.Status.Allowed = sar.Status.Allowed || verb == sar.Spec.ResourceAttributes.Verb
+		}
+		return true, sar, nil
+	})
+
+	r := gin.New()
+	api := r.Group("/api", func(c *gin.Context) {
+		auth.SetUser(c, auth.User{Name: c.GetHeader("X-User")})
+	})
+	h := &Handler{
+		Client:       c,
+		Authz:        authz.New(kube.AuthorizationV1().SubjectAccessReviews()),
+		Limits:       limits,
+		ExportLimits: limits,
+		Audit:        audit.New(auditLog),
+		Pools:        &connections.Pools{Reader: c, ApplicationName: ApplicationName, Connect: driver.Connect},
+	}
+	h.Register(api)
+	return r
+}
+
+func run(r http.Handler, user, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/api/console/team-a", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-User", user)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestRun(t *testing.T) {
+	driver := &dbtest.Driver{Handler: func(_ context.Context, sql string, _ []any) (dbtest.Result, error) {
+		if !strings.HasPrefix(sql, "SELECT") {
+			return dbtest.Result{Tag: "SET"}, nil
+		}
+		return dbtest.Result{
+			Columns: []db.Column{{Name: "id", Type: "int8", OID: 20}, {Name: "status", Type: "text", OID: 25}},
+			Rows:    [][]any{{int64(1), "open"}, {int64(2), "shipped"}, {int64(3), "open"}},
+		}, nil
+	}}
+	r := newServer(t, driver, Limits{StatementTimeout: 5 * time.Second, MaxRows: 2}, io.Discard)
+
+	body := `{"connectionRef":"orders","sql":"SELECT id, status FROM orders"}`
+	if w := run(r, "viewer", body); w.Code != http.StatusForbidden {
+		t.Fatalf("viewer run = %d, want 403", w.Code)
+	}
+	w := run(r, "analyst", body)
+	if w.Code != http.StatusOK {
+		t.Fatalf("analyst run = %d: %s", w.Code, w.Body)
+	}
+	var res Result
+	if err := json.Unmarshal(w.Body.Bytes(), &res); err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Columns) != 2 || res.Columns[0] != (Column{Name: "id", Type: "int8", OID: 20}) {
+		t.Errorf("columns = %+v", res.Columns)
+	}
+	if len(res.Rows) != 2 || res.RowCount != 2 || !res.Truncated {
+		t.Errorf("rows = %v, truncated = %v; want 2 rows, truncated", res.Rows, res.Truncated)
+	}
+	if row := res.Rows[1]; row[0] != "2" || row[1] != "shipped" {
+		t.Errorf("row = %#v, want int8 as a string, in column order", row)
+	}
+
+	want := []string{"BEGIN READ ONLY", "SET LOCAL statement_timeout = 5000", "SELECT id, status FROM orders", "ROLLBACK"}
+	if got := driver.Statements(); strings.Join(got, "; ") != strings.Join(want, "; ") {
+		t.Errorf("statements = %q, want %q", got, want)
+	}
+	cfg := driver.Configs()[0]
+	if cfg.Host != "db" || cfg.User != "reader" || cfg.RuntimeParams["application_name"] != ApplicationName {
+		t.Errorf("config = %+v", cfg)
+	}
+	if driver.OpenSessions() != 0 {
+		t.Error("session not released")
+	}
+}
+
+func TestRunNDJSON(t *testing.T) {
+	driver := &dbtest.Driver{Handler: func(_ context.Context, sql string, _ []any) (dbtest.Result, error) {
+		rows := make([][]any, 250)
+		for i := range rows {
+			rows[i] = []any{int32(i), []byte{byte(i)}}
+		}
+		return dbtest.Result{Columns: []db.Column{{Name: "n", Type: "int4"}, {Name: "b", Type: "bytea"}}, Rows: rows}, nil
+	}}
+	r := newServer(t, driver, Limits{MaxRows: 200}, io.Discard)
+	req := httptest.NewRequest(http.MethodPost, "/api/console/team-a", strings.NewReader(`{"connectionRef":"orders","sql":"SELECT n, b FROM t"}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/x-ndjson")
+	req.Header.Set("X-User", "analyst")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if ct := w.Header().Get("Content-Type"); w.Code != http.StatusOK || ct != "application/x-ndjson" {
+		t.Fatalf("run = %d %s: %s", w.Code, ct, w.Body)
+	}
+
+	lines := strings.Split(strings.TrimSpace(w.Body.String()), "\n")
+	if len(lines) != 202 {
+		t.Fatalf("got %d lines, want columns, 200 rows and a summary", len(lines))
+	}
+	var header struct{ Columns []Column }
+	if err := json.Unmarshal([]byte(lines[0]), &header); err != nil || len(header.Columns) != 2 {
+		t.Errorf("header = %s", lines[0])
+	}
+	if lines[11] != `[10,"\\x0a"]` {
+		t.Errorf("row 10 = %s", lines[11])
+	}
+	var summary Summary
+	if err := json.Unmarshal([]byte(lines[201]), &summary); err != nil || summary.RowCount != 200 || !summary.Truncated {
+		t.Errorf("summary = %s", lines[201])
+	}
+}
+
+func TestRunLimitsBytes(t *testing.T) {
+	driver := &dbtest.Driver{Handler: func(context.Context, string, []any) (dbtest.Result, error) {
+		return dbtest.Result{
+			Columns: []db.Column{{Name: "doc", Type: "text"}},
+			Rows:    [][]any{{strings.Repeat("a", 60)}, {strings.Repeat("b", 60)}},
+		}, nil
+	}}
+	r := newServer(t, driver, Limits{MaxBytes: 100}, io.Discard)
+	w := run(r, "analyst", `{"connectionRef":"orders","sql":"SELECT doc FROM docs"}`)
+	var res Result
+	if err := json.Unmarshal(w.Body.Bytes(), &res); err != nil || w.Code != http.StatusOK {
+		t.Fatalf("run = %d: %s", w.Code, w.Body)
+	}
+	if len(res.Rows) != 1 || !res.Truncated {
+		t.Errorf("rows = %d, truncated = %v; want 1 row, truncated", len(res.Rows), res.Truncated)
+	}
+}
+
+func TestRunErrors(t *testing.T) {
+	driver := &dbtest.Driver{Handler: func(_ context.Context, sql string, _ []any) (dbtest.Result, error) {
+		if strings.HasPrefix(sql, "DELETE") {
+			return dbtest.Result{}, errors.New("cannot execute DELETE in a read-only transaction")
+		}
+		return dbtest.Result{}, nil
+	}}
+	r := newServer(t, driver, Limits{}, io.Discard)
+
+	for _, tc := range []struct {
+		body string
+		code int
+	}{
+		{`{"connectionRef":"orders","sql":"DELETE FROM orders"}`, http.StatusBadRequest},
+		{`{"connectionRef":"orders","sql":" "}`, http.StatusBadRequest},
+		{`{"connectionRef":"missing","sql":"SELECT 1"}`, http.StatusNotFound},
+		{`{"connectionRef":"legacy","sql":"SELECT 1"}`, http.StatusBadRequest},
+	} {
+		if w := run(r, "analyst", tc.body); w.Code != tc.code {
+			t.Errorf("run %s = %d, want %d: %s", tc.body, w.Code, tc.code, w.Body)
+		}
+	}
+	if got := driver.Statements(); got[len(got)-1] != "ROLLBACK" {
+		t.Errorf("statements = %q, want the failed transaction rolled back", got)
+	}
+	if len(driver.Configs()) != 1 {
+		t.Errorf("connected %d times, want only for the orders connection", len(driver.Configs()))
+	}
+}
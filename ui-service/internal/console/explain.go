@@ -0,0 +1,78 @@
+package console
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/rsavage/KubeQuery/pkg/db"
+	"github.com/rsavage/KubeQuery/pkg/explain"
+
+	"github.com/rsavage/KubeQuery/ui-service/internal/audit"
+)
+
+// ExplainRequest is the body of POST /console/:namespace/explain.
+type ExplainRequest struct {
+	Request
+	// Analyze executes the statement to report actual rows and times.
+	Analyze bool `json:"analyze,omitempty"`
+	// Buffers reports buffer usage.
+	Buffers bool `json:"buffers,omitempty"`
+}
+
+func (h *Handler) explain(c *gin.Context) {
+	var req ExplainRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+	h.Explain(c, c.Param("namespace"), req)
+}
+
+// Explain runs EXPLAIN of req against a PostgresConnection of namespace and
+// writes the parsed plan, as POST /console/:namespace/explain does. The
+// caller must have checked that the user may use the console in namespace.
+//
+// EXPLAIN runs in a read-only transaction like any console statement, with
+// or without ANALYZE, so statements that write cannot be analyzed here: their
+// plans are reviewed through a PostgresQuery with options.explain, which is
+// subject to approval like any other.
+func (h *Handler) Explain(c *gin.Context, namespace string, req ExplainRequest) {
+	limits := h.limits()
+	pool, red, ok := h.connect(c, namespace, req.Request)
+	if !ok {
+		return
+	}
+	ctx, cancel := context.WithTimeout(c.Request.Context(), connectTimeout+limits.StatementTimeout)
+	defer cancel()
+
+	start := time.Now()
+	opts := explain.Options{Analyze: req.Analyze, Buffers: req.Buffers, StatementTimeout: limits.StatementTimeout}
+	var plan *explain.Plan
+	data, err := runExplain(ctx, pool, req, opts)
+	if err == nil {
+		plan, err = explain.Parse(data)
+	}
+	action := audit.ActionExplain
+	if req.Analyze {
+		action = audit.ActionExplainAnalyze
+	}
+	h.record(c, namespace, action, "", req.Request, Summary{DurationMillis: time.Since(start).Milliseconds()}, red, err)
+	if err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": red.Error(err)})
+		return
+	}
+	c.JSON(http.StatusOK, plan)
+}
+
+// runExplain runs EXPLAIN of req on a session of pool, read-only.
+func runExplain(ctx context.Context, pool db.Pool, req ExplainRequest, opts explain.Options) ([]byte, error) {
+	conn, err := pool.Acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Release()
+	return explain.Run(ctx, conn, req.SQL, req.args(), opts, true)
+}
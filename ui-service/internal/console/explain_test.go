@@ -0,0 +1,89 @@
+package console
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rsavage/KubeQuery/pkg/db"
+	"github.com/rsavage/KubeQuery/pkg/db/dbtest"
+	"github.com/rsavage/KubeQuery/pkg/explain"
+)
+
+const plan = `[{"Plan": {"Node Type": "ModifyTable", "Operation": "Delete", "Relation Name": "orders",
+  "Startup Cost": 0, "Total Cost": 35, "Plan Rows": 10, "Plan Width": 6,
+  "Actual Startup Time": 0.5, "Actual Total Time": 0.5, "Actual Rows": 0, "Actual Loops": 1,
+  "Plans": [{"Node Type": "Seq Scan", "Parent Relationship": "Outer", "Relation Name": "orders",
+    "Startup Cost": 0, "Total Cost": 35, "Plan Rows": 10, "Plan Width": 6,
+    "Actual Startup Time": 0.1, "Actual Total Time": 0.4, "Actual Rows": 400, "Actual Loops": 1}]},
+  "Execution Time": 0.6}]`
+
+func explainRequest(r http.Handler, user, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/api/console/team-a/explain", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-User", user)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestExplain(t *testing.T) {
+	driver := &dbtest.Driver{Handler: func(_ context.Context, sql string, args []any) (dbtest.Result, error) {
+		if !strings.HasPrefix(sql, "EXPLAIN") {
+			return dbtest.Result{Tag: "SET"}, nil
+		}
+		if strings.Contains(sql, "UPDATE") {
+			return dbtest.Result{}, errors.New("cannot execute UPDATE in a read-only transaction")
+		}
+		return dbtest.Result{Columns: []db.Column{{Name: "QUERY PLAN", Type: "json", OID: 114}}, Rows: [][]any{{plan}}}, nil
+	}}
+	var auditLog bytes.Buffer
+	r := newServer(t, driver, Limits{StatementTimeout: 5 * time.Second}, &auditLog)
+	body := `{"connectionRef":"orders","sql":"DELETE FROM orders WHERE status = $1","args":["void"],"analyze":true}`
+
+	if w := explainRequest(r, "viewer", body); w.Code != http.StatusForbidden {
+		t.Fatalf("viewer explain = %d, want 403", w.Code)
+	}
+	w := explainRequest(r, "analyst", body)
+	if w.Code != http.StatusOK {
+		t.Fatalf("analyst explain = %d: %s", w.Code, w.Body)
+	}
+	var p explain.Plan
+	if err := json.Unmarshal(w.Body.Bytes(), &p); err != nil {
+		t.Fatal(err)
+	}
+	scan := p.Root.Children[0]
+	if !p.Analyzed || scan.NodeType != "Seq Scan" || *scan.EstimateFactor != 40 || len(p.HotSpots) == 0 {
+		t.Errorf("plan = %s", w.Body)
+	}
+	want := []string{"BEGIN READ ONLY", "SET LOCAL statement_timeout = 5000", "EXPLAIN (FORMAT JSON, ANALYZE) DELETE FROM orders WHERE status = $1", "ROLLBACK"}
+	if got := driver.Statements(); strings.Join(got, "; ") != strings.Join(want, "; ") {
+		t.Errorf("statements = %q, want %q", got, want)
+	}
+	if !strings.Contains(auditLog.String(), `"action":"explain-analyze"`) || !strings.Contains(auditLog.String(), `"args":["void"]`) {
+		t.Errorf("audit log = %s", auditLog.String())
+	}
+
+	// The console cannot change data, not even for users who may create
+	// queries: their statements are analyzed read-only too, so writes fail.
+	update := `{"connectionRef":"orders","sql":"UPDATE orders SET status = 'void'","analyze":true}`
+	if w := explainRequest(r, "developer", update); w.Code != http.StatusBadRequest {
+		t.Fatalf("developer explain analyze of a write = %d, want 400: %s", w.Code, w.Body)
+	}
+	if got := driver.Statements(); got[len(want)] != "BEGIN READ ONLY" {
+		t.Errorf("developer statements = %q, want a read-only transaction", got)
+	}
+
+	if w := explainRequest(r, "analyst", `{"connectionRef":"legacy","sql":"SELECT 1"}`); w.Code != http.StatusBadRequest {
+		t.Errorf("explain on MySQL = %d, want 400", w.Code)
+	}
+	if driver.OpenSessions() != 0 {
+		t.Error("session not released")
+	}
+}
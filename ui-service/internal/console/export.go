@@ -0,0 +1,313 @@
+package console
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/rsavage/KubeQuery/ui-service/internal/audit"
+	"github.com/rsavage/KubeQuery/ui-service/internal/parquet"
+)
+
+// Export formats.
+const (
+	FormatCSV     = "csv"
+	FormatNDJSON  = "ndjson"
+	FormatParquet = "parquet"
+)
+
+var contentTypes = map[string]string{
+	FormatCSV:     "text/csv; charset=utf-8",
+	FormatNDJSON:  ndjsonContentType,
+	FormatParquet: "application/vnd.apache.parquet",
+}
+
+// export runs a statement like run, but writes its result as a file to
+// download, within ExportLimits:
+//
+//   - csv has a header row of column names, then values as text; NULL is an
+//     empty field
+//   - ndjson has an object per row, keyed by column name in column order,
+//     with values encoded by Value
+//   - parquet has a column per result column, typed by exportField
+//
+// The file is written as rows are read, so exports of any size take little
+// memory; only Parquet holds a row group at a time. Column names repeated in
+// a result get a _2, _3, ... suffix so that every column can be told apart.
+// An export that reaches its limits fails like one whose statement fails
+// midway, rather than leaving a file that silently lacks rows.
+func (h *Handler) export(c *gin.Context) {
+	format := c.Query("format")
+	if _, ok := contentTypes[format]; !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format must be csv, ndjson or parquet"})
+		return
+	}
+	var req Request
+	if err := c.ShouldBind(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+	ns := c.Param("namespace")
+	limits := h.exportLimits()
+	pool, red, ok := h.connect(c, ns, req)
+	if !ok {
+		return
+	}
+	ctx, cancel := context.WithTimeout(c.Request.Context(), connectTimeout+limits.StatementTimeout)
+	defer cancel()
+
+	w := &exportWriter{
+		c:        c,
+		format:   format,
+		filename: fmt.Sprintf("%s-%s.%s", req.ConnectionRef, time.Now().UTC().Format("20060102-150405"), format),
+	}
+	summary, err := Stream(ctx, pool, req.SQL, req.args(), limits, w)
+	if err == nil && summary.Truncated {
+		err = fmt.Errorf("result exceeds the export limit of %d rows or %d bytes", limits.MaxRows, limits.MaxBytes)
+	}
+	if err == nil {
+		err = w.close()
+	}
+	h.record(c, ns, audit.ActionExport, format, req, summary, red, err)
+	switch {
+	case err != nil && w.started:
+		abort(c)
+	case err != nil:
+		c.JSON(errorStatus(err), gin.H{"error": red.Error(err)})
+	}
+}
+
+// abort ends a response whose body was started by closing the connection
+// without completing it, so that the client sees the download fail rather
+// than a file that ends early.
+func abort(c *gin.Context) {
+	// Servers that cannot hijack, such as HTTP/2 ones, panic; their
+	// response ends as it is.
+	defer func() { _ = recover() }()
+	if conn, _, err := c.Writer.Hijack(); err == nil {
+		_ = conn.Close()
+	}
+}
+
+// exportWriter is a Writer writing a result as a file in format. The
+// response starts with the columns, once the statement has succeeded, so
+// that earlier errors are still reported as JSON.
+type exportWriter struct {
+	c        *gin.Context
+	format   string
+	filename string
+	started  bool
+
+	names   []string
+	csv     *csv.Writer
+	fields  []parquet.Field
+	parquet *parquet.Writer
+	record  []string
+	row     []any
+}
+
+func (w *exportWriter) Columns(cols []Column) error {
+	w.c.Header("Content-Type", contentTypes[w.format])
+	w.c.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": w.filename}))
+	w.c.Header("X-Accel-Buffering", "no")
+	w.c.Status(http.StatusOK)
+	w.started = true
+
+	w.names = uniqueNames(cols)
+	switch w.format {
+	case FormatCSV:
+		w.csv = csv.NewWriter(w.c.Writer)
+		w.record = make([]string, len(cols))
+		return w.csv.Write(w.names)
+	case FormatParquet:
+		w.fields = make([]parquet.Field, len(cols))
+		for i, col := range cols {
+			w.fields[i] = exportField(w.names[i], col)
+		}
+		w.row = make([]any, len(cols))
+		var err error
+		w.parquet, err = parquet.NewWriter(w.c.Writer, w.fields)
+		return err
+	}
+	return nil
+}
+
+func (w *exportWriter) Row(raw, values []any) error {
+	switch w.format {
+	case FormatCSV:
+		for i, v := range values {
+			w.record[i] = text(v)
+		}
+		return w.csv.Write(w.record)
+	case FormatParquet:
+		for i, v := range values {
+			pv, err := parquetValue(raw[i], v, w.fields[i])
+			if err != nil {
+				return fmt.Errorf("column %s: %w", w.names[i], err)
+			}
+			w.row[i] = pv
+		}
+		return w.parquet.Write(w.row)
+	}
+	return w.ndjsonRow(values)
+}
+
+// ndjsonRow writes values as an object, keeping the column order.
+func (w *exportWriter) ndjsonRow(values []any) error {
+	b := []byte{'{'}
+	for i, v := range values {
+		if i > 0 {
+			b = append(b, ',')
+		}
+		name, _ := json.Marshal(w.names[i])
+		value, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		b = append(append(append(b, name...), ':'), value...)
+	}
+	_, err := w.c.Writer.Write(append(b, '}', '\n'))
+	return err
+}
+
+// close finishes the file after the last row.
+func (w *exportWriter) close() error {
+	switch {
+	case w.csv != nil:
+		w.csv.Flush()
+		return w.csv.Error()
+	case w.parquet != nil:
+		return w.parquet.Close()
+	}
+	return nil
+}
+
+// uniqueNames returns the names of cols, with a _2, _3, ... suffix on
+// names repeated, as in SELECT 1, 1.
+func uniqueNames(cols []Column) []string {
+	names := make([]string, len(cols))
+	seen := make(map[string]bool, len(cols))
+	for i, col := range cols {
+		name := col.Name
+		for n := 2; seen[name]; n++ {
+			name = fmt.Sprintf("%s_%d", col.Name, n)
+		}
+		seen[name] = true
+		names[i] = name
+	}
+	return names
+}
+
+// exportField returns the Parquet column a result column is exported as:
+//
+//   - bool is BOOLEAN; int2 and int4 are INT32 and int8 is INT64
+//   - float4 is FLOAT and float8 is DOUBLE
+//   - date is DATE; timestamptz is a TIMESTAMP in microseconds adjusted to
+//     UTC and timestamp one that is not; infinity and -infinity are the
+//     largest and smallest values of the type, as PostgreSQL stores them
+//   - json and jsonb are JSON; bytea is a plain BYTE_ARRAY
+//   - everything else, including numeric, whose precision a result does
+//     not carry, is a STRING of the value's text
+func exportField(name string, col Column) parquet.Field {
+	f := parquet.Field{Name: name, Type: parquet.ByteArray, Logical: parquet.String}
+	switch col.Type {
+	case "bool":
+		f.Type, f.Logical = parquet.Boolean, parquet.None
+	case "int2":
+		f.Type, f.Logical = parquet.Int32, parquet.Int16
+	case "int4":
+		f.Type, f.Logical = parquet.Int32, parquet.None
+	case "int8":
+		f.Type, f.Logical = parquet.Int64, parquet.None
+	case "float4":
+		f.Type, f.Logical = parquet.Float, parquet.None
+	case "float8":
+		f.Type, f.Logical = parquet.Double, parquet.None
+	case "date":
+		f.Type, f.Logical = parquet.Int32, parquet.Date
+	case "timestamptz":
+		f.Type, f.Logical = parquet.Int64, parquet.Timestamp
+	case "timestamp":
+		f.Type, f.Logical = parquet.Int64, parquet.LocalTimestamp
+	case "json", "jsonb":
+		f.Logical = parquet.JSON
+	case "bytea":
+		f.Logical = parquet.None
+	}
+	return f
+}
+
+// parquetValue converts a value to a value of field: v is the value encoded
+// by Value, raw the value it was encoded from. Dates and timestamps are
+// converted from raw, as their text cannot be parsed back for years before
+// 1 AD or after 9999.
+func parquetValue(raw, v any, field parquet.Field) (any, error) {
+	if v == nil {
+		return nil, nil
+	}
+	switch field.Logical {
+	case parquet.String:
+		return text(v), nil
+	case parquet.JSON:
+		b, err := json.Marshal(v)
+		return b, err
+	case parquet.Date:
+		switch text(v) {
+		case "infinity":
+			return int32(math.MaxInt32), nil
+		case "-infinity":
+			return int32(math.MinInt32), nil
+		}
+		if t, ok := raw.(time.Time); ok {
+			days := t.Unix() / 86400
+			if t.Unix()%86400 < 0 {
+				days--
+			}
+			return int32(days), nil
+		}
+		return nil, fmt.Errorf("cannot export %v as %s", v, field.Type)
+	case parquet.Timestamp, parquet.LocalTimestamp:
+		switch text(v) {
+		case "infinity":
+			return int64(math.MaxInt64), nil
+		case "-infinity":
+			return int64(math.MinInt64), nil
+		}
+		if t, ok := raw.(time.Time); ok {
+			return t.UnixMicro(), nil
+		}
+		return nil, fmt.Errorf("cannot export %v as %s", v, field.Type)
+	}
+	switch field.Type {
+	case parquet.Boolean:
+		if b, ok := v.(bool); ok {
+			return b, nil
+		}
+	case parquet.Int32:
+		n, err := strconv.ParseInt(text(v), 10, 32)
+		return int32(n), err
+	case parquet.Int64:
+		return strconv.ParseInt(text(v), 10, 64)
+	case parquet.Float, parquet.Double:
+		f, err := strconv.ParseFloat(text(v), 64)
+		if field.Type == parquet.Float {
+			return float32(f), err
+		}
+		return f, err
+	case parquet.ByteArray:
+		if s, ok := v.(string); ok && strings.HasPrefix(s, `\x`) {
+			return hex.DecodeString(s[2:])
+		}
+	}
+	return nil, fmt.Errorf("cannot export %v as %s", v, field.Type)
+}
@@ -0,0 +1,206 @@
+package console
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rsavage/KubeQuery/pkg/db"
+	"github.com/rsavage/KubeQuery/pkg/db/dbtest"
+
+	"github.com/rsavage/KubeQuery/ui-service/internal/audit"
+	"github.com/rsavage/KubeQuery/ui-service/internal/parquet"
+)
+
+func export(r http.Handler, format, contentType, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/api/console/team-a/export?format="+format, strings.NewReader(body))
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("X-User", "analyst")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestExport(t *testing.T) {
+	at := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	driver := &dbtest.Driver{Handler: func(_ context.Context, sql string, _ []any) (dbtest.Result, error) {
+		if strings.HasPrefix(sql, "DELETE") {
+			return dbtest.Result{}, errors.New("cannot execute DELETE in a read-only transaction")
+		}
+		return dbtest.Result{
+			Columns: []db.Column{
+				{Name: "id", Type: "int8"}, {Name: "name", Type: "text"}, {Name: "name", Type: "text"},
+				{Name: "at", Type: "timestamptz"}, {Name: "n", Type: "int4"},
+			},
+			Rows: [][]any{
+				{int64(1), "a,b", "x", at, int32(7)},
+				{int64(2), nil, `say "hi"`, nil, nil},
+			},
+		}, nil
+	}}
+	var auditLog bytes.Buffer
+	r := newServer(t, driver, Limits{}, &auditLog)
+	body := `{"connectionRef":"orders","sql":"SELECT * FROM orders"}`
+
+	w := export(r, "csv", "application/json", body)
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "text/csv; charset=utf-8" {
+		t.Fatalf("csv export = %d %s: %s", w.Code, w.Header().Get("Content-Type"), w.Body)
+	}
+	if cd := w.Header().Get("Content-Disposition"); !strings.HasPrefix(cd, `attachment; filename=orders-`) || !strings.HasSuffix(cd, `.csv`) {
+		t.Errorf("Content-Disposition = %q", cd)
+	}
+	want := "id,name,name_2,at,n\n1,\"a,b\",x,2025-06-01T12:00:00Z,7\n2,,\"say \"\"hi\"\"\",,\n"
+	if w.Body.String() != want {
+		t.Errorf("csv = %q, want %q", w.Body, want)
+	}
+
+	// Exports may be posted as a form, for browsers to download directly.
+	form := url.Values{"connectionRef": {"orders"}, "sql": {"SELECT * FROM orders"}}.Encode()
+	w = export(r, "ndjson", "application/x-www-form-urlencoded", form)
+	lines := strings.Split(strings.TrimSpace(w.Body.String()), "\n")
+	if w.Code != http.StatusOK || len(lines) != 2 {
+		t.Fatalf("ndjson export = %d: %s", w.Code, w.Body)
+	}
+	if lines[0] != `{"id":"1","name":"a,b","name_2":"x","at":"2025-06-01T12:00:00Z","n":7}` {
+		t.Errorf("ndjson row = %s", lines[0])
+	}
+
+	w = export(r, "parquet", "application/json", body)
+	file := w.Body.Bytes()
+	if w.Code != http.StatusOK || !bytes.HasPrefix(file, []byte("PAR1")) || !bytes.HasSuffix(file, []byte("PAR1")) {
+		t.Fatalf("parquet export = %d: %q", w.Code, file)
+	}
+	if !bytes.Contains(file, []byte(parquet.CreatedBy)) || !bytes.Contains(file, []byte("name_2")) {
+		t.Error("parquet footer lacks the schema")
+	}
+
+	for _, tc := range []struct {
+		format, body string
+		code         int
+	}{
+		{"xlsx", body, http.StatusBadRequest},
+		{"csv", `{"connectionRef":"orders","sql":"DELETE FROM orders"}`, http.StatusBadRequest},
+		{"csv", `{"connectionRef":"missing","sql":"SELECT 1"}`, http.StatusNotFound},
+	} {
+		w := export(r, tc.format, "application/json", tc.body)
+		if w.Code != tc.code || w.Header().Get("Content-Disposition") != "" {
+			t.Errorf("%s export of %s = %d, want %d as JSON: %s", tc.format, tc.body, w.Code, tc.code, w.Body)
+		}
+	}
+
+	var records []audit.Record
+	for _, line := range strings.Split(strings.TrimSpace(auditLog.String()), "\n") {
+		var entry struct{ Audit audit.Record }
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatal(err)
+		}
+		records = append(records, entry.Audit)
+	}
+	if len(records) != 4 {
+		t.Fatalf("got %d audit records, want one per statement run: %s", len(records), auditLog.String())
+	}
+	rec := records[2]
+	if rec.Action != audit.ActionExport || rec.Format != "parquet" || rec.User != "analyst" || rec.Connection != "orders" ||
+		rec.RowCount != 2 || rec.SQLHash != audit.Hash("SELECT * FROM orders") {
+		t.Errorf("audit record = %+v", rec)
+	}
+	if records[3].Error == "" {
+		t.Errorf("audit record = %+v, want the error", records[3])
+	}
+}
+
+func TestExportLimits(t *testing.T) {
+	driver := &dbtest.Driver{Handler: func(context.Context, string, []any) (dbtest.Result, error) {
+		return dbtest.Result{
+			Columns: []db.Column{{Name: "id", Type: "int4"}},
+			Rows:    [][]any{{int32(1)}, {int32(2)}, {int32(3)}},
+		}, nil
+	}}
+	var auditLog bytes.Buffer
+	// A real server, as the download is cut off by closing the connection.
+	srv := httptest.NewServer(newServer(t, driver, Limits{MaxRows: 2}, &auditLog))
+	defer srv.Close()
+
+	req, err := http.NewRequest(http.MethodPost, srv.URL+"/api/console/team-a/export?format=csv",
+		strings.NewReader(`{"connectionRef":"orders","sql":"SELECT id FROM t"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-User", "analyst")
+	resp, err := srv.Client().Do(req)
+	if err == nil {
+		var body []byte
+		body, err = io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err == nil {
+			t.Fatalf("export beyond the limits = %d %q, want the download to fail", resp.StatusCode, body)
+		}
+	}
+
+	var entry struct{ Audit audit.Record }
+	if err := json.Unmarshal(auditLog.Bytes(), &entry); err != nil {
+		t.Fatal(err)
+	}
+	if rec := entry.Audit; !rec.Truncated || !strings.Contains(rec.Error, "export limit") {
+		t.Errorf("audit record = %+v, want truncated with an error", rec)
+	}
+}
+
+func TestParquetValue(t *testing.T) {
+	for _, tc := range []struct {
+		typ   string
+		value any
+		want  any
+	}{
+		{"int8", int64(-3), int64(-3)},
+		{"int2", int16(5), int32(5)},
+		{"float4", float32(1.5), float32(1.5)},
+		{"bool", true, true},
+		{"date", time.Date(1969, 12, 31, 0, 0, 0, 0, time.UTC), int32(-1)},
+		{"timestamp", time.Date(1970, 1, 1, 0, 0, 1, 0, time.UTC), int64(1000000)},
+		{"timestamptz", time.Date(1970, 1, 1, 1, 0, 0, 0, time.FixedZone("", 3600)), int64(0)},
+		{"bytea", []byte{0xca, 0xfe}, []byte{0xca, 0xfe}},
+		{"jsonb", map[string]any{"a": 1.0}, []byte(`{"a":1}`)},
+		{"numeric", numeric("1.10"), "1.10"},
+		{"uuid", nil, nil},
+		{"date", "infinity", int32(math.MaxInt32)},
+		{"date", "-infinity", int32(math.MinInt32)},
+		{"timestamptz", "infinity", int64(math.MaxInt64)},
+		{"timestamp", "-infinity", int64(math.MinInt64)},
+		{"date", time.Date(-43, 3, 15, 0, 0, 0, 0, time.UTC), int32(-735160)},
+		{"date", time.Date(10000, 1, 1, 0, 0, 0, 0, time.UTC), int32(2932897)},
+		{"timestamp", time.Date(-43, 3, 15, 12, 0, 0, 0, time.UTC), int64(-63517780800000000)},
+		{"timestamptz", time.Date(10000, 1, 1, 0, 0, 0, 0, time.UTC), int64(253402300800000000)},
+	} {
+		col := Column{Name: "c", Type: tc.typ}
+		field := exportField("c", col)
+		got, err := parquetValue(tc.value, Value(tc.value, col), field)
+		if err != nil {
+			t.Errorf("%s %v: %v", tc.typ, tc.value, err)
+			continue
+		}
+		if b, ok := got.([]byte); ok {
+			got = string(b)
+			if w, ok := tc.want.([]byte); ok {
+				tc.want = string(w)
+			}
+		}
+		if got != tc.want {
+			t.Errorf("%s %v = %#v, want %#v", tc.typ, tc.value, got, tc.want)
+		}
+	}
+	nan := Column{Name: "c", Type: "float8"}
+	if got, err := parquetValue(math.NaN(), Value(math.NaN(), nan), exportField("c", nan)); err != nil || !math.IsNaN(got.(float64)) {
+		t.Errorf("float8 NaN = %v, %v", got, err)
+	}
+}
@@ -0,0 +1,114 @@
+package console
+
+import (
+	"database/sql/driver"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Value returns v, a value of column col as returned by db.Rows.Values, in
+// the form it takes in console results. Every value of a column is encoded
+// the same way:
+//
+//   - bool, int2, int4 and float columns are JSON booleans and numbers;
+//     NaN and infinities are the strings "NaN", "Infinity" and "-Infinity"
+//   - int8 and numeric are strings, which JavaScript clients can parse
+//     without losing precision
+//   - bytea is a string of hex digits prefixed with \x, as PostgreSQL
+//     prints it
+//   - timestamptz is RFC 3339 text; timestamp has no zone; date is YYYY-MM-DD
+//   - json and jsonb are embedded as JSON; arrays are JSON arrays
+//   - everything else, e.g. uuid, interval or inet, is its PostgreSQL text
+//   - NULL is null
+func Value(v any, col Column) any {
+	switch v := v.(type) {
+	case nil, bool, string, int, int8, int16, int32, uint8, uint16, uint32:
+		return v
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case uint64:
+		return strconv.FormatUint(v, 10)
+	case float32:
+		return float(float64(v))
+	case float64:
+		if col.Type == "json" || col.Type == "jsonb" {
+			return v
+		}
+		return float(v)
+	case []byte:
+		return `\x` + hex.EncodeToString(v)
+	case [16]byte:
+		return fmt.Sprintf("%x-%x-%x-%x-%x", v[0:4], v[4:6], v[6:8], v[8:10], v[10:16])
+	case time.Time:
+		switch col.Type {
+		case "date":
+			return v.Format(time.DateOnly)
+		case "timestamp":
+			return v.Format("2006-01-02T15:04:05.999999")
+		}
+		return v.Format(time.RFC3339Nano)
+	case map[string]any:
+		return v
+	case []any:
+		elem := Column{Name: col.Name, Type: strings.TrimPrefix(col.Type, "_")}
+		values := make([]any, len(v))
+		for i, e := range v {
+			values[i] = Value(e, elem)
+		}
+		return values
+	case driver.Valuer:
+		text, err := v.Value()
+		if err != nil {
+			return fmt.Sprint(v)
+		}
+		return Value(text, col)
+	case fmt.Stringer:
+		return v.String()
+	}
+	return fmt.Sprint(v)
+}
+
+// float returns f as a JSON number, or as a string if JSON has no number for it.
+func float(f float64) any {
+	switch {
+	case math.IsNaN(f):
+		return "NaN"
+	case math.IsInf(f, 1):
+		return "Infinity"
+	case math.IsInf(f, -1):
+		return "-Infinity"
+	}
+	return f
+}
+
+// Text returns v, a value of column col as returned by db.Rows.Values, as
+// text: the string form of Value, with JSON for json values and arrays. NULL
+// is the empty string.
+func Text(v any, col Column) string {
+	return text(Value(v, col))
+}
+
+// text returns v, a value encoded by Value, as text.
+func text(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	case bool, int, int8, int16, int32, uint8, uint16, uint32:
+		return fmt.Sprint(v)
+	default:
+		b, err := json.Marshal(v)
+		if err != nil {
+			return fmt.Sprint(v)
+		}
+		return string(b)
+	}
+}
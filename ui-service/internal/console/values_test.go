@@ -0,0 +1,58 @@
+package console
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"math"
+	"net/netip"
+	"testing"
+	"time"
+)
+
+// numeric stands in for pgtype.Numeric, which is a driver.Valuer.
+type numeric string
+
+func (n numeric) Value() (driver.Value, error) {
+	return string(n), nil
+}
+
+func TestValue(t *testing.T) {
+	ts := time.Date(2025, 6, 1, 12, 30, 0, 500000000, time.FixedZone("CEST", 2*3600))
+	for _, tc := range []struct {
+		typ   string
+		value any
+		json  string
+		text  string
+	}{
+		{"int4", int32(42), `42`, "42"},
+		{"int8", int64(9007199254740993), `"9007199254740993"`, "9007199254740993"},
+		{"float8", 1.5, `1.5`, "1.5"},
+		{"float8", math.NaN(), `"NaN"`, "NaN"},
+		{"float4", float32(math.Inf(-1)), `"-Infinity"`, "-Infinity"},
+		{"numeric", numeric("12345678901234567890.01"), `"12345678901234567890.01"`, "12345678901234567890.01"},
+		{"bool", true, `true`, "true"},
+		{"text", "héllo", `"héllo"`, "héllo"},
+		{"bytea", []byte{0xde, 0xad}, `"\\xdead"`, `\xdead`},
+		{"uuid", [16]byte{0x12, 0x34, 0x56, 0x78, 0x9a, 0xbc, 0xde, 0xf0, 0x12, 0x34, 0x56, 0x78, 0x9a, 0xbc, 0xde, 0xf0},
+			`"12345678-9abc-def0-1234-56789abcdef0"`, "12345678-9abc-def0-1234-56789abcdef0"},
+		{"timestamptz", ts, `"2025-06-01T12:30:00.5+02:00"`, "2025-06-01T12:30:00.5+02:00"},
+		{"timestamp", ts.UTC(), `"2025-06-01T10:30:00.5"`, "2025-06-01T10:30:00.5"},
+		{"date", time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC), `"2025-06-01"`, "2025-06-01"},
+		{"jsonb", map[string]any{"a": []any{1.0, "b"}}, `{"a":[1,"b"]}`, `{"a":[1,"b"]}`},
+		{"_int8", []any{int64(1), nil}, `["1",null]`, `["1",null]`},
+		{"inet", netip.MustParsePrefix("10.0.0.0/8"), `"10.0.0.0/8"`, "10.0.0.0/8"},
+		{"text", nil, `null`, ""},
+	} {
+		col := Column{Name: "c", Type: tc.typ}
+		b, err := json.Marshal(Value(tc.value, col))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(b) != tc.json {
+			t.Errorf("%s %v: JSON = %s, want %s", tc.typ, tc.value, b, tc.json)
+		}
+		if got := Text(tc.value, col); got != tc.text {
+			t.Errorf("%s %v: text = %q, want %q", tc.typ, tc.value, got, tc.text)
+		}
+	}
+}
@@ -0,0 +1,392 @@
+// Package parquet writes Apache Parquet files as they are produced, one row
+// group at a time, so that results of any size can be exported while only a
+// row group is held in memory. It writes what exports need and no more: a
+// flat schema of optional columns, and one uncompressed, PLAIN-encoded data
+// page per column chunk.
+package parquet
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+)
+
+// Type is a Parquet physical type.
+type Type int32
+
+const (
+	Boolean   Type = 0
+	Int32     Type = 1
+	Int64     Type = 2
+	Float     Type = 4
+	Double    Type = 5
+	ByteArray Type = 6
+)
+
+func (t Type) String() string {
+	switch t {
+	case Boolean:
+		return "BOOLEAN"
+	case Int32:
+		return "INT32"
+	case Int64:
+		return "INT64"
+	case Float:
+		return "FLOAT"
+	case Double:
+		return "DOUBLE"
+	case ByteArray:
+		return "BYTE_ARRAY"
+	}
+	return fmt.Sprintf("Type(%d)", int32(t))
+}
+
+// Logical annotates a physical type with the meaning of its values.
+type Logical int
+
+const (
+	None Logical = iota
+	// String is UTF-8 text in a BYTE_ARRAY.
+	String
+	// JSON is a JSON document in a BYTE_ARRAY.
+	JSON
+	// Int16 is a signed 16-bit integer in an INT32.
+	Int16
+	// Date is the number of days since 1970-01-01 in an INT32.
+	Date
+	// Timestamp is the number of microseconds since the Unix epoch in an
+	// INT64, adjusted to UTC.
+	Timestamp
+	// LocalTimestamp is a timestamp without a time zone: the microseconds
+	// since 1970-01-01 00:00 in an INT64, in no particular zone.
+	LocalTimestamp
+)
+
+// Field is a column of a file. All columns are optional, so every value
+// may be nil.
+type Field struct {
+	Name    string
+	Type    Type
+	Logical Logical
+}
+
+// DefaultRowGroupSize is the size of the values buffered before a row
+// group is written, when Writer.RowGroupSize is not set.
+const DefaultRowGroupSize = 16 << 20
+
+// CreatedBy is recorded in the files written as the application that wrote
+// them.
+const CreatedBy = "kubequery ui-service"
+
+var magic = []byte("PAR1")
+
+// Writer writes a Parquet file. Rows are buffered into a row group until
+// RowGroupSize bytes of values are buffered; Close writes the last one and
+// the file footer.
+type Writer struct {
+	// RowGroupSize is the size of the values buffered before a row group
+	// is written; DefaultRowGroupSize when 0.
+	RowGroupSize int
+
+	w       io.Writer
+	fields  []Field
+	columns []column
+	offset  int64
+	rows    int // rows buffered
+	size    int // bytes buffered
+	numRows int64
+	groups  []rowGroup
+	err     error
+}
+
+// column buffers the values of a column for a row group.
+type column struct {
+	defined []bool // per row, whether the value is not null
+	values  []byte // PLAIN-encoded values, except booleans
+	bools   []bool
+}
+
+type rowGroup struct {
+	chunks  []chunk
+	numRows int64
+	size    int64
+}
+
+type chunk struct {
+	offset int64
+	size   int64
+}
+
+// NewWriter starts a file with fields as its columns on w.
+func NewWriter(w io.Writer, fields []Field) (*Writer, error) {
+	pw := &Writer{w: w, fields: fields, columns: make([]column, len(fields))}
+	if err := pw.write(magic); err != nil {
+		return nil, err
+	}
+	return pw, nil
+}
+
+func (w *Writer) write(b []byte) error {
+	n, err := w.w.Write(b)
+	w.offset += int64(n)
+	if err != nil {
+		w.err = err
+	}
+	return err
+}
+
+// Write adds a row of values in column order. Each value is nil or, by the
+// type of its column, a bool, int32, int64, float32, float64, or a string or
+// []byte for BYTE_ARRAY columns.
+func (w *Writer) Write(row []any) error {
+	if w.err != nil {
+		return w.err
+	}
+	if len(row) != len(w.fields) {
+		return fmt.Errorf("parquet: row has %d values, want %d", len(row), len(w.fields))
+	}
+	for i, v := range row {
+		if !valid(w.fields[i].Type, v) {
+			return fmt.Errorf("parquet: column %s: %T is not a %s value", w.fields[i].Name, v, w.fields[i].Type)
+		}
+	}
+	for i, v := range row {
+		col := &w.columns[i]
+		col.defined = append(col.defined, v != nil)
+		n := len(col.values)
+		switch v := v.(type) {
+		case bool:
+			col.bools = append(col.bools, v)
+			w.size++
+		case int32:
+			col.values = binary.LittleEndian.AppendUint32(col.values, uint32(v))
+		case int64:
+			col.values = binary.LittleEndian.AppendUint64(col.values, uint64(v))
+		case float32:
+			col.values = binary.LittleEndian.AppendUint32(col.values, math.Float32bits(v))
+		case float64:
+			col.values = binary.LittleEndian.AppendUint64(col.values, math.Float64bits(v))
+		case string:
+			col.values = binary.LittleEndian.AppendUint32(col.values, uint32(len(v)))
+			col.values = append(col.values, v...)
+		case []byte:
+			col.values = binary.LittleEndian.AppendUint32(col.values, uint32(len(v)))
+			col.values = append(col.values, v...)
+		}
+		w.size += len(col.values) - n
+	}
+	w.rows++
+	if w.size >= w.rowGroupSize() {
+		return w.flush()
+	}
+	return nil
+}
+
+func valid(t Type, v any) bool {
+	switch v.(type) {
+	case nil:
+		return true
+	case bool:
+		return t == Boolean
+	case int32:
+		return t == Int32
+	case int64:
+		return t == Int64
+	case float32:
+		return t == Float
+	case float64:
+		return t == Double
+	case string, []byte:
+		return t == ByteArray
+	}
+	return false
+}
+
+func (w *Writer) rowGroupSize() int {
+	if w.RowGroupSize > 0 {
+		return w.RowGroupSize
+	}
+	return DefaultRowGroupSize
+}
+
+// flush writes the buffered rows as a row group.
+func (w *Writer) flush() error {
+	if w.rows == 0 {
+		return nil
+	}
+	group := rowGroup{numRows: int64(w.rows)}
+	for i := range w.columns {
+		col := &w.columns[i]
+		page := pageData(col)
+		var h compact
+		h.begin()
+		h.i32(1, 0) // DATA_PAGE
+		h.i32(2, int32(len(page)))
+		h.i32(3, int32(len(page)))
+		h.structField(5)
+		h.i32(1, int32(w.rows))
+		h.i32(2, 0) // PLAIN
+		h.i32(3, 3) // RLE definition levels
+		h.i32(4, 3) // RLE repetition levels
+		h.end()
+		h.end()
+
+		c := chunk{offset: w.offset, size: int64(len(h.buf) + len(page))}
+		if err := w.write(h.buf); err != nil {
+			return err
+		}
+		if err := w.write(page); err != nil {
+			return err
+		}
+		group.chunks = append(group.chunks, c)
+		group.size += c.size
+		*col = column{
+			defined: col.defined[:0],
+			values:  col.values[:0],
+			bools:   col.bools[:0],
+		}
+	}
+	w.groups = append(w.groups, group)
+	w.numRows += group.numRows
+	w.rows, w.size = 0, 0
+	return nil
+}
+
+// pageData returns the data of a page of the values of col: their
+// definition levels, then the values.
+func pageData(col *column) []byte {
+	// Definition levels are 1 bit wide, written as a single bit-packed run
+	// of the RLE/bit-packing hybrid encoding, prefixed with its length.
+	groups := (len(col.defined) + 7) / 8
+	levels := binary.AppendUvarint(nil, uint64(groups)<<1|1)
+	levels = append(levels, packBits(col.defined)...)
+
+	page := binary.LittleEndian.AppendUint32(nil, uint32(len(levels)))
+	page = append(page, levels...)
+	if col.bools != nil {
+		return append(page, packBits(col.bools)...)
+	}
+	return append(page, col.values...)
+}
+
+// packBits packs bits eight to a byte, least significant bit first.
+func packBits(bits []bool) []byte {
+	b := make([]byte, (len(bits)+7)/8)
+	for i, bit := range bits {
+		if bit {
+			b[i/8] |= 1 << (i % 8)
+		}
+	}
+	return b
+}
+
+// Close writes the buffered rows and the file footer. It does not close the
+// underlying writer.
+func (w *Writer) Close() error {
+	if w.err != nil {
+		return w.err
+	}
+	if err := w.flush(); err != nil {
+		return err
+	}
+	footer := w.footer()
+	footer = binary.LittleEndian.AppendUint32(footer, uint32(len(footer)))
+	return w.write(append(footer, magic...))
+}
+
+// footer returns the FileMetaData of the file.
+func (w *Writer) footer() []byte {
+	var m compact
+	m.begin()
+	m.i32(1, 1) // version
+	m.list(2, thriftStruct, len(w.fields)+1)
+	m.begin()
+	m.string(4, "schema")
+	m.i32(5, int32(len(w.fields)))
+	m.end()
+	for _, f := range w.fields {
+		m.begin()
+		m.i32(1, int32(f.Type))
+		m.i32(3, 1) // OPTIONAL
+		m.string(4, f.Name)
+		logicalType(&m, f.Logical)
+		m.end()
+	}
+	m.i64(3, w.numRows)
+	m.list(4, thriftStruct, len(w.groups))
+	for _, g := range w.groups {
+		m.begin()
+		m.list(1, thriftStruct, len(g.chunks))
+		for i, c := range g.chunks {
+			m.begin()
+			m.i64(2, c.offset)
+			m.structField(3)
+			m.i32(1, int32(w.fields[i].Type))
+			m.list(2, thriftI32, 2)
+			m.varint(0) // PLAIN
+			m.varint(3) // RLE
+			m.list(3, thriftBinary, 1)
+			m.str(w.fields[i].Name)
+			m.i32(4, 0) // UNCOMPRESSED
+			m.i64(5, g.numRows)
+			m.i64(6, c.size)
+			m.i64(7, c.size)
+			m.i64(9, c.offset)
+			m.end()
+			m.end()
+		}
+		m.i64(2, g.size)
+		m.i64(3, g.numRows)
+		m.end()
+	}
+	m.string(6, CreatedBy)
+	m.end()
+	return m.buf
+}
+
+// logicalType writes the converted_type and logicalType fields of a
+// SchemaElement for l. Both are written so that readers predating logical
+// types understand them too, except for LocalTimestamp, which has no
+// converted type.
+func logicalType(m *compact, l Logical) {
+	emptyStruct := func(id int16) {
+		m.structField(id)
+		m.end()
+	}
+	switch l {
+	case String:
+		m.i32(6, 0) // UTF8
+		m.structField(10)
+		emptyStruct(1)
+	case JSON:
+		m.i32(6, 19)
+		m.structField(10)
+		emptyStruct(12)
+	case Int16:
+		m.i32(6, 16) // INT_16
+		m.structField(10)
+		m.structField(10)
+		m.i8(1, 16)
+		m.bool(2, true)
+		m.end()
+	case Date:
+		m.i32(6, 6)
+		m.structField(10)
+		emptyStruct(6)
+	case Timestamp, LocalTimestamp:
+		if l == Timestamp {
+			m.i32(6, 10) // TIMESTAMP_MICROS
+		}
+		m.structField(10)
+		m.structField(8)
+		m.bool(1, l == Timestamp)
+		m.structField(2)
+		emptyStruct(2) // MICROS
+		m.end()
+		m.end()
+	default:
+		return
+	}
+	m.end()
+}
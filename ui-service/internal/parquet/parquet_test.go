@@ -0,0 +1,401 @@
+package parquet
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"reflect"
+	"testing"
+)
+
+// decoder reads Thrift compact protocol structs into maps of field IDs to
+// values, to check the metadata written without a Parquet library.
+type decoder struct {
+	b   []byte
+	err bool
+}
+
+func (d *decoder) byte() byte {
+	if len(d.b) == 0 {
+		d.err = true
+		return 0
+	}
+	c := d.b[0]
+	d.b = d.b[1:]
+	return c
+}
+
+func (d *decoder) uvarint() uint64 {
+	v, n := binary.Uvarint(d.b)
+	if n <= 0 {
+		d.err = true
+		return 0
+	}
+	d.b = d.b[n:]
+	return v
+}
+
+func (d *decoder) varint() int64 {
+	u := d.uvarint()
+	return int64(u>>1) ^ -int64(u&1)
+}
+
+func (d *decoder) value(typ byte) any {
+	switch typ {
+	case thriftTrue:
+		return true
+	case thriftFalse:
+		return false
+	case thriftByte:
+		return int64(int8(d.byte()))
+	case thriftI32, thriftI64:
+		return d.varint()
+	case thriftBinary:
+		n := int(d.uvarint())
+		if n > len(d.b) {
+			d.err = true
+			return ""
+		}
+		s := string(d.b[:n])
+		d.b = d.b[n:]
+		return s
+	case thriftList:
+		h := d.byte()
+		n, elem := int(h>>4), h&0x0f
+		if n == 15 {
+			n = int(d.uvarint())
+		}
+		var list []any
+		for range n {
+			list = append(list, d.value(elem))
+		}
+		return list
+	case thriftStruct:
+		s := map[int16]any{}
+		var id int16
+		for !d.err {
+			h := d.byte()
+			if h == 0 {
+				break
+			}
+			if delta := int16(h >> 4); delta != 0 {
+				id += delta
+			} else {
+				id = int16(d.varint())
+			}
+			s[id] = d.value(h & 0x0f)
+		}
+		return s
+	}
+	d.err = true
+	return nil
+}
+
+func decode(t *testing.T, b []byte) (map[int16]any, int) {
+	t.Helper()
+	d := &decoder{b: b}
+	s := d.value(thriftStruct).(map[int16]any)
+	if d.err {
+		t.Fatalf("invalid Thrift struct: % x", b)
+	}
+	return s, len(b) - len(d.b)
+}
+
+func TestWriter(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, []Field{
+		{Name: "id", Type: Int64},
+		{Name: "name", Type: ByteArray, Logical: String},
+		{Name: "ok", Type: Boolean},
+		{Name: "at", Type: Int64, Logical: Timestamp},
+		{Name: "score", Type: Double},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Two values of name fill a row group.
+	w.RowGroupSize = 40
+	rows := [][]any{
+		{int64(1), "alpha", true, int64(1700000000000000), 1.5},
+		{int64(2), nil, false, nil, math.Inf(1)},
+		{int64(3), "gamma", nil, int64(0), nil},
+	}
+	for _, row := range rows {
+		if err := w.Write(row); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Write([]any{"4", nil, nil, nil, nil}); err == nil {
+		t.Error("wrote a string to an INT64 column")
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	file := buf.Bytes()
+	if !bytes.HasPrefix(file, magic) || !bytes.HasSuffix(file, magic) {
+		t.Fatal("missing PAR1 magic")
+	}
+	n := int(binary.LittleEndian.Uint32(file[len(file)-8:]))
+	meta, _ := decode(t, file[len(file)-8-n:len(file)-8])
+
+	if meta[3] != int64(3) {
+		t.Errorf("num_rows = %v, want 3", meta[3])
+	}
+	schema := meta[2].([]any)
+	if len(schema) != 6 || schema[0].(map[int16]any)[5] != int64(5) {
+		t.Fatalf("schema = %v", schema)
+	}
+	name := schema[2].(map[int16]any)
+	if name[1] != int64(ByteArray) || name[3] != int64(1) || name[4] != "name" || name[6] != int64(0) {
+		t.Errorf("name column = %v, want an optional UTF8 BYTE_ARRAY", name)
+	}
+	at := schema[4].(map[int16]any)
+	ts := at[10].(map[int16]any)[8].(map[int16]any)
+	if at[6] != int64(10) || ts[1] != true || ts[2].(map[int16]any)[2] == nil {
+		t.Errorf("at column = %v, want a UTC TIMESTAMP in microseconds", at)
+	}
+
+	groups := meta[4].([]any)
+	if len(groups) != 2 {
+		t.Fatalf("got %d row groups, want 2", len(groups))
+	}
+	var total int64
+	for _, g := range groups {
+		total += g.(map[int16]any)[3].(int64)
+	}
+	if total != 3 {
+		t.Errorf("row groups hold %d rows, want 3", total)
+	}
+
+	// The first name chunk holds "alpha" and a null.
+	chunk := groups[0].(map[int16]any)[1].([]any)[1].(map[int16]any)[3].(map[int16]any)
+	offset := chunk[9].(int64)
+	header, size := decode(t, file[offset:])
+	dph := header[5].(map[int16]any)
+	if header[1] != int64(0) || dph[1] != int64(2) || dph[2] != int64(0) {
+		t.Errorf("page header = %v, want a PLAIN data page of 2 values", header)
+	}
+	page := file[int(offset)+size:][:header[2].(int64)]
+	levels := int(binary.LittleEndian.Uint32(page))
+	if got := page[4 : 4+levels]; !bytes.Equal(got, []byte{1<<1 | 1, 0b01}) {
+		t.Errorf("definition levels = % x, want one bit-packed group of 1, 0", got)
+	}
+	if got := page[4+levels:]; !bytes.Equal(got, append([]byte{5, 0, 0, 0}, "alpha"...)) {
+		t.Errorf("values = %q", got)
+	}
+}
+
+func TestWriterEmpty(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, []Field{{Name: "n", Type: Int32}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	file := buf.Bytes()
+	n := int(binary.LittleEndian.Uint32(file[len(file)-8:]))
+	if 4+n+8 != len(file) {
+		t.Fatalf("footer length %d does not match a file of %d bytes", n, len(file))
+	}
+	meta, _ := decode(t, file[4:len(file)-8])
+	if meta[3] != int64(0) || meta[4] != nil && len(meta[4].([]any)) != 0 {
+		t.Errorf("metadata = %v, want no rows", meta)
+	}
+}
+
+// readLevels decodes the definition levels of n values, 1 bit wide, from
+// RLE/bit-packing hybrid runs.
+func readLevels(t *testing.T, b []byte, n int) []bool {
+	t.Helper()
+	var levels []bool
+	for len(levels) < n {
+		header, size := binary.Uvarint(b)
+		if size <= 0 {
+			t.Fatalf("invalid run header: % x", b)
+		}
+		b = b[size:]
+		if header&1 == 1 {
+			groups := int(header >> 1)
+			for _, c := range b[:groups] {
+				for bit := range 8 {
+					levels = append(levels, c&(1<<bit) != 0)
+				}
+			}
+			b = b[groups:]
+		} else {
+			for range header >> 1 {
+				levels = append(levels, b[0] != 0)
+			}
+			b = b[1:]
+		}
+	}
+	return levels[:n]
+}
+
+// readColumn decodes the values of column i in every row group of file,
+// with nil for nulls, BYTE_ARRAY values as strings.
+func readColumn(t *testing.T, file []byte, meta map[int16]any, i int, typ Type) []any {
+	t.Helper()
+	var values []any
+	for _, g := range meta[4].([]any) {
+		chunk := g.(map[int16]any)[1].([]any)[i].(map[int16]any)[3].(map[int16]any)
+		offset := chunk[9].(int64)
+		header, size := decode(t, file[offset:])
+		dph := header[5].(map[int16]any)
+		if header[1] != int64(0) || dph[2] != int64(0) || dph[3] != int64(3) {
+			t.Fatalf("column %d: page header = %v, want a PLAIN data page with RLE levels", i, header)
+		}
+		page := file[int(offset)+size:][:header[2].(int64)]
+		n := int(binary.LittleEndian.Uint32(page))
+		defined := readLevels(t, page[4:4+n], int(dph[1].(int64)))
+		page = page[4+n:]
+		bit := 0
+		for _, ok := range defined {
+			if !ok {
+				values = append(values, nil)
+				continue
+			}
+			var v any
+			switch typ {
+			case Boolean:
+				v = page[bit/8]&(1<<(bit%8)) != 0
+				bit++
+			case Int32:
+				v, page = int32(binary.LittleEndian.Uint32(page)), page[4:]
+			case Int64:
+				v, page = int64(binary.LittleEndian.Uint64(page)), page[8:]
+			case Float:
+				v, page = math.Float32frombits(binary.LittleEndian.Uint32(page)), page[4:]
+			case Double:
+				v, page = math.Float64frombits(binary.LittleEndian.Uint64(page)), page[8:]
+			case ByteArray:
+				n := int(binary.LittleEndian.Uint32(page))
+				v, page = string(page[4:4+n]), page[4+n:]
+			}
+			values = append(values, v)
+		}
+	}
+	return values
+}
+
+// TestWriterRoundTrip reads every column of a file with several row groups
+// back, following the format specification rather than the writer.
+func TestWriterRoundTrip(t *testing.T) {
+	fields := []Field{
+		{Name: "ok", Type: Boolean},
+		{Name: "small", Type: Int32, Logical: Int16},
+		{Name: "n", Type: Int32},
+		{Name: "day", Type: Int32, Logical: Date},
+		{Name: "id", Type: Int64},
+		{Name: "at", Type: Int64, Logical: Timestamp},
+		{Name: "local", Type: Int64, Logical: LocalTimestamp},
+		{Name: "ratio", Type: Float},
+		{Name: "score", Type: Double},
+		{Name: "name", Type: ByteArray, Logical: String},
+		{Name: "doc", Type: ByteArray, Logical: JSON},
+		{Name: "raw", Type: ByteArray},
+	}
+	rows := [][]any{
+		{true, int32(-5), int32(7), int32(19000), int64(1), int64(1700000000000000), int64(-1), float32(0.5), 1.5, "alpha", []byte(`{"a":1}`), []byte{0xca, 0xfe}},
+		{nil, nil, nil, nil, int64(2), nil, nil, nil, nil, nil, nil, nil},
+		{false, int32(5), int32(math.MinInt32), int32(math.MaxInt32), int64(math.MaxInt64), int64(math.MinInt64), int64(0), float32(-1), math.Inf(-1), "", []byte(`[]`), []byte{}},
+	}
+	// Nine more rows of booleans fill more than one byte of bits.
+	for i := range 9 {
+		row := make([]any, len(fields))
+		row[0], row[4] = i%3 == 0, int64(i)
+		rows = append(rows, row)
+	}
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, fields)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The first three rows fill a row group each, the rest share one.
+	w.RowGroupSize = 8
+	for _, row := range rows {
+		if err := w.Write(row); err != nil {
+			t.Fatal(err)
+		}
+		if len(w.groups) == 3 {
+			w.RowGroupSize = 1 << 20
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	file := buf.Bytes()
+	n := int(binary.LittleEndian.Uint32(file[len(file)-8:]))
+	meta, _ := decode(t, file[len(file)-8-n:len(file)-8])
+	if meta[3] != int64(len(rows)) || len(meta[4].([]any)) != 4 {
+		t.Errorf("num_rows = %v in %d row groups, want %d in 4", meta[3], len(meta[4].([]any)), len(rows))
+	}
+	if meta[6] != CreatedBy {
+		t.Errorf("created_by = %v", meta[6])
+	}
+
+	// Converted type and member of the LogicalType union of each
+	// logical type; -1 when there is none.
+	annotations := map[Logical]struct{ converted, logical int64 }{
+		None:           {-1, -1},
+		String:         {0, 1},
+		JSON:           {19, 12},
+		Int16:          {16, 10},
+		Date:           {6, 6},
+		Timestamp:      {10, 8},
+		LocalTimestamp: {-1, 8},
+	}
+	schema := meta[2].([]any)[1:]
+	for i, f := range fields {
+		el := schema[i].(map[int16]any)
+		if el[1] != int64(f.Type) || el[3] != int64(1) || el[4] != f.Name {
+			t.Errorf("column %s: schema = %v, want an optional %s", f.Name, el, f.Type)
+		}
+		want := annotations[f.Logical]
+		converted, ok := el[6].(int64)
+		if !ok {
+			converted = -1
+		}
+		var union map[int16]any
+		logical := int64(-1)
+		if lt, ok := el[10].(map[int16]any); ok {
+			for id, v := range lt {
+				logical, union = int64(id), v.(map[int16]any)
+			}
+		}
+		if converted != want.converted || logical != want.logical {
+			t.Errorf("column %s: converted type = %d, logical type = %d, want %d and %d",
+				f.Name, converted, logical, want.converted, want.logical)
+		}
+		switch f.Logical {
+		case Int16:
+			if union[1] != int64(16) || union[2] != true {
+				t.Errorf("column %s: INTEGER = %v, want a signed 16-bit integer", f.Name, union)
+			}
+		case Timestamp, LocalTimestamp:
+			if union[1] != (f.Logical == Timestamp) || union[2].(map[int16]any)[2] == nil {
+				t.Errorf("column %s: TIMESTAMP = %v", f.Name, union)
+			}
+		}
+	}
+
+	for i, f := range fields {
+		values := readColumn(t, file, meta, i, f.Type)
+		if len(values) != len(rows) {
+			t.Fatalf("column %s: read %d values, want %d", f.Name, len(values), len(rows))
+		}
+		for r, row := range rows {
+			want := row[i]
+			if b, ok := want.([]byte); ok {
+				want = string(b)
+			}
+			if !reflect.DeepEqual(values[r], want) {
+				t.Errorf("column %s row %d = %#v, want %#v", f.Name, r, values[r], want)
+			}
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package parquet
+
+import "encoding/binary"
+
+// Thrift compact protocol type codes.
+const (
+	thriftTrue   = 1
+	thriftFalse  = 2
+	thriftByte   = 3
+	thriftI32    = 5
+	thriftI64    = 6
+	thriftBinary = 8
+	thriftList   = 9
+	thriftStruct = 12
+)
+
+// compact encodes the Thrift structures of the Parquet format with the
+// Thrift compact protocol. Fields must be written in increasing ID order
+// within a struct; begin and end delimit structs, including the outermost.
+type compact struct {
+	buf  []byte
+	last []int16 // ID of the last field written, per open struct
+}
+
+func (w *compact) begin() {
+	w.last = append(w.last, 0)
+}
+
+func (w *compact) end() {
+	w.buf = append(w.buf, 0)
+	w.last = w.last[:len(w.last)-1]
+}
+
+func (w *compact) field(id int16, typ byte) {
+	last := &w.last[len(w.last)-1]
+	if delta := id - *last; delta > 0 && delta <= 15 {
+		w.buf = append(w.buf, byte(delta)<<4|typ)
+	} else {
+		w.buf = append(w.buf, typ)
+		w.varint(int64(id))
+	}
+	*last = id
+}
+
+// varint appends v zigzag-encoded, as the compact protocol encodes i16, i32
+// and i64 values.
+func (w *compact) varint(v int64) {
+	w.buf = binary.AppendUvarint(w.buf, uint64(v<<1^v>>63))
+}
+
+func (w *compact) i8(id int16, v int8) {
+	w.field(id, thriftByte)
+	w.buf = append(w.buf, byte(v))
+}
+
+func (w *compact) i32(id int16, v int32) {
+	w.field(id, thriftI32)
+	w.varint(int64(v))
+}
+
+func (w *compact) i64(id int16, v int64) {
+	w.field(id, thriftI64)
+	w.varint(v)
+}
+
+func (w *compact) bool(id int16, v bool) {
+	if v {
+		w.field(id, thriftTrue)
+	} else {
+		w.field(id, thriftFalse)
+	}
+}
+
+func (w *compact) string(id int16, s string) {
+	w.field(id, thriftBinary)
+	w.str(s)
+}
+
+// str appends s as a binary value, for string fields and list elements.
+func (w *compact) str(s string) {
+	w.buf = binary.AppendUvarint(w.buf, uint64(len(s)))
+	w.buf = append(w.buf, s...)
+}
+
+// structField starts a struct-valued field; end closes it.
+func (w *compact) structField(id int16) {
+	w.field(id, thriftStruct)
+	w.begin()
+}
+
+// list starts a list field of n elements of type elem, which follow
+// without field headers.
+func (w *compact) list(id int16, elem byte, n int) {
+	w.field(id, thriftList)
+	if n < 15 {
+		w.buf = append(w.buf, byte(n)<<4|elem)
+	} else {
+		w.buf = append(w.buf, 0xf0|elem)
+		w.buf = binary.AppendUvarint(w.buf, uint64(n))
+	}
+}
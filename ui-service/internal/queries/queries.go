@@ -0,0 +1,271 @@
+// Package queries serves the ui-service API for PostgresQuery objects. The
+// UI never runs SQL itself: it creates PostgresQuery objects for the
+// controller to execute, so queries from the UI get the same idempotency,
+// secret handling, approvals and status as any other.
+package queries
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	corev1client "k8s.io/client-go/kubernetes/typed/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	kubequeryv1beta1 "github.com/rsavage/KubeQuery/api/v1beta1"
+	"github.com/rsavage/KubeQuery/pkg/explain"
+
+	"github.com/rsavage/KubeQuery/ui-service/internal/auth"
+	"github.com/rsavage/KubeQuery/ui-service/internal/authz"
+)
+
+// SubmittedByAnnotation records the UI user who created a query; the object
+// itself is created by the UI's service account, which the manager's
+// --approval-delegates must list.
+const SubmittedByAnnotation = kubequeryv1beta1.SubmittedByAnnotation
+
+// generateName prefixes the names of queries created without a name.
+const generateName = "ui-"
+
+// Handler serves the query endpoints.
+type Handler struct {
+	Client client.WithWatch
+	// Events reads the Kubernetes Events about queries.
+	Events corev1client.EventsGetter
+	Authz  *authz.Authorizer
+}
+
+// Register adds the query routes to r, which must run behind
+// auth.Authenticator.Middleware:
+//
+//	GET  /queries/:namespace                list queries, newest first
+//	POST /queries/:namespace                create a query
+//	GET  /queries/:namespace/:name          get a query
+//	GET  /queries/:namespace/:name/watch    stream a query's changes and events
+//	GET  /queries/:namespace/:name/plan     get the annotated plan of an
+//	                                        explained query
+//	POST /queries/:namespace/:name/approve  approve a query pending approval
+func (h *Handler) Register(r gin.IRouter) {
+	ns := authz.Param("namespace")
+	r.GET("/queries/:namespace", h.Authz.Require(authz.ActionList, ns), h.list)
+	r.POST("/queries/:namespace", h.Authz.Require(authz.ActionCreate, ns), h.create)
+	r.GET("/queries/:namespace/:name", h.Authz.Require(authz.ActionGet, ns), h.get)
+	r.GET("/queries/:namespace/:name/watch", h.Authz.Require(authz.ActionGet, ns), h.watch)
+	r.GET("/queries/:namespace/:name/plan", h.Authz.Require(authz.ActionGet, ns), h.plan)
+	r.POST("/queries/:namespace/:name/approve", h.Authz.Require(authz.ActionApprove, ns), h.approve)
+}
+
+// CreateRequest is the body of POST /queries/:namespace.
+type CreateRequest struct {
+	// Name of the query; generated when empty.
+	Name string `json:"name,omitempty"`
+	// ConnectionRef names a PostgresConnection in the namespace.
+	ConnectionRef   string                         `json:"connectionRef"`
+	SQL             string                         `json:"sql"`
+	Options         *kubequeryv1beta1.QueryOptions `json:"options,omitempty"`
+	ExecutionMode   kubequeryv1beta1.ExecutionMode `json:"executionMode,omitempty"`
+	RequireApproval bool                           `json:"requireApproval,omitempty"`
+}
+
+// Summary describes a query in lists.
+type Summary struct {
+	Namespace       string                      `json:"namespace"`
+	Name            string                      `json:"name"`
+	Connection      string                      `json:"connection,omitempty"`
+	SQL             string                      `json:"sql,omitempty"`
+	Phase           kubequeryv1beta1.QueryPhase `json:"phase,omitempty"`
+	Result          string                      `json:"result,omitempty"`
+	Message         string                      `json:"message,omitempty"`
+	SubmittedBy     string                      `json:"submittedBy,omitempty"`
+	ApprovedBy      string                      `json:"approvedBy,omitempty"`
+	RequireApproval bool                        `json:"requireApproval,omitempty"`
+	CreatedAt       time.Time                   `json:"createdAt"`
+	StartTime       *metav1.Time                `json:"startTime,omitempty"`
+	CompletionTime  *metav1.Time                `json:"completionTime,omitempty"`
+}
+
+// Detail is a query with its full spec and status.
+type Detail struct {
+	Summary
+	Spec   kubequeryv1beta1.PostgresQuerySpec   `json:"spec"`
+	Status kubequeryv1beta1.PostgresQueryStatus `json:"status"`
+}
+
+// Summarize returns the Summary of pq.
+func Summarize(pq *kubequeryv1beta1.PostgresQuery) Summary {
+	s := Summary{
+		Namespace:       pq.Namespace,
+		Name:            pq.Name,
+		SQL:             pq.Spec.SQLSource.Inline,
+		Phase:           pq.Status.Phase,
+		Result:          pq.Status.Result,
+		Message:         pq.Message(),
+		SubmittedBy:     pq.Annotations[SubmittedByAnnotation],
+		ApprovedBy:      pq.Annotations[kubequeryv1beta1.ApprovedByAnnotation],
+		RequireApproval: pq.Spec.RequireApproval,
+		CreatedAt:       pq.CreationTimestamp.Time,
+		StartTime:       pq.Status.StartTime,
+		CompletionTime:  pq.Status.CompletionTime,
+	}
+	if pq.Spec.ConnectionRef != nil {
+		s.Connection = pq.Spec.ConnectionRef.Name
+	}
+	return s
+}
+
+// list returns the queries of a namespace, newest first, filtered by the
+// optional query parameters phase, connection, submittedBy and search (a
+// substring of the name or inline SQL), and truncated to limit.
+func (h *Handler) list(c *gin.Context) {
+	var list kubequeryv1beta1.PostgresQueryList
+	if err := h.Client.List(c.Request.Context(), &list, client.InNamespace(c.Param("namespace"))); err != nil {
+		Abort(c, err)
+		return
+	}
+	phase := c.Query("phase")
+	connection := c.Query("connection")
+	submittedBy := c.Query("submittedBy")
+	search := strings.ToLower(c.Query("search"))
+	limit, _ := strconv.Atoi(c.Query("limit"))
+
+	items := make([]Summary, 0, len(list.Items))
+	for i := range list.Items {
+		s := Summarize(&list.Items[i])
+		switch {
+		case phase != "" && string(s.Phase) != phase,
+			connection != "" && s.Connection != connection,
+			submittedBy != "" && s.SubmittedBy != submittedBy,
+			search != "" && !strings.Contains(strings.ToLower(s.Name), search) &&
+				!strings.Contains(strings.ToLower(s.SQL), search):
+			continue
+		}
+		items = append(items, s)
+	}
+	sort.Slice(items, func(i, j int) bool { return items[j].CreatedAt.Before(items[i].CreatedAt) })
+	if limit > 0 && len(items) > limit {
+		items = items[:limit]
+	}
+	c.JSON(http.StatusOK, gin.H{"items": items})
+}
+
+func (h *Handler) get(c *gin.Context) {
+	var pq kubequeryv1beta1.PostgresQuery
+	key := types.NamespacedName{Namespace: c.Param("namespace"), Name: c.Param("name")}
+	if err := h.Client.Get(c.Request.Context(), key, &pq); err != nil {
+		Abort(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, Detail{Summary: Summarize(&pq), Spec: pq.Spec, Status: pq.Status})
+}
+
+// plan parses the plan recorded in the status of a query run with
+// spec.options.explain, for the UI to draw.
+func (h *Handler) plan(c *gin.Context) {
+	var pq kubequeryv1beta1.PostgresQuery
+	key := types.NamespacedName{Namespace: c.Param("namespace"), Name: c.Param("name")}
+	if err := h.Client.Get(c.Request.Context(), key, &pq); err != nil {
+		Abort(c, err)
+		return
+	}
+	if pq.Status.Plan == "" {
+		c.JSON(http.StatusNotFound, gin.H{"error": "query has no recorded plan"})
+		return
+	}
+	plan, err := explain.Parse([]byte(pq.Status.Plan))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, plan)
+}
+
+func (h *Handler) create(c *gin.Context) {
+	var req CreateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+	if strings.TrimSpace(req.SQL) == "" || req.ConnectionRef == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "sql and connectionRef are required"})
+		return
+	}
+	user, _ := auth.UserFrom(c)
+	pq := NewQuery(c.Param("namespace"), user.Name, req)
+	if err := h.Client.Create(c.Request.Context(), pq); err != nil {
+		Abort(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, Detail{Summary: Summarize(pq), Spec: pq.Spec, Status: pq.Status})
+}
+
+// NewQuery returns the PostgresQuery that req describes in namespace,
+// submitted by the UI user submittedBy. Its name is generated when req has
+// none.
+func NewQuery(namespace, submittedBy string, req CreateRequest) *kubequeryv1beta1.PostgresQuery {
+	pq := &kubequeryv1beta1.PostgresQuery{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        req.Name,
+			Namespace:   namespace,
+			Annotations: map[string]string{SubmittedByAnnotation: submittedBy},
+		},
+		Spec: kubequeryv1beta1.PostgresQuerySpec{
+			ConnectionRef:   &kubequeryv1beta1.ConnectionReference{Name: req.ConnectionRef},
+			SQLSource:       kubequeryv1beta1.SQLSource{Inline: req.SQL},
+			Options:         req.Options,
+			ExecutionMode:   req.ExecutionMode,
+			RequireApproval: req.RequireApproval,
+		},
+	}
+	if pq.Name == "" {
+		pq.GenerateName = generateName
+	}
+	return pq
+}
+
+// approve sets the approval annotations for the query's current approval
+// hash, like `kubectl kubequery approve`, in the name of the UI user.
+func (h *Handler) approve(c *gin.Context) {
+	ctx := c.Request.Context()
+	var pq kubequeryv1beta1.PostgresQuery
+	key := types.NamespacedName{Namespace: c.Param("namespace"), Name: c.Param("name")}
+	if err := h.Client.Get(ctx, key, &pq); err != nil {
+		Abort(c, err)
+		return
+	}
+	if pq.Status.Phase != kubequeryv1beta1.PhasePendingApproval || pq.Status.ApprovalHash == "" {
+		c.JSON(http.StatusConflict, gin.H{"error": "query is not pending approval"})
+		return
+	}
+	user, _ := auth.UserFrom(c)
+	if pq.Annotations[SubmittedByAnnotation] == user.Name {
+		c.JSON(http.StatusForbidden, gin.H{"error": "a query cannot be approved by the user who submitted it"})
+		return
+	}
+	patch := client.MergeFromWithOptions(pq.DeepCopy(), client.MergeFromWithOptimisticLock{})
+	if pq.Annotations == nil {
+		pq.Annotations = map[string]string{}
+	}
+	pq.Annotations[kubequeryv1beta1.ApprovedByAnnotation] = user.Name
+	pq.Annotations[kubequeryv1beta1.ApprovedHashAnnotation] = pq.Status.ApprovalHash
+	if err := h.Client.Patch(ctx, &pq, patch); err != nil {
+		Abort(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, Summarize(&pq))
+}
+
+// Abort responds with the HTTP status of a Kubernetes API error, so that
+// validation errors from the API server reach the user.
+func Abort(c *gin.Context, err error) {
+	code := http.StatusInternalServerError
+	if status, ok := err.(apierrors.APIStatus); ok && status.Status().Code != 0 {
+		code = int(status.Status().Code)
+	}
+	c.AbortWithStatusJSON(code, gin.H{"error": err.Error()})
+}
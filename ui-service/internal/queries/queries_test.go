@@ -0,0 +1,258 @@
+package queries
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	kubequeryv1beta1 "github.com/rsavage/KubeQuery/api/v1beta1"
+
+	"github.com/rsavage/KubeQuery/ui-service/internal/auth"
+	"github.com/rsavage/KubeQuery/ui-service/internal/authz"
+)
+
+// grants maps users to the verbs RBAC allows them in every namespace.
+var grants = map[string][]string{
+	"lister": {"list"},
+	"viewer": {"get", "list"},
+	"editor": {"get", "list", "create"},
+	"admin":  {"get", "list", "create", "approve"},
+}
+
+func newServer(t *testing.T, objs ...client.Object) (*gin.Engine, client.WithWatch, *kubefake.Clientset) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	scheme := runtime.NewScheme()
+	if err := kubequeryv1beta1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(objs...).
+		WithStatusSubresource(&kubequeryv1beta1.PostgresQuery{}).Build()
+
+	kube := kubefake.NewSimpleClientset()
+	kube.PrependReactor("create", "subjectaccessreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		sar := action.(k8stesting.CreateAction).GetObject().(*authorizationv1.SubjectAccessReview)
+		for _, verb := range grants[sar.Spec.User] {
+			sar.Status.Allowed = sar.Status.Allowed || verb == sar.Spec.ResourceAttributes.Verb
+		}
+		return true, sar, nil
+	})
+
+	r := gin.New()
+	api := r.Group("/api", func(c *gin.Context) {
+		auth.SetUser(c, auth.User{Name: c.GetHeader("X-User")})
+	})
+	h := &Handler{Client: c, Events: kube.CoreV1(), Authz: authz.New(kube.AuthorizationV1().SubjectAccessReviews())}
+	h.Register(api)
+	return r, c, kube
+}
+
+func do(r http.Handler, user, method, path, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-User", user)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestCreate(t *testing.T) {
+	r, c, _ := newServer(t)
+
+	body := `{"name":"backfill","connectionRef":"orders","sql":"UPDATE orders SET x = 1","options":{"timeoutSeconds":30},"requireApproval":true}`
+	if w := do(r, "viewer", http.MethodPost, "/api/queries/team-a", body); w.Code != http.StatusForbidden {
+		t.Fatalf("viewer create = %d, want 403", w.Code)
+	}
+	w := do(r, "editor", http.MethodPost, "/api/queries/team-a", body)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("editor create = %d: %s", w.Code, w.Body)
+	}
+
+	var pq kubequeryv1beta1.PostgresQuery
+	if err := c.Get(context.Background(), types.NamespacedName{Namespace: "team-a", Name: "backfill"}, &pq); err != nil {
+		t.Fatal(err)
+	}
+	if pq.Spec.ConnectionRef == nil || pq.Spec.ConnectionRef.Name != "orders" ||
+		pq.Spec.SQLSource.Inline != "UPDATE orders SET x = 1" || !pq.Spec.RequireApproval ||
+		pq.Spec.Options == nil || *pq.Spec.Options.TimeoutSeconds != 30 {
+		t.Errorf("spec = %+v", pq.Spec)
+	}
+	if got := pq.Annotations[SubmittedByAnnotation]; got != "editor" {
+		t.Errorf("submitted-by = %q, want editor", got)
+	}
+
+	if w := do(r, "editor", http.MethodPost, "/api/queries/team-a", `{"connectionRef":"orders","sql":"  "}`); w.Code != http.StatusBadRequest {
+		t.Errorf("create without SQL = %d, want 400", w.Code)
+	}
+	if w := do(r, "editor", http.MethodPost, "/api/queries/team-a", body); w.Code != http.StatusConflict {
+		t.Errorf("create existing = %d, want 409", w.Code)
+	}
+}
+
+func TestList(t *testing.T) {
+	now := time.Now()
+	query := func(name, conn string, phase kubequeryv1beta1.QueryPhase, age time.Duration) *kubequeryv1beta1.PostgresQuery {
+		return &kubequeryv1beta1.PostgresQuery{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace:         "team-a",
+				Name:              name,
+				CreationTimestamp: metav1.NewTime(now.Add(-age)),
+			},
+			Spec: kubequeryv1beta1.PostgresQuerySpec{
+				ConnectionRef: &kubequeryv1beta1.ConnectionReference{Name: conn},
+				SQLSource:     kubequeryv1beta1.SQLSource{Inline: "SELECT '" + name + "'"},
+			},
+			Status: kubequeryv1beta1.PostgresQueryStatus{Phase: phase},
+		}
+	}
+	r, _, _ := newServer(t,
+		query("old", "orders", kubequeryv1beta1.PhaseSucceeded, 2*time.Hour),
+		query("new", "orders", kubequeryv1beta1.PhaseFailed, time.Minute),
+		query("other", "billing", kubequeryv1beta1.PhaseSucceeded, time.Hour),
+	)
+
+	names := func(query string) []string {
+		w := do(r, "viewer", http.MethodGet, "/api/queries/team-a"+query, "")
+		var resp struct{ Items []Summary }
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil || w.Code != http.StatusOK {
+			t.Fatalf("list%s = %d: %s", query, w.Code, w.Body)
+		}
+		var names []string
+		for _, s := range resp.Items {
+			names = append(names, s.Name)
+		}
+		return names
+	}
+	for query, want := range map[string]string{
+		"":                   "new,other,old",
+		"?phase=Succeeded":   "other,old",
+		"?connection=orders": "new,old",
+		"?search=OTH":        "other",
+		"?limit=1":           "new",
+	} {
+		if got := strings.Join(names(query), ","); got != want {
+			t.Errorf("list%s = %s, want %s", query, got, want)
+		}
+	}
+	if w := do(r, "nobody", http.MethodGet, "/api/queries/team-a", ""); w.Code != http.StatusForbidden {
+		t.Errorf("list without permission = %d, want 403", w.Code)
+	}
+	if w := do(r, "viewer", http.MethodGet, "/api/queries/team-a/missing", ""); w.Code != http.StatusNotFound {
+		t.Errorf("get missing = %d, want 404", w.Code)
+	}
+	if w := do(r, "lister", http.MethodGet, "/api/queries/team-a", ""); w.Code != http.StatusOK {
+		t.Errorf("list with the list verb = %d, want 200", w.Code)
+	}
+	for _, path := range []string{"/api/queries/team-a/old", "/api/queries/team-a/old/watch", "/api/queries/team-a/old/plan"} {
+		if w := do(r, "lister", http.MethodGet, path, ""); w.Code != http.StatusForbidden {
+			t.Errorf("GET %s without the get verb = %d, want 403", path, w.Code)
+		}
+	}
+}
+
+func TestApprove(t *testing.T) {
+	pending := &kubequeryv1beta1.PostgresQuery{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "team-a", Name: "drop", Annotations: map[string]string{
+			SubmittedByAnnotation: "editor",
+		}},
+		Spec: kubequeryv1beta1.PostgresQuerySpec{
+			ConnectionRef:   &kubequeryv1beta1.ConnectionReference{Name: "orders"},
+			SQLSource:       kubequeryv1beta1.SQLSource{Inline: "DROP TABLE t"},
+			RequireApproval: true,
+		},
+		Status: kubequeryv1beta1.PostgresQueryStatus{
+			Phase:           kubequeryv1beta1.PhasePendingApproval,
+			IdempotencyHash: "abc",
+			ApprovalHash:    "abc123",
+		},
+	}
+	r, c, _ := newServer(t, pending)
+
+	if w := do(r, "editor", http.MethodPost, "/api/queries/team-a/drop/approve", ""); w.Code != http.StatusForbidden {
+		t.Fatalf("editor approve = %d, want 403", w.Code)
+	}
+	if w := do(r, "admin", http.MethodPost, "/api/queries/team-a/drop/approve", ""); w.Code != http.StatusOK {
+		t.Fatalf("admin approve = %d: %s", w.Code, w.Body)
+	}
+	var pq kubequeryv1beta1.PostgresQuery
+	if err := c.Get(context.Background(), client.ObjectKeyFromObject(pending), &pq); err != nil {
+		t.Fatal(err)
+	}
+	if pq.Annotations[kubequeryv1beta1.ApprovedByAnnotation] != "admin" ||
+		pq.Annotations[kubequeryv1beta1.ApprovedHashAnnotation] != "abc123" || !pq.Approved("abc123") {
+		t.Errorf("annotations = %v", pq.Annotations)
+	}
+
+	pq.Status.Phase = kubequeryv1beta1.PhaseSucceeded
+	if err := c.Status().Update(context.Background(), &pq); err != nil {
+		t.Fatal(err)
+	}
+	if w := do(r, "admin", http.MethodPost, "/api/queries/team-a/drop/approve", ""); w.Code != http.StatusConflict {
+		t.Errorf("approve finished query = %d, want 409", w.Code)
+	}
+
+	own := pending.DeepCopy()
+	own.ResourceVersion = ""
+	own.Name = "own"
+	own.Annotations[SubmittedByAnnotation] = "admin"
+	if err := c.Create(context.Background(), own); err != nil {
+		t.Fatal(err)
+	}
+	own.Status = pending.Status
+	if err := c.Status().Update(context.Background(), own); err != nil {
+		t.Fatal(err)
+	}
+	if w := do(r, "admin", http.MethodPost, "/api/queries/team-a/own/approve", ""); w.Code != http.StatusForbidden {
+		t.Errorf("approve own query = %d, want 403", w.Code)
+	}
+}
+
+func TestPlan(t *testing.T) {
+	explained := &kubequeryv1beta1.PostgresQuery{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "team-a", Name: "explained"},
+		Status: kubequeryv1beta1.PostgresQueryStatus{
+			Phase: kubequeryv1beta1.PhaseSucceeded,
+			Plan:  `[{"Plan":{"Node Type":"Seq Scan","Relation Name":"orders","Startup Cost":0,"Total Cost":35,"Plan Rows":10,"Plan Width":6}}]`,
+		},
+	}
+	executed := &kubequeryv1beta1.PostgresQuery{ObjectMeta: metav1.ObjectMeta{Namespace: "team-a", Name: "executed"}}
+	r, _, _ := newServer(t, explained, executed)
+
+	w := do(r, "viewer", http.MethodGet, "/api/queries/team-a/explained/plan", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("plan = %d: %s", w.Code, w.Body)
+	}
+	var plan struct {
+		Root struct {
+			NodeType string `json:"nodeType"`
+			Relation string `json:"relation"`
+		} `json:"root"`
+		HotSpots []int `json:"hotSpots"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &plan); err != nil {
+		t.Fatal(err)
+	}
+	if plan.Root.NodeType != "Seq Scan" || plan.Root.Relation != "orders" || len(plan.HotSpots) != 1 {
+		t.Errorf("plan = %s", w.Body)
+	}
+	if w := do(r, "viewer", http.MethodGet, "/api/queries/team-a/executed/plan", ""); w.Code != http.StatusNotFound {
+		t.Errorf("plan of an executed query = %d, want 404", w.Code)
+	}
+	if w := do(r, "nobody", http.MethodGet, "/api/queries/team-a/explained/plan", ""); w.Code != http.StatusForbidden {
+		t.Errorf("plan without list = %d, want 403", w.Code)
+	}
+}
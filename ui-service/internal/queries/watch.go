@@ -0,0 +1,161 @@
+package queries
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/tools/cache"
+	watchtools "k8s.io/client-go/tools/watch"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	kubequeryv1beta1 "github.com/rsavage/KubeQuery/api/v1beta1"
+)
+
+// keepAliveInterval is how often an idle stream sends a comment, so that
+// proxies do not close it.
+const keepAliveInterval = 30 * time.Second
+
+// Event is a Kubernetes Event about a query, e.g. Started, LockTimeout or
+// Succeeded.
+type Event struct {
+	Type    string    `json:"type"`
+	Reason  string    `json:"reason"`
+	Message string    `json:"message"`
+	Count   int32     `json:"count,omitempty"`
+	Time    time.Time `json:"time"`
+}
+
+func newEvent(e *corev1.Event) Event {
+	t := e.LastTimestamp.Time
+	switch {
+	case !e.EventTime.IsZero() && t.IsZero():
+		t = e.EventTime.Time
+	case t.IsZero():
+		t = e.CreationTimestamp.Time
+	}
+	return Event{Type: e.Type, Reason: e.Reason, Message: e.Message, Count: e.Count, Time: t}
+}
+
+// watch streams a query as Server-Sent Events until the query is deleted or
+// the client disconnects:
+//
+//	event: query    a Detail, first the current state, then after every change
+//	event: event    an Event, first past events, then new ones as they happen
+//	event: deleted  the query was deleted; the stream ends
+//	event: error    the watch failed; the stream ends
+//
+// Clients should close the stream once the phase is final, as the controller
+// emits no further changes.
+func (h *Handler) watch(c *gin.Context) {
+	ctx := c.Request.Context()
+	ns, name := c.Param("namespace"), c.Param("name")
+	var pq kubequeryv1beta1.PostgresQuery
+	if err := h.Client.Get(ctx, types.NamespacedName{Namespace: ns, Name: name}, &pq); err != nil {
+		Abort(c, err)
+		return
+	}
+	eventSelector := fields.Set{"involvedObject.kind": "PostgresQuery", "involvedObject.name": name}.AsSelector().String()
+	past, err := h.Events.Events(ns).List(ctx, metav1.ListOptions{FieldSelector: eventSelector})
+	if err != nil {
+		Abort(c, err)
+		return
+	}
+
+	// RetryWatchers resume from the last seen version when the API server
+	// closes a watch, as it does periodically.
+	queries, err := watchtools.NewRetryWatcher(pq.ResourceVersion, &cache.ListWatch{
+		WatchFunc: func(opts metav1.ListOptions) (watch.Interface, error) {
+			return h.Client.Watch(ctx, &kubequeryv1beta1.PostgresQueryList{}, &client.ListOptions{
+				Namespace:     ns,
+				FieldSelector: fields.OneTermEqualSelector("metadata.name", name),
+				Raw:           &opts,
+			})
+		},
+	})
+	if err != nil {
+		Abort(c, err)
+		return
+	}
+	defer queries.Stop()
+	events, err := watchtools.NewRetryWatcher(past.ResourceVersion, &cache.ListWatch{
+		WatchFunc: func(opts metav1.ListOptions) (watch.Interface, error) {
+			opts.FieldSelector = eventSelector
+			return h.Events.Events(ns).Watch(ctx, opts)
+		},
+	})
+	if err != nil {
+		Abort(c, err)
+		return
+	}
+	defer events.Stop()
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+	send := func(event string, data any) {
+		c.SSEvent(event, data)
+		c.Writer.Flush()
+	}
+
+	send("query", Detail{Summary: Summarize(&pq), Spec: pq.Spec, Status: pq.Status})
+	for i := range past.Items {
+		// Events of an earlier query with the same name are skipped.
+		if e := &past.Items[i]; e.InvolvedObject.UID == pq.UID {
+			send("event", newEvent(e))
+		}
+	}
+
+	keepAlive := time.NewTicker(keepAliveInterval)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-keepAlive.C:
+			fmt.Fprint(c.Writer, ": keep-alive\n\n")
+			c.Writer.Flush()
+		case ev, ok := <-queries.ResultChan():
+			if !ok {
+				send("error", gin.H{"error": "watch closed"})
+				return
+			}
+			switch ev.Type {
+			case watch.Added, watch.Modified:
+				if obj, ok := ev.Object.(*kubequeryv1beta1.PostgresQuery); ok && obj.UID == pq.UID {
+					send("query", Detail{Summary: Summarize(obj), Spec: obj.Spec, Status: obj.Status})
+				}
+			case watch.Deleted:
+				if obj, ok := ev.Object.(*kubequeryv1beta1.PostgresQuery); ok && obj.UID == pq.UID {
+					send("deleted", gin.H{"namespace": ns, "name": name})
+					return
+				}
+			case watch.Error:
+				send("error", gin.H{"error": apierrors.FromObject(ev.Object).Error()})
+				return
+			}
+		case ev, ok := <-events.ResultChan():
+			if !ok {
+				send("error", gin.H{"error": "watch closed"})
+				return
+			}
+			switch ev.Type {
+			case watch.Added, watch.Modified:
+				if e, ok := ev.Object.(*corev1.Event); ok && e.InvolvedObject.UID == pq.UID {
+					send("event", newEvent(e))
+				}
+			case watch.Error:
+				send("error", gin.H{"error": apierrors.FromObject(ev.Object).Error()})
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,161 @@
+package queries
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	k8stesting "k8s.io/client-go/testing"
+
+	kubequeryv1beta1 "github.com/rsavage/KubeQuery/api/v1beta1"
+)
+
+// sseEvent is an event read from a Server-Sent Events stream.
+type sseEvent struct {
+	name string
+	data string
+}
+
+// readEvents sends the events of the stream in body to the returned channel,
+// which is closed when the stream ends.
+func readEvents(body *bufio.Reader) <-chan sseEvent {
+	ch := make(chan sseEvent)
+	go func() {
+		defer close(ch)
+		var ev sseEvent
+		for {
+			line, err := body.ReadString('\n')
+			if err != nil {
+				return
+			}
+			line = strings.TrimRight(line, "\n")
+			switch {
+			case strings.HasPrefix(line, "event:"):
+				ev.name = strings.TrimPrefix(line, "event:")
+			case strings.HasPrefix(line, "data:"):
+				ev.data += strings.TrimPrefix(line, "data:")
+			case line == "" && ev.name != "":
+				ch <- ev
+				ev = sseEvent{}
+			}
+		}
+	}()
+	return ch
+}
+
+func TestWatch(t *testing.T) {
+	pq := &kubequeryv1beta1.PostgresQuery{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "team-a", Name: "backfill", UID: "uid-1"},
+		Spec: kubequeryv1beta1.PostgresQuerySpec{
+			ConnectionRef: &kubequeryv1beta1.ConnectionReference{Name: "orders"},
+			SQLSource:     kubequeryv1beta1.SQLSource{Inline: "UPDATE orders SET x = 1"},
+		},
+	}
+	r, c, kube := newServer(t, pq)
+	// The fake clientset sets no resource versions, which the API server
+	// always sets on lists and objects and watches resume from.
+	kube.PrependReactor("list", "events", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		obj, err := kube.Tracker().List(corev1.SchemeGroupVersion.WithResource("events"),
+			corev1.SchemeGroupVersion.WithKind("Event"), action.GetNamespace())
+		if err != nil {
+			return true, nil, err
+		}
+		list := obj.(*corev1.EventList)
+		list.ResourceVersion = "1"
+		return true, list, nil
+	})
+	ctx := context.Background()
+	event := func(name, reason, uid string) *corev1.Event {
+		return &corev1.Event{
+			ObjectMeta:     metav1.ObjectMeta{Namespace: "team-a", Name: name, ResourceVersion: "2"},
+			InvolvedObject: corev1.ObjectReference{Kind: "PostgresQuery", Namespace: "team-a", Name: "backfill", UID: types.UID("uid-" + uid)},
+			Type:           corev1.EventTypeNormal,
+			Reason:         reason,
+			Message:        reason + " message",
+			LastTimestamp:  metav1.Now(),
+		}
+	}
+	for _, e := range []*corev1.Event{event("old", "Succeeded", "0"), event("started", "Started", "1")} {
+		if _, err := kube.CoreV1().Events("team-a").Create(ctx, e, metav1.CreateOptions{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+	if w := do(r, "nobody", http.MethodGet, "/api/queries/team-a/backfill/watch", ""); w.Code != http.StatusForbidden {
+		t.Fatalf("watch without permission = %d, want 403", w.Code)
+	}
+	req, _ := http.NewRequest(http.MethodGet, srv.URL+"/api/queries/team-a/backfill/watch", nil)
+	req.Header.Set("X-User", "viewer")
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	if ct := res.Header.Get("Content-Type"); !strings.HasPrefix(ct, "text/event-stream") {
+		b, _ := io.ReadAll(res.Body)
+		t.Fatalf("Content-Type = %q: %s", ct, b)
+	}
+	stream := readEvents(bufio.NewReader(res.Body))
+	next := func(want string) string {
+		t.Helper()
+		select {
+		case ev, ok := <-stream:
+			if !ok {
+				t.Fatalf("stream ended, want %s", want)
+			}
+			if ev.name != want {
+				t.Fatalf("got %s event %s, want %s", ev.name, ev.data, want)
+			}
+			return ev.data
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out waiting for %s", want)
+		}
+		return ""
+	}
+	var detail Detail
+	if err := json.Unmarshal([]byte(next("query")), &detail); err != nil || detail.Name != "backfill" {
+		t.Fatalf("initial query = %+v, %v", detail, err)
+	}
+	// Only the event of this query, not of an earlier one with its name.
+	var e Event
+	if err := json.Unmarshal([]byte(next("event")), &e); err != nil || e.Reason != "Started" {
+		t.Fatalf("past event = %+v, %v", e, err)
+	}
+
+	pq.Status.Phase = kubequeryv1beta1.PhaseSucceeded
+	pq.Status.Result = "UPDATE 3"
+	if err := c.Status().Update(ctx, pq); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal([]byte(next("query")), &detail); err != nil ||
+		detail.Phase != kubequeryv1beta1.PhaseSucceeded || detail.Result != "UPDATE 3" {
+		t.Fatalf("updated query = %+v, %v", detail, err)
+	}
+
+	if _, err := kube.CoreV1().Events("team-a").Create(ctx, event("done", "Succeeded", "1"), metav1.CreateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal([]byte(next("event")), &e); err != nil || e.Reason != "Succeeded" {
+		t.Fatalf("new event = %+v, %v", e, err)
+	}
+
+	if err := c.Delete(ctx, pq); err != nil {
+		t.Fatal(err)
+	}
+	next("deleted")
+	if _, ok := <-stream; ok {
+		t.Error("stream continued after deletion")
+	}
+}
@@ -0,0 +1,358 @@
+package saved
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	kubequeryv1beta1 "github.com/rsavage/KubeQuery/api/v1beta1"
+
+	"github.com/rsavage/KubeQuery/ui-service/internal/auth"
+	"github.com/rsavage/KubeQuery/ui-service/internal/authz"
+	"github.com/rsavage/KubeQuery/ui-service/internal/console"
+	"github.com/rsavage/KubeQuery/ui-service/internal/queries"
+)
+
+// SavedQueryAnnotation records the ID of the saved query a PostgresQuery
+// was promoted from.
+const SavedQueryAnnotation = "kubequery.cloudnexus.io/saved-query"
+
+const (
+	// defaultLimit is how many saved queries or executions are listed when
+	// the request sets no limit.
+	defaultLimit = 100
+	// maxLimit is the most listed.
+	maxLimit = 1000
+)
+
+// Handler serves the saved query and history endpoints.
+type Handler struct {
+	Store *Store
+	// Client creates the PostgresQuery objects saved queries are promoted
+	// to.
+	Client client.Client
+	Authz  *authz.Authorizer
+	// Console runs saved queries.
+	Console *console.Handler
+}
+
+// Register adds the saved query and history routes to r, which must run
+// behind auth.Authenticator.Middleware. Users see their own saved queries,
+// public ones, and those shared with the namespaces they may list queries
+// in; they may change only their own:
+//
+//	GET    /saved              list saved queries, by name; ?search= filters
+//	POST   /saved              save a query
+//	GET    /saved/:id          get a saved query
+//	PUT    /saved/:id          replace a saved query
+//	DELETE /saved/:id          delete a saved query
+//	POST   /saved/:id/run      run a saved query in the console
+//	POST   /saved/:id/promote  create a PostgresQuery from a saved query
+//	GET    /history            list the user's executions, newest first
+func (h *Handler) Register(r gin.IRouter) {
+	r.GET("/saved", h.list)
+	r.POST("/saved", h.create)
+	r.GET("/saved/:id", h.get)
+	r.PUT("/saved/:id", h.update)
+	r.DELETE("/saved/:id", h.delete)
+	r.POST("/saved/:id/run", h.run)
+	r.POST("/saved/:id/promote", h.promote)
+	r.GET("/history", h.history)
+}
+
+// RunRequest is the body of POST /saved/:id/run.
+type RunRequest struct {
+	// Args are the values of the query's parameters by name; parameters
+	// left out take their defaults.
+	Args map[string]string `json:"args,omitempty"`
+}
+
+// PromoteRequest is the body of POST /saved/:id/promote. The parameters
+// are inlined into the SQL of the PostgresQuery as literals.
+type PromoteRequest struct {
+	RunRequest
+	// Name of the query; generated when empty.
+	Name            string                         `json:"name,omitempty"`
+	Options         *kubequeryv1beta1.QueryOptions `json:"options,omitempty"`
+	ExecutionMode   kubequeryv1beta1.ExecutionMode `json:"executionMode,omitempty"`
+	RequireApproval bool                           `json:"requireApproval,omitempty"`
+}
+
+// limit returns the limit query parameter, bounded by maxLimit.
+func limit(c *gin.Context) int {
+	n, err := strconv.Atoi(c.Query("limit"))
+	if err != nil || n <= 0 {
+		return defaultLimit
+	}
+	return min(n, maxLimit)
+}
+
+func (h *Handler) list(c *gin.Context) {
+	user, ok := requireUser(c)
+	if !ok {
+		return
+	}
+	ctx := c.Request.Context()
+	// The namespaces whose shared queries the user may see are passed to
+	// the store, so that the limit applies to visible queries only.
+	shared, err := h.Store.SharedNamespaces(ctx, user.Name)
+	if err != nil {
+		fail(c, err)
+		return
+	}
+	namespaces := []string{}
+	for _, ns := range shared {
+		ok, _, err := h.Authz.Allowed(ctx, user, authz.ActionList, ns)
+		if err != nil {
+			fail(c, err)
+			return
+		}
+		if ok {
+			namespaces = append(namespaces, ns)
+		}
+	}
+	items, err := h.Store.List(ctx, user.Name, c.Query("search"), namespaces, limit(c))
+	if err != nil {
+		fail(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"items": items})
+}
+
+func (h *Handler) create(c *gin.Context) {
+	user, ok := requireUser(c)
+	if !ok {
+		return
+	}
+	var q Query
+	if !h.bind(c, user, &q) {
+		return
+	}
+	if err := h.Store.Create(c.Request.Context(), &q); err != nil {
+		fail(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, q)
+}
+
+func (h *Handler) get(c *gin.Context) {
+	if q, _, ok := h.lookup(c); ok {
+		c.JSON(http.StatusOK, q)
+	}
+}
+
+func (h *Handler) update(c *gin.Context) {
+	user, ok := requireUser(c)
+	if !ok {
+		return
+	}
+	id, ok := idParam(c)
+	if !ok {
+		return
+	}
+	var q Query
+	if !h.bind(c, user, &q) {
+		return
+	}
+	q.ID = id
+	if err := h.Store.Update(c.Request.Context(), &q); err != nil {
+		fail(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, q)
+}
+
+func (h *Handler) delete(c *gin.Context) {
+	user, ok := requireUser(c)
+	if !ok {
+		return
+	}
+	id, ok := idParam(c)
+	if !ok {
+		return
+	}
+	if err := h.Store.Delete(c.Request.Context(), id, user.Name); err != nil {
+		fail(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// run runs a saved query through the console, which requires the console
+// permission in the query's namespace, and responds as the console does.
+func (h *Handler) run(c *gin.Context) {
+	q, user, ok := h.lookup(c)
+	if !ok {
+		return
+	}
+	var req RunRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+	args, err := q.Args(req.Args)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !h.allowed(c, user, authz.ActionConsole, q.Namespace) {
+		return
+	}
+	h.Console.Execute(c, q.Namespace, console.Request{ConnectionRef: q.ConnectionRef, SQL: q.SQL, Args: args})
+}
+
+// promote creates a PostgresQuery from a saved query, with its parameters
+// inlined, for statements that change data and so cannot run in the
+// console. It requires the create permission in the query's namespace.
+func (h *Handler) promote(c *gin.Context) {
+	q, user, ok := h.lookup(c)
+	if !ok {
+		return
+	}
+	var req PromoteRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+	args, err := q.Args(req.Args)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !h.allowed(c, user, authz.ActionCreate, q.Namespace) {
+		return
+	}
+	pq := queries.NewQuery(q.Namespace, user.Name, queries.CreateRequest{
+		Name:            req.Name,
+		ConnectionRef:   q.ConnectionRef,
+		SQL:             q.Inline(args),
+		Options:         req.Options,
+		ExecutionMode:   req.ExecutionMode,
+		RequireApproval: req.RequireApproval,
+	})
+	pq.Annotations[SavedQueryAnnotation] = strconv.FormatInt(q.ID, 10)
+	if err := h.Client.Create(c.Request.Context(), pq); err != nil {
+		queries.Abort(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, queries.Detail{Summary: queries.Summarize(pq), Spec: pq.Spec, Status: pq.Status})
+}
+
+func (h *Handler) history(c *gin.Context) {
+	user, ok := requireUser(c)
+	if !ok {
+		return
+	}
+	filter := HistoryFilter{
+		Namespace:     c.Query("namespace"),
+		ConnectionRef: c.Query("connection"),
+		SQLHash:       c.Query("sqlHash"),
+	}
+	items, err := h.Store.History(c.Request.Context(), user.Name, filter, limit(c))
+	if err != nil {
+		fail(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"items": items})
+}
+
+// bind reads a saved query of user from the request body and checks it,
+// and that the user may list queries in its namespace. If it cannot, it
+// writes the error response and returns false.
+func (h *Handler) bind(c *gin.Context, user auth.User, q *Query) bool {
+	if err := c.ShouldBindJSON(q); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return false
+	}
+	q.Owner = user.Name
+	if q.Scope == "" {
+		q.Scope = ScopePrivate
+	}
+	if err := q.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return false
+	}
+	return h.allowed(c, user, authz.ActionList, q.Namespace)
+}
+
+// lookup returns the saved query of the id URL parameter, if the user may
+// see it. If not, it writes the error response and returns false.
+func (h *Handler) lookup(c *gin.Context) (*Query, auth.User, bool) {
+	user, ok := requireUser(c)
+	if !ok {
+		return nil, user, false
+	}
+	id, ok := idParam(c)
+	if !ok {
+		return nil, user, false
+	}
+	q, err := h.Store.Get(c.Request.Context(), id)
+	if err != nil {
+		fail(c, err)
+		return nil, user, false
+	}
+	visible := q.Owner == user.Name || q.Scope == ScopePublic
+	if !visible && q.Scope == ScopeNamespace {
+		if visible, _, err = h.Authz.Allowed(c.Request.Context(), user, authz.ActionList, q.Namespace); err != nil {
+			fail(c, err)
+			return nil, user, false
+		}
+	}
+	// Queries the user may not see are not found, so as not to reveal them.
+	if !visible {
+		fail(c, ErrNotFound)
+		return nil, user, false
+	}
+	return q, user, true
+}
+
+// allowed reports whether user may perform action in namespace, writing
+// the error response if not.
+func (h *Handler) allowed(c *gin.Context, user auth.User, action authz.Action, namespace string) bool {
+	ok, reason, err := h.Authz.Allowed(c.Request.Context(), user, action, namespace)
+	if err != nil {
+		fail(c, err)
+		return false
+	}
+	if !ok {
+		msg := fmt.Sprintf("%s may not %s PostgresQuery objects in namespace %q", user.Name, action, namespace)
+		if reason != "" {
+			msg += ": " + reason
+		}
+		c.JSON(http.StatusForbidden, gin.H{"error": msg})
+	}
+	return ok
+}
+
+func requireUser(c *gin.Context) (auth.User, bool) {
+	user, ok := auth.UserFrom(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "authentication required"})
+	}
+	return user, ok
+}
+
+func idParam(c *gin.Context) (int64, bool) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		fail(c, ErrNotFound)
+		return 0, false
+	}
+	return id, true
+}
+
+// fail responds with the status of a Store error.
+func fail(c *gin.Context, err error) {
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, ErrNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, ErrExists):
+		status = http.StatusConflict
+	}
+	c.JSON(status, gin.H{"error": err.Error()})
+}
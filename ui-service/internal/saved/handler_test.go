@@ -0,0 +1,432 @@
+package saved
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgconn"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	k8stesting "k8s.io/client-go/testing"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	kubequeryv1beta1 "github.com/rsavage/KubeQuery/api/v1beta1"
+	"github.com/rsavage/KubeQuery/pkg/db"
+	"github.com/rsavage/KubeQuery/pkg/db/dbtest"
+
+	"github.com/rsavage/KubeQuery/ui-service/internal/audit"
+	"github.com/rsavage/KubeQuery/ui-service/internal/auth"
+	"github.com/rsavage/KubeQuery/ui-service/internal/authz"
+	"github.com/rsavage/KubeQuery/ui-service/internal/connections"
+	"github.com/rsavage/KubeQuery/ui-service/internal/console"
+	"github.com/rsavage/KubeQuery/ui-service/internal/queries"
+)
+
+// grants maps users to the verbs RBAC allows them, by namespace.
+var grants = map[string]map[string][]string{
+	"alice": {"team-a": {"list", "console", "create"}},
+	"bob":   {"team-a": {"list"}},
+	"carol": {"team-b": {"list", "console"}},
+}
+
+// fakeDB answers the statements of the Store, keeping its tables in
+// memory, and answers console statements with their arguments.
+type fakeDB struct {
+	mu      sync.Mutex
+	saved   map[int64]Query
+	history []audit.Record
+	nextID  int64
+}
+
+var savedCols = []db.Column{{Name: "id"}, {Name: "name"}, {Name: "description"}, {Name: "owner"}, {Name: "scope"},
+	{Name: "namespace"}, {Name: "connection"}, {Name: "sql"}, {Name: "parameters"}, {Name: "created_at"}, {Name: "updated_at"}}
+
+func savedRow(q Query) []any {
+	params, _ := json.Marshal(q.Parameters)
+	return []any{q.ID, q.Name, q.Description, q.Owner, string(q.Scope), q.Namespace, q.ConnectionRef, q.SQL, string(params), q.CreatedAt, q.UpdatedAt}
+}
+
+func queryFrom(args []any) (Query, error) {
+	q := Query{Name: args[0].(string), Description: args[1].(string), Owner: args[2].(string), Scope: Scope(args[3].(string)),
+		Namespace: args[4].(string), ConnectionRef: args[5].(string), SQL: args[6].(string)}
+	return q, json.Unmarshal([]byte(args[7].(string)), &q.Parameters)
+}
+
+func (f *fakeDB) handle(_ context.Context, sql string, args []any) (dbtest.Result, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	now := time.Now().UTC()
+	switch {
+	case strings.HasPrefix(sql, "CREATE"):
+		return dbtest.Result{Tag: "CREATE"}, nil
+	case strings.HasPrefix(sql, "SET"):
+		return dbtest.Result{Tag: "SET"}, nil
+	case strings.HasPrefix(sql, `INSERT INTO "kubequery_ui".saved_queries`):
+		q, err := queryFrom(args)
+		if err != nil {
+			return dbtest.Result{}, err
+		}
+		for _, other := range f.saved {
+			if other.Owner == q.Owner && other.Name == q.Name {
+				return dbtest.Result{}, &pgconn.PgError{Code: "23505", Message: "duplicate key value"}
+			}
+		}
+		f.nextID++
+		q.ID, q.CreatedAt, q.UpdatedAt = f.nextID, now, now
+		f.saved[q.ID] = q
+		return dbtest.Result{Columns: savedCols, Rows: [][]any{savedRow(q)}}, nil
+	case strings.HasPrefix(sql, `UPDATE "kubequery_ui".saved_queries`):
+		old, ok := f.saved[args[0].(int64)]
+		if !ok || old.Owner != args[1] {
+			return dbtest.Result{Columns: savedCols}, nil
+		}
+		q, err := queryFrom(append([]any{args[2], args[3], args[1]}, args[4:]...))
+		if err != nil {
+			return dbtest.Result{}, err
+		}
+		q.ID, q.CreatedAt, q.UpdatedAt = old.ID, old.CreatedAt, now
+		f.saved[q.ID] = q
+		return dbtest.Result{Columns: savedCols, Rows: [][]any{savedRow(q)}}, nil
+	case strings.HasPrefix(sql, `DELETE FROM "kubequery_ui".saved_queries`):
+		if q, ok := f.saved[args[0].(int64)]; ok && q.Owner == args[1] {
+			delete(f.saved, q.ID)
+			return dbtest.Result{Tag: "DELETE 1"}, nil
+		}
+		return dbtest.Result{Tag: "DELETE 0"}, nil
+	case strings.Contains(sql, `FROM "kubequery_ui".saved_queries WHERE id = $1`):
+		res := dbtest.Result{Columns: savedCols}
+		if q, ok := f.saved[args[0].(int64)]; ok {
+			res.Rows = append(res.Rows, savedRow(q))
+		}
+		return res, nil
+	case strings.HasPrefix(sql, `SELECT DISTINCT namespace FROM "kubequery_ui".saved_queries`):
+		seen := map[string]bool{}
+		res := dbtest.Result{Columns: []db.Column{{Name: "namespace"}}}
+		for _, q := range f.saved {
+			if q.Scope == ScopeNamespace && q.Owner != args[0] && !seen[q.Namespace] {
+				seen[q.Namespace] = true
+				res.Rows = append(res.Rows, []any{q.Namespace})
+			}
+		}
+		return res, nil
+	case strings.Contains(sql, `FROM "kubequery_ui".saved_queries`):
+		search := strings.ToLower(strings.Trim(args[1].(string), "%"))
+		var items []Query
+		for _, q := range f.saved {
+			visible := q.Owner == args[0] || q.Scope == ScopePublic ||
+				q.Scope == ScopeNamespace && slices.Contains(args[3].([]string), q.Namespace)
+			if visible && strings.Contains(strings.ToLower(q.Name+q.Description+q.SQL), search) {
+				items = append(items, q)
+			}
+		}
+		sort.Slice(items, func(i, j int) bool { return items[i].Name < items[j].Name })
+		res := dbtest.Result{Columns: savedCols}
+		for _, q := range items[:min(len(items), args[2].(int))] {
+			res.Rows = append(res.Rows, savedRow(q))
+		}
+		return res, nil
+	case strings.HasPrefix(sql, `INSERT INTO "kubequery_ui".history`):
+		f.history = append(f.history, audit.Record{User: args[0].(string), Time: args[1].(time.Time), Action: args[2].(string),
+			Namespace: args[4].(string), Connection: args[5].(string), SQLHash: args[6].(string),
+			RowCount: int(args[8].(int64)), Error: args[10].(string)})
+		return dbtest.Result{Tag: "INSERT 0 1"}, nil
+	case strings.Contains(sql, `FROM "kubequery_ui".history`):
+		res := dbtest.Result{Columns: make([]db.Column, 11)}
+		for i := len(f.history) - 1; i >= 0; i-- {
+			r := f.history[i]
+			if r.User != args[0] || args[1] != "" && r.Namespace != args[1] {
+				continue
+			}
+			res.Rows = append(res.Rows, []any{int64(i + 1), r.Time, r.Action, "", r.Namespace, r.Connection, r.SQLHash,
+				int64(0), int64(r.RowCount), false, r.Error})
+		}
+		return res, nil
+	}
+	// A console statement: its arguments are the result.
+	res := dbtest.Result{Columns: []db.Column{{Name: "arg", Type: "text", OID: 25}}}
+	for _, a := range args {
+		res.Rows = append(res.Rows, []any{a})
+	}
+	return res, nil
+}
+
+func connection(ns, name string) *kubequeryv1beta1.PostgresConnection {
+	return &kubequeryv1beta1.PostgresConnection{
+		ObjectMeta: metav1.ObjectMeta{Namespace: ns, Name: name},
+		Spec: kubequeryv1beta1.PostgresConnectionSpec{
+			Host:              "db",
+			Port:              5432,
+			Database:          name,
+			User:              "ui",
+			PasswordSecretRef: &kubequeryv1beta1.SecretKeySelector{Name: "db", Key: "password"},
+		},
+	}
+}
+
+func newServer(t *testing.T) (http.Handler, *fakeDB, client.Client) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	if err := kubequeryv1beta1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	var objs []client.Object
+	for _, ns := range []string{"kubequery", "team-a", "team-b"} {
+		objs = append(objs, &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Namespace: ns, Name: "db"},
+			Data:       map[string][]byte{"password": []byte("s3cr3t-pass")},
+		})
+	}
+	objs = append(objs, connection("kubequery", "ui"), connection("team-a", "orders"), connection("team-b", "billing"))
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(objs...).Build()
+
+	kube := kubefake.NewSimpleClientset()
+	kube.PrependReactor("create", "subjectaccessreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		sar := action.(k8stesting.CreateAction).GetObject().(*authorizationv1.SubjectAccessReview)
+		for _, verb := range grants[sar.Spec.User][sar.Spec.ResourceAttributes.Namespace] {
+			sar.Status.Allowed = sar.Status.Allowed || verb == sar.Spec.ResourceAttributes.Verb
+		}
+		return true, sar, nil
+	})
+	authorizer := authz.New(kube.AuthorizationV1().SubjectAccessReviews())
+
+	fdb := &fakeDB{saved: map[int64]Query{}}
+	driver := &dbtest.Driver{Handler: fdb.handle}
+	pools := &connections.Pools{Reader: c, ApplicationName: console.ApplicationName, Connect: driver.Connect}
+	store := &Store{Client: c, Pools: pools, Connection: types.NamespacedName{Namespace: "kubequery", Name: "ui"}}
+	consoleAPI := &console.Handler{Client: c, Authz: authorizer, Audit: audit.New(io.Discard), History: store, Pools: pools}
+
+	r := gin.New()
+	api := r.Group("/api", func(c *gin.Context) {
+		auth.SetUser(c, auth.User{Name: c.GetHeader("X-User")})
+	})
+	consoleAPI.Register(api)
+	(&Handler{Store: store, Client: c, Authz: authorizer, Console: consoleAPI}).Register(api)
+	return r, fdb, c
+}
+
+func do(r http.Handler, user, method, path, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-User", user)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func names(t *testing.T, w *httptest.ResponseRecorder) []string {
+	t.Helper()
+	var res struct{ Items []Query }
+	if err := json.Unmarshal(w.Body.Bytes(), &res); err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, q := range res.Items {
+		names = append(names, q.Name)
+	}
+	return names
+}
+
+func TestSavedQueries(t *testing.T) {
+	r, _, _ := newServer(t)
+	save := func(user, scope, ns, conn, name string) Query {
+		t.Helper()
+		body := fmt.Sprintf(`{"name":%q,"scope":%q,"namespace":%q,"connectionRef":%q,"sql":"SELECT $1","parameters":[{"name":"p"}]}`,
+			name, scope, ns, conn)
+		w := do(r, user, http.MethodPost, "/api/saved", body)
+		if w.Code != http.StatusCreated {
+			t.Fatalf("%s save %s = %d %s", user, name, w.Code, w.Body)
+		}
+		var q Query
+		if err := json.Unmarshal(w.Body.Bytes(), &q); err != nil {
+			t.Fatal(err)
+		}
+		return q
+	}
+	mine := save("alice", "private", "team-a", "orders", "a-private")
+	shared := save("alice", "namespace", "team-a", "orders", "a-team")
+	save("alice", "public", "team-a", "orders", "a-public")
+	save("carol", "namespace", "team-b", "billing", "c-team")
+	if mine.ID == 0 || mine.Owner != "alice" || mine.CreatedAt.IsZero() {
+		t.Errorf("saved query = %+v, want an ID, owner and creation time", mine)
+	}
+
+	if w := do(r, "alice", http.MethodPost, "/api/saved",
+		`{"name":"a-private","namespace":"team-a","connectionRef":"orders","sql":"SELECT 1"}`); w.Code != http.StatusConflict {
+		t.Errorf("duplicate name = %d, want 409", w.Code)
+	}
+	if w := do(r, "bob", http.MethodPost, "/api/saved",
+		`{"name":"x","namespace":"team-b","connectionRef":"billing","sql":"SELECT 1"}`); w.Code != http.StatusForbidden {
+		t.Errorf("saving for a namespace bob may not list = %d, want 403", w.Code)
+	}
+	if w := do(r, "alice", http.MethodPost, "/api/saved",
+		`{"name":"x","namespace":"team-a","connectionRef":"orders","sql":"SELECT $2"}`); w.Code != http.StatusBadRequest {
+		t.Errorf("saving with an undeclared parameter = %d, want 400", w.Code)
+	}
+
+	for user, want := range map[string]string{
+		"alice": "a-private,a-public,a-team",
+		"bob":   "a-public,a-team",
+		"carol": "a-public,c-team",
+	} {
+		if got := strings.Join(names(t, do(r, user, http.MethodGet, "/api/saved", "")), ","); got != want {
+			t.Errorf("%s lists %s, want %s", user, got, want)
+		}
+	}
+	if got := names(t, do(r, "alice", http.MethodGet, "/api/saved?search=PUB", "")); len(got) != 1 || got[0] != "a-public" {
+		t.Errorf("search lists %v, want [a-public]", got)
+	}
+	// a-team, which carol may not see, sorts before c-team.
+	if got := strings.Join(names(t, do(r, "carol", http.MethodGet, "/api/saved?limit=2", "")), ","); got != "a-public,c-team" {
+		t.Errorf("carol lists %s with a limit of 2, want a-public,c-team", got)
+	}
+
+	path := fmt.Sprintf("/api/saved/%d", mine.ID)
+	if w := do(r, "bob", http.MethodGet, path, ""); w.Code != http.StatusNotFound {
+		t.Errorf("bob gets alice's private query = %d, want 404", w.Code)
+	}
+	if w := do(r, "carol", http.MethodGet, fmt.Sprintf("/api/saved/%d", shared.ID), ""); w.Code != http.StatusNotFound {
+		t.Errorf("carol gets a query shared with team-a = %d, want 404", w.Code)
+	}
+	if w := do(r, "bob", http.MethodGet, fmt.Sprintf("/api/saved/%d", shared.ID), ""); w.Code != http.StatusOK {
+		t.Errorf("bob gets a query shared with team-a = %d, want 200", w.Code)
+	}
+
+	update := `{"name":"renamed","scope":"private","namespace":"team-a","connectionRef":"orders","sql":"SELECT 2"}`
+	if w := do(r, "bob", http.MethodPut, fmt.Sprintf("/api/saved/%d", shared.ID), update); w.Code != http.StatusNotFound {
+		t.Errorf("bob updates alice's query = %d, want 404", w.Code)
+	}
+	w := do(r, "alice", http.MethodPut, path, update)
+	var updated Query
+	if err := json.Unmarshal(w.Body.Bytes(), &updated); err != nil || w.Code != http.StatusOK {
+		t.Fatalf("update = %d %s", w.Code, w.Body)
+	}
+	if updated.ID != mine.ID || updated.Name != "renamed" || updated.SQL != "SELECT 2" || updated.Owner != "alice" {
+		t.Errorf("updated query = %+v", updated)
+	}
+
+	if w := do(r, "bob", http.MethodDelete, path, ""); w.Code != http.StatusNotFound {
+		t.Errorf("bob deletes alice's query = %d, want 404", w.Code)
+	}
+	if w := do(r, "alice", http.MethodDelete, path, ""); w.Code != http.StatusNoContent {
+		t.Errorf("delete = %d, want 204", w.Code)
+	}
+	if w := do(r, "alice", http.MethodGet, path, ""); w.Code != http.StatusNotFound {
+		t.Errorf("get after delete = %d, want 404", w.Code)
+	}
+	if w := do(r, "alice", http.MethodGet, "/api/saved/abc", ""); w.Code != http.StatusNotFound {
+		t.Errorf("get with an invalid ID = %d, want 404", w.Code)
+	}
+}
+
+func TestRunAndHistory(t *testing.T) {
+	r, fdb, _ := newServer(t)
+	w := do(r, "alice", http.MethodPost, "/api/saved", `{"name":"by status","scope":"namespace","namespace":"team-a",
+		"connectionRef":"orders","sql":"SELECT * FROM orders WHERE status = $1 LIMIT $2",
+		"parameters":[{"name":"status"},{"name":"limit","type":"int8","default":"10"}]}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("save = %d %s", w.Code, w.Body)
+	}
+	var q Query
+	if err := json.Unmarshal(w.Body.Bytes(), &q); err != nil {
+		t.Fatal(err)
+	}
+	run := fmt.Sprintf("/api/saved/%d/run", q.ID)
+
+	if w := do(r, "bob", http.MethodPost, run, `{"args":{"status":"open"}}`); w.Code != http.StatusForbidden {
+		t.Errorf("bob runs without console = %d, want 403", w.Code)
+	}
+	if w := do(r, "alice", http.MethodPost, run, `{}`); w.Code != http.StatusBadRequest {
+		t.Errorf("run without a required value = %d, want 400", w.Code)
+	}
+	w = do(r, "alice", http.MethodPost, run, `{"args":{"status":"open"}}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("run = %d %s", w.Code, w.Body)
+	}
+	var res console.Result
+	if err := json.Unmarshal(w.Body.Bytes(), &res); err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(res.Rows) != "[[open] [10]]" {
+		t.Errorf("run sent args %v, want [[open] [10]]", res.Rows)
+	}
+
+	// Statements run from the console are recorded as well.
+	if w := do(r, "alice", http.MethodPost, "/api/console/team-a", `{"connectionRef":"orders","sql":"SELECT 1"}`); w.Code != http.StatusOK {
+		t.Fatalf("console = %d %s", w.Code, w.Body)
+	}
+	if len(fdb.history) != 2 {
+		t.Fatalf("history has %d records, want 2", len(fdb.history))
+	}
+
+	w = do(r, "alice", http.MethodGet, "/api/history?namespace=team-a", "")
+	var history struct{ Items []Execution }
+	if err := json.Unmarshal(w.Body.Bytes(), &history); err != nil || w.Code != http.StatusOK {
+		t.Fatalf("history = %d %s", w.Code, w.Body)
+	}
+	if len(history.Items) != 2 {
+		t.Fatalf("history lists %d executions, want 2", len(history.Items))
+	}
+	latest := history.Items[0]
+	if latest.SQLHash != audit.Hash("SELECT 1") || latest.User != "alice" || latest.ConnectionRef != "orders" ||
+		latest.RowCount != 0 || latest.Action != audit.ActionConsole {
+		t.Errorf("latest execution = %+v", latest)
+	}
+	if history.Items[1].SQLHash != audit.Hash(q.SQL) || history.Items[1].RowCount != 2 {
+		t.Errorf("first execution = %+v, want the saved query's", history.Items[1])
+	}
+	if w := do(r, "bob", http.MethodGet, "/api/history", ""); !strings.Contains(w.Body.String(), `"items":[]`) {
+		t.Errorf("bob's history = %s, want none", w.Body)
+	}
+}
+
+func TestPromote(t *testing.T) {
+	r, _, c := newServer(t)
+	w := do(r, "alice", http.MethodPost, "/api/saved", `{"name":"close","scope":"public","namespace":"team-a",
+		"connectionRef":"orders","sql":"UPDATE orders SET status = 'closed' WHERE id = $1",
+		"parameters":[{"name":"id","type":"int8"}]}`)
+	var q Query
+	if err := json.Unmarshal(w.Body.Bytes(), &q); err != nil || w.Code != http.StatusCreated {
+		t.Fatalf("save = %d %s", w.Code, w.Body)
+	}
+	promote := fmt.Sprintf("/api/saved/%d/promote", q.ID)
+
+	if w := do(r, "bob", http.MethodPost, promote, `{"args":{"id":"7"}}`); w.Code != http.StatusForbidden {
+		t.Errorf("bob promotes without create = %d, want 403", w.Code)
+	}
+	w = do(r, "alice", http.MethodPost, promote, `{"name":"close-7","args":{"id":"7"},"requireApproval":true}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("promote = %d %s", w.Code, w.Body)
+	}
+	var pq kubequeryv1beta1.PostgresQuery
+	if err := c.Get(context.Background(), types.NamespacedName{Namespace: "team-a", Name: "close-7"}, &pq); err != nil {
+		t.Fatal(err)
+	}
+	if want := "UPDATE orders SET status = 'closed' WHERE id = '7'::int8"; pq.Spec.SQLSource.Inline != want {
+		t.Errorf("promoted SQL = %q, want %q", pq.Spec.SQLSource.Inline, want)
+	}
+	if pq.Annotations[SavedQueryAnnotation] != fmt.Sprint(q.ID) || pq.Annotations[queries.SubmittedByAnnotation] != "alice" ||
+		!pq.Spec.RequireApproval || pq.Spec.ConnectionRef.Name != "orders" {
+		t.Errorf("promoted query = %+v %+v", pq.Annotations, pq.Spec)
+	}
+}
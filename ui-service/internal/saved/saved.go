@@ -0,0 +1,265 @@
+// Package saved keeps the statements UI users come back to: named queries
+// they save, with parameters, to share and run again, and the history of
+// the statements each of them ran from the console. Saved queries run
+// through the console, so read-only, or are promoted to PostgresQuery
+// objects to change data.
+package saved
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Scope is who can see and run a saved query besides its owner.
+type Scope string
+
+const (
+	// ScopePrivate queries are seen by their owner only.
+	ScopePrivate Scope = "private"
+	// ScopeNamespace queries are seen by the users who may list queries in
+	// their namespace.
+	ScopeNamespace Scope = "namespace"
+	// ScopePublic queries are seen by every user.
+	ScopePublic Scope = "public"
+)
+
+// Query is a saved query.
+type Query struct {
+	ID          int64  `json:"id"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Owner       string `json:"owner"`
+	Scope       Scope  `json:"scope"`
+	// Namespace and ConnectionRef name the PostgresConnection the query
+	// runs against.
+	Namespace     string `json:"namespace"`
+	ConnectionRef string `json:"connectionRef"`
+	SQL           string `json:"sql"`
+	// Parameters describe the $1, $2, ... parameters of SQL, in order.
+	Parameters []Parameter `json:"parameters"`
+	CreatedAt  time.Time   `json:"createdAt"`
+	UpdatedAt  time.Time   `json:"updatedAt"`
+}
+
+// Parameter is a parameter of a saved query.
+type Parameter struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	// Type is the PostgreSQL type of the parameter, e.g. int8 or date. It
+	// is optional: parameters are sent as text, which the server converts
+	// to the type the statement needs.
+	Type string `json:"type,omitempty"`
+	// Default is used when no value is given; without one, a value is
+	// required.
+	Default *string `json:"default,omitempty"`
+}
+
+// Execution is a statement a user ran, as recorded in their history.
+type Execution struct {
+	ID   int64     `json:"id"`
+	User string    `json:"user"`
+	Time time.Time `json:"time"`
+	// Action is console or export.
+	Action        string `json:"action"`
+	Format        string `json:"format,omitempty"`
+	Namespace     string `json:"namespace"`
+	ConnectionRef string `json:"connectionRef"`
+	// SQLHash is the hex SHA-256 digest of the statement; the statement
+	// itself is kept in the audit trail only.
+	SQLHash        string `json:"sqlHash"`
+	DurationMillis int64  `json:"durationMillis"`
+	RowCount       int64  `json:"rowCount"`
+	Truncated      bool   `json:"truncated,omitempty"`
+	Error          string `json:"error,omitempty"`
+}
+
+// typeName matches the type names parameters may declare, such as int8,
+// numeric(10, 2), timestamp with time zone or text[].
+var typeName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_ ]*(\([0-9, ]+\))?(\[\])*$`)
+
+// Validate checks q before it is saved.
+func (q *Query) Validate() error {
+	switch {
+	case strings.TrimSpace(q.Name) == "":
+		return errors.New("name is required")
+	case q.Namespace == "" || q.ConnectionRef == "":
+		return errors.New("namespace and connectionRef are required")
+	case strings.TrimSpace(q.SQL) == "":
+		return errors.New("sql is required")
+	}
+	switch q.Scope {
+	case ScopePrivate, ScopeNamespace, ScopePublic:
+	default:
+		return fmt.Errorf("scope must be %s, %s or %s", ScopePrivate, ScopeNamespace, ScopePublic)
+	}
+	seen := map[string]bool{}
+	for _, p := range q.Parameters {
+		if p.Name == "" || seen[p.Name] {
+			return fmt.Errorf("parameter names must be set and unique: %q", p.Name)
+		}
+		seen[p.Name] = true
+		if p.Type != "" && !typeName.MatchString(p.Type) {
+			return fmt.Errorf("invalid type %q of parameter %s", p.Type, p.Name)
+		}
+	}
+	var err error
+	scanPlaceholders(q.SQL, func(n int) string {
+		if n < 1 || n > len(q.Parameters) {
+			err = fmt.Errorf("$%d has no parameter: the query has %d", n, len(q.Parameters))
+		}
+		return ""
+	})
+	return err
+}
+
+// Args returns the values of q's parameters, in order, from values by
+// parameter name, falling back to the parameters' defaults.
+func (q *Query) Args(values map[string]string) ([]string, error) {
+	args := make([]string, len(q.Parameters))
+	for i, p := range q.Parameters {
+		v, ok := values[p.Name]
+		if !ok && p.Default != nil {
+			v, ok = *p.Default, true
+		}
+		if !ok {
+			return nil, fmt.Errorf("parameter %s requires a value", p.Name)
+		}
+		args[i] = v
+	}
+	for name := range values {
+		if !q.hasParameter(name) {
+			return nil, fmt.Errorf("the query has no parameter %s", name)
+		}
+	}
+	return args, nil
+}
+
+func (q *Query) hasParameter(name string) bool {
+	for _, p := range q.Parameters {
+		if p.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Inline returns q's SQL with its parameters replaced by args as literals,
+// cast to their types, for PostgresQuery objects, which have no
+// parameters.
+func (q *Query) Inline(args []string) string {
+	return scanPlaceholders(q.SQL, func(n int) string {
+		lit := quoteLiteral(args[n-1])
+		if t := q.Parameters[n-1].Type; t != "" {
+			lit += "::" + t
+		}
+		return lit
+	})
+}
+
+// quoteLiteral quotes s as a PostgreSQL string literal, as quote_literal
+// does: quotes are doubled, and strings with backslashes are escape strings
+// with the backslashes doubled, so the literal means s whatever
+// standard_conforming_strings is.
+func quoteLiteral(s string) string {
+	quoted := "'" + strings.ReplaceAll(s, "'", "''") + "'"
+	if strings.Contains(s, `\`) {
+		quoted = "E" + strings.ReplaceAll(quoted, `\`, `\\`)
+	}
+	return quoted
+}
+
+// scanPlaceholders returns sql with each $n parameter placeholder replaced
+// by replace(n). Placeholders inside string literals, quoted identifiers,
+// dollar-quoted strings and comments are left alone.
+func scanPlaceholders(sql string, replace func(n int) string) string {
+	var b strings.Builder
+	i := 0
+	// skipTo copies sql up to and including the end of the first match of
+	// end from position from, or to the end of sql.
+	skipTo := func(from int, end string) {
+		j := strings.Index(sql[from:], end)
+		if j < 0 {
+			i = len(sql)
+		} else {
+			i = from + j + len(end)
+		}
+	}
+	for i < len(sql) {
+		start := i
+		c := sql[i]
+		switch {
+		case c == '\'':
+			// E'...' strings escape quotes with backslashes too.
+			escapes := i > 0 && (sql[i-1] == 'E' || sql[i-1] == 'e') && (i == 1 || !identChar(sql[i-2]))
+			i++
+			for i < len(sql) {
+				if escapes && sql[i] == '\\' {
+					i += 2
+					continue
+				}
+				if sql[i] == '\'' {
+					if i+1 < len(sql) && sql[i+1] == '\'' {
+						i += 2
+						continue
+					}
+					break
+				}
+				i++
+			}
+			i = min(i+1, len(sql))
+		case c == '"':
+			skipTo(i+1, `"`)
+		case strings.HasPrefix(sql[i:], "--"):
+			skipTo(i, "\n")
+		case strings.HasPrefix(sql[i:], "/*"):
+			// Block comments nest.
+			depth := 0
+			for i < len(sql) {
+				if strings.HasPrefix(sql[i:], "/*") {
+					depth++
+					i += 2
+				} else if strings.HasPrefix(sql[i:], "*/") {
+					depth--
+					i += 2
+					if depth == 0 {
+						break
+					}
+				} else {
+					i++
+				}
+			}
+		case c == '$' && (i == 0 || !identChar(sql[i-1])):
+			j := i + 1
+			for j < len(sql) && sql[j] >= '0' && sql[j] <= '9' {
+				j++
+			}
+			if j > i+1 {
+				n, _ := strconv.Atoi(sql[i+1 : j])
+				b.WriteString(replace(n))
+				i = j
+				continue
+			}
+			// A dollar-quoted string: $tag$ ... $tag$.
+			for j < len(sql) && identChar(sql[j]) {
+				j++
+			}
+			if j < len(sql) && sql[j] == '$' {
+				skipTo(j+1, sql[i:j+1])
+			} else {
+				i++
+			}
+		default:
+			i++
+		}
+		b.WriteString(sql[start:i])
+	}
+	return b.String()
+}
+
+func identChar(c byte) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c >= 0x80
+}
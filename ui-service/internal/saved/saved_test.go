@@ -0,0 +1,99 @@
+package saved
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func ptr(s string) *string { return &s }
+
+func TestValidate(t *testing.T) {
+	valid := func() Query {
+		return Query{
+			Name:          "late orders",
+			Scope:         ScopePrivate,
+			Namespace:     "team-a",
+			ConnectionRef: "orders",
+			SQL:           "SELECT * FROM orders WHERE status = $1 AND placed < $2",
+			Parameters:    []Parameter{{Name: "status"}, {Name: "before", Type: "timestamp with time zone"}},
+		}
+	}
+	q := valid()
+	if err := q.Validate(); err != nil {
+		t.Fatalf("Validate() = %v", err)
+	}
+	for name, change := range map[string]func(q *Query){
+		"no name":            func(q *Query) { q.Name = " " },
+		"no connection":      func(q *Query) { q.ConnectionRef = "" },
+		"no sql":             func(q *Query) { q.SQL = "" },
+		"bad scope":          func(q *Query) { q.Scope = "team" },
+		"duplicate param":    func(q *Query) { q.Parameters[1].Name = "status" },
+		"bad type":           func(q *Query) { q.Parameters[1].Type = "int); DROP TABLE orders; --" },
+		"missing parameter":  func(q *Query) { q.SQL += " LIMIT $3" },
+		"placeholder $0":     func(q *Query) { q.SQL += " OR $0" },
+		"unnamed parameter":  func(q *Query) { q.Parameters[0].Name = "" },
+		"missing parameters": func(q *Query) { q.Parameters = nil },
+	} {
+		q := valid()
+		change(&q)
+		if err := q.Validate(); err == nil {
+			t.Errorf("%s: Validate() = nil, want an error", name)
+		}
+	}
+	q = valid()
+	q.SQL = `SELECT '$3', "$4", $$ $5 $$, $tag$ $6 $tag$ -- $7
+		/* $8 /* nested */ $9 */ FROM t WHERE a = $1 AND b = $2`
+	if err := q.Validate(); err != nil {
+		t.Errorf("Validate() with quoted placeholders = %v", err)
+	}
+}
+
+func TestArgs(t *testing.T) {
+	q := Query{Parameters: []Parameter{{Name: "status"}, {Name: "limit", Default: ptr("10")}}}
+	args, err := q.Args(map[string]string{"status": "open"})
+	if err != nil || strings.Join(args, ",") != "open,10" {
+		t.Errorf("Args() = %q, %v; want [open 10]", args, err)
+	}
+	args, err = q.Args(map[string]string{"status": "open", "limit": "5"})
+	if err != nil || strings.Join(args, ",") != "open,5" {
+		t.Errorf("Args() = %q, %v; want [open 5]", args, err)
+	}
+	if _, err := q.Args(map[string]string{"limit": "5"}); err == nil {
+		t.Error("Args() without a required value succeeded")
+	}
+	if _, err := q.Args(map[string]string{"status": "open", "owner": "me"}); err == nil {
+		t.Error("Args() with an unknown parameter succeeded")
+	}
+}
+
+func TestInline(t *testing.T) {
+	q := Query{
+		SQL:        `UPDATE orders SET note = $2 WHERE id = $1 AND note <> '$1' AND tag = E'\'$2'`,
+		Parameters: []Parameter{{Name: "id", Type: "int8"}, {Name: "note"}},
+	}
+	got := q.Inline([]string{"42", `it's a \path`})
+	want := `UPDATE orders SET note = E'it''s a \\path' WHERE id = '42'::int8 AND note <> '$1' AND tag = E'\'$2'`
+	if got != want {
+		t.Errorf("Inline() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestScanPlaceholders(t *testing.T) {
+	for sql, want := range map[string]string{
+		"SELECT $1, $12":                 "SELECT #1, #12",
+		"SELECT a$1 FROM t":              "SELECT a$1 FROM t",
+		"SELECT 'it''s $1', $2":          "SELECT 'it''s $1', #2",
+		`SELECT "col$1", $1`:             `SELECT "col$1", #1`,
+		"SELECT $q$ $1 $q$, $1":          "SELECT $q$ $1 $q$, #1",
+		"SELECT 1 -- $1\n, $2":           "SELECT 1 -- $1\n, #2",
+		"SELECT /* $1 /* $2 */ $3 */ $4": "SELECT /* $1 /* $2 */ $3 */ #4",
+		"SELECT 'unterminated $1":        "SELECT 'unterminated $1",
+		"SELECT $":                       "SELECT $",
+	} {
+		got := scanPlaceholders(sql, func(n int) string { return "#" + strconv.Itoa(n) })
+		if got != want {
+			t.Errorf("scanPlaceholders(%q) = %q, want %q", sql, got, want)
+		}
+	}
+}
@@ -0,0 +1,414 @@
+package saved
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	kubequeryv1beta1 "github.com/rsavage/KubeQuery/api/v1beta1"
+	"github.com/rsavage/KubeQuery/pkg/db"
+
+	"github.com/rsavage/KubeQuery/ui-service/internal/audit"
+	"github.com/rsavage/KubeQuery/ui-service/internal/connections"
+)
+
+// DefaultSchema is the schema the Store keeps its tables in when
+// Store.Schema is not set.
+const DefaultSchema = "kubequery_ui"
+
+var (
+	// ErrNotFound is returned for saved queries that do not exist or that
+	// the user may not change.
+	ErrNotFound = errors.New("saved query not found")
+	// ErrExists is returned when the owner already has a saved query of
+	// the same name.
+	ErrExists = errors.New("a saved query of that name already exists")
+)
+
+// uniqueViolation is the SQLSTATE of unique constraint violations.
+const uniqueViolation = "23505"
+
+// Store keeps saved queries and execution history in a schema of its own
+// in the database of a PostgresConnection. The schema and its tables are
+// created on first use.
+type Store struct {
+	// Client reads the PostgresConnection.
+	Client client.Reader
+	// Pools holds the pool of the connection.
+	Pools *connections.Pools
+	// Connection is the PostgresConnection of the database. Its user needs
+	// to be able to create the schema, or own it.
+	Connection types.NamespacedName
+	// Schema is the schema of the tables; DefaultSchema when empty.
+	Schema string
+	// HistoryRetention is how long executions are kept; forever when 0.
+	HistoryRetention time.Duration
+
+	mu       sync.Mutex
+	migrated bool
+	pruned   time.Time
+}
+
+// migrations create the Store's tables; {schema} is replaced by the quoted
+// schema name. Every statement is idempotent, so they run on every start.
+var migrations = []string{
+	`CREATE SCHEMA IF NOT EXISTS {schema}`,
+	`CREATE TABLE IF NOT EXISTS {schema}.saved_queries (
+		id bigserial PRIMARY KEY,
+		name text NOT NULL,
+		description text NOT NULL DEFAULT '',
+		owner text NOT NULL,
+		scope text NOT NULL CHECK (scope IN ('private', 'namespace', 'public')),
+		namespace text NOT NULL,
+		connection text NOT NULL,
+		sql text NOT NULL,
+		parameters jsonb NOT NULL DEFAULT '[]',
+		created_at timestamptz NOT NULL DEFAULT now(),
+		updated_at timestamptz NOT NULL DEFAULT now(),
+		UNIQUE (owner, name)
+	)`,
+	`CREATE TABLE IF NOT EXISTS {schema}.history (
+		id bigserial PRIMARY KEY,
+		username text NOT NULL,
+		executed_at timestamptz NOT NULL,
+		action text NOT NULL,
+		format text NOT NULL DEFAULT '',
+		namespace text NOT NULL,
+		connection text NOT NULL,
+		sql_hash text NOT NULL,
+		duration_ms bigint NOT NULL,
+		row_count bigint NOT NULL,
+		truncated boolean NOT NULL DEFAULT false,
+		error text NOT NULL DEFAULT ''
+	)`,
+	`CREATE INDEX IF NOT EXISTS history_username_executed_at ON {schema}.history (username, executed_at DESC)`,
+}
+
+// sql returns stmt with {schema} replaced by the quoted schema name.
+func (s *Store) sql(stmt string) string {
+	schema := s.Schema
+	if schema == "" {
+		schema = DefaultSchema
+	}
+	return strings.ReplaceAll(stmt, "{schema}", `"`+strings.ReplaceAll(schema, `"`, `""`)+`"`)
+}
+
+// conn acquires a session on the Store's database, creating the tables
+// first if this is the first use. The caller must release it.
+func (s *Store) conn(ctx context.Context) (db.Conn, error) {
+	var pc kubequeryv1beta1.PostgresConnection
+	if err := s.Client.Get(ctx, s.Connection, &pc); err != nil {
+		return nil, fmt.Errorf("failed to get the PostgresConnection of saved queries: %w", err)
+	}
+	pool, red, err := s.Pools.Get(ctx, &pc)
+	if err != nil {
+		return nil, errors.New(red.Error(err))
+	}
+	conn, err := pool.Acquire(ctx)
+	if err != nil {
+		return nil, errors.New(red.Error(err))
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.migrated {
+		for _, stmt := range migrations {
+			if _, err := conn.Exec(ctx, s.sql(stmt)); err != nil {
+				conn.Release()
+				return nil, fmt.Errorf("failed to create the tables of saved queries: %s", red.Error(err))
+			}
+		}
+		s.migrated = true
+	}
+	return conn, nil
+}
+
+// exec runs stmt with args and returns its command tag.
+func (s *Store) exec(ctx context.Context, stmt string, args ...any) (string, error) {
+	conn, err := s.conn(ctx)
+	if err != nil {
+		return "", err
+	}
+	defer conn.Release()
+	return conn.Exec(ctx, s.sql(stmt), args...)
+}
+
+// query runs stmt with args and calls scan with the values of each row.
+func (s *Store) query(ctx context.Context, stmt string, args []any, scan func(values []any) error) error {
+	conn, err := s.conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+	rows, err := conn.Query(ctx, s.sql(stmt), args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		values, err := rows.Values()
+		if err != nil {
+			return err
+		}
+		if err := scan(values); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// savedColumns are the columns read into a Query, in the order scanQuery
+// expects them.
+const savedColumns = `id, name, description, owner, scope, namespace, connection, sql, parameters::text, created_at, updated_at`
+
+func scanQuery(values []any) (Query, error) {
+	r := &row{values: values}
+	q := Query{
+		ID:            field[int64](r),
+		Name:          field[string](r),
+		Description:   field[string](r),
+		Owner:         field[string](r),
+		Scope:         Scope(field[string](r)),
+		Namespace:     field[string](r),
+		ConnectionRef: field[string](r),
+		SQL:           field[string](r),
+	}
+	params := field[string](r)
+	q.CreatedAt = field[time.Time](r)
+	q.UpdatedAt = field[time.Time](r)
+	if err := r.done(); err != nil {
+		return q, err
+	}
+	if err := json.Unmarshal([]byte(params), &q.Parameters); err != nil {
+		return q, fmt.Errorf("invalid parameters of saved query %d: %w", q.ID, err)
+	}
+	return q, nil
+}
+
+// row reads the values of a row in order, as returned by db.Rows.Values.
+type row struct {
+	values []any
+	next   int
+	err    error
+}
+
+// field returns the next value of r, which must be a T.
+func field[T any](r *row) T {
+	var v T
+	if r.err != nil {
+		return v
+	}
+	if r.next >= len(r.values) {
+		r.err = fmt.Errorf("row has %d columns, want more", len(r.values))
+		return v
+	}
+	v, ok := r.values[r.next].(T)
+	if !ok {
+		r.err = fmt.Errorf("column %d is a %T, want a %T", r.next, r.values[r.next], v)
+	}
+	r.next++
+	return v
+}
+
+// done returns the first error reading r, or an error if values were left.
+func (r *row) done() error {
+	if r.err == nil && r.next != len(r.values) {
+		return fmt.Errorf("row has %d columns, want %d", len(r.values), r.next)
+	}
+	return r.err
+}
+
+// Create saves q, setting its ID and times.
+func (s *Store) Create(ctx context.Context, q *Query) error {
+	params, err := json.Marshal(q.Parameters)
+	if err != nil {
+		return err
+	}
+	err = s.query(ctx, `INSERT INTO {schema}.saved_queries (name, description, owner, scope, namespace, connection, sql, parameters)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8::text::jsonb) RETURNING `+savedColumns,
+		[]any{q.Name, q.Description, q.Owner, string(q.Scope), q.Namespace, q.ConnectionRef, q.SQL, string(params)},
+		func(values []any) error {
+			created, err := scanQuery(values)
+			*q = created
+			return err
+		})
+	return conflict(err)
+}
+
+// Update replaces the saved query q.ID of q.Owner with q.
+func (s *Store) Update(ctx context.Context, q *Query) error {
+	params, err := json.Marshal(q.Parameters)
+	if err != nil {
+		return err
+	}
+	found := false
+	err = s.query(ctx, `UPDATE {schema}.saved_queries
+		SET name = $3, description = $4, scope = $5, namespace = $6, connection = $7, sql = $8, parameters = $9::text::jsonb, updated_at = now()
+		WHERE id = $1 AND owner = $2 RETURNING `+savedColumns,
+		[]any{q.ID, q.Owner, q.Name, q.Description, string(q.Scope), q.Namespace, q.ConnectionRef, q.SQL, string(params)},
+		func(values []any) error {
+			found = true
+			updated, err := scanQuery(values)
+			*q = updated
+			return err
+		})
+	if err := conflict(err); err != nil {
+		return err
+	}
+	if !found {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// conflict returns ErrExists for unique violations, and err otherwise.
+func conflict(err error) error {
+	var state interface{ SQLState() string }
+	if errors.As(err, &state) && state.SQLState() == uniqueViolation {
+		return ErrExists
+	}
+	return err
+}
+
+// Delete deletes the saved query id of owner.
+func (s *Store) Delete(ctx context.Context, id int64, owner string) error {
+	tag, err := s.exec(ctx, `DELETE FROM {schema}.saved_queries WHERE id = $1 AND owner = $2`, id, owner)
+	if err != nil {
+		return err
+	}
+	if tag == "DELETE 0" {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// Get returns the saved query id.
+func (s *Store) Get(ctx context.Context, id int64) (*Query, error) {
+	var found *Query
+	err := s.query(ctx, `SELECT `+savedColumns+` FROM {schema}.saved_queries WHERE id = $1`, []any{id}, func(values []any) error {
+		q, err := scanQuery(values)
+		found = &q
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if found == nil {
+		return nil, ErrNotFound
+	}
+	return found, nil
+}
+
+// SharedNamespaces returns the namespaces that other users than user share
+// saved queries with, for the caller to check which of them user may see.
+func (s *Store) SharedNamespaces(ctx context.Context, user string) ([]string, error) {
+	namespaces := []string{}
+	err := s.query(ctx, `SELECT DISTINCT namespace FROM {schema}.saved_queries
+		WHERE scope = 'namespace' AND owner <> $1 ORDER BY namespace`,
+		[]any{user},
+		func(values []any) error {
+			ns, _ := values[0].(string)
+			namespaces = append(namespaces, ns)
+			return nil
+		})
+	return namespaces, err
+}
+
+// List returns, by name, at most limit saved queries that user owns, that
+// are public or that are shared with one of namespaces, whose name,
+// description or SQL contain search, if set.
+func (s *Store) List(ctx context.Context, user, search string, namespaces []string, limit int) ([]Query, error) {
+	pattern := "%" + strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(search) + "%"
+	items := []Query{}
+	err := s.query(ctx, `SELECT `+savedColumns+` FROM {schema}.saved_queries
+		WHERE (owner = $1 OR scope = 'public' OR (scope = 'namespace' AND namespace = ANY($4)))
+		AND (name ILIKE $2 OR description ILIKE $2 OR sql ILIKE $2)
+		ORDER BY name, id LIMIT $3`,
+		[]any{user, pattern, limit, namespaces},
+		func(values []any) error {
+			q, err := scanQuery(values)
+			items = append(items, q)
+			return err
+		})
+	return items, err
+}
+
+// Add records the execution of a statement in the history of its user. It
+// implements console.History.
+func (s *Store) Add(ctx context.Context, r audit.Record) error {
+	_, err := s.exec(ctx, `INSERT INTO {schema}.history
+		(username, executed_at, action, format, namespace, connection, sql_hash, duration_ms, row_count, truncated, error)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)`,
+		r.User, r.Time, r.Action, r.Format, r.Namespace, r.Connection, r.SQLHash, r.DurationMillis, int64(r.RowCount), r.Truncated, r.Error)
+	if err != nil {
+		return err
+	}
+	return s.prune(ctx)
+}
+
+// pruneInterval is how often executions older than the retention are
+// deleted.
+const pruneInterval = time.Hour
+
+// prune deletes executions older than HistoryRetention, at most once per
+// pruneInterval.
+func (s *Store) prune(ctx context.Context) error {
+	if s.HistoryRetention <= 0 {
+		return nil
+	}
+	s.mu.Lock()
+	due := time.Since(s.pruned) >= pruneInterval
+	if due {
+		s.pruned = time.Now()
+	}
+	s.mu.Unlock()
+	if !due {
+		return nil
+	}
+	_, err := s.exec(ctx, `DELETE FROM {schema}.history WHERE executed_at < $1`, time.Now().Add(-s.HistoryRetention))
+	return err
+}
+
+// HistoryFilter selects executions; empty fields match all.
+type HistoryFilter struct {
+	Namespace     string
+	ConnectionRef string
+	SQLHash       string
+}
+
+// History returns the latest executions of user, newest first, at most
+// limit.
+func (s *Store) History(ctx context.Context, user string, filter HistoryFilter, limit int) ([]Execution, error) {
+	items := []Execution{}
+	err := s.query(ctx, `SELECT id, executed_at, action, format, namespace, connection, sql_hash, duration_ms, row_count, truncated, error
+		FROM {schema}.history
+		WHERE username = $1 AND ($2 = '' OR namespace = $2) AND ($3 = '' OR connection = $3) AND ($4 = '' OR sql_hash = $4)
+		ORDER BY executed_at DESC, id DESC LIMIT $5`,
+		[]any{user, filter.Namespace, filter.ConnectionRef, filter.SQLHash, limit},
+		func(values []any) error {
+			r := &row{values: values}
+			items = append(items, Execution{
+				ID:             field[int64](r),
+				User:           user,
+				Time:           field[time.Time](r),
+				Action:         field[string](r),
+				Format:         field[string](r),
+				Namespace:      field[string](r),
+				ConnectionRef:  field[string](r),
+				SQLHash:        field[string](r),
+				DurationMillis: field[int64](r),
+				RowCount:       field[int64](r),
+				Truncated:      field[bool](r),
+				Error:          field[string](r),
+			})
+			return r.done()
+		})
+	return items, err
+}